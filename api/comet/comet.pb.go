@@ -112,12 +112,21 @@ func (m *PushMsgReply) XXX_DiscardUnknown() {
 var xxx_messageInfo_PushMsgReply proto.InternalMessageInfo
 
 type BroadcastReq struct {
-	ProtoOp              int32           `protobuf:"varint,1,opt,name=protoOp,proto3" json:"protoOp,omitempty"`
-	Proto                *protocol.Proto `protobuf:"bytes,2,opt,name=proto,proto3" json:"proto,omitempty"`
-	Speed                int32           `protobuf:"varint,3,opt,name=speed,proto3" json:"speed,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
-	XXX_unrecognized     []byte          `json:"-"`
-	XXX_sizecache        int32           `json:"-"`
+	ProtoOp int32           `protobuf:"varint,1,opt,name=protoOp,proto3" json:"protoOp,omitempty"`
+	Proto   *protocol.Proto `protobuf:"bytes,2,opt,name=proto,proto3" json:"proto,omitempty"`
+	// speed messages per second across buckets, 0 uses the server default
+	Speed int32 `protobuf:"varint,3,opt,name=speed,proto3" json:"speed,omitempty"`
+	// id cancels or queries the broadcast, generated if empty
+	Id string `protobuf:"bytes,4,opt,name=id,proto3" json:"id,omitempty"`
+	// urgent broadcasts are sent before the others
+	Urgent bool `protobuf:"varint,5,opt,name=urgent,proto3" json:"urgent,omitempty"`
+	// broadcast to the platform only, empty for all
+	Platform string `protobuf:"bytes,6,opt,name=platform,proto3" json:"platform,omitempty"`
+	// broadcast to the app namespace, empty for the default
+	App                  string   `protobuf:"bytes,7,opt,name=app,proto3" json:"app,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *BroadcastReq) Reset()         { *m = BroadcastReq{} }
@@ -166,7 +175,36 @@ func (m *BroadcastReq) GetSpeed() int32 {
 	return 0
 }
 
+func (m *BroadcastReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *BroadcastReq) GetUrgent() bool {
+	if m != nil {
+		return m.Urgent
+	}
+	return false
+}
+
+func (m *BroadcastReq) GetPlatform() string {
+	if m != nil {
+		return m.Platform
+	}
+	return ""
+}
+
+func (m *BroadcastReq) GetApp() string {
+	if m != nil {
+		return m.App
+	}
+	return ""
+}
+
 type BroadcastReply struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -197,6 +235,275 @@ func (m *BroadcastReply) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_BroadcastReply proto.InternalMessageInfo
 
+func (m *BroadcastReply) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type CancelBroadcastReq struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CancelBroadcastReq) Reset()         { *m = CancelBroadcastReq{} }
+func (m *CancelBroadcastReq) String() string { return proto.CompactTextString(m) }
+func (*CancelBroadcastReq) ProtoMessage()    {}
+func (*CancelBroadcastReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_327b4a7d084564be, []int{4}
+}
+
+func (m *CancelBroadcastReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CancelBroadcastReq.Unmarshal(m, b)
+}
+func (m *CancelBroadcastReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CancelBroadcastReq.Marshal(b, m, deterministic)
+}
+func (m *CancelBroadcastReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CancelBroadcastReq.Merge(m, src)
+}
+func (m *CancelBroadcastReq) XXX_Size() int {
+	return xxx_messageInfo_CancelBroadcastReq.Size(m)
+}
+func (m *CancelBroadcastReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_CancelBroadcastReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CancelBroadcastReq proto.InternalMessageInfo
+
+func (m *CancelBroadcastReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type CancelBroadcastReply struct {
+	Canceled             bool     `protobuf:"varint,1,opt,name=canceled,proto3" json:"canceled,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CancelBroadcastReply) Reset()         { *m = CancelBroadcastReply{} }
+func (m *CancelBroadcastReply) String() string { return proto.CompactTextString(m) }
+func (*CancelBroadcastReply) ProtoMessage()    {}
+func (*CancelBroadcastReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_327b4a7d084564be, []int{5}
+}
+
+func (m *CancelBroadcastReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CancelBroadcastReply.Unmarshal(m, b)
+}
+func (m *CancelBroadcastReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CancelBroadcastReply.Marshal(b, m, deterministic)
+}
+func (m *CancelBroadcastReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CancelBroadcastReply.Merge(m, src)
+}
+func (m *CancelBroadcastReply) XXX_Size() int {
+	return xxx_messageInfo_CancelBroadcastReply.Size(m)
+}
+func (m *CancelBroadcastReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_CancelBroadcastReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CancelBroadcastReply proto.InternalMessageInfo
+
+func (m *CancelBroadcastReply) GetCanceled() bool {
+	if m != nil {
+		return m.Canceled
+	}
+	return false
+}
+
+type BroadcastProgressReq struct {
+	// id of the broadcast, all the known broadcasts if empty
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BroadcastProgressReq) Reset()         { *m = BroadcastProgressReq{} }
+func (m *BroadcastProgressReq) String() string { return proto.CompactTextString(m) }
+func (*BroadcastProgressReq) ProtoMessage()    {}
+func (*BroadcastProgressReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_327b4a7d084564be, []int{6}
+}
+
+func (m *BroadcastProgressReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BroadcastProgressReq.Unmarshal(m, b)
+}
+func (m *BroadcastProgressReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BroadcastProgressReq.Marshal(b, m, deterministic)
+}
+func (m *BroadcastProgressReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BroadcastProgressReq.Merge(m, src)
+}
+func (m *BroadcastProgressReq) XXX_Size() int {
+	return xxx_messageInfo_BroadcastProgressReq.Size(m)
+}
+func (m *BroadcastProgressReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_BroadcastProgressReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BroadcastProgressReq proto.InternalMessageInfo
+
+func (m *BroadcastProgressReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type BroadcastTask struct {
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// state queued, running, done or canceled
+	State  string `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	Urgent bool   `protobuf:"varint,3,opt,name=urgent,proto3" json:"urgent,omitempty"`
+	Speed  int32  `protobuf:"varint,4,opt,name=speed,proto3" json:"speed,omitempty"`
+	// total channels when the broadcast starts
+	Total                int64    `protobuf:"varint,5,opt,name=total,proto3" json:"total,omitempty"`
+	Sent                 int64    `protobuf:"varint,6,opt,name=sent,proto3" json:"sent,omitempty"`
+	Dropped              int64    `protobuf:"varint,7,opt,name=dropped,proto3" json:"dropped,omitempty"`
+	Ctime                int64    `protobuf:"varint,8,opt,name=ctime,proto3" json:"ctime,omitempty"`
+	Mtime                int64    `protobuf:"varint,9,opt,name=mtime,proto3" json:"mtime,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BroadcastTask) Reset()         { *m = BroadcastTask{} }
+func (m *BroadcastTask) String() string { return proto.CompactTextString(m) }
+func (*BroadcastTask) ProtoMessage()    {}
+func (*BroadcastTask) Descriptor() ([]byte, []int) {
+	return fileDescriptor_327b4a7d084564be, []int{7}
+}
+
+func (m *BroadcastTask) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BroadcastTask.Unmarshal(m, b)
+}
+func (m *BroadcastTask) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BroadcastTask.Marshal(b, m, deterministic)
+}
+func (m *BroadcastTask) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BroadcastTask.Merge(m, src)
+}
+func (m *BroadcastTask) XXX_Size() int {
+	return xxx_messageInfo_BroadcastTask.Size(m)
+}
+func (m *BroadcastTask) XXX_DiscardUnknown() {
+	xxx_messageInfo_BroadcastTask.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BroadcastTask proto.InternalMessageInfo
+
+func (m *BroadcastTask) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *BroadcastTask) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *BroadcastTask) GetUrgent() bool {
+	if m != nil {
+		return m.Urgent
+	}
+	return false
+}
+
+func (m *BroadcastTask) GetSpeed() int32 {
+	if m != nil {
+		return m.Speed
+	}
+	return 0
+}
+
+func (m *BroadcastTask) GetTotal() int64 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+func (m *BroadcastTask) GetSent() int64 {
+	if m != nil {
+		return m.Sent
+	}
+	return 0
+}
+
+func (m *BroadcastTask) GetDropped() int64 {
+	if m != nil {
+		return m.Dropped
+	}
+	return 0
+}
+
+func (m *BroadcastTask) GetCtime() int64 {
+	if m != nil {
+		return m.Ctime
+	}
+	return 0
+}
+
+func (m *BroadcastTask) GetMtime() int64 {
+	if m != nil {
+		return m.Mtime
+	}
+	return 0
+}
+
+type BroadcastProgressReply struct {
+	Tasks                []*BroadcastTask `protobuf:"bytes,1,rep,name=tasks,proto3" json:"tasks,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *BroadcastProgressReply) Reset()         { *m = BroadcastProgressReply{} }
+func (m *BroadcastProgressReply) String() string { return proto.CompactTextString(m) }
+func (*BroadcastProgressReply) ProtoMessage()    {}
+func (*BroadcastProgressReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_327b4a7d084564be, []int{8}
+}
+
+func (m *BroadcastProgressReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BroadcastProgressReply.Unmarshal(m, b)
+}
+func (m *BroadcastProgressReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BroadcastProgressReply.Marshal(b, m, deterministic)
+}
+func (m *BroadcastProgressReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BroadcastProgressReply.Merge(m, src)
+}
+func (m *BroadcastProgressReply) XXX_Size() int {
+	return xxx_messageInfo_BroadcastProgressReply.Size(m)
+}
+func (m *BroadcastProgressReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_BroadcastProgressReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BroadcastProgressReply proto.InternalMessageInfo
+
+func (m *BroadcastProgressReply) GetTasks() []*BroadcastTask {
+	if m != nil {
+		return m.Tasks
+	}
+	return nil
+}
+
 type BroadcastRoomReq struct {
 	RoomID               string          `protobuf:"bytes,1,opt,name=roomID,proto3" json:"roomID,omitempty"`
 	Proto                *protocol.Proto `protobuf:"bytes,2,opt,name=proto,proto3" json:"proto,omitempty"`
@@ -209,7 +516,7 @@ func (m *BroadcastRoomReq) Reset()         { *m = BroadcastRoomReq{} }
 func (m *BroadcastRoomReq) String() string { return proto.CompactTextString(m) }
 func (*BroadcastRoomReq) ProtoMessage()    {}
 func (*BroadcastRoomReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_327b4a7d084564be, []int{4}
+	return fileDescriptor_327b4a7d084564be, []int{9}
 }
 
 func (m *BroadcastRoomReq) XXX_Unmarshal(b []byte) error {
@@ -254,7 +561,7 @@ func (m *BroadcastRoomReply) Reset()         { *m = BroadcastRoomReply{} }
 func (m *BroadcastRoomReply) String() string { return proto.CompactTextString(m) }
 func (*BroadcastRoomReply) ProtoMessage()    {}
 func (*BroadcastRoomReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_327b4a7d084564be, []int{5}
+	return fileDescriptor_327b4a7d084564be, []int{10}
 }
 
 func (m *BroadcastRoomReply) XXX_Unmarshal(b []byte) error {
@@ -285,7 +592,7 @@ func (m *RoomsReq) Reset()         { *m = RoomsReq{} }
 func (m *RoomsReq) String() string { return proto.CompactTextString(m) }
 func (*RoomsReq) ProtoMessage()    {}
 func (*RoomsReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_327b4a7d084564be, []int{6}
+	return fileDescriptor_327b4a7d084564be, []int{11}
 }
 
 func (m *RoomsReq) XXX_Unmarshal(b []byte) error {
@@ -317,7 +624,7 @@ func (m *RoomsReply) Reset()         { *m = RoomsReply{} }
 func (m *RoomsReply) String() string { return proto.CompactTextString(m) }
 func (*RoomsReply) ProtoMessage()    {}
 func (*RoomsReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_327b4a7d084564be, []int{7}
+	return fileDescriptor_327b4a7d084564be, []int{12}
 }
 
 func (m *RoomsReply) XXX_Unmarshal(b []byte) error {
@@ -345,48 +652,169 @@ func (m *RoomsReply) GetRooms() map[string]bool {
 	return nil
 }
 
+type RoomMembersReq struct {
+	RoomID string `protobuf:"bytes,1,opt,name=roomID,proto3" json:"roomID,omitempty"`
+	// the mids greater than the cursor
+	Cursor               int64    `protobuf:"varint,2,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	Size                 int32    `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RoomMembersReq) Reset()         { *m = RoomMembersReq{} }
+func (m *RoomMembersReq) String() string { return proto.CompactTextString(m) }
+func (*RoomMembersReq) ProtoMessage()    {}
+func (*RoomMembersReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_327b4a7d084564be, []int{13}
+}
+
+func (m *RoomMembersReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RoomMembersReq.Unmarshal(m, b)
+}
+func (m *RoomMembersReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RoomMembersReq.Marshal(b, m, deterministic)
+}
+func (m *RoomMembersReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RoomMembersReq.Merge(m, src)
+}
+func (m *RoomMembersReq) XXX_Size() int {
+	return xxx_messageInfo_RoomMembersReq.Size(m)
+}
+func (m *RoomMembersReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_RoomMembersReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RoomMembersReq proto.InternalMessageInfo
+
+func (m *RoomMembersReq) GetRoomID() string {
+	if m != nil {
+		return m.RoomID
+	}
+	return ""
+}
+
+func (m *RoomMembersReq) GetCursor() int64 {
+	if m != nil {
+		return m.Cursor
+	}
+	return 0
+}
+
+func (m *RoomMembersReq) GetSize() int32 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+type RoomMembersReply struct {
+	// the mids in ascending order
+	Mids                 []int64  `protobuf:"varint,1,rep,packed,name=mids,proto3" json:"mids,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RoomMembersReply) Reset()         { *m = RoomMembersReply{} }
+func (m *RoomMembersReply) String() string { return proto.CompactTextString(m) }
+func (*RoomMembersReply) ProtoMessage()    {}
+func (*RoomMembersReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_327b4a7d084564be, []int{14}
+}
+
+func (m *RoomMembersReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RoomMembersReply.Unmarshal(m, b)
+}
+func (m *RoomMembersReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RoomMembersReply.Marshal(b, m, deterministic)
+}
+func (m *RoomMembersReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RoomMembersReply.Merge(m, src)
+}
+func (m *RoomMembersReply) XXX_Size() int {
+	return xxx_messageInfo_RoomMembersReply.Size(m)
+}
+func (m *RoomMembersReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_RoomMembersReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RoomMembersReply proto.InternalMessageInfo
+
+func (m *RoomMembersReply) GetMids() []int64 {
+	if m != nil {
+		return m.Mids
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*PushMsgReq)(nil), "goim.comet.PushMsgReq")
 	proto.RegisterType((*PushMsgReply)(nil), "goim.comet.PushMsgReply")
 	proto.RegisterType((*BroadcastReq)(nil), "goim.comet.BroadcastReq")
 	proto.RegisterType((*BroadcastReply)(nil), "goim.comet.BroadcastReply")
+	proto.RegisterType((*CancelBroadcastReq)(nil), "goim.comet.CancelBroadcastReq")
+	proto.RegisterType((*CancelBroadcastReply)(nil), "goim.comet.CancelBroadcastReply")
+	proto.RegisterType((*BroadcastProgressReq)(nil), "goim.comet.BroadcastProgressReq")
+	proto.RegisterType((*BroadcastTask)(nil), "goim.comet.BroadcastTask")
+	proto.RegisterType((*BroadcastProgressReply)(nil), "goim.comet.BroadcastProgressReply")
 	proto.RegisterType((*BroadcastRoomReq)(nil), "goim.comet.BroadcastRoomReq")
 	proto.RegisterType((*BroadcastRoomReply)(nil), "goim.comet.BroadcastRoomReply")
 	proto.RegisterType((*RoomsReq)(nil), "goim.comet.RoomsReq")
 	proto.RegisterType((*RoomsReply)(nil), "goim.comet.RoomsReply")
 	proto.RegisterMapType((map[string]bool)(nil), "goim.comet.RoomsReply.RoomsEntry")
+	proto.RegisterType((*RoomMembersReq)(nil), "goim.comet.RoomMembersReq")
+	proto.RegisterType((*RoomMembersReply)(nil), "goim.comet.RoomMembersReply")
 }
 
 func init() { proto.RegisterFile("comet/comet.proto", fileDescriptor_327b4a7d084564be) }
 
 var fileDescriptor_327b4a7d084564be = []byte{
-	// 404 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x53, 0xcf, 0x4f, 0xe2, 0x40,
-	0x14, 0x4e, 0x61, 0xcb, 0x8f, 0x07, 0x4b, 0xd8, 0x49, 0x43, 0x9a, 0x66, 0xb3, 0x61, 0x7b, 0x62,
-	0xd7, 0xd8, 0x26, 0x18, 0x22, 0x91, 0x93, 0xa8, 0x07, 0x0f, 0x44, 0x32, 0x31, 0x1e, 0xbc, 0x15,
-	0x18, 0x01, 0x69, 0x9d, 0xd2, 0x16, 0x93, 0x9e, 0xfc, 0x0b, 0xfc, 0x9f, 0xcd, 0x9b, 0xa9, 0x6d,
-	0x31, 0x95, 0x44, 0x2f, 0xcd, 0xf7, 0xde, 0x7c, 0xf3, 0xbe, 0x6f, 0xbe, 0x07, 0xf0, 0x6b, 0xce,
-	0x3d, 0x16, 0xd9, 0xe2, 0x6b, 0xf9, 0x01, 0x8f, 0x38, 0x81, 0x25, 0x5f, 0x7b, 0x96, 0xe8, 0x18,
-	0x83, 0xe5, 0x3a, 0x5a, 0xed, 0x66, 0x58, 0xd9, 0xb7, 0x2c, 0x08, 0xe2, 0xe3, 0x89, 0xc3, 0x6d,
-	0x24, 0xd8, 0x8e, 0xbf, 0xb6, 0xc5, 0x85, 0x39, 0x77, 0x53, 0x20, 0x47, 0x98, 0x0f, 0x00, 0xd3,
-	0x5d, 0xb8, 0x9a, 0x84, 0x4b, 0xca, 0xb6, 0x84, 0xc0, 0x8f, 0x0d, 0x8b, 0x43, 0x5d, 0xe9, 0x96,
-	0x7b, 0x75, 0x2a, 0x30, 0xd1, 0xa1, 0x2a, 0xa8, 0x37, 0xbe, 0x5e, 0xee, 0x2a, 0x3d, 0x95, 0xbe,
-	0x97, 0xe4, 0x3f, 0xa8, 0x02, 0xea, 0xa5, 0xae, 0xd2, 0x6b, 0xf4, 0x35, 0x4b, 0xd8, 0x49, 0x05,
-	0xa6, 0x08, 0xa8, 0xa4, 0x98, 0x2d, 0x68, 0xa6, 0x3a, 0xbe, 0x1b, 0x9b, 0x8f, 0xd0, 0x1c, 0x07,
-	0xdc, 0x59, 0xcc, 0x9d, 0x30, 0x42, 0xe5, 0x9c, 0x8a, 0xf2, 0x6d, 0x15, 0xa2, 0x81, 0x1a, 0xfa,
-	0x8c, 0x2d, 0x12, 0xa7, 0xb2, 0x30, 0xdb, 0xd0, 0xca, 0x69, 0xa1, 0xfa, 0x1d, 0xb4, 0xb3, 0x0e,
-	0xe7, 0x1e, 0x3a, 0xe8, 0x40, 0x25, 0xe0, 0xdc, 0xbb, 0xbe, 0x14, 0x06, 0xea, 0x34, 0xa9, 0xbe,
-	0xf4, 0x4a, 0x0d, 0xc8, 0x87, 0xb9, 0xa8, 0x06, 0x50, 0xc3, 0x22, 0xa4, 0x6c, 0x6b, 0xbe, 0x00,
-	0x24, 0xd8, 0x77, 0x63, 0x72, 0x0a, 0x2a, 0xaa, 0xc8, 0xc0, 0x1b, 0xfd, 0xbf, 0x56, 0xb6, 0x50,
-	0x2b, 0xa3, 0x49, 0x78, 0xf5, 0x14, 0x05, 0x31, 0x95, 0x7c, 0x63, 0x98, 0x8c, 0x11, 0x4d, 0xd2,
-	0x86, 0xf2, 0x86, 0xc5, 0x89, 0x6f, 0x84, 0x18, 0xc4, 0xb3, 0xe3, 0xee, 0x98, 0x30, 0x5d, 0xa3,
-	0xb2, 0x38, 0x2b, 0x0d, 0x95, 0xfe, 0x6b, 0x09, 0xd4, 0x0b, 0x14, 0x20, 0x23, 0xa8, 0x26, 0x2b,
-	0x21, 0x9d, 0xbc, 0x70, 0xf6, 0x7b, 0x30, 0xf4, 0xc2, 0x3e, 0x3a, 0x3f, 0x87, 0x7a, 0xfa, 0x52,
-	0xb2, 0x47, 0xcb, 0xaf, 0xd5, 0x30, 0x3e, 0x39, 0xc1, 0x11, 0x13, 0xf8, 0xb9, 0x17, 0x16, 0xf9,
-	0x5d, 0x4c, 0x96, 0xfb, 0x31, 0xfe, 0x1c, 0x38, 0xc5, 0x71, 0x03, 0x50, 0x45, 0x24, 0x44, 0x2b,
-	0x48, 0x71, 0x6b, 0x74, 0x8a, 0xb3, 0x1d, 0x1f, 0xdd, 0xff, 0x3b, 0xfc, 0xcf, 0x11, 0x37, 0x46,
-	0xe2, 0x3b, 0xab, 0x88, 0x35, 0x9f, 0xbc, 0x05, 0x00, 0x00, 0xff, 0xff, 0xb3, 0xcb, 0xf3, 0x2a,
-	0x8c, 0x03, 0x00, 0x00,
+	// 704 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x55, 0xc1, 0x6e, 0xd3, 0x40,
+	0x10, 0x95, 0xe3, 0x38, 0x75, 0xa6, 0x25, 0xb4, 0x2b, 0x2b, 0x32, 0x56, 0x85, 0x8c, 0x85, 0xaa,
+	0x00, 0x22, 0x91, 0x82, 0x2a, 0x2a, 0x7a, 0xa2, 0x05, 0xa1, 0x1e, 0x22, 0xaa, 0xa5, 0x42, 0x82,
+	0x9b, 0x6b, 0x6f, 0x53, 0x2b, 0x76, 0xd7, 0x5d, 0x3b, 0x48, 0xe6, 0xc2, 0x8d, 0xef, 0xe2, 0x1f,
+	0xf8, 0x21, 0x34, 0xbb, 0x8e, 0xe3, 0xa4, 0x6e, 0x10, 0x5c, 0xa2, 0x79, 0xb3, 0xcf, 0x6f, 0x76,
+	0xde, 0xec, 0x6e, 0x60, 0x2f, 0xe0, 0x09, 0xcb, 0x47, 0xf2, 0x77, 0x98, 0x0a, 0x9e, 0x73, 0x02,
+	0x53, 0x1e, 0x25, 0x43, 0x99, 0x71, 0x0e, 0xa7, 0x51, 0x7e, 0x3d, 0xbf, 0x44, 0x34, 0xba, 0x60,
+	0x42, 0x14, 0x2f, 0x27, 0x3e, 0x1f, 0x21, 0x61, 0xe4, 0xa7, 0xd1, 0x48, 0x7e, 0x10, 0xf0, 0xb8,
+	0x0a, 0x94, 0x84, 0x77, 0x05, 0x70, 0x3e, 0xcf, 0xae, 0x27, 0xd9, 0x94, 0xb2, 0x5b, 0x42, 0xa0,
+	0x3d, 0x63, 0x45, 0x66, 0x6b, 0xae, 0x3e, 0xe8, 0x52, 0x19, 0x13, 0x1b, 0xb6, 0x24, 0xf5, 0x63,
+	0x6a, 0xeb, 0xae, 0x36, 0x30, 0xe8, 0x02, 0x92, 0xe7, 0x60, 0xc8, 0xd0, 0x6e, 0xb9, 0xda, 0x60,
+	0x7b, 0x6c, 0x0d, 0xe5, 0x76, 0xaa, 0x02, 0xe7, 0x18, 0x50, 0x45, 0xf1, 0x7a, 0xb0, 0x53, 0xd5,
+	0x49, 0xe3, 0xc2, 0xfb, 0xa5, 0xc1, 0xce, 0x89, 0xe0, 0x7e, 0x18, 0xf8, 0x59, 0x8e, 0xa5, 0x6b,
+	0x65, 0xb4, 0xff, 0x2e, 0x43, 0x2c, 0x30, 0xb2, 0x94, 0xb1, 0xb0, 0xdc, 0xaa, 0x02, 0xa4, 0x07,
+	0xad, 0x28, 0xb4, 0xdb, 0xae, 0x36, 0xe8, 0xd2, 0x56, 0x14, 0x92, 0x3e, 0x74, 0xe6, 0x62, 0xca,
+	0x6e, 0x72, 0xdb, 0x70, 0xb5, 0x81, 0x49, 0x4b, 0x44, 0x1c, 0x30, 0xd3, 0xd8, 0xcf, 0xaf, 0xb8,
+	0x48, 0xec, 0x8e, 0x64, 0x57, 0x98, 0xec, 0x82, 0xee, 0xa7, 0xa9, 0xbd, 0x25, 0xd3, 0x18, 0x7a,
+	0x2e, 0xf4, 0x6a, 0x1d, 0xa4, 0x71, 0x51, 0xd6, 0xd1, 0x16, 0x75, 0xbc, 0xa7, 0x40, 0x4e, 0xfd,
+	0x9b, 0x80, 0xc5, 0x2b, 0x9d, 0xae, 0xb3, 0xc6, 0x60, 0xdd, 0x61, 0xa1, 0x9a, 0x03, 0x66, 0x20,
+	0xf3, 0x4c, 0xb1, 0x4d, 0x5a, 0x61, 0xef, 0x00, 0xac, 0x8a, 0x7d, 0x2e, 0xf8, 0x54, 0xb0, 0x2c,
+	0x6b, 0xd2, 0xfe, 0xad, 0xc1, 0x83, 0x8a, 0x78, 0xe1, 0x67, 0xb3, 0x75, 0x86, 0x74, 0x2c, 0xf7,
+	0x73, 0x26, 0xdd, 0xed, 0x52, 0x05, 0x6a, 0x0e, 0xe9, 0x2b, 0x0e, 0x55, 0xfe, 0xb6, 0xeb, 0xfe,
+	0x5a, 0x60, 0xe4, 0x3c, 0xf7, 0x63, 0x69, 0xa7, 0x4e, 0x15, 0xc0, 0xc3, 0x94, 0xa1, 0x42, 0x47,
+	0x26, 0x65, 0x8c, 0x53, 0x0e, 0x05, 0x4f, 0x53, 0x16, 0x4a, 0x27, 0x75, 0xba, 0x80, 0xa8, 0x11,
+	0xe4, 0x51, 0xc2, 0x6c, 0x53, 0x69, 0x48, 0x80, 0xd9, 0x44, 0x66, 0xbb, 0x2a, 0x2b, 0x81, 0x77,
+	0x06, 0xfd, 0x86, 0xee, 0xd1, 0xb3, 0x11, 0x18, 0xb9, 0x9f, 0xcd, 0xd4, 0x09, 0xde, 0x1e, 0x3f,
+	0x1a, 0x2e, 0x6f, 0xc8, 0x70, 0xc5, 0x07, 0xaa, 0x78, 0xde, 0x67, 0xd8, 0x5d, 0xda, 0xce, 0x79,
+	0x82, 0x26, 0xf6, 0xa1, 0x23, 0x38, 0x4f, 0xce, 0xde, 0x95, 0x36, 0x95, 0xe8, 0x9f, 0xce, 0xbb,
+	0x05, 0x64, 0x4d, 0x17, 0x4f, 0x3d, 0x80, 0x89, 0x00, 0x47, 0xe5, 0xfd, 0x00, 0x28, 0x63, 0xdc,
+	0xf8, 0x6b, 0x30, 0xb0, 0xca, 0x62, 0xe3, 0x4f, 0xea, 0x1b, 0x5f, 0xd2, 0x54, 0xf8, 0xfe, 0x26,
+	0x17, 0x05, 0x55, 0x7c, 0xe7, 0x08, 0x60, 0x99, 0xc4, 0x53, 0x3a, 0x63, 0x45, 0xb9, 0x6f, 0x0c,
+	0xd1, 0xc1, 0x6f, 0x7e, 0x3c, 0x57, 0xf3, 0x35, 0xa9, 0x02, 0x6f, 0x5a, 0x47, 0x9a, 0x77, 0x01,
+	0x3d, 0xfc, 0x72, 0xc2, 0x92, 0x4b, 0x26, 0xb2, 0x4d, 0x8d, 0xf7, 0xa1, 0x13, 0xcc, 0x45, 0xc6,
+	0x85, 0x14, 0xd1, 0x69, 0x89, 0xe4, 0x84, 0xa3, 0xef, 0xac, 0xbc, 0x6c, 0x32, 0xf6, 0x0e, 0x60,
+	0x77, 0x45, 0x15, 0x9b, 0x23, 0xd0, 0x4e, 0xa2, 0x50, 0xf5, 0xa6, 0x53, 0x19, 0x8f, 0x7f, 0xb6,
+	0xc1, 0x38, 0xc5, 0xf6, 0xc8, 0x31, 0x6c, 0x95, 0x4f, 0x03, 0xe9, 0xd7, 0xdb, 0x5e, 0xbe, 0x4b,
+	0x8e, 0xdd, 0x98, 0x47, 0xe9, 0xb7, 0xd0, 0xad, 0x7c, 0x26, 0x76, 0xe3, 0xb8, 0x51, 0xc0, 0xb9,
+	0x67, 0x05, 0x25, 0x26, 0xb5, 0x2b, 0x82, 0x5b, 0x27, 0xfb, 0xcd, 0x64, 0x75, 0x3a, 0x9c, 0xc7,
+	0x1b, 0x56, 0x51, 0xee, 0x10, 0x0c, 0x04, 0x19, 0xb1, 0x1a, 0x66, 0x78, 0xeb, 0xf4, 0x9b, 0x27,
+	0x4b, 0x3e, 0xc1, 0xc3, 0xb5, 0x57, 0x80, 0xac, 0x54, 0xba, 0xfb, 0x90, 0x38, 0xee, 0xc6, 0x75,
+	0x14, 0xfd, 0x02, 0x7b, 0x77, 0x2e, 0x0a, 0x71, 0x1b, 0x1b, 0xa8, 0xbd, 0x22, 0x8e, 0xf7, 0x17,
+	0x06, 0x4a, 0x7f, 0x80, 0xed, 0xda, 0x9c, 0x89, 0xb3, 0xde, 0xd6, 0xf2, 0x58, 0x39, 0xfb, 0xf7,
+	0xae, 0xa5, 0x71, 0x71, 0xf2, 0xe2, 0xeb, 0xb3, 0xcd, 0x7f, 0x5d, 0xf2, 0xbb, 0x63, 0xf9, 0x7b,
+	0xd9, 0x91, 0xb7, 0xeb, 0xd5, 0x9f, 0x01, 0x00, 0xfb, 0xdd, 0x9e, 0x14, 0x0d, 0x07, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -409,6 +837,12 @@ type CometClient interface {
 	BroadcastRoom(ctx context.Context, in *BroadcastRoomReq, opts ...grpc.CallOption) (*BroadcastRoomReply, error)
 	// Rooms get all rooms
 	Rooms(ctx context.Context, in *RoomsReq, opts ...grpc.CallOption) (*RoomsReply, error)
+	// CancelBroadcast cancel a queued or running broadcast
+	CancelBroadcast(ctx context.Context, in *CancelBroadcastReq, opts ...grpc.CallOption) (*CancelBroadcastReply, error)
+	// BroadcastProgress get the progress of broadcasts
+	BroadcastProgress(ctx context.Context, in *BroadcastProgressReq, opts ...grpc.CallOption) (*BroadcastProgressReply, error)
+	// RoomMembers get the mids in a room
+	RoomMembers(ctx context.Context, in *RoomMembersReq, opts ...grpc.CallOption) (*RoomMembersReply, error)
 }
 
 type cometClient struct {
@@ -455,6 +889,33 @@ func (c *cometClient) Rooms(ctx context.Context, in *RoomsReq, opts ...grpc.Call
 	return out, nil
 }
 
+func (c *cometClient) CancelBroadcast(ctx context.Context, in *CancelBroadcastReq, opts ...grpc.CallOption) (*CancelBroadcastReply, error) {
+	out := new(CancelBroadcastReply)
+	err := c.cc.Invoke(ctx, "/goim.comet.Comet/CancelBroadcast", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cometClient) BroadcastProgress(ctx context.Context, in *BroadcastProgressReq, opts ...grpc.CallOption) (*BroadcastProgressReply, error) {
+	out := new(BroadcastProgressReply)
+	err := c.cc.Invoke(ctx, "/goim.comet.Comet/BroadcastProgress", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cometClient) RoomMembers(ctx context.Context, in *RoomMembersReq, opts ...grpc.CallOption) (*RoomMembersReply, error) {
+	out := new(RoomMembersReply)
+	err := c.cc.Invoke(ctx, "/goim.comet.Comet/RoomMembers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CometServer is the server API for Comet service.
 type CometServer interface {
 	// PushMsg push by key or mid
@@ -465,6 +926,12 @@ type CometServer interface {
 	BroadcastRoom(context.Context, *BroadcastRoomReq) (*BroadcastRoomReply, error)
 	// Rooms get all rooms
 	Rooms(context.Context, *RoomsReq) (*RoomsReply, error)
+	// CancelBroadcast cancel a queued or running broadcast
+	CancelBroadcast(context.Context, *CancelBroadcastReq) (*CancelBroadcastReply, error)
+	// BroadcastProgress get the progress of broadcasts
+	BroadcastProgress(context.Context, *BroadcastProgressReq) (*BroadcastProgressReply, error)
+	// RoomMembers get the mids in a room
+	RoomMembers(context.Context, *RoomMembersReq) (*RoomMembersReply, error)
 }
 
 // UnimplementedCometServer can be embedded to have forward compatible implementations.
@@ -483,6 +950,15 @@ func (*UnimplementedCometServer) BroadcastRoom(ctx context.Context, req *Broadca
 func (*UnimplementedCometServer) Rooms(ctx context.Context, req *RoomsReq) (*RoomsReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Rooms not implemented")
 }
+func (*UnimplementedCometServer) CancelBroadcast(ctx context.Context, req *CancelBroadcastReq) (*CancelBroadcastReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelBroadcast not implemented")
+}
+func (*UnimplementedCometServer) BroadcastProgress(ctx context.Context, req *BroadcastProgressReq) (*BroadcastProgressReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BroadcastProgress not implemented")
+}
+func (*UnimplementedCometServer) RoomMembers(ctx context.Context, req *RoomMembersReq) (*RoomMembersReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RoomMembers not implemented")
+}
 
 func RegisterCometServer(s *grpc.Server, srv CometServer) {
 	s.RegisterService(&_Comet_serviceDesc, srv)
@@ -560,6 +1036,60 @@ func _Comet_Rooms_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Comet_CancelBroadcast_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelBroadcastReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CometServer).CancelBroadcast(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goim.comet.Comet/CancelBroadcast",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CometServer).CancelBroadcast(ctx, req.(*CancelBroadcastReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Comet_BroadcastProgress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BroadcastProgressReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CometServer).BroadcastProgress(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goim.comet.Comet/BroadcastProgress",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CometServer).BroadcastProgress(ctx, req.(*BroadcastProgressReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Comet_RoomMembers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomMembersReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CometServer).RoomMembers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goim.comet.Comet/RoomMembers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CometServer).RoomMembers(ctx, req.(*RoomMembersReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Comet_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "goim.comet.Comet",
 	HandlerType: (*CometServer)(nil),
@@ -580,6 +1110,18 @@ var _Comet_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Rooms",
 			Handler:    _Comet_Rooms_Handler,
 		},
+		{
+			MethodName: "CancelBroadcast",
+			Handler:    _Comet_CancelBroadcast_Handler,
+		},
+		{
+			MethodName: "BroadcastProgress",
+			Handler:    _Comet_BroadcastProgress_Handler,
+		},
+		{
+			MethodName: "RoomMembers",
+			Handler:    _Comet_RoomMembers_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "comet/comet.proto",
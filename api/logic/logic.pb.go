@@ -54,16 +54,22 @@ func (PushMsg_Type) EnumDescriptor() ([]byte, []int) {
 }
 
 type PushMsg struct {
-	Type                 PushMsg_Type `protobuf:"varint,1,opt,name=type,proto3,enum=goim.logic.PushMsg_Type" json:"type,omitempty"`
-	Operation            int32        `protobuf:"varint,2,opt,name=operation,proto3" json:"operation,omitempty"`
-	Speed                int32        `protobuf:"varint,3,opt,name=speed,proto3" json:"speed,omitempty"`
-	Server               string       `protobuf:"bytes,4,opt,name=server,proto3" json:"server,omitempty"`
-	Room                 string       `protobuf:"bytes,5,opt,name=room,proto3" json:"room,omitempty"`
-	Keys                 []string     `protobuf:"bytes,6,rep,name=keys,proto3" json:"keys,omitempty"`
-	Msg                  []byte       `protobuf:"bytes,7,opt,name=msg,proto3" json:"msg,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
-	XXX_unrecognized     []byte       `json:"-"`
-	XXX_sizecache        int32        `json:"-"`
+	Type      PushMsg_Type `protobuf:"varint,1,opt,name=type,proto3,enum=goim.logic.PushMsg_Type" json:"type,omitempty"`
+	Operation int32        `protobuf:"varint,2,opt,name=operation,proto3" json:"operation,omitempty"`
+	Speed     int32        `protobuf:"varint,3,opt,name=speed,proto3" json:"speed,omitempty"`
+	Server    string       `protobuf:"bytes,4,opt,name=server,proto3" json:"server,omitempty"`
+	Room      string       `protobuf:"bytes,5,opt,name=room,proto3" json:"room,omitempty"`
+	Keys      []string     `protobuf:"bytes,6,rep,name=keys,proto3" json:"keys,omitempty"`
+	Msg       []byte       `protobuf:"bytes,7,opt,name=msg,proto3" json:"msg,omitempty"`
+	// broadcast to the platform only, empty for all
+	Platform string `protobuf:"bytes,8,opt,name=platform,proto3" json:"platform,omitempty"`
+	// room message priority, the low ones are throttled first
+	Priority int32 `protobuf:"varint,9,opt,name=priority,proto3" json:"priority,omitempty"`
+	// broadcast to the app namespace, empty for the default
+	App                  string   `protobuf:"bytes,10,opt,name=app,proto3" json:"app,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *PushMsg) Reset()         { *m = PushMsg{} }
@@ -140,10 +146,32 @@ func (m *PushMsg) GetMsg() []byte {
 	return nil
 }
 
+func (m *PushMsg) GetPlatform() string {
+	if m != nil {
+		return m.Platform
+	}
+	return ""
+}
+
+func (m *PushMsg) GetPriority() int32 {
+	if m != nil {
+		return m.Priority
+	}
+	return 0
+}
+
+func (m *PushMsg) GetApp() string {
+	if m != nil {
+		return m.App
+	}
+	return ""
+}
+
 type ConnectReq struct {
 	Server               string   `protobuf:"bytes,1,opt,name=server,proto3" json:"server,omitempty"`
 	Cookie               string   `protobuf:"bytes,2,opt,name=cookie,proto3" json:"cookie,omitempty"`
 	Token                []byte   `protobuf:"bytes,3,opt,name=token,proto3" json:"token,omitempty"`
+	Ip                   string   `protobuf:"bytes,4,opt,name=ip,proto3" json:"ip,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -195,12 +223,28 @@ func (m *ConnectReq) GetToken() []byte {
 	return nil
 }
 
+func (m *ConnectReq) GetIp() string {
+	if m != nil {
+		return m.Ip
+	}
+	return ""
+}
+
 type ConnectReply struct {
-	Mid                  int64    `protobuf:"varint,1,opt,name=mid,proto3" json:"mid,omitempty"`
-	Key                  string   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
-	RoomID               string   `protobuf:"bytes,3,opt,name=roomID,proto3" json:"roomID,omitempty"`
-	Accepts              []int32  `protobuf:"varint,4,rep,packed,name=accepts,proto3" json:"accepts,omitempty"`
-	Heartbeat            int64    `protobuf:"varint,5,opt,name=heartbeat,proto3" json:"heartbeat,omitempty"`
+	Mid       int64   `protobuf:"varint,1,opt,name=mid,proto3" json:"mid,omitempty"`
+	Key       string  `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	RoomID    string  `protobuf:"bytes,3,opt,name=roomID,proto3" json:"roomID,omitempty"`
+	Accepts   []int32 `protobuf:"varint,4,rep,packed,name=accepts,proto3" json:"accepts,omitempty"`
+	Heartbeat int64   `protobuf:"varint,5,opt,name=heartbeat,proto3" json:"heartbeat,omitempty"`
+	Platform  string  `protobuf:"bytes,6,opt,name=platform,proto3" json:"platform,omitempty"`
+	// resume token, empty if resume is disabled
+	Resume string `protobuf:"bytes,7,opt,name=resume,proto3" json:"resume,omitempty"`
+	// the session is resumed by the token
+	Resumed bool `protobuf:"varint,8,opt,name=resumed,proto3" json:"resumed,omitempty"`
+	// token expire time in unix seconds, zero never expires
+	Expire int64 `protobuf:"varint,9,opt,name=expire,proto3" json:"expire,omitempty"`
+	// app namespace of the connection, empty for the default
+	App                  string   `protobuf:"bytes,10,opt,name=app,proto3" json:"app,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -266,6 +310,41 @@ func (m *ConnectReply) GetHeartbeat() int64 {
 	return 0
 }
 
+func (m *ConnectReply) GetPlatform() string {
+	if m != nil {
+		return m.Platform
+	}
+	return ""
+}
+
+func (m *ConnectReply) GetResume() string {
+	if m != nil {
+		return m.Resume
+	}
+	return ""
+}
+
+func (m *ConnectReply) GetResumed() bool {
+	if m != nil {
+		return m.Resumed
+	}
+	return false
+}
+
+func (m *ConnectReply) GetExpire() int64 {
+	if m != nil {
+		return m.Expire
+	}
+	return 0
+}
+
+func (m *ConnectReply) GetApp() string {
+	if m != nil {
+		return m.App
+	}
+	return ""
+}
+
 type DisconnectReq struct {
 	Mid                  int64    `protobuf:"varint,1,opt,name=mid,proto3" json:"mid,omitempty"`
 	Key                  string   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
@@ -446,9 +525,166 @@ func (m *HeartbeatReply) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_HeartbeatReply proto.InternalMessageInfo
 
+type ReauthReq struct {
+	Mid                  int64    `protobuf:"varint,1,opt,name=mid,proto3" json:"mid,omitempty"`
+	Key                  string   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Server               string   `protobuf:"bytes,3,opt,name=server,proto3" json:"server,omitempty"`
+	Token                []byte   `protobuf:"bytes,4,opt,name=token,proto3" json:"token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReauthReq) Reset()         { *m = ReauthReq{} }
+func (m *ReauthReq) String() string { return proto.CompactTextString(m) }
+func (*ReauthReq) ProtoMessage()    {}
+func (*ReauthReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{7}
+}
+
+func (m *ReauthReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReauthReq.Unmarshal(m, b)
+}
+func (m *ReauthReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReauthReq.Marshal(b, m, deterministic)
+}
+func (m *ReauthReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReauthReq.Merge(m, src)
+}
+func (m *ReauthReq) XXX_Size() int {
+	return xxx_messageInfo_ReauthReq.Size(m)
+}
+func (m *ReauthReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReauthReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReauthReq proto.InternalMessageInfo
+
+func (m *ReauthReq) GetMid() int64 {
+	if m != nil {
+		return m.Mid
+	}
+	return 0
+}
+
+func (m *ReauthReq) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *ReauthReq) GetServer() string {
+	if m != nil {
+		return m.Server
+	}
+	return ""
+}
+
+func (m *ReauthReq) GetToken() []byte {
+	if m != nil {
+		return m.Token
+	}
+	return nil
+}
+
+type ReauthReply struct {
+	// token expire time in unix seconds, zero never expires
+	Expire               int64    `protobuf:"varint,1,opt,name=expire,proto3" json:"expire,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReauthReply) Reset()         { *m = ReauthReply{} }
+func (m *ReauthReply) String() string { return proto.CompactTextString(m) }
+func (*ReauthReply) ProtoMessage()    {}
+func (*ReauthReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{8}
+}
+
+func (m *ReauthReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReauthReply.Unmarshal(m, b)
+}
+func (m *ReauthReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReauthReply.Marshal(b, m, deterministic)
+}
+func (m *ReauthReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReauthReply.Merge(m, src)
+}
+func (m *ReauthReply) XXX_Size() int {
+	return xxx_messageInfo_ReauthReply.Size(m)
+}
+func (m *ReauthReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReauthReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReauthReply proto.InternalMessageInfo
+
+func (m *ReauthReply) GetExpire() int64 {
+	if m != nil {
+		return m.Expire
+	}
+	return 0
+}
+
+// RoomUsers is the users of a room on a comet.
+type RoomUsers struct {
+	// the sketch of the mids, see pkg/hll
+	Mids []byte `protobuf:"bytes,1,opt,name=mids,proto3" json:"mids,omitempty"`
+	// the guest connections, a guest counts as one
+	Guests               int32    `protobuf:"varint,2,opt,name=guests,proto3" json:"guests,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RoomUsers) Reset()         { *m = RoomUsers{} }
+func (m *RoomUsers) String() string { return proto.CompactTextString(m) }
+func (*RoomUsers) ProtoMessage()    {}
+func (*RoomUsers) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{9}
+}
+
+func (m *RoomUsers) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RoomUsers.Unmarshal(m, b)
+}
+func (m *RoomUsers) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RoomUsers.Marshal(b, m, deterministic)
+}
+func (m *RoomUsers) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RoomUsers.Merge(m, src)
+}
+func (m *RoomUsers) XXX_Size() int {
+	return xxx_messageInfo_RoomUsers.Size(m)
+}
+func (m *RoomUsers) XXX_DiscardUnknown() {
+	xxx_messageInfo_RoomUsers.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RoomUsers proto.InternalMessageInfo
+
+func (m *RoomUsers) GetMids() []byte {
+	if m != nil {
+		return m.Mids
+	}
+	return nil
+}
+
+func (m *RoomUsers) GetGuests() int32 {
+	if m != nil {
+		return m.Guests
+	}
+	return 0
+}
+
 type OnlineReq struct {
-	Server               string           `protobuf:"bytes,1,opt,name=server,proto3" json:"server,omitempty"`
-	RoomCount            map[string]int32 `protobuf:"bytes,2,rep,name=roomCount,proto3" json:"roomCount,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	Server    string           `protobuf:"bytes,1,opt,name=server,proto3" json:"server,omitempty"`
+	RoomCount map[string]int32 `protobuf:"bytes,2,rep,name=roomCount,proto3" json:"roomCount,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	// the users of the rooms merged across the comets
+	RoomUsers map[string]*RoomUsers `protobuf:"bytes,5,rep,name=roomUsers,proto3" json:"roomUsers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// the connections of the apps
+	AppConns             map[string]int32 `protobuf:"bytes,4,rep,name=appConns,proto3" json:"appConns,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
 	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
 	XXX_unrecognized     []byte           `json:"-"`
 	XXX_sizecache        int32            `json:"-"`
@@ -458,7 +694,7 @@ func (m *OnlineReq) Reset()         { *m = OnlineReq{} }
 func (m *OnlineReq) String() string { return proto.CompactTextString(m) }
 func (*OnlineReq) ProtoMessage()    {}
 func (*OnlineReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_2dfb3aef05fe3328, []int{7}
+	return fileDescriptor_2dfb3aef05fe3328, []int{10}
 }
 
 func (m *OnlineReq) XXX_Unmarshal(b []byte) error {
@@ -493,6 +729,20 @@ func (m *OnlineReq) GetRoomCount() map[string]int32 {
 	return nil
 }
 
+func (m *OnlineReq) GetRoomUsers() map[string]*RoomUsers {
+	if m != nil {
+		return m.RoomUsers
+	}
+	return nil
+}
+
+func (m *OnlineReq) GetAppConns() map[string]int32 {
+	if m != nil {
+		return m.AppConns
+	}
+	return nil
+}
+
 type OnlineReply struct {
 	AllRoomCount         map[string]int32 `protobuf:"bytes,1,rep,name=allRoomCount,proto3" json:"allRoomCount,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
 	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
@@ -504,7 +754,7 @@ func (m *OnlineReply) Reset()         { *m = OnlineReply{} }
 func (m *OnlineReply) String() string { return proto.CompactTextString(m) }
 func (*OnlineReply) ProtoMessage()    {}
 func (*OnlineReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_2dfb3aef05fe3328, []int{8}
+	return fileDescriptor_2dfb3aef05fe3328, []int{11}
 }
 
 func (m *OnlineReply) XXX_Unmarshal(b []byte) error {
@@ -544,7 +794,7 @@ func (m *ReceiveReq) Reset()         { *m = ReceiveReq{} }
 func (m *ReceiveReq) String() string { return proto.CompactTextString(m) }
 func (*ReceiveReq) ProtoMessage()    {}
 func (*ReceiveReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_2dfb3aef05fe3328, []int{9}
+	return fileDescriptor_2dfb3aef05fe3328, []int{12}
 }
 
 func (m *ReceiveReq) XXX_Unmarshal(b []byte) error {
@@ -589,7 +839,7 @@ func (m *ReceiveReply) Reset()         { *m = ReceiveReply{} }
 func (m *ReceiveReply) String() string { return proto.CompactTextString(m) }
 func (*ReceiveReply) ProtoMessage()    {}
 func (*ReceiveReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_2dfb3aef05fe3328, []int{10}
+	return fileDescriptor_2dfb3aef05fe3328, []int{13}
 }
 
 func (m *ReceiveReply) XXX_Unmarshal(b []byte) error {
@@ -610,6 +860,140 @@ func (m *ReceiveReply) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_ReceiveReply proto.InternalMessageInfo
 
+type PresenceEvent struct {
+	RoomID string `protobuf:"bytes,1,opt,name=roomID,proto3" json:"roomID,omitempty"`
+	Mid    int64  `protobuf:"varint,2,opt,name=mid,proto3" json:"mid,omitempty"`
+	// join or leave
+	Join                 bool     `protobuf:"varint,3,opt,name=join,proto3" json:"join,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PresenceEvent) Reset()         { *m = PresenceEvent{} }
+func (m *PresenceEvent) String() string { return proto.CompactTextString(m) }
+func (*PresenceEvent) ProtoMessage()    {}
+func (*PresenceEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{14}
+}
+
+func (m *PresenceEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PresenceEvent.Unmarshal(m, b)
+}
+func (m *PresenceEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PresenceEvent.Marshal(b, m, deterministic)
+}
+func (m *PresenceEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PresenceEvent.Merge(m, src)
+}
+func (m *PresenceEvent) XXX_Size() int {
+	return xxx_messageInfo_PresenceEvent.Size(m)
+}
+func (m *PresenceEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_PresenceEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PresenceEvent proto.InternalMessageInfo
+
+func (m *PresenceEvent) GetRoomID() string {
+	if m != nil {
+		return m.RoomID
+	}
+	return ""
+}
+
+func (m *PresenceEvent) GetMid() int64 {
+	if m != nil {
+		return m.Mid
+	}
+	return 0
+}
+
+func (m *PresenceEvent) GetJoin() bool {
+	if m != nil {
+		return m.Join
+	}
+	return false
+}
+
+type PresenceReq struct {
+	Server               string           `protobuf:"bytes,1,opt,name=server,proto3" json:"server,omitempty"`
+	Events               []*PresenceEvent `protobuf:"bytes,2,rep,name=events,proto3" json:"events,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *PresenceReq) Reset()         { *m = PresenceReq{} }
+func (m *PresenceReq) String() string { return proto.CompactTextString(m) }
+func (*PresenceReq) ProtoMessage()    {}
+func (*PresenceReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{15}
+}
+
+func (m *PresenceReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PresenceReq.Unmarshal(m, b)
+}
+func (m *PresenceReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PresenceReq.Marshal(b, m, deterministic)
+}
+func (m *PresenceReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PresenceReq.Merge(m, src)
+}
+func (m *PresenceReq) XXX_Size() int {
+	return xxx_messageInfo_PresenceReq.Size(m)
+}
+func (m *PresenceReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_PresenceReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PresenceReq proto.InternalMessageInfo
+
+func (m *PresenceReq) GetServer() string {
+	if m != nil {
+		return m.Server
+	}
+	return ""
+}
+
+func (m *PresenceReq) GetEvents() []*PresenceEvent {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
+type PresenceReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PresenceReply) Reset()         { *m = PresenceReply{} }
+func (m *PresenceReply) String() string { return proto.CompactTextString(m) }
+func (*PresenceReply) ProtoMessage()    {}
+func (*PresenceReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{16}
+}
+
+func (m *PresenceReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PresenceReply.Unmarshal(m, b)
+}
+func (m *PresenceReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PresenceReply.Marshal(b, m, deterministic)
+}
+func (m *PresenceReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PresenceReply.Merge(m, src)
+}
+func (m *PresenceReply) XXX_Size() int {
+	return xxx_messageInfo_PresenceReply.Size(m)
+}
+func (m *PresenceReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_PresenceReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PresenceReply proto.InternalMessageInfo
+
 type NodesReq struct {
 	Platform             string   `protobuf:"bytes,1,opt,name=platform,proto3" json:"platform,omitempty"`
 	ClientIP             string   `protobuf:"bytes,2,opt,name=clientIP,proto3" json:"clientIP,omitempty"`
@@ -622,7 +1006,7 @@ func (m *NodesReq) Reset()         { *m = NodesReq{} }
 func (m *NodesReq) String() string { return proto.CompactTextString(m) }
 func (*NodesReq) ProtoMessage()    {}
 func (*NodesReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_2dfb3aef05fe3328, []int{11}
+	return fileDescriptor_2dfb3aef05fe3328, []int{17}
 }
 
 func (m *NodesReq) XXX_Unmarshal(b []byte) error {
@@ -675,7 +1059,7 @@ func (m *NodesReply) Reset()         { *m = NodesReply{} }
 func (m *NodesReply) String() string { return proto.CompactTextString(m) }
 func (*NodesReply) ProtoMessage()    {}
 func (*NodesReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_2dfb3aef05fe3328, []int{12}
+	return fileDescriptor_2dfb3aef05fe3328, []int{18}
 }
 
 func (m *NodesReply) XXX_Unmarshal(b []byte) error {
@@ -766,7 +1150,7 @@ func (m *Backoff) Reset()         { *m = Backoff{} }
 func (m *Backoff) String() string { return proto.CompactTextString(m) }
 func (*Backoff) ProtoMessage()    {}
 func (*Backoff) Descriptor() ([]byte, []int) {
-	return fileDescriptor_2dfb3aef05fe3328, []int{13}
+	return fileDescriptor_2dfb3aef05fe3328, []int{19}
 }
 
 func (m *Backoff) XXX_Unmarshal(b []byte) error {
@@ -815,86 +1199,1213 @@ func (m *Backoff) GetJitter() float32 {
 	return 0
 }
 
-func init() {
-	proto.RegisterEnum("goim.logic.PushMsg_Type", PushMsg_Type_name, PushMsg_Type_value)
-	proto.RegisterType((*PushMsg)(nil), "goim.logic.PushMsg")
-	proto.RegisterType((*ConnectReq)(nil), "goim.logic.ConnectReq")
-	proto.RegisterType((*ConnectReply)(nil), "goim.logic.ConnectReply")
-	proto.RegisterType((*DisconnectReq)(nil), "goim.logic.DisconnectReq")
-	proto.RegisterType((*DisconnectReply)(nil), "goim.logic.DisconnectReply")
-	proto.RegisterType((*HeartbeatReq)(nil), "goim.logic.HeartbeatReq")
-	proto.RegisterType((*HeartbeatReply)(nil), "goim.logic.HeartbeatReply")
-	proto.RegisterType((*OnlineReq)(nil), "goim.logic.OnlineReq")
-	proto.RegisterMapType((map[string]int32)(nil), "goim.logic.OnlineReq.RoomCountEntry")
-	proto.RegisterType((*OnlineReply)(nil), "goim.logic.OnlineReply")
-	proto.RegisterMapType((map[string]int32)(nil), "goim.logic.OnlineReply.AllRoomCountEntry")
-	proto.RegisterType((*ReceiveReq)(nil), "goim.logic.ReceiveReq")
-	proto.RegisterType((*ReceiveReply)(nil), "goim.logic.ReceiveReply")
-	proto.RegisterType((*NodesReq)(nil), "goim.logic.NodesReq")
-	proto.RegisterType((*NodesReply)(nil), "goim.logic.NodesReply")
-	proto.RegisterType((*Backoff)(nil), "goim.logic.Backoff")
-}
-
-func init() { proto.RegisterFile("logic/logic.proto", fileDescriptor_2dfb3aef05fe3328) }
+type KeyResult struct {
+	Key string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// enqueued, offline or failed
+	Status string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	// the comet enqueued to
+	Server               string   `protobuf:"bytes,3,opt,name=server,proto3" json:"server,omitempty"`
+	Error                string   `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *KeyResult) Reset()         { *m = KeyResult{} }
+func (m *KeyResult) String() string { return proto.CompactTextString(m) }
+func (*KeyResult) ProtoMessage()    {}
+func (*KeyResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{20}
+}
+
+func (m *KeyResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_KeyResult.Unmarshal(m, b)
+}
+func (m *KeyResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_KeyResult.Marshal(b, m, deterministic)
+}
+func (m *KeyResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_KeyResult.Merge(m, src)
+}
+func (m *KeyResult) XXX_Size() int {
+	return xxx_messageInfo_KeyResult.Size(m)
+}
+func (m *KeyResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_KeyResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_KeyResult proto.InternalMessageInfo
+
+func (m *KeyResult) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *KeyResult) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *KeyResult) GetServer() string {
+	if m != nil {
+		return m.Server
+	}
+	return ""
+}
+
+func (m *KeyResult) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+type MidResult struct {
+	Mid int64 `protobuf:"varint,1,opt,name=mid,proto3" json:"mid,omitempty"`
+	// enqueued if any of the keys is, offline if there is no key
+	Status               string       `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Keys                 []*KeyResult `protobuf:"bytes,3,rep,name=keys,proto3" json:"keys,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *MidResult) Reset()         { *m = MidResult{} }
+func (m *MidResult) String() string { return proto.CompactTextString(m) }
+func (*MidResult) ProtoMessage()    {}
+func (*MidResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{21}
+}
+
+func (m *MidResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MidResult.Unmarshal(m, b)
+}
+func (m *MidResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MidResult.Marshal(b, m, deterministic)
+}
+func (m *MidResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MidResult.Merge(m, src)
+}
+func (m *MidResult) XXX_Size() int {
+	return xxx_messageInfo_MidResult.Size(m)
+}
+func (m *MidResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_MidResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MidResult proto.InternalMessageInfo
+
+func (m *MidResult) GetMid() int64 {
+	if m != nil {
+		return m.Mid
+	}
+	return 0
+}
+
+func (m *MidResult) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *MidResult) GetKeys() []*KeyResult {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+type PushKeysReq struct {
+	Op   int32    `protobuf:"varint,1,opt,name=op,proto3" json:"op,omitempty"`
+	Keys []string `protobuf:"bytes,2,rep,name=keys,proto3" json:"keys,omitempty"`
+	Msg  []byte   `protobuf:"bytes,3,opt,name=msg,proto3" json:"msg,omitempty"`
+	// app namespace, empty for the default
+	App                  string   `protobuf:"bytes,4,opt,name=app,proto3" json:"app,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PushKeysReq) Reset()         { *m = PushKeysReq{} }
+func (m *PushKeysReq) String() string { return proto.CompactTextString(m) }
+func (*PushKeysReq) ProtoMessage()    {}
+func (*PushKeysReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{22}
+}
+
+func (m *PushKeysReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PushKeysReq.Unmarshal(m, b)
+}
+func (m *PushKeysReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PushKeysReq.Marshal(b, m, deterministic)
+}
+func (m *PushKeysReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PushKeysReq.Merge(m, src)
+}
+func (m *PushKeysReq) XXX_Size() int {
+	return xxx_messageInfo_PushKeysReq.Size(m)
+}
+func (m *PushKeysReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_PushKeysReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PushKeysReq proto.InternalMessageInfo
+
+func (m *PushKeysReq) GetOp() int32 {
+	if m != nil {
+		return m.Op
+	}
+	return 0
+}
+
+func (m *PushKeysReq) GetKeys() []string {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+func (m *PushKeysReq) GetMsg() []byte {
+	if m != nil {
+		return m.Msg
+	}
+	return nil
+}
+
+func (m *PushKeysReq) GetApp() string {
+	if m != nil {
+		return m.App
+	}
+	return ""
+}
+
+type PushKeysReply struct {
+	Results              []*KeyResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *PushKeysReply) Reset()         { *m = PushKeysReply{} }
+func (m *PushKeysReply) String() string { return proto.CompactTextString(m) }
+func (*PushKeysReply) ProtoMessage()    {}
+func (*PushKeysReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{23}
+}
+
+func (m *PushKeysReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PushKeysReply.Unmarshal(m, b)
+}
+func (m *PushKeysReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PushKeysReply.Marshal(b, m, deterministic)
+}
+func (m *PushKeysReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PushKeysReply.Merge(m, src)
+}
+func (m *PushKeysReply) XXX_Size() int {
+	return xxx_messageInfo_PushKeysReply.Size(m)
+}
+func (m *PushKeysReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_PushKeysReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PushKeysReply proto.InternalMessageInfo
+
+func (m *PushKeysReply) GetResults() []*KeyResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+type PushMidsReq struct {
+	Op                   int32    `protobuf:"varint,1,opt,name=op,proto3" json:"op,omitempty"`
+	Mids                 []int64  `protobuf:"varint,2,rep,packed,name=mids,proto3" json:"mids,omitempty"`
+	Msg                  []byte   `protobuf:"bytes,3,opt,name=msg,proto3" json:"msg,omitempty"`
+	App                  string   `protobuf:"bytes,4,opt,name=app,proto3" json:"app,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PushMidsReq) Reset()         { *m = PushMidsReq{} }
+func (m *PushMidsReq) String() string { return proto.CompactTextString(m) }
+func (*PushMidsReq) ProtoMessage()    {}
+func (*PushMidsReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{24}
+}
+
+func (m *PushMidsReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PushMidsReq.Unmarshal(m, b)
+}
+func (m *PushMidsReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PushMidsReq.Marshal(b, m, deterministic)
+}
+func (m *PushMidsReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PushMidsReq.Merge(m, src)
+}
+func (m *PushMidsReq) XXX_Size() int {
+	return xxx_messageInfo_PushMidsReq.Size(m)
+}
+func (m *PushMidsReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_PushMidsReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PushMidsReq proto.InternalMessageInfo
+
+func (m *PushMidsReq) GetOp() int32 {
+	if m != nil {
+		return m.Op
+	}
+	return 0
+}
+
+func (m *PushMidsReq) GetMids() []int64 {
+	if m != nil {
+		return m.Mids
+	}
+	return nil
+}
+
+func (m *PushMidsReq) GetMsg() []byte {
+	if m != nil {
+		return m.Msg
+	}
+	return nil
+}
+
+func (m *PushMidsReq) GetApp() string {
+	if m != nil {
+		return m.App
+	}
+	return ""
+}
+
+type PushMidsReply struct {
+	Results              []*MidResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *PushMidsReply) Reset()         { *m = PushMidsReply{} }
+func (m *PushMidsReply) String() string { return proto.CompactTextString(m) }
+func (*PushMidsReply) ProtoMessage()    {}
+func (*PushMidsReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{25}
+}
+
+func (m *PushMidsReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PushMidsReply.Unmarshal(m, b)
+}
+func (m *PushMidsReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PushMidsReply.Marshal(b, m, deterministic)
+}
+func (m *PushMidsReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PushMidsReply.Merge(m, src)
+}
+func (m *PushMidsReply) XXX_Size() int {
+	return xxx_messageInfo_PushMidsReply.Size(m)
+}
+func (m *PushMidsReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_PushMidsReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PushMidsReply proto.InternalMessageInfo
+
+func (m *PushMidsReply) GetResults() []*MidResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+type PushRoomReq struct {
+	Op   int32  `protobuf:"varint,1,opt,name=op,proto3" json:"op,omitempty"`
+	Type string `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Room string `protobuf:"bytes,3,opt,name=room,proto3" json:"room,omitempty"`
+	// the low ones are throttled first if the room is over the budget
+	Priority             int32    `protobuf:"varint,4,opt,name=priority,proto3" json:"priority,omitempty"`
+	Msg                  []byte   `protobuf:"bytes,5,opt,name=msg,proto3" json:"msg,omitempty"`
+	App                  string   `protobuf:"bytes,6,opt,name=app,proto3" json:"app,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PushRoomReq) Reset()         { *m = PushRoomReq{} }
+func (m *PushRoomReq) String() string { return proto.CompactTextString(m) }
+func (*PushRoomReq) ProtoMessage()    {}
+func (*PushRoomReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{26}
+}
+
+func (m *PushRoomReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PushRoomReq.Unmarshal(m, b)
+}
+func (m *PushRoomReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PushRoomReq.Marshal(b, m, deterministic)
+}
+func (m *PushRoomReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PushRoomReq.Merge(m, src)
+}
+func (m *PushRoomReq) XXX_Size() int {
+	return xxx_messageInfo_PushRoomReq.Size(m)
+}
+func (m *PushRoomReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_PushRoomReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PushRoomReq proto.InternalMessageInfo
+
+func (m *PushRoomReq) GetOp() int32 {
+	if m != nil {
+		return m.Op
+	}
+	return 0
+}
+
+func (m *PushRoomReq) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *PushRoomReq) GetRoom() string {
+	if m != nil {
+		return m.Room
+	}
+	return ""
+}
+
+func (m *PushRoomReq) GetPriority() int32 {
+	if m != nil {
+		return m.Priority
+	}
+	return 0
+}
+
+func (m *PushRoomReq) GetMsg() []byte {
+	if m != nil {
+		return m.Msg
+	}
+	return nil
+}
+
+func (m *PushRoomReq) GetApp() string {
+	if m != nil {
+		return m.App
+	}
+	return ""
+}
+
+type PushRoomReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PushRoomReply) Reset()         { *m = PushRoomReply{} }
+func (m *PushRoomReply) String() string { return proto.CompactTextString(m) }
+func (*PushRoomReply) ProtoMessage()    {}
+func (*PushRoomReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{27}
+}
+
+func (m *PushRoomReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PushRoomReply.Unmarshal(m, b)
+}
+func (m *PushRoomReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PushRoomReply.Marshal(b, m, deterministic)
+}
+func (m *PushRoomReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PushRoomReply.Merge(m, src)
+}
+func (m *PushRoomReply) XXX_Size() int {
+	return xxx_messageInfo_PushRoomReply.Size(m)
+}
+func (m *PushRoomReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_PushRoomReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PushRoomReply proto.InternalMessageInfo
+
+type PushAllReq struct {
+	Op                   int32    `protobuf:"varint,1,opt,name=op,proto3" json:"op,omitempty"`
+	Speed                int32    `protobuf:"varint,2,opt,name=speed,proto3" json:"speed,omitempty"`
+	Msg                  []byte   `protobuf:"bytes,3,opt,name=msg,proto3" json:"msg,omitempty"`
+	App                  string   `protobuf:"bytes,4,opt,name=app,proto3" json:"app,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PushAllReq) Reset()         { *m = PushAllReq{} }
+func (m *PushAllReq) String() string { return proto.CompactTextString(m) }
+func (*PushAllReq) ProtoMessage()    {}
+func (*PushAllReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{28}
+}
+
+func (m *PushAllReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PushAllReq.Unmarshal(m, b)
+}
+func (m *PushAllReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PushAllReq.Marshal(b, m, deterministic)
+}
+func (m *PushAllReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PushAllReq.Merge(m, src)
+}
+func (m *PushAllReq) XXX_Size() int {
+	return xxx_messageInfo_PushAllReq.Size(m)
+}
+func (m *PushAllReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_PushAllReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PushAllReq proto.InternalMessageInfo
+
+func (m *PushAllReq) GetOp() int32 {
+	if m != nil {
+		return m.Op
+	}
+	return 0
+}
+
+func (m *PushAllReq) GetSpeed() int32 {
+	if m != nil {
+		return m.Speed
+	}
+	return 0
+}
+
+func (m *PushAllReq) GetMsg() []byte {
+	if m != nil {
+		return m.Msg
+	}
+	return nil
+}
+
+func (m *PushAllReq) GetApp() string {
+	if m != nil {
+		return m.App
+	}
+	return ""
+}
+
+type PushAllReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PushAllReply) Reset()         { *m = PushAllReply{} }
+func (m *PushAllReply) String() string { return proto.CompactTextString(m) }
+func (*PushAllReply) ProtoMessage()    {}
+func (*PushAllReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{29}
+}
+
+func (m *PushAllReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PushAllReply.Unmarshal(m, b)
+}
+func (m *PushAllReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PushAllReply.Marshal(b, m, deterministic)
+}
+func (m *PushAllReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PushAllReply.Merge(m, src)
+}
+func (m *PushAllReply) XXX_Size() int {
+	return xxx_messageInfo_PushAllReply.Size(m)
+}
+func (m *PushAllReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_PushAllReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PushAllReply proto.InternalMessageInfo
+
+type OnlineMidsReq struct {
+	Mids                 []int64  `protobuf:"varint,1,rep,packed,name=mids,proto3" json:"mids,omitempty"`
+	App                  string   `protobuf:"bytes,2,opt,name=app,proto3" json:"app,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *OnlineMidsReq) Reset()         { *m = OnlineMidsReq{} }
+func (m *OnlineMidsReq) String() string { return proto.CompactTextString(m) }
+func (*OnlineMidsReq) ProtoMessage()    {}
+func (*OnlineMidsReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{30}
+}
+
+func (m *OnlineMidsReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OnlineMidsReq.Unmarshal(m, b)
+}
+func (m *OnlineMidsReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OnlineMidsReq.Marshal(b, m, deterministic)
+}
+func (m *OnlineMidsReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OnlineMidsReq.Merge(m, src)
+}
+func (m *OnlineMidsReq) XXX_Size() int {
+	return xxx_messageInfo_OnlineMidsReq.Size(m)
+}
+func (m *OnlineMidsReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_OnlineMidsReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OnlineMidsReq proto.InternalMessageInfo
+
+func (m *OnlineMidsReq) GetMids() []int64 {
+	if m != nil {
+		return m.Mids
+	}
+	return nil
+}
+
+func (m *OnlineMidsReq) GetApp() string {
+	if m != nil {
+		return m.App
+	}
+	return ""
+}
+
+type OnlineMidsReply struct {
+	// the mids online in the order of the request
+	Mids                 []int64  `protobuf:"varint,1,rep,packed,name=mids,proto3" json:"mids,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *OnlineMidsReply) Reset()         { *m = OnlineMidsReply{} }
+func (m *OnlineMidsReply) String() string { return proto.CompactTextString(m) }
+func (*OnlineMidsReply) ProtoMessage()    {}
+func (*OnlineMidsReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{31}
+}
+
+func (m *OnlineMidsReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OnlineMidsReply.Unmarshal(m, b)
+}
+func (m *OnlineMidsReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OnlineMidsReply.Marshal(b, m, deterministic)
+}
+func (m *OnlineMidsReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OnlineMidsReply.Merge(m, src)
+}
+func (m *OnlineMidsReply) XXX_Size() int {
+	return xxx_messageInfo_OnlineMidsReply.Size(m)
+}
+func (m *OnlineMidsReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_OnlineMidsReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OnlineMidsReply proto.InternalMessageInfo
+
+func (m *OnlineMidsReply) GetMids() []int64 {
+	if m != nil {
+		return m.Mids
+	}
+	return nil
+}
+
+type RoomOnline struct {
+	Count                int32    `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Users                int32    `protobuf:"varint,2,opt,name=users,proto3" json:"users,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RoomOnline) Reset()         { *m = RoomOnline{} }
+func (m *RoomOnline) String() string { return proto.CompactTextString(m) }
+func (*RoomOnline) ProtoMessage()    {}
+func (*RoomOnline) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{32}
+}
+
+func (m *RoomOnline) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RoomOnline.Unmarshal(m, b)
+}
+func (m *RoomOnline) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RoomOnline.Marshal(b, m, deterministic)
+}
+func (m *RoomOnline) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RoomOnline.Merge(m, src)
+}
+func (m *RoomOnline) XXX_Size() int {
+	return xxx_messageInfo_RoomOnline.Size(m)
+}
+func (m *RoomOnline) XXX_DiscardUnknown() {
+	xxx_messageInfo_RoomOnline.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RoomOnline proto.InternalMessageInfo
+
+func (m *RoomOnline) GetCount() int32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+func (m *RoomOnline) GetUsers() int32 {
+	if m != nil {
+		return m.Users
+	}
+	return 0
+}
+
+type OnlineRoomReq struct {
+	Type                 string   `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Rooms                []string `protobuf:"bytes,2,rep,name=rooms,proto3" json:"rooms,omitempty"`
+	App                  string   `protobuf:"bytes,3,opt,name=app,proto3" json:"app,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *OnlineRoomReq) Reset()         { *m = OnlineRoomReq{} }
+func (m *OnlineRoomReq) String() string { return proto.CompactTextString(m) }
+func (*OnlineRoomReq) ProtoMessage()    {}
+func (*OnlineRoomReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{33}
+}
+
+func (m *OnlineRoomReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OnlineRoomReq.Unmarshal(m, b)
+}
+func (m *OnlineRoomReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OnlineRoomReq.Marshal(b, m, deterministic)
+}
+func (m *OnlineRoomReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OnlineRoomReq.Merge(m, src)
+}
+func (m *OnlineRoomReq) XXX_Size() int {
+	return xxx_messageInfo_OnlineRoomReq.Size(m)
+}
+func (m *OnlineRoomReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_OnlineRoomReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OnlineRoomReq proto.InternalMessageInfo
+
+func (m *OnlineRoomReq) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *OnlineRoomReq) GetRooms() []string {
+	if m != nil {
+		return m.Rooms
+	}
+	return nil
+}
+
+func (m *OnlineRoomReq) GetApp() string {
+	if m != nil {
+		return m.App
+	}
+	return ""
+}
+
+type OnlineRoomReply struct {
+	Rooms                map[string]*RoomOnline `protobuf:"bytes,1,rep,name=rooms,proto3" json:"rooms,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}               `json:"-"`
+	XXX_unrecognized     []byte                 `json:"-"`
+	XXX_sizecache        int32                  `json:"-"`
+}
+
+func (m *OnlineRoomReply) Reset()         { *m = OnlineRoomReply{} }
+func (m *OnlineRoomReply) String() string { return proto.CompactTextString(m) }
+func (*OnlineRoomReply) ProtoMessage()    {}
+func (*OnlineRoomReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{34}
+}
+
+func (m *OnlineRoomReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OnlineRoomReply.Unmarshal(m, b)
+}
+func (m *OnlineRoomReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OnlineRoomReply.Marshal(b, m, deterministic)
+}
+func (m *OnlineRoomReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OnlineRoomReply.Merge(m, src)
+}
+func (m *OnlineRoomReply) XXX_Size() int {
+	return xxx_messageInfo_OnlineRoomReply.Size(m)
+}
+func (m *OnlineRoomReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_OnlineRoomReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OnlineRoomReply proto.InternalMessageInfo
+
+func (m *OnlineRoomReply) GetRooms() map[string]*RoomOnline {
+	if m != nil {
+		return m.Rooms
+	}
+	return nil
+}
+
+type RoomMembersReq struct {
+	Type                 string   `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Room                 string   `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	Cursor               int64    `protobuf:"varint,3,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	Size                 int32    `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	App                  string   `protobuf:"bytes,5,opt,name=app,proto3" json:"app,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RoomMembersReq) Reset()         { *m = RoomMembersReq{} }
+func (m *RoomMembersReq) String() string { return proto.CompactTextString(m) }
+func (*RoomMembersReq) ProtoMessage()    {}
+func (*RoomMembersReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{35}
+}
+
+func (m *RoomMembersReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RoomMembersReq.Unmarshal(m, b)
+}
+func (m *RoomMembersReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RoomMembersReq.Marshal(b, m, deterministic)
+}
+func (m *RoomMembersReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RoomMembersReq.Merge(m, src)
+}
+func (m *RoomMembersReq) XXX_Size() int {
+	return xxx_messageInfo_RoomMembersReq.Size(m)
+}
+func (m *RoomMembersReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_RoomMembersReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RoomMembersReq proto.InternalMessageInfo
+
+func (m *RoomMembersReq) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *RoomMembersReq) GetRoom() string {
+	if m != nil {
+		return m.Room
+	}
+	return ""
+}
+
+func (m *RoomMembersReq) GetCursor() int64 {
+	if m != nil {
+		return m.Cursor
+	}
+	return 0
+}
+
+func (m *RoomMembersReq) GetSize() int32 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *RoomMembersReq) GetApp() string {
+	if m != nil {
+		return m.App
+	}
+	return ""
+}
+
+type RoomMembersReply struct {
+	Mids []int64 `protobuf:"varint,1,rep,packed,name=mids,proto3" json:"mids,omitempty"`
+	// the cursor of the next page, zero if there is no more
+	Next                 int64    `protobuf:"varint,2,opt,name=next,proto3" json:"next,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RoomMembersReply) Reset()         { *m = RoomMembersReply{} }
+func (m *RoomMembersReply) String() string { return proto.CompactTextString(m) }
+func (*RoomMembersReply) ProtoMessage()    {}
+func (*RoomMembersReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{36}
+}
+
+func (m *RoomMembersReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RoomMembersReply.Unmarshal(m, b)
+}
+func (m *RoomMembersReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RoomMembersReply.Marshal(b, m, deterministic)
+}
+func (m *RoomMembersReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RoomMembersReply.Merge(m, src)
+}
+func (m *RoomMembersReply) XXX_Size() int {
+	return xxx_messageInfo_RoomMembersReply.Size(m)
+}
+func (m *RoomMembersReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_RoomMembersReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RoomMembersReply proto.InternalMessageInfo
+
+func (m *RoomMembersReply) GetMids() []int64 {
+	if m != nil {
+		return m.Mids
+	}
+	return nil
+}
+
+func (m *RoomMembersReply) GetNext() int64 {
+	if m != nil {
+		return m.Next
+	}
+	return 0
+}
+
+type OnlineAppReq struct {
+	App                  string   `protobuf:"bytes,1,opt,name=app,proto3" json:"app,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *OnlineAppReq) Reset()         { *m = OnlineAppReq{} }
+func (m *OnlineAppReq) String() string { return proto.CompactTextString(m) }
+func (*OnlineAppReq) ProtoMessage()    {}
+func (*OnlineAppReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{37}
+}
+
+func (m *OnlineAppReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OnlineAppReq.Unmarshal(m, b)
+}
+func (m *OnlineAppReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OnlineAppReq.Marshal(b, m, deterministic)
+}
+func (m *OnlineAppReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OnlineAppReq.Merge(m, src)
+}
+func (m *OnlineAppReq) XXX_Size() int {
+	return xxx_messageInfo_OnlineAppReq.Size(m)
+}
+func (m *OnlineAppReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_OnlineAppReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OnlineAppReq proto.InternalMessageInfo
+
+func (m *OnlineAppReq) GetApp() string {
+	if m != nil {
+		return m.App
+	}
+	return ""
+}
+
+type OnlineAppReply struct {
+	Conns                int32    `protobuf:"varint,1,opt,name=conns,proto3" json:"conns,omitempty"`
+	Rooms                int32    `protobuf:"varint,2,opt,name=rooms,proto3" json:"rooms,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *OnlineAppReply) Reset()         { *m = OnlineAppReply{} }
+func (m *OnlineAppReply) String() string { return proto.CompactTextString(m) }
+func (*OnlineAppReply) ProtoMessage()    {}
+func (*OnlineAppReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{38}
+}
+
+func (m *OnlineAppReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OnlineAppReply.Unmarshal(m, b)
+}
+func (m *OnlineAppReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OnlineAppReply.Marshal(b, m, deterministic)
+}
+func (m *OnlineAppReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OnlineAppReply.Merge(m, src)
+}
+func (m *OnlineAppReply) XXX_Size() int {
+	return xxx_messageInfo_OnlineAppReply.Size(m)
+}
+func (m *OnlineAppReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_OnlineAppReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OnlineAppReply proto.InternalMessageInfo
+
+func (m *OnlineAppReply) GetConns() int32 {
+	if m != nil {
+		return m.Conns
+	}
+	return 0
+}
+
+func (m *OnlineAppReply) GetRooms() int32 {
+	if m != nil {
+		return m.Rooms
+	}
+	return 0
+}
+
+type OnlineTotalReq struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *OnlineTotalReq) Reset()         { *m = OnlineTotalReq{} }
+func (m *OnlineTotalReq) String() string { return proto.CompactTextString(m) }
+func (*OnlineTotalReq) ProtoMessage()    {}
+func (*OnlineTotalReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{39}
+}
+
+func (m *OnlineTotalReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OnlineTotalReq.Unmarshal(m, b)
+}
+func (m *OnlineTotalReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OnlineTotalReq.Marshal(b, m, deterministic)
+}
+func (m *OnlineTotalReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OnlineTotalReq.Merge(m, src)
+}
+func (m *OnlineTotalReq) XXX_Size() int {
+	return xxx_messageInfo_OnlineTotalReq.Size(m)
+}
+func (m *OnlineTotalReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_OnlineTotalReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OnlineTotalReq proto.InternalMessageInfo
+
+type OnlineTotalReply struct {
+	IpCount              int64    `protobuf:"varint,1,opt,name=ipCount,proto3" json:"ipCount,omitempty"`
+	ConnCount            int64    `protobuf:"varint,2,opt,name=connCount,proto3" json:"connCount,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *OnlineTotalReply) Reset()         { *m = OnlineTotalReply{} }
+func (m *OnlineTotalReply) String() string { return proto.CompactTextString(m) }
+func (*OnlineTotalReply) ProtoMessage()    {}
+func (*OnlineTotalReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2dfb3aef05fe3328, []int{40}
+}
+
+func (m *OnlineTotalReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OnlineTotalReply.Unmarshal(m, b)
+}
+func (m *OnlineTotalReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OnlineTotalReply.Marshal(b, m, deterministic)
+}
+func (m *OnlineTotalReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OnlineTotalReply.Merge(m, src)
+}
+func (m *OnlineTotalReply) XXX_Size() int {
+	return xxx_messageInfo_OnlineTotalReply.Size(m)
+}
+func (m *OnlineTotalReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_OnlineTotalReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OnlineTotalReply proto.InternalMessageInfo
+
+func (m *OnlineTotalReply) GetIpCount() int64 {
+	if m != nil {
+		return m.IpCount
+	}
+	return 0
+}
+
+func (m *OnlineTotalReply) GetConnCount() int64 {
+	if m != nil {
+		return m.ConnCount
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterEnum("goim.logic.PushMsg_Type", PushMsg_Type_name, PushMsg_Type_value)
+	proto.RegisterType((*PushMsg)(nil), "goim.logic.PushMsg")
+	proto.RegisterType((*ConnectReq)(nil), "goim.logic.ConnectReq")
+	proto.RegisterType((*ConnectReply)(nil), "goim.logic.ConnectReply")
+	proto.RegisterType((*DisconnectReq)(nil), "goim.logic.DisconnectReq")
+	proto.RegisterType((*DisconnectReply)(nil), "goim.logic.DisconnectReply")
+	proto.RegisterType((*HeartbeatReq)(nil), "goim.logic.HeartbeatReq")
+	proto.RegisterType((*HeartbeatReply)(nil), "goim.logic.HeartbeatReply")
+	proto.RegisterType((*ReauthReq)(nil), "goim.logic.ReauthReq")
+	proto.RegisterType((*ReauthReply)(nil), "goim.logic.ReauthReply")
+	proto.RegisterType((*RoomUsers)(nil), "goim.logic.RoomUsers")
+	proto.RegisterType((*OnlineReq)(nil), "goim.logic.OnlineReq")
+	proto.RegisterMapType((map[string]int32)(nil), "goim.logic.OnlineReq.AppConnsEntry")
+	proto.RegisterMapType((map[string]int32)(nil), "goim.logic.OnlineReq.RoomCountEntry")
+	proto.RegisterMapType((map[string]*RoomUsers)(nil), "goim.logic.OnlineReq.RoomUsersEntry")
+	proto.RegisterType((*OnlineReply)(nil), "goim.logic.OnlineReply")
+	proto.RegisterMapType((map[string]int32)(nil), "goim.logic.OnlineReply.AllRoomCountEntry")
+	proto.RegisterType((*ReceiveReq)(nil), "goim.logic.ReceiveReq")
+	proto.RegisterType((*ReceiveReply)(nil), "goim.logic.ReceiveReply")
+	proto.RegisterType((*PresenceEvent)(nil), "goim.logic.PresenceEvent")
+	proto.RegisterType((*PresenceReq)(nil), "goim.logic.PresenceReq")
+	proto.RegisterType((*PresenceReply)(nil), "goim.logic.PresenceReply")
+	proto.RegisterType((*NodesReq)(nil), "goim.logic.NodesReq")
+	proto.RegisterType((*NodesReply)(nil), "goim.logic.NodesReply")
+	proto.RegisterType((*Backoff)(nil), "goim.logic.Backoff")
+	proto.RegisterType((*KeyResult)(nil), "goim.logic.KeyResult")
+	proto.RegisterType((*MidResult)(nil), "goim.logic.MidResult")
+	proto.RegisterType((*PushKeysReq)(nil), "goim.logic.PushKeysReq")
+	proto.RegisterType((*PushKeysReply)(nil), "goim.logic.PushKeysReply")
+	proto.RegisterType((*PushMidsReq)(nil), "goim.logic.PushMidsReq")
+	proto.RegisterType((*PushMidsReply)(nil), "goim.logic.PushMidsReply")
+	proto.RegisterType((*PushRoomReq)(nil), "goim.logic.PushRoomReq")
+	proto.RegisterType((*PushRoomReply)(nil), "goim.logic.PushRoomReply")
+	proto.RegisterType((*PushAllReq)(nil), "goim.logic.PushAllReq")
+	proto.RegisterType((*PushAllReply)(nil), "goim.logic.PushAllReply")
+	proto.RegisterType((*OnlineMidsReq)(nil), "goim.logic.OnlineMidsReq")
+	proto.RegisterType((*OnlineMidsReply)(nil), "goim.logic.OnlineMidsReply")
+	proto.RegisterType((*RoomOnline)(nil), "goim.logic.RoomOnline")
+	proto.RegisterType((*OnlineRoomReq)(nil), "goim.logic.OnlineRoomReq")
+	proto.RegisterType((*OnlineRoomReply)(nil), "goim.logic.OnlineRoomReply")
+	proto.RegisterMapType((map[string]*RoomOnline)(nil), "goim.logic.OnlineRoomReply.RoomsEntry")
+	proto.RegisterType((*RoomMembersReq)(nil), "goim.logic.RoomMembersReq")
+	proto.RegisterType((*RoomMembersReply)(nil), "goim.logic.RoomMembersReply")
+	proto.RegisterType((*OnlineAppReq)(nil), "goim.logic.OnlineAppReq")
+	proto.RegisterType((*OnlineAppReply)(nil), "goim.logic.OnlineAppReply")
+	proto.RegisterType((*OnlineTotalReq)(nil), "goim.logic.OnlineTotalReq")
+	proto.RegisterType((*OnlineTotalReply)(nil), "goim.logic.OnlineTotalReply")
+}
+
+func init() { proto.RegisterFile("logic/logic.proto", fileDescriptor_2dfb3aef05fe3328) }
 
 var fileDescriptor_2dfb3aef05fe3328 = []byte{
-	// 893 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x55, 0x4f, 0x6f, 0xe3, 0x44,
-	0x14, 0xc7, 0x71, 0x9c, 0xc4, 0x2f, 0x69, 0xc9, 0x0e, 0x25, 0xeb, 0x7a, 0x41, 0x8a, 0xbc, 0x1c,
-	0x52, 0x60, 0x13, 0x29, 0x68, 0x25, 0xc4, 0x82, 0x50, 0xd3, 0x20, 0xed, 0x2e, 0x84, 0x46, 0xb3,
-	0xe5, 0xc2, 0xa5, 0x9a, 0x38, 0xd3, 0xd4, 0xc4, 0xf6, 0x18, 0x7b, 0xd2, 0xd4, 0x37, 0xc4, 0xf7,
-	0xe0, 0xc8, 0x77, 0xe3, 0x3b, 0x70, 0x41, 0xf3, 0x27, 0xb6, 0xa3, 0x4d, 0x57, 0x20, 0x2e, 0xd6,
-	0xfb, 0x37, 0xbf, 0xf9, 0xbd, 0xf7, 0xe6, 0x3d, 0xc3, 0xa3, 0x90, 0xad, 0x02, 0x7f, 0x24, 0xbf,
-	0xc3, 0x24, 0x65, 0x9c, 0x21, 0x58, 0xb1, 0x20, 0x1a, 0x4a, 0x8b, 0xfb, 0x7c, 0x15, 0xf0, 0xdb,
-	0xcd, 0x62, 0xe8, 0xb3, 0x68, 0x74, 0x45, 0xd3, 0x34, 0x7f, 0x36, 0x23, 0x6c, 0x24, 0x02, 0x46,
-	0x24, 0x09, 0x46, 0xf2, 0x80, 0xcf, 0xc2, 0x42, 0x50, 0x10, 0xde, 0x5f, 0x06, 0x34, 0xe7, 0x9b,
-	0xec, 0x76, 0x96, 0xad, 0xd0, 0xe7, 0x50, 0xe7, 0x79, 0x42, 0x1d, 0xa3, 0x6f, 0x0c, 0x8e, 0xc7,
-	0xce, 0xb0, 0x44, 0x1f, 0xea, 0x90, 0xe1, 0x55, 0x9e, 0x50, 0x2c, 0xa3, 0xd0, 0x47, 0x60, 0xb3,
-	0x84, 0xa6, 0x84, 0x07, 0x2c, 0x76, 0x6a, 0x7d, 0x63, 0x60, 0xe1, 0xd2, 0x80, 0x4e, 0xc0, 0xca,
-	0x12, 0x4a, 0x97, 0x8e, 0x29, 0x3d, 0x4a, 0x41, 0x3d, 0x68, 0x64, 0x34, 0xbd, 0xa3, 0xa9, 0x53,
-	0xef, 0x1b, 0x03, 0x1b, 0x6b, 0x0d, 0x21, 0xa8, 0xa7, 0x8c, 0x45, 0x8e, 0x25, 0xad, 0x52, 0x16,
-	0xb6, 0x35, 0xcd, 0x33, 0xa7, 0xd1, 0x37, 0x85, 0x4d, 0xc8, 0xa8, 0x0b, 0x66, 0x94, 0xad, 0x9c,
-	0x66, 0xdf, 0x18, 0x74, 0xb0, 0x10, 0xbd, 0x33, 0xa8, 0x0b, 0x4e, 0xa8, 0x05, 0xf5, 0xf9, 0x4f,
-	0x6f, 0x5e, 0x76, 0xdf, 0x13, 0x12, 0xbe, 0xbc, 0x9c, 0x75, 0x0d, 0x74, 0x04, 0xf6, 0x04, 0x5f,
-	0x9e, 0x4f, 0x2f, 0xce, 0xdf, 0x5c, 0x75, 0x6b, 0x1e, 0x06, 0xb8, 0x60, 0x71, 0x4c, 0x7d, 0x8e,
-	0xe9, 0xaf, 0x15, 0x2a, 0xc6, 0x1e, 0x95, 0x1e, 0x34, 0x7c, 0xc6, 0xd6, 0x01, 0x95, 0x39, 0xd9,
-	0x58, 0x6b, 0x22, 0x21, 0xce, 0xd6, 0x34, 0x96, 0x09, 0x75, 0xb0, 0x52, 0xbc, 0xdf, 0x0d, 0xe8,
-	0x14, 0xa0, 0x49, 0x98, 0x4b, 0x86, 0xc1, 0x52, 0x62, 0x9a, 0x58, 0x88, 0xc2, 0xb2, 0xa6, 0xb9,
-	0x46, 0x13, 0xa2, 0xb8, 0x42, 0x64, 0xf8, 0x6a, 0x2a, 0xb1, 0x6c, 0xac, 0x35, 0xe4, 0x40, 0x93,
-	0xf8, 0x3e, 0x4d, 0x78, 0xe6, 0xd4, 0xfb, 0xe6, 0xc0, 0xc2, 0x3b, 0x55, 0xd4, 0xfa, 0x96, 0x92,
-	0x94, 0x2f, 0x28, 0xe1, 0xb2, 0x48, 0x26, 0x2e, 0x0d, 0xde, 0xf7, 0x70, 0x34, 0x0d, 0x32, 0xbf,
-	0xcc, 0xed, 0x5f, 0x92, 0xd0, 0xf9, 0x9b, 0xd5, 0xfc, 0xbd, 0xa7, 0xf0, 0x7e, 0x15, 0x4c, 0xe7,
-	0x74, 0x4b, 0x32, 0x09, 0xd7, 0xc2, 0x42, 0xf4, 0x5e, 0x43, 0xe7, 0xe5, 0xee, 0xfa, 0xff, 0x7b,
-	0x61, 0x17, 0x8e, 0x2b, 0x58, 0x49, 0x98, 0x7b, 0x7f, 0x1a, 0x60, 0x5f, 0xc6, 0x61, 0x10, 0xd3,
-	0x77, 0x35, 0x6a, 0x02, 0xb6, 0xa8, 0xdb, 0x05, 0xdb, 0xc4, 0xdc, 0xa9, 0xf5, 0xcd, 0x41, 0x7b,
-	0xfc, 0x49, 0xf5, 0xc9, 0x16, 0x08, 0x43, 0xbc, 0x0b, 0xfb, 0x2e, 0xe6, 0x69, 0x8e, 0xcb, 0x63,
-	0xee, 0xd7, 0x70, 0xbc, 0xef, 0xdc, 0xf1, 0x36, 0x4a, 0xde, 0x27, 0x60, 0xdd, 0x91, 0x70, 0x43,
-	0xf5, 0x1b, 0x57, 0xca, 0x57, 0xb5, 0x2f, 0x0d, 0xef, 0x0f, 0x03, 0xda, 0xbb, 0x5b, 0x44, 0x9d,
-	0x66, 0xd0, 0x21, 0x61, 0x58, 0x00, 0x3a, 0x86, 0x24, 0x75, 0x76, 0x88, 0x54, 0x12, 0xe6, 0xc3,
-	0xf3, 0x4a, 0xac, 0x62, 0xb6, 0x77, 0xdc, 0xfd, 0x16, 0x1e, 0xbd, 0x15, 0xf2, 0x9f, 0xf8, 0xbd,
-	0x06, 0xc0, 0xd4, 0xa7, 0xc1, 0x1d, 0x3d, 0xdc, 0xa3, 0x4f, 0xc1, 0x92, 0x4b, 0x40, 0x9e, 0x6c,
-	0x8f, 0x4f, 0x14, 0xd1, 0x62, 0x41, 0xcc, 0x85, 0x80, 0x55, 0x88, 0x77, 0x0c, 0x9d, 0x02, 0x4b,
-	0xf4, 0x68, 0x02, 0xad, 0x1f, 0xd9, 0x92, 0x66, 0x02, 0xd9, 0x85, 0x56, 0x12, 0x12, 0x7e, 0xc3,
-	0xd2, 0x48, 0x13, 0x2b, 0x74, 0xe1, 0xf3, 0xc3, 0x80, 0xc6, 0xfc, 0xd5, 0x5c, 0x3f, 0x86, 0x42,
-	0xf7, 0xfe, 0x36, 0x00, 0x34, 0x88, 0x28, 0x5f, 0x0f, 0x1a, 0x4b, 0x16, 0x91, 0x20, 0xde, 0x35,
-	0x5a, 0x69, 0xe8, 0x14, 0x5a, 0xdc, 0x4f, 0xae, 0x13, 0x96, 0x72, 0x9d, 0x63, 0x93, 0xfb, 0xc9,
-	0x9c, 0xa5, 0x1c, 0x3d, 0x86, 0xe6, 0x36, 0x53, 0x1e, 0xb5, 0x67, 0x1a, 0xdb, 0x4c, 0x3a, 0x4e,
-	0xa1, 0xb5, 0xcd, 0xb4, 0xa7, 0xae, 0xce, 0x6c, 0x33, 0xe5, 0x7a, 0x6b, 0x96, 0xac, 0xca, 0x2c,
-	0x89, 0x6a, 0xc6, 0x82, 0x92, 0x5e, 0x3b, 0x4a, 0x41, 0xcf, 0xa0, 0xb9, 0x20, 0xfe, 0x9a, 0xdd,
-	0xdc, 0xc8, 0xdd, 0xd3, 0x1e, 0x7f, 0x50, 0x6d, 0xea, 0x44, 0xb9, 0xf0, 0x2e, 0x06, 0x3d, 0x85,
-	0xa3, 0x02, 0xf1, 0x3a, 0x22, 0xf7, 0x4e, 0x4b, 0x5e, 0xd3, 0x29, 0x8c, 0x33, 0x72, 0xef, 0x6d,
-	0xa0, 0xa9, 0x0f, 0xa2, 0x27, 0x60, 0x47, 0xe4, 0xfe, 0x7a, 0x49, 0x43, 0xa2, 0x5a, 0x6b, 0xe1,
-	0x56, 0x44, 0xee, 0xa7, 0x42, 0x47, 0x1f, 0x03, 0x2c, 0x48, 0x46, 0xb5, 0x57, 0x2f, 0x5a, 0x61,
-	0x51, 0xee, 0x1e, 0x34, 0x6e, 0x88, 0xcf, 0x99, 0x1a, 0xab, 0x1a, 0xd6, 0x9a, 0xb0, 0xff, 0x12,
-	0x70, 0xae, 0x57, 0x6d, 0x0d, 0x6b, 0x6d, 0xfc, 0x9b, 0x09, 0xd6, 0x0f, 0x82, 0x36, 0x7a, 0x01,
-	0x4d, 0xbd, 0xba, 0x50, 0xaf, 0x9a, 0x4e, 0xb9, 0x24, 0x5d, 0xe7, 0xa0, 0x5d, 0x34, 0x6b, 0x0a,
-	0x50, 0xae, 0x09, 0x74, 0x5a, 0x8d, 0xdb, 0xdb, 0x45, 0xee, 0x93, 0x87, 0x5c, 0x02, 0xe5, 0x1c,
-	0xec, 0x62, 0xf6, 0xd1, 0xde, 0x65, 0xd5, 0xf5, 0xe2, 0xba, 0x0f, 0x78, 0x04, 0xc4, 0x37, 0xd0,
-	0xc6, 0x34, 0xa6, 0x5b, 0x35, 0x59, 0xe8, 0xc3, 0x83, 0x2b, 0xc0, 0x7d, 0xfc, 0xc0, 0x10, 0x8a,
-	0x22, 0xe8, 0x77, 0xbd, 0x5f, 0x84, 0x72, 0x70, 0xf6, 0x8b, 0x50, 0x1d, 0x02, 0xf4, 0x1c, 0x2c,
-	0xf9, 0x7e, 0xd1, 0x49, 0x35, 0x64, 0x37, 0x17, 0x6e, 0xef, 0x80, 0x35, 0x09, 0xf3, 0xc9, 0x67,
-	0x3f, 0x9f, 0xbd, 0xfb, 0x67, 0x2d, 0x4f, 0xbc, 0x90, 0xdf, 0x45, 0x43, 0xce, 0xdf, 0x17, 0xff,
-	0x04, 0x00, 0x00, 0xff, 0xff, 0x6f, 0x34, 0x54, 0x90, 0xff, 0x07, 0x00, 0x00,
+	// 1767 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x58, 0xef, 0x6e, 0xdb, 0xc8,
+	0x11, 0x2f, 0x49, 0x51, 0x7f, 0x46, 0xb2, 0xac, 0x63, 0x7d, 0x0e, 0xc5, 0xa4, 0x80, 0xc0, 0xf4,
+	0x0a, 0xa7, 0x77, 0x27, 0xa3, 0x2e, 0x82, 0x06, 0x97, 0xb4, 0x57, 0x3b, 0x3e, 0xf4, 0x2e, 0xa9,
+	0x1a, 0x63, 0x93, 0x14, 0x45, 0x81, 0x22, 0xa5, 0xa9, 0xb5, 0xcd, 0x33, 0x45, 0xb2, 0x24, 0x65,
+	0x5b, 0xfd, 0xde, 0xc7, 0xe8, 0x87, 0xbe, 0x42, 0x1f, 0xa1, 0xcf, 0xd2, 0xa7, 0xb8, 0x7e, 0x29,
+	0x66, 0xff, 0x70, 0x97, 0x16, 0xe5, 0xbb, 0x20, 0x5f, 0x84, 0x9d, 0x99, 0x9d, 0x3f, 0x3b, 0x3b,
+	0x3b, 0xf3, 0xa3, 0xe0, 0xa3, 0x38, 0x3d, 0x8f, 0xc2, 0x7d, 0xf6, 0x3b, 0xcd, 0xf2, 0xb4, 0x4c,
+	0x1d, 0x38, 0x4f, 0xa3, 0xc5, 0x94, 0x71, 0xbc, 0xc7, 0xe7, 0x51, 0x79, 0xb1, 0x3c, 0x9d, 0x86,
+	0xe9, 0x62, 0xff, 0x0d, 0xcd, 0xf3, 0xd5, 0xe7, 0xb3, 0x20, 0xdd, 0xc7, 0x0d, 0xfb, 0x41, 0x16,
+	0xed, 0x33, 0x85, 0x30, 0x8d, 0xab, 0x05, 0x37, 0xe1, 0xff, 0xdb, 0x84, 0xce, 0xc9, 0xb2, 0xb8,
+	0x98, 0x15, 0xe7, 0xce, 0x67, 0xd0, 0x2a, 0x57, 0x19, 0x75, 0x8d, 0x89, 0xb1, 0x37, 0x3c, 0x70,
+	0xa7, 0xca, 0xfa, 0x54, 0x6c, 0x99, 0xbe, 0x59, 0x65, 0x94, 0xb0, 0x5d, 0xce, 0x03, 0xe8, 0xa5,
+	0x19, 0xcd, 0x83, 0x32, 0x4a, 0x13, 0xd7, 0x9c, 0x18, 0x7b, 0x36, 0x51, 0x0c, 0x67, 0x07, 0xec,
+	0x22, 0xa3, 0x74, 0xee, 0x5a, 0x4c, 0xc2, 0x09, 0x67, 0x17, 0xda, 0x05, 0xcd, 0xaf, 0x68, 0xee,
+	0xb6, 0x26, 0xc6, 0x5e, 0x8f, 0x08, 0xca, 0x71, 0xa0, 0x95, 0xa7, 0xe9, 0xc2, 0xb5, 0x19, 0x97,
+	0xad, 0x91, 0x77, 0x49, 0x57, 0x85, 0xdb, 0x9e, 0x58, 0xc8, 0xc3, 0xb5, 0x33, 0x02, 0x6b, 0x51,
+	0x9c, 0xbb, 0x9d, 0x89, 0xb1, 0x37, 0x20, 0xb8, 0x74, 0x3c, 0xe8, 0x66, 0x71, 0x50, 0x9e, 0xa5,
+	0xf9, 0xc2, 0xed, 0x32, 0xed, 0x8a, 0x66, 0xb2, 0x3c, 0x4a, 0xf3, 0xa8, 0x5c, 0xb9, 0x3d, 0x16,
+	0x46, 0x45, 0xa3, 0xa5, 0x20, 0xcb, 0x5c, 0x60, 0x2a, 0xb8, 0xf4, 0x1f, 0x41, 0x0b, 0x4f, 0xe7,
+	0x74, 0xa1, 0x75, 0xf2, 0xf6, 0xf5, 0xd7, 0xa3, 0x1f, 0xe1, 0x8a, 0xbc, 0x7a, 0x35, 0x1b, 0x19,
+	0xce, 0x16, 0xf4, 0x8e, 0xc8, 0xab, 0xc3, 0xe3, 0xe7, 0x87, 0xaf, 0xdf, 0x8c, 0x4c, 0xff, 0x14,
+	0xe0, 0x79, 0x9a, 0x24, 0x34, 0x2c, 0x09, 0xfd, 0x9b, 0x76, 0x28, 0xa3, 0x76, 0xa8, 0x5d, 0x68,
+	0x87, 0x69, 0x7a, 0x19, 0x51, 0x96, 0x9d, 0x1e, 0x11, 0x14, 0xa6, 0xa6, 0x4c, 0x2f, 0x69, 0xc2,
+	0x52, 0x33, 0x20, 0x9c, 0x70, 0x86, 0x60, 0x46, 0x99, 0x48, 0x8b, 0x19, 0x65, 0xfe, 0x77, 0x06,
+	0x0c, 0x2a, 0x27, 0x59, 0xcc, 0x22, 0x5e, 0x44, 0x73, 0xe6, 0xc3, 0x22, 0xb8, 0x44, 0xce, 0x25,
+	0x5d, 0x09, 0xeb, 0xb8, 0x44, 0x97, 0x98, 0xbb, 0x6f, 0x8e, 0x99, 0xed, 0x1e, 0x11, 0x94, 0xe3,
+	0x42, 0x27, 0x08, 0x43, 0x9a, 0x95, 0x85, 0xdb, 0x9a, 0x58, 0x7b, 0x36, 0x91, 0x24, 0xde, 0xe2,
+	0x05, 0x0d, 0xf2, 0xf2, 0x94, 0x06, 0x25, 0x4b, 0xbf, 0x45, 0x14, 0xa3, 0x96, 0xdd, 0xf6, 0xad,
+	0xec, 0xa2, 0x2f, 0x5a, 0x2c, 0x17, 0xd4, 0xed, 0x08, 0x5f, 0x8c, 0x42, 0x5f, 0x7c, 0x35, 0x67,
+	0x17, 0xd2, 0x25, 0x92, 0x44, 0x0d, 0x7a, 0x93, 0x45, 0x39, 0x65, 0xb7, 0x61, 0x11, 0x41, 0x35,
+	0xdc, 0xc5, 0x4b, 0xd8, 0x3a, 0x8e, 0x8a, 0x50, 0xe5, 0xf8, 0x07, 0x1e, 0x5e, 0xdc, 0x83, 0xa5,
+	0xdf, 0x83, 0xff, 0x10, 0xb6, 0x75, 0x63, 0x22, 0x97, 0x17, 0x41, 0xc1, 0xcc, 0x75, 0x09, 0x2e,
+	0xfd, 0x17, 0x30, 0xf8, 0x5a, 0x1e, 0xfb, 0x43, 0x1d, 0x8e, 0x60, 0xa8, 0xd9, 0xca, 0xe2, 0x95,
+	0xff, 0x17, 0xe8, 0x11, 0x1a, 0x2c, 0xcb, 0x8b, 0x0f, 0x34, 0xad, 0x6a, 0xa7, 0xa5, 0xd5, 0x8e,
+	0xff, 0x09, 0xf4, 0xa5, 0x79, 0x3c, 0x9d, 0xca, 0xb3, 0xa1, 0xe7, 0xd9, 0xff, 0x15, 0xf4, 0x48,
+	0x9a, 0x2e, 0xde, 0x16, 0x34, 0x2f, 0xf0, 0x79, 0x2d, 0xa2, 0x39, 0xcf, 0xc1, 0x80, 0xb0, 0x35,
+	0x2a, 0x9e, 0x2f, 0x69, 0x51, 0x16, 0xe2, 0x3d, 0x0b, 0xca, 0xff, 0x8f, 0x05, 0xbd, 0x57, 0x49,
+	0x1c, 0x25, 0xf4, 0xae, 0x7a, 0x3f, 0x82, 0x1e, 0x96, 0xdb, 0xf3, 0x74, 0x99, 0x94, 0xae, 0x39,
+	0xb1, 0xf6, 0xfa, 0x07, 0x3f, 0xd5, 0x7b, 0x48, 0x65, 0x61, 0x4a, 0xe4, 0xb6, 0xaf, 0x92, 0x32,
+	0x5f, 0x11, 0xa5, 0x26, 0x6d, 0xb0, 0x10, 0x5d, 0xfb, 0xfb, 0x6c, 0xb0, 0x6d, 0x9a, 0x0d, 0x7e,
+	0xb2, 0x2f, 0xa1, 0x1b, 0x64, 0x19, 0xbe, 0x1d, 0x5e, 0xed, 0xfd, 0x83, 0x87, 0xcd, 0x26, 0x0e,
+	0xc5, 0x2e, 0x6e, 0xa1, 0x52, 0xf2, 0x9e, 0xc1, 0xb0, 0x1e, 0xa1, 0xbc, 0x20, 0x43, 0x5d, 0xd0,
+	0x0e, 0xd8, 0x57, 0x41, 0xbc, 0xa4, 0x22, 0x53, 0x9c, 0xf8, 0xc2, 0x7c, 0x62, 0x78, 0xaf, 0xb9,
+	0xb6, 0x8a, 0xad, 0x41, 0xfb, 0x53, 0x5d, 0xbb, 0x7f, 0xf0, 0xb1, 0x1e, 0x5f, 0xa5, 0xac, 0x1b,
+	0x7d, 0x0a, 0x5b, 0xb5, 0x68, 0xdf, 0x27, 0xa2, 0x17, 0xad, 0xae, 0x35, 0x6a, 0xf9, 0xff, 0x34,
+	0xa0, 0x2f, 0xcf, 0x8e, 0x55, 0x32, 0x83, 0x41, 0x10, 0xc7, 0xd5, 0x41, 0x5d, 0x83, 0xa5, 0xea,
+	0x51, 0x53, 0xaa, 0xb2, 0x78, 0x35, 0x3d, 0xd4, 0xf6, 0xf2, 0x84, 0xd5, 0xd4, 0xbd, 0x2f, 0xe1,
+	0xa3, 0xb5, 0x2d, 0xef, 0x13, 0xa5, 0xff, 0x02, 0x80, 0xd0, 0x90, 0x46, 0x57, 0xb4, 0xf9, 0x91,
+	0xfc, 0x1c, 0x6c, 0x36, 0xb2, 0x44, 0xce, 0x76, 0x78, 0xa0, 0xd5, 0x38, 0x3b, 0xc1, 0x05, 0xe1,
+	0x5b, 0xfc, 0x21, 0x0c, 0x2a, 0x5b, 0xf8, 0xfe, 0x66, 0xb0, 0x75, 0x92, 0xd3, 0x82, 0x26, 0x21,
+	0xfd, 0xea, 0x8a, 0x26, 0xa5, 0xd6, 0x28, 0x8d, 0x5a, 0xa3, 0x14, 0x6e, 0x4d, 0xe5, 0xd6, 0x81,
+	0xd6, 0xb7, 0x69, 0xc4, 0x9b, 0x75, 0x97, 0xb0, 0xb5, 0xff, 0x27, 0xe8, 0x4b, 0x73, 0x77, 0x3d,
+	0x88, 0x5f, 0x40, 0x9b, 0xa2, 0xb7, 0x42, 0xbc, 0x86, 0x71, 0x6d, 0xa2, 0xea, 0xf1, 0x10, 0xb1,
+	0xd1, 0xdf, 0x56, 0x81, 0xf2, 0xc8, 0x8f, 0xa0, 0xfb, 0x87, 0x74, 0x4e, 0x0b, 0xf4, 0xa3, 0x77,
+	0x63, 0x63, 0x7d, 0xd6, 0x85, 0x71, 0x44, 0x93, 0xf2, 0x9b, 0x13, 0xd1, 0x47, 0x2a, 0xda, 0xff,
+	0x9f, 0x01, 0x20, 0x8c, 0x88, 0xf6, 0x30, 0x4f, 0x17, 0x41, 0x94, 0xc8, 0x70, 0x39, 0xe5, 0x8c,
+	0xa1, 0x5b, 0x86, 0xd9, 0xbb, 0x2c, 0xcd, 0x4b, 0x71, 0x3b, 0x9d, 0x32, 0xcc, 0x4e, 0xd2, 0xbc,
+	0x74, 0xee, 0x41, 0xe7, 0xba, 0xe0, 0x12, 0x3e, 0xcf, 0xdb, 0xd7, 0x05, 0x13, 0x8c, 0xa1, 0x7b,
+	0x5d, 0x08, 0x49, 0x8b, 0xeb, 0x5c, 0x17, 0x5c, 0xb4, 0x36, 0x59, 0x6c, 0x7d, 0xb2, 0xec, 0x80,
+	0x9d, 0x60, 0x48, 0x62, 0xbc, 0x73, 0xc2, 0xf9, 0x1c, 0x3a, 0xa7, 0x41, 0x78, 0x99, 0x9e, 0x9d,
+	0xb1, 0xa1, 0xd2, 0x3f, 0xf8, 0xb1, 0x9e, 0xb2, 0x23, 0x2e, 0x22, 0x72, 0x8f, 0xf3, 0x10, 0xb6,
+	0x2a, 0x8b, 0xef, 0x16, 0xc1, 0x0d, 0x1b, 0x38, 0x36, 0x19, 0x54, 0xcc, 0x59, 0x70, 0xe3, 0x2f,
+	0xa1, 0x23, 0x14, 0x9d, 0xfb, 0xd0, 0x5b, 0x04, 0x37, 0xef, 0xe6, 0x34, 0x0e, 0x78, 0x51, 0xda,
+	0xa4, 0xbb, 0x08, 0x6e, 0x8e, 0x91, 0x76, 0x7e, 0x02, 0x70, 0x1a, 0x14, 0x54, 0x48, 0x05, 0xa0,
+	0x41, 0x0e, 0x17, 0xef, 0x42, 0xfb, 0x2c, 0x08, 0xcb, 0x94, 0x77, 0x64, 0x93, 0x08, 0x0a, 0xf9,
+	0xdf, 0x46, 0x65, 0x29, 0x20, 0x8d, 0x49, 0x04, 0xe5, 0x87, 0xd0, 0x7b, 0x49, 0x57, 0x84, 0x16,
+	0xcb, 0xb8, 0x6c, 0x78, 0x07, 0x58, 0x33, 0x65, 0x50, 0x2e, 0x0b, 0x09, 0x0e, 0x38, 0x75, 0x57,
+	0xe3, 0xa7, 0x79, 0x9e, 0x4a, 0xe0, 0xc4, 0x09, 0xff, 0xaf, 0xd0, 0x9b, 0x45, 0x73, 0xe5, 0xe4,
+	0xd6, 0x93, 0xd9, 0xe4, 0xe4, 0x91, 0x80, 0x56, 0xd6, 0xc4, 0xba, 0xdd, 0x7d, 0xaa, 0x98, 0x39,
+	0xe2, 0xf2, 0xdf, 0x42, 0x1f, 0xb1, 0xdf, 0x4b, 0xba, 0x62, 0x25, 0x38, 0x04, 0x33, 0xcd, 0x44,
+	0xea, 0xcc, 0x34, 0xab, 0x40, 0x9a, 0xb9, 0x0e, 0xd2, 0x2c, 0x05, 0xd2, 0xc4, 0x80, 0x6f, 0xa9,
+	0x01, 0xff, 0x5b, 0xd8, 0x52, 0x66, 0xb1, 0x28, 0xf7, 0x39, 0x6a, 0x88, 0xcb, 0xc2, 0x35, 0xee,
+	0x8a, 0x4a, 0xee, 0x92, 0x81, 0xcd, 0xa2, 0xf9, 0xa6, 0xc0, 0xd8, 0x78, 0xc3, 0xc0, 0x2c, 0x31,
+	0xde, 0xde, 0x23, 0x30, 0x6e, 0xf6, 0xfb, 0x03, 0xab, 0xb2, 0xaf, 0x02, 0xfb, 0x87, 0xc1, 0x23,
+	0xc3, 0x56, 0xb8, 0x21, 0x32, 0x86, 0xb2, 0xf9, 0x95, 0xb0, 0x75, 0x85, 0x7f, 0x2d, 0x0d, 0xff,
+	0xea, 0xe8, 0xb5, 0xb5, 0x8e, 0x5e, 0xf1, 0x24, 0xf6, 0xda, 0x49, 0xda, 0xea, 0x24, 0xdb, 0xb0,
+	0xa5, 0xc2, 0xc0, 0x56, 0xf2, 0x47, 0x00, 0x64, 0x60, 0x97, 0x6e, 0x08, 0xab, 0x02, 0xec, 0xa6,
+	0x0e, 0xd8, 0x7f, 0x48, 0xca, 0x86, 0x30, 0xa8, 0xec, 0xa2, 0x9f, 0xc7, 0xb0, 0xc5, 0x07, 0x87,
+	0xbc, 0x1b, 0x05, 0x35, 0x6a, 0x77, 0x81, 0x66, 0x4c, 0x65, 0xe6, 0x13, 0xd8, 0xd6, 0xd5, 0x30,
+	0xf7, 0x0d, 0x8a, 0xfe, 0x13, 0x00, 0x3c, 0x12, 0xdf, 0x8a, 0x51, 0x87, 0x62, 0x7a, 0xb1, 0xa8,
+	0x19, 0x81, 0xdc, 0x25, 0x43, 0x10, 0xe2, 0x2c, 0x8c, 0x40, 0x50, 0xc9, 0xb5, 0xe4, 0xcd, 0x38,
+	0xda, 0xf7, 0x8e, 0xbc, 0x89, 0x1d, 0xb0, 0x31, 0xfb, 0xb2, 0xa2, 0x39, 0x21, 0xa3, 0xb5, 0x54,
+	0xb4, 0xff, 0x32, 0x60, 0x5b, 0xb7, 0x86, 0xe1, 0x3e, 0x93, 0xba, 0xbc, 0x50, 0x7e, 0xd6, 0x30,
+	0x4a, 0xe5, 0x5e, 0x36, 0xe5, 0x05, 0xf0, 0xe0, 0x4a, 0xde, 0x09, 0x80, 0x62, 0x36, 0x74, 0x8c,
+	0xcf, 0xea, 0x98, 0x61, 0xf7, 0x36, 0x66, 0x10, 0x1e, 0xb4, 0x89, 0x7a, 0xc5, 0x91, 0xc8, 0x8c,
+	0x2e, 0x4e, 0x11, 0x4e, 0x6c, 0x38, 0xb1, 0xac, 0x3d, 0x53, 0xab, 0x3d, 0xfc, 0x74, 0x59, 0xe6,
+	0x85, 0x68, 0x76, 0x16, 0x11, 0x14, 0xee, 0x2d, 0xa2, 0xbf, 0x53, 0x51, 0x8f, 0x6c, 0x2d, 0x73,
+	0x63, 0xab, 0xdc, 0x7c, 0x01, 0xa3, 0x9a, 0xdf, 0x0d, 0x57, 0x89, 0xbc, 0x84, 0xde, 0x94, 0x62,
+	0xda, 0xb2, 0xb5, 0x3f, 0x81, 0x01, 0x3f, 0xc8, 0x61, 0x96, 0x09, 0x1c, 0x80, 0xd6, 0x0d, 0x65,
+	0xfd, 0x19, 0x0c, 0xb5, 0x1d, 0x68, 0x9b, 0x15, 0x01, 0xa2, 0xbd, 0xaa, 0x08, 0x92, 0xa4, 0xd0,
+	0x6f, 0x92, 0x71, 0x19, 0xe1, 0x8f, 0xa4, 0xf6, 0x9b, 0xb4, 0x0c, 0xf0, 0x21, 0xf8, 0x2f, 0x60,
+	0x54, 0xe3, 0xa0, 0x45, 0x17, 0x3a, 0x51, 0xf6, 0xbc, 0x2a, 0x2c, 0x8b, 0x48, 0x12, 0xa7, 0x1a,
+	0x9a, 0x97, 0x20, 0x17, 0x65, 0x8a, 0x71, 0xf0, 0x9d, 0x05, 0xf6, 0xef, 0xf1, 0x46, 0x9c, 0xa7,
+	0xd0, 0x11, 0x5f, 0x6f, 0x4e, 0xed, 0xa6, 0xd4, 0x77, 0xa3, 0xe7, 0x36, 0xf2, 0xd1, 0xfd, 0x31,
+	0x80, 0xfa, 0x62, 0x71, 0x6a, 0xb0, 0xa1, 0xf6, 0x59, 0xe4, 0xdd, 0xdf, 0x24, 0x42, 0x2b, 0x87,
+	0xd0, 0xab, 0x3e, 0x43, 0x9c, 0x9a, 0x33, 0xfd, 0x4b, 0xc7, 0xf3, 0x36, 0x48, 0xd0, 0xc4, 0x13,
+	0x68, 0xf3, 0x0f, 0x0b, 0xa7, 0x0e, 0x51, 0xe5, 0xb7, 0x8c, 0x77, 0xaf, 0x89, 0x8d, 0x9a, 0xbf,
+	0xc6, 0x4f, 0x92, 0x84, 0x5e, 0x8b, 0x77, 0xfa, 0x71, 0x23, 0x02, 0xf7, 0xee, 0x35, 0xb1, 0x51,
+	0xfd, 0x29, 0x74, 0x04, 0x80, 0xab, 0xa7, 0x4f, 0x21, 0x44, 0xcf, 0x6d, 0xe4, 0xa3, 0xf2, 0x6f,
+	0xa0, 0x2b, 0x41, 0x94, 0x73, 0xaf, 0x09, 0x73, 0xa1, 0xfa, 0xb8, 0x59, 0x80, 0xfa, 0x8f, 0xc1,
+	0x66, 0x70, 0xc9, 0xd9, 0xd1, 0xf7, 0x48, 0x18, 0xe6, 0xed, 0x36, 0x70, 0xb3, 0x78, 0x75, 0xf0,
+	0xdf, 0x16, 0xb4, 0xb0, 0x11, 0x32, 0xff, 0x62, 0xb8, 0xdd, 0xf2, 0xaf, 0x26, 0xa9, 0x37, 0x6e,
+	0x16, 0xc8, 0xf8, 0xc5, 0x0c, 0x5a, 0xd7, 0x17, 0x4d, 0xd5, 0x1b, 0x37, 0x0b, 0x34, 0x7d, 0x7c,
+	0x83, 0xeb, 0xfa, 0xa2, 0xf9, 0x79, 0xe3, 0x66, 0x81, 0x48, 0xbe, 0x68, 0xe8, 0xf5, 0xe4, 0xab,
+	0xe9, 0xe1, 0xb9, 0x8d, 0x7c, 0x51, 0xbb, 0xaa, 0x8d, 0xd7, 0x6b, 0xb7, 0x36, 0x15, 0xbc, 0xfb,
+	0x9b, 0x44, 0x35, 0x2b, 0xec, 0x10, 0xe3, 0x4d, 0x9d, 0xb4, 0xd1, 0x8a, 0x3a, 0xc8, 0xef, 0xa0,
+	0xaf, 0x35, 0x22, 0xc7, 0xbb, 0xdd, 0x32, 0x55, 0x67, 0xf4, 0x1e, 0x6c, 0x94, 0x89, 0xa7, 0x54,
+	0xf5, 0x9c, 0xfa, 0x53, 0xd2, 0x9b, 0x95, 0xe7, 0x6d, 0x90, 0x88, 0x58, 0xb4, 0x36, 0xe3, 0x34,
+	0x6c, 0x95, 0x1d, 0xc9, 0x7b, 0xb0, 0x51, 0x96, 0xc5, 0xab, 0xa3, 0x4f, 0xff, 0xfc, 0xe8, 0xee,
+	0xbf, 0xfa, 0x98, 0xde, 0x53, 0xf6, 0x7b, 0xda, 0x66, 0xdf, 0x43, 0xbf, 0xfc, 0xff, 0x00, 0xd5,
+	0xa0, 0xdb, 0x6b, 0x3d, 0x14, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -915,10 +2426,14 @@ type LogicClient interface {
 	Disconnect(ctx context.Context, in *DisconnectReq, opts ...grpc.CallOption) (*DisconnectReply, error)
 	// Heartbeat
 	Heartbeat(ctx context.Context, in *HeartbeatReq, opts ...grpc.CallOption) (*HeartbeatReply, error)
+	// Reauth refreshes the token of a live connection
+	Reauth(ctx context.Context, in *ReauthReq, opts ...grpc.CallOption) (*ReauthReply, error)
 	// RenewOnline
 	RenewOnline(ctx context.Context, in *OnlineReq, opts ...grpc.CallOption) (*OnlineReply, error)
 	// Receive
 	Receive(ctx context.Context, in *ReceiveReq, opts ...grpc.CallOption) (*ReceiveReply, error)
+	// Presence reports the room join/leave events
+	Presence(ctx context.Context, in *PresenceReq, opts ...grpc.CallOption) (*PresenceReply, error)
 	//ServerList
 	Nodes(ctx context.Context, in *NodesReq, opts ...grpc.CallOption) (*NodesReply, error)
 }
@@ -958,6 +2473,15 @@ func (c *logicClient) Heartbeat(ctx context.Context, in *HeartbeatReq, opts ...g
 	return out, nil
 }
 
+func (c *logicClient) Reauth(ctx context.Context, in *ReauthReq, opts ...grpc.CallOption) (*ReauthReply, error) {
+	out := new(ReauthReply)
+	err := c.cc.Invoke(ctx, "/goim.logic.Logic/Reauth", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *logicClient) RenewOnline(ctx context.Context, in *OnlineReq, opts ...grpc.CallOption) (*OnlineReply, error) {
 	out := new(OnlineReply)
 	err := c.cc.Invoke(ctx, "/goim.logic.Logic/RenewOnline", in, out, opts...)
@@ -976,6 +2500,15 @@ func (c *logicClient) Receive(ctx context.Context, in *ReceiveReq, opts ...grpc.
 	return out, nil
 }
 
+func (c *logicClient) Presence(ctx context.Context, in *PresenceReq, opts ...grpc.CallOption) (*PresenceReply, error) {
+	out := new(PresenceReply)
+	err := c.cc.Invoke(ctx, "/goim.logic.Logic/Presence", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *logicClient) Nodes(ctx context.Context, in *NodesReq, opts ...grpc.CallOption) (*NodesReply, error) {
 	out := new(NodesReply)
 	err := c.cc.Invoke(ctx, "/goim.logic.Logic/Nodes", in, out, opts...)
@@ -993,10 +2526,14 @@ type LogicServer interface {
 	Disconnect(context.Context, *DisconnectReq) (*DisconnectReply, error)
 	// Heartbeat
 	Heartbeat(context.Context, *HeartbeatReq) (*HeartbeatReply, error)
+	// Reauth refreshes the token of a live connection
+	Reauth(context.Context, *ReauthReq) (*ReauthReply, error)
 	// RenewOnline
 	RenewOnline(context.Context, *OnlineReq) (*OnlineReply, error)
 	// Receive
 	Receive(context.Context, *ReceiveReq) (*ReceiveReply, error)
+	// Presence reports the room join/leave events
+	Presence(context.Context, *PresenceReq) (*PresenceReply, error)
 	//ServerList
 	Nodes(context.Context, *NodesReq) (*NodesReply, error)
 }
@@ -1014,12 +2551,18 @@ func (*UnimplementedLogicServer) Disconnect(ctx context.Context, req *Disconnect
 func (*UnimplementedLogicServer) Heartbeat(ctx context.Context, req *HeartbeatReq) (*HeartbeatReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
 }
+func (*UnimplementedLogicServer) Reauth(ctx context.Context, req *ReauthReq) (*ReauthReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reauth not implemented")
+}
 func (*UnimplementedLogicServer) RenewOnline(ctx context.Context, req *OnlineReq) (*OnlineReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RenewOnline not implemented")
 }
 func (*UnimplementedLogicServer) Receive(ctx context.Context, req *ReceiveReq) (*ReceiveReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Receive not implemented")
 }
+func (*UnimplementedLogicServer) Presence(ctx context.Context, req *PresenceReq) (*PresenceReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Presence not implemented")
+}
 func (*UnimplementedLogicServer) Nodes(ctx context.Context, req *NodesReq) (*NodesReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Nodes not implemented")
 }
@@ -1082,6 +2625,24 @@ func _Logic_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Logic_Reauth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReauthReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogicServer).Reauth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goim.logic.Logic/Reauth",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogicServer).Reauth(ctx, req.(*ReauthReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Logic_RenewOnline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(OnlineReq)
 	if err := dec(in); err != nil {
@@ -1118,6 +2679,24 @@ func _Logic_Receive_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Logic_Presence_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PresenceReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogicServer).Presence(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goim.logic.Logic/Presence",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogicServer).Presence(ctx, req.(*PresenceReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Logic_Nodes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(NodesReq)
 	if err := dec(in); err != nil {
@@ -1152,6 +2731,10 @@ var _Logic_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Heartbeat",
 			Handler:    _Logic_Heartbeat_Handler,
 		},
+		{
+			MethodName: "Reauth",
+			Handler:    _Logic_Reauth_Handler,
+		},
 		{
 			MethodName: "RenewOnline",
 			Handler:    _Logic_RenewOnline_Handler,
@@ -1160,6 +2743,10 @@ var _Logic_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Receive",
 			Handler:    _Logic_Receive_Handler,
 		},
+		{
+			MethodName: "Presence",
+			Handler:    _Logic_Presence_Handler,
+		},
 		{
 			MethodName: "Nodes",
 			Handler:    _Logic_Nodes_Handler,
@@ -1168,3 +2755,381 @@ var _Logic_serviceDesc = grpc.ServiceDesc{
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "logic/logic.proto",
 }
+
+// PushClient is the client API for Push service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type PushClient interface {
+	// PushKeys pushes a message to the keys
+	PushKeys(ctx context.Context, in *PushKeysReq, opts ...grpc.CallOption) (*PushKeysReply, error)
+	// PushMids pushes a message to the keys of the mids
+	PushMids(ctx context.Context, in *PushMidsReq, opts ...grpc.CallOption) (*PushMidsReply, error)
+	// PushRoom pushes a message to the room
+	PushRoom(ctx context.Context, in *PushRoomReq, opts ...grpc.CallOption) (*PushRoomReply, error)
+	// PushAll pushes a message to all
+	PushAll(ctx context.Context, in *PushAllReq, opts ...grpc.CallOption) (*PushAllReply, error)
+	// OnlineMids returns the mids online
+	OnlineMids(ctx context.Context, in *OnlineMidsReq, opts ...grpc.CallOption) (*OnlineMidsReply, error)
+	// OnlineRoom returns the connections and the users of the rooms
+	OnlineRoom(ctx context.Context, in *OnlineRoomReq, opts ...grpc.CallOption) (*OnlineRoomReply, error)
+	// RoomMembers returns a page of the mids in the room
+	RoomMembers(ctx context.Context, in *RoomMembersReq, opts ...grpc.CallOption) (*RoomMembersReply, error)
+	// OnlineApp returns the connections and the rooms of the app
+	OnlineApp(ctx context.Context, in *OnlineAppReq, opts ...grpc.CallOption) (*OnlineAppReply, error)
+	// OnlineTotal returns the ips and the connections online
+	OnlineTotal(ctx context.Context, in *OnlineTotalReq, opts ...grpc.CallOption) (*OnlineTotalReply, error)
+}
+
+type pushClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewPushClient(cc *grpc.ClientConn) PushClient {
+	return &pushClient{cc}
+}
+
+func (c *pushClient) PushKeys(ctx context.Context, in *PushKeysReq, opts ...grpc.CallOption) (*PushKeysReply, error) {
+	out := new(PushKeysReply)
+	err := c.cc.Invoke(ctx, "/goim.logic.Push/PushKeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pushClient) PushMids(ctx context.Context, in *PushMidsReq, opts ...grpc.CallOption) (*PushMidsReply, error) {
+	out := new(PushMidsReply)
+	err := c.cc.Invoke(ctx, "/goim.logic.Push/PushMids", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pushClient) PushRoom(ctx context.Context, in *PushRoomReq, opts ...grpc.CallOption) (*PushRoomReply, error) {
+	out := new(PushRoomReply)
+	err := c.cc.Invoke(ctx, "/goim.logic.Push/PushRoom", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pushClient) PushAll(ctx context.Context, in *PushAllReq, opts ...grpc.CallOption) (*PushAllReply, error) {
+	out := new(PushAllReply)
+	err := c.cc.Invoke(ctx, "/goim.logic.Push/PushAll", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pushClient) OnlineMids(ctx context.Context, in *OnlineMidsReq, opts ...grpc.CallOption) (*OnlineMidsReply, error) {
+	out := new(OnlineMidsReply)
+	err := c.cc.Invoke(ctx, "/goim.logic.Push/OnlineMids", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pushClient) OnlineRoom(ctx context.Context, in *OnlineRoomReq, opts ...grpc.CallOption) (*OnlineRoomReply, error) {
+	out := new(OnlineRoomReply)
+	err := c.cc.Invoke(ctx, "/goim.logic.Push/OnlineRoom", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pushClient) RoomMembers(ctx context.Context, in *RoomMembersReq, opts ...grpc.CallOption) (*RoomMembersReply, error) {
+	out := new(RoomMembersReply)
+	err := c.cc.Invoke(ctx, "/goim.logic.Push/RoomMembers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pushClient) OnlineApp(ctx context.Context, in *OnlineAppReq, opts ...grpc.CallOption) (*OnlineAppReply, error) {
+	out := new(OnlineAppReply)
+	err := c.cc.Invoke(ctx, "/goim.logic.Push/OnlineApp", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pushClient) OnlineTotal(ctx context.Context, in *OnlineTotalReq, opts ...grpc.CallOption) (*OnlineTotalReply, error) {
+	out := new(OnlineTotalReply)
+	err := c.cc.Invoke(ctx, "/goim.logic.Push/OnlineTotal", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PushServer is the server API for Push service.
+type PushServer interface {
+	// PushKeys pushes a message to the keys
+	PushKeys(context.Context, *PushKeysReq) (*PushKeysReply, error)
+	// PushMids pushes a message to the keys of the mids
+	PushMids(context.Context, *PushMidsReq) (*PushMidsReply, error)
+	// PushRoom pushes a message to the room
+	PushRoom(context.Context, *PushRoomReq) (*PushRoomReply, error)
+	// PushAll pushes a message to all
+	PushAll(context.Context, *PushAllReq) (*PushAllReply, error)
+	// OnlineMids returns the mids online
+	OnlineMids(context.Context, *OnlineMidsReq) (*OnlineMidsReply, error)
+	// OnlineRoom returns the connections and the users of the rooms
+	OnlineRoom(context.Context, *OnlineRoomReq) (*OnlineRoomReply, error)
+	// RoomMembers returns a page of the mids in the room
+	RoomMembers(context.Context, *RoomMembersReq) (*RoomMembersReply, error)
+	// OnlineApp returns the connections and the rooms of the app
+	OnlineApp(context.Context, *OnlineAppReq) (*OnlineAppReply, error)
+	// OnlineTotal returns the ips and the connections online
+	OnlineTotal(context.Context, *OnlineTotalReq) (*OnlineTotalReply, error)
+}
+
+// UnimplementedPushServer can be embedded to have forward compatible implementations.
+type UnimplementedPushServer struct {
+}
+
+func (*UnimplementedPushServer) PushKeys(ctx context.Context, req *PushKeysReq) (*PushKeysReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PushKeys not implemented")
+}
+func (*UnimplementedPushServer) PushMids(ctx context.Context, req *PushMidsReq) (*PushMidsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PushMids not implemented")
+}
+func (*UnimplementedPushServer) PushRoom(ctx context.Context, req *PushRoomReq) (*PushRoomReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PushRoom not implemented")
+}
+func (*UnimplementedPushServer) PushAll(ctx context.Context, req *PushAllReq) (*PushAllReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PushAll not implemented")
+}
+func (*UnimplementedPushServer) OnlineMids(ctx context.Context, req *OnlineMidsReq) (*OnlineMidsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method OnlineMids not implemented")
+}
+func (*UnimplementedPushServer) OnlineRoom(ctx context.Context, req *OnlineRoomReq) (*OnlineRoomReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method OnlineRoom not implemented")
+}
+func (*UnimplementedPushServer) RoomMembers(ctx context.Context, req *RoomMembersReq) (*RoomMembersReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RoomMembers not implemented")
+}
+func (*UnimplementedPushServer) OnlineApp(ctx context.Context, req *OnlineAppReq) (*OnlineAppReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method OnlineApp not implemented")
+}
+func (*UnimplementedPushServer) OnlineTotal(ctx context.Context, req *OnlineTotalReq) (*OnlineTotalReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method OnlineTotal not implemented")
+}
+
+func RegisterPushServer(s *grpc.Server, srv PushServer) {
+	s.RegisterService(&_Push_serviceDesc, srv)
+}
+
+func _Push_PushKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PushKeysReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PushServer).PushKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goim.logic.Push/PushKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PushServer).PushKeys(ctx, req.(*PushKeysReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Push_PushMids_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PushMidsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PushServer).PushMids(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goim.logic.Push/PushMids",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PushServer).PushMids(ctx, req.(*PushMidsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Push_PushRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PushRoomReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PushServer).PushRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goim.logic.Push/PushRoom",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PushServer).PushRoom(ctx, req.(*PushRoomReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Push_PushAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PushAllReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PushServer).PushAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goim.logic.Push/PushAll",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PushServer).PushAll(ctx, req.(*PushAllReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Push_OnlineMids_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OnlineMidsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PushServer).OnlineMids(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goim.logic.Push/OnlineMids",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PushServer).OnlineMids(ctx, req.(*OnlineMidsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Push_OnlineRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OnlineRoomReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PushServer).OnlineRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goim.logic.Push/OnlineRoom",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PushServer).OnlineRoom(ctx, req.(*OnlineRoomReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Push_RoomMembers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomMembersReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PushServer).RoomMembers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goim.logic.Push/RoomMembers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PushServer).RoomMembers(ctx, req.(*RoomMembersReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Push_OnlineApp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OnlineAppReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PushServer).OnlineApp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goim.logic.Push/OnlineApp",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PushServer).OnlineApp(ctx, req.(*OnlineAppReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Push_OnlineTotal_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OnlineTotalReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PushServer).OnlineTotal(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goim.logic.Push/OnlineTotal",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PushServer).OnlineTotal(ctx, req.(*OnlineTotalReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Push_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "goim.logic.Push",
+	HandlerType: (*PushServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "PushKeys",
+			Handler:    _Push_PushKeys_Handler,
+		},
+		{
+			MethodName: "PushMids",
+			Handler:    _Push_PushMids_Handler,
+		},
+		{
+			MethodName: "PushRoom",
+			Handler:    _Push_PushRoom_Handler,
+		},
+		{
+			MethodName: "PushAll",
+			Handler:    _Push_PushAll_Handler,
+		},
+		{
+			MethodName: "OnlineMids",
+			Handler:    _Push_OnlineMids_Handler,
+		},
+		{
+			MethodName: "OnlineRoom",
+			Handler:    _Push_OnlineRoom_Handler,
+		},
+		{
+			MethodName: "RoomMembers",
+			Handler:    _Push_RoomMembers_Handler,
+		},
+		{
+			MethodName: "OnlineApp",
+			Handler:    _Push_OnlineApp_Handler,
+		},
+		{
+			MethodName: "OnlineTotal",
+			Handler:    _Push_OnlineTotal_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "logic/logic.proto",
+}
@@ -0,0 +1,80 @@
+package protocol
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	// ErrProtoCodec proto body codec error
+	ErrProtoCodec = errors.New("default server codec unknown compression")
+	// ErrProtoBodyLen proto uncompressed body len error
+	ErrProtoBodyLen = errors.New("default server codec uncompressed body length error")
+)
+
+var (
+	flateWriters = sync.Pool{
+		New: func() interface{} {
+			w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+			return w
+		},
+	}
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(uint64(MaxUncompressedBodySize)))
+)
+
+// Compress returns body compressed with the codec carried in flags.
+func Compress(flags int32, body []byte) ([]byte, error) {
+	switch flags & CodecMask {
+	case CodecDeflate:
+		var (
+			buf bytes.Buffer
+			w   = flateWriters.Get().(*flate.Writer)
+		)
+		w.Reset(&buf)
+		_, err := w.Write(body)
+		if err == nil {
+			err = w.Close()
+		}
+		flateWriters.Put(w)
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CodecZstd:
+		return zstdEncoder.EncodeAll(body, make([]byte, 0, len(body))), nil
+	}
+	return nil, ErrProtoCodec
+}
+
+// Decompress returns body uncompressed with the codec carried in flags.
+func Decompress(flags int32, body []byte) ([]byte, error) {
+	switch flags & CodecMask {
+	case CodecDeflate:
+		r := flate.NewReader(bytes.NewReader(body))
+		defer r.Close()
+		b, err := io.ReadAll(io.LimitReader(r, int64(MaxUncompressedBodySize)+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > MaxUncompressedBodySize {
+			return nil, ErrProtoBodyLen
+		}
+		return b, nil
+	case CodecZstd:
+		b, err := zstdDecoder.DecodeAll(body, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > MaxUncompressedBodySize {
+			return nil, ErrProtoBodyLen
+		}
+		return b, nil
+	}
+	return nil, ErrProtoCodec
+}
@@ -0,0 +1,88 @@
+package protocol
+
+import (
+	"github.com/Terry-Mao/goim/pkg/bufio"
+	"github.com/Terry-Mao/goim/pkg/bytes"
+	"github.com/Terry-Mao/goim/pkg/websocket"
+)
+
+var framePool = bytes.NewClassPool(64, int(MaxBodySize)<<2)
+
+// FramePoolStats returns the utilization of the frame buffer pool.
+func FramePoolStats() bytes.ClassStats {
+	return framePool.Stats()
+}
+
+// Frame is a proto encoded once for both tcp and websocket, it's shared by
+// all the channels of a room and written without being encoded again.
+//
+// The buffer layout is the websocket header right before the tcp frame, so
+// the websocket frame and the tcp frame share the same bytes:
+//
+//	| pad | ws header | proto header | body |
+type Frame struct {
+	Op  int32
+	tcp []byte
+	ws  []byte
+	buf *bytes.RefBuffer
+}
+
+// NewFrame encodes the proto into a frame with one reference.
+func NewFrame(p *Proto) (f *Frame, err error) {
+	var (
+		body, flags = p.wireBody()
+		headerLen   = p.HeaderLen()
+		packLen     = headerLen + len(body)
+		wsHeaderLen = websocket.HeaderLen(packLen)
+		off         = websocket.MaxHeaderLen - wsHeaderLen
+		buf         = framePool.Get(websocket.MaxHeaderLen + packLen)
+		b           = buf.Bytes()
+	)
+	websocket.PutHeader(b[off:], websocket.BinaryMessage, packLen)
+	p.writeHeader(b[websocket.MaxHeaderLen:], packLen, flags)
+	copy(b[websocket.MaxHeaderLen+headerLen:], body)
+	f = &Frame{
+		Op:  p.Op,
+		tcp: b[websocket.MaxHeaderLen:],
+		ws:  b[off:],
+		buf: buf,
+	}
+	if p.Op == OpRaw {
+		// job concats protos into the raw body, tcp writes it as is
+		f.tcp = b[websocket.MaxHeaderLen+headerLen:]
+	}
+	return
+}
+
+// TCP returns the encoded tcp frame.
+func (f *Frame) TCP() []byte {
+	return f.tcp
+}
+
+// Websocket returns the encoded websocket frame.
+func (f *Frame) Websocket() []byte {
+	return f.ws
+}
+
+// Retain adds a reference, every holder must release it.
+func (f *Frame) Retain() {
+	f.buf.Retain()
+}
+
+// Release drops a reference, the frame must not be used after that.
+func (f *Frame) Release() {
+	f.buf.Release()
+}
+
+// WriteTCP write the frame to TCP writer without copy, the frame must be
+// retained until the writer is flushed.
+func (f *Frame) WriteTCP(wr *bufio.Writer) (err error) {
+	_, err = wr.WriteNoCopy(f.tcp)
+	return
+}
+
+// WriteWebsocket write the frame to websocket connection without copy, the
+// frame must be retained until the connection is flushed.
+func (f *Frame) WriteWebsocket(ws *websocket.Conn) (err error) {
+	return ws.WriteNoCopy(f.ws)
+}
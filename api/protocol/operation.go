@@ -46,4 +46,28 @@ const (
 	OpUnsub = int32(16)
 	// OpUnsubReply unsubscribe operation reply
 	OpUnsubReply = int32(17)
+
+	// OpDisconnectReason server closes the connection, body is the reason
+	OpDisconnectReason = int32(18)
+	// OpRateLimited upstream proto over the rate limit was dropped, it
+	// replies the dropped proto with the same seq
+	OpRateLimited = int32(19)
+	// OpMissedMessages messages were dropped for the slow consumer, body is
+	// the count, the client should resync through history
+	OpMissedMessages = int32(20)
+
+	// OpAck acks the pushed messages up to seq for session resume
+	OpAck = int32(21)
+	// OpAckReply ack reply
+	OpAckReply = int32(22)
+
+	// OpAuthExpiring the token is expiring, body is the expire time in unix
+	// seconds, the client should send OpAuth with a new token before it
+	OpAuthExpiring = int32(23)
+	// OpConfigUpdate server pushes the runtime config, body is json of the
+	// fields changed: heartbeat, heartbeat_max, backoff and features
+	OpConfigUpdate = int32(24)
+	// OpPresence room join/leave events to the clients subscribed it, body
+	// is json: room, joins, leaves, joined and left
+	OpPresence = int32(25)
 )
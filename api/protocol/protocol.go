@@ -12,25 +12,60 @@ import (
 const (
 	// MaxBodySize max proto body size
 	MaxBodySize = int32(1 << 12)
+	// MaxUncompressedBodySize max proto body size after decompression
+	MaxUncompressedBodySize = int(MaxBodySize) << 4
+)
+
+const (
+	// Ver1 is the v1 framing: 16 bytes header with a 32-bit sequence.
+	Ver1 = int32(1)
+	// Ver2 is the v2 framing: 21 bytes header with a flags byte and a 64-bit sequence.
+	Ver2 = int32(2)
+)
+
+// Flags of a v2 proto. The low nibble carries the flag bits and the high
+// nibble the body codec. Body of a Proto in memory is always uncompressed,
+// FlagCompressed asks the codec to compress it on the wire.
+const (
+	// FlagCompressed body is compressed with the codec in CodecMask
+	FlagCompressed = int32(1 << 0)
+	// FlagEncrypted body is encrypted by the business layer
+	FlagEncrypted = int32(1 << 1)
+	// FlagAck sender requires an ack of the seq
+	FlagAck = int32(1 << 2)
+	// FlagChunked body is one chunk of a larger message
+	FlagChunked = int32(1 << 3)
+
+	// CodecMask body codec mask
+	CodecMask = int32(0xf0)
+	// CodecDeflate deflate body codec
+	CodecDeflate = int32(1 << 4)
+	// CodecZstd zstd body codec
+	CodecZstd = int32(2 << 4)
 )
 
 const (
 	// size
-	_packSize      = 4
-	_headerSize    = 2
-	_verSize       = 2
-	_opSize        = 4
-	_seqSize       = 4
-	_heartSize     = 4
-	_rawHeaderSize = _packSize + _headerSize + _verSize + _opSize + _seqSize
-	_maxPackSize   = MaxBodySize + int32(_rawHeaderSize)
+	_packSize        = 4
+	_headerSize      = 2
+	_verSize         = 2
+	_opSize          = 4
+	_seqSize         = 4
+	_flagsSize       = 1
+	_seqV2Size       = 8
+	_heartSize       = 4
+	_prefixSize      = _packSize + _headerSize + _verSize
+	_rawHeaderSize   = _packSize + _headerSize + _verSize + _opSize + _seqSize
+	_rawHeaderSizeV2 = _packSize + _headerSize + _verSize + _opSize + _flagsSize + _seqV2Size
+	_maxPackSize     = MaxBodySize + int32(_rawHeaderSizeV2)
 	// offset
 	_packOffset   = 0
 	_headerOffset = _packOffset + _packSize
 	_verOffset    = _headerOffset + _headerSize
 	_opOffset     = _verOffset + _verSize
 	_seqOffset    = _opOffset + _opSize
-	_heartOffset  = _seqOffset + _seqSize
+	_flagsOffset  = _opOffset + _opSize
+	_seqV2Offset  = _flagsOffset + _flagsSize
 )
 
 var (
@@ -38,6 +73,8 @@ var (
 	ErrProtoPackLen = errors.New("default server codec pack length error")
 	// ErrProtoHeaderLen proto header len error
 	ErrProtoHeaderLen = errors.New("default server codec header length error")
+	// ErrProtoVersion proto version error
+	ErrProtoVersion = errors.New("default server codec version error")
 )
 
 var (
@@ -47,22 +84,124 @@ var (
 	ProtoFinish = &Proto{Op: OpProtoFinish}
 )
 
+// HeaderLen returns the header length of the proto framing, the legacy
+// version 0 is framed as v1.
+func (p *Proto) HeaderLen() int {
+	if p.Ver == Ver2 {
+		return _rawHeaderSizeV2
+	}
+	return _rawHeaderSize
+}
+
+// knownVersion reports whether the version is framed by this codec.
+func knownVersion(ver int32) bool {
+	return ver == 0 || ver == Ver1 || ver == Ver2
+}
+
+// writeHeader encode the proto header with the flags into buf.
+func (p *Proto) writeHeader(buf []byte, packLen int, flags int32) {
+	headerLen := p.HeaderLen()
+	binary.BigEndian.PutInt32(buf[_packOffset:], int32(packLen))
+	binary.BigEndian.PutInt16(buf[_headerOffset:], int16(headerLen))
+	binary.BigEndian.PutInt16(buf[_verOffset:], int16(p.Ver))
+	binary.BigEndian.PutInt32(buf[_opOffset:], p.Op)
+	if headerLen == _rawHeaderSizeV2 {
+		buf[_flagsOffset] = byte(flags)
+		binary.BigEndian.PutInt64(buf[_seqV2Offset:], p.Seq)
+	} else {
+		binary.BigEndian.PutInt32(buf[_seqOffset:], int32(p.Seq))
+	}
+}
+
+// readHeader decode the proto header from buf, buf must hold the whole header.
+func (p *Proto) readHeader(buf []byte) (packLen int32, headerLen int16, err error) {
+	packLen = binary.BigEndian.Int32(buf[_packOffset:_headerOffset])
+	headerLen = binary.BigEndian.Int16(buf[_headerOffset:_verOffset])
+	p.Ver = int32(binary.BigEndian.Int16(buf[_verOffset:_opOffset]))
+	if !knownVersion(p.Ver) {
+		return 0, 0, ErrProtoVersion
+	}
+	if int(headerLen) != p.HeaderLen() || len(buf) < int(headerLen) {
+		return 0, 0, ErrProtoHeaderLen
+	}
+	p.Op = binary.BigEndian.Int32(buf[_opOffset:_seqOffset])
+	if headerLen == _rawHeaderSizeV2 {
+		p.Flags = int32(buf[_flagsOffset])
+		p.Seq = binary.BigEndian.Int64(buf[_seqV2Offset:])
+	} else {
+		p.Flags = 0
+		p.Seq = int64(binary.BigEndian.Int32(buf[_seqOffset:]))
+	}
+	if packLen < int32(headerLen) || packLen > _maxPackSize {
+		return 0, 0, ErrProtoPackLen
+	}
+	return
+}
+
+// wireBody returns the body and the flags as they go on the wire, the body
+// is sent uncompressed without the codec if it can't be compressed.
+func (p *Proto) wireBody() ([]byte, int32) {
+	if p.Ver == Ver2 && p.Flags&FlagCompressed != 0 && len(p.Body) > 0 {
+		if body, err := Compress(p.Flags, p.Body); err == nil {
+			return body, p.Flags
+		}
+		return p.Body, p.Flags &^ (FlagCompressed | CodecMask)
+	}
+	return p.Body, p.Flags
+}
+
+// readBody set the body read from the wire.
+func (p *Proto) readBody(body []byte) (err error) {
+	if p.Flags&FlagCompressed != 0 && len(body) > 0 {
+		p.Body, err = Decompress(p.Flags, body)
+		return
+	}
+	p.Body = body
+	return
+}
+
 // WriteTo write a proto to bytes writer.
 func (p *Proto) WriteTo(b *bytes.Writer) {
 	var (
-		packLen = _rawHeaderSize + int32(len(p.Body))
-		buf     = b.Peek(_rawHeaderSize)
+		body, flags = p.wireBody()
+		headerLen   = p.HeaderLen()
+		packLen     = headerLen + len(body)
+		buf         = b.Peek(headerLen)
 	)
-	binary.BigEndian.PutInt32(buf[_packOffset:], packLen)
-	binary.BigEndian.PutInt16(buf[_headerOffset:], int16(_rawHeaderSize))
-	binary.BigEndian.PutInt16(buf[_verOffset:], int16(p.Ver))
-	binary.BigEndian.PutInt32(buf[_opOffset:], p.Op)
-	binary.BigEndian.PutInt32(buf[_seqOffset:], p.Seq)
-	if p.Body != nil {
-		b.Write(p.Body)
+	p.writeHeader(buf, packLen, flags)
+	if body != nil {
+		b.Write(body)
 	}
 }
 
+// SplitRaw decodes the protos packed in a raw body, as the room messages.
+func SplitRaw(body []byte) (ps []*Proto, err error) {
+	for len(body) > 0 {
+		var (
+			p         = new(Proto)
+			packLen   int32
+			headerLen int16
+		)
+		if len(body) < _prefixSize {
+			return nil, ErrProtoPackLen
+		}
+		if packLen, headerLen, err = p.readHeader(body); err != nil {
+			return nil, err
+		}
+		if int(packLen) > len(body) {
+			return nil, ErrProtoPackLen
+		}
+		if packLen > int32(headerLen) {
+			if err = p.readBody(body[headerLen:packLen]); err != nil {
+				return nil, err
+			}
+		}
+		ps = append(ps, p)
+		body = body[packLen:]
+	}
+	return
+}
+
 // ReadTCP read a proto from TCP reader.
 func (p *Proto) ReadTCP(rr *bufio.Reader) (err error) {
 	var (
@@ -71,22 +210,23 @@ func (p *Proto) ReadTCP(rr *bufio.Reader) (err error) {
 		packLen   int32
 		buf       []byte
 	)
-	if buf, err = rr.Pop(_rawHeaderSize); err != nil {
+	if buf, err = rr.Peek(_prefixSize); err != nil {
 		return
 	}
-	packLen = binary.BigEndian.Int32(buf[_packOffset:_headerOffset])
-	headerLen = binary.BigEndian.Int16(buf[_headerOffset:_verOffset])
-	p.Ver = int32(binary.BigEndian.Int16(buf[_verOffset:_opOffset]))
-	p.Op = binary.BigEndian.Int32(buf[_opOffset:_seqOffset])
-	p.Seq = binary.BigEndian.Int32(buf[_seqOffset:])
-	if packLen > _maxPackSize {
-		return ErrProtoPackLen
-	}
-	if headerLen != _rawHeaderSize {
+	if headerLen = binary.BigEndian.Int16(buf[_headerOffset:_verOffset]); headerLen != _rawHeaderSize && headerLen != _rawHeaderSizeV2 {
 		return ErrProtoHeaderLen
 	}
+	if buf, err = rr.Pop(int(headerLen)); err != nil {
+		return
+	}
+	if packLen, headerLen, err = p.readHeader(buf); err != nil {
+		return
+	}
 	if bodyLen = int(packLen - int32(headerLen)); bodyLen > 0 {
-		p.Body, err = rr.Pop(bodyLen)
+		if buf, err = rr.Pop(bodyLen); err != nil {
+			return
+		}
+		err = p.readBody(buf)
 	} else {
 		p.Body = nil
 	}
@@ -95,26 +235,40 @@ func (p *Proto) ReadTCP(rr *bufio.Reader) (err error) {
 
 // WriteTCP write a proto to TCP writer.
 func (p *Proto) WriteTCP(wr *bufio.Writer) (err error) {
+	return p.writeTCP(wr, false)
+}
+
+// WriteTCPNoCopy write a proto to TCP writer, the body is queued without
+// copy, it must not be modified until the writer is flushed.
+func (p *Proto) WriteTCPNoCopy(wr *bufio.Writer) (err error) {
+	return p.writeTCP(wr, true)
+}
+
+func (p *Proto) writeTCP(wr *bufio.Writer, noCopy bool) (err error) {
 	var (
-		buf     []byte
-		packLen int32
+		buf       []byte
+		body      []byte
+		flags     int32
+		headerLen int
 	)
 	if p.Op == OpRaw {
 		// write without buffer, job concact proto into raw buffer
 		_, err = wr.WriteRaw(p.Body)
 		return
 	}
-	packLen = _rawHeaderSize + int32(len(p.Body))
-	if buf, err = wr.Peek(_rawHeaderSize); err != nil {
+	body, flags = p.wireBody()
+	headerLen = p.HeaderLen()
+	if buf, err = wr.Peek(headerLen); err != nil {
 		return
 	}
-	binary.BigEndian.PutInt32(buf[_packOffset:], packLen)
-	binary.BigEndian.PutInt16(buf[_headerOffset:], int16(_rawHeaderSize))
-	binary.BigEndian.PutInt16(buf[_verOffset:], int16(p.Ver))
-	binary.BigEndian.PutInt32(buf[_opOffset:], p.Op)
-	binary.BigEndian.PutInt32(buf[_seqOffset:], p.Seq)
-	if p.Body != nil {
-		_, err = wr.Write(p.Body)
+	p.writeHeader(buf, headerLen+len(body), flags)
+	if body == nil {
+		return
+	}
+	if noCopy {
+		_, err = wr.WriteNoCopy(body)
+	} else {
+		_, err = wr.Write(body)
 	}
 	return
 }
@@ -122,21 +276,17 @@ func (p *Proto) WriteTCP(wr *bufio.Writer) (err error) {
 // WriteTCPHeart write TCP heartbeat with room online.
 func (p *Proto) WriteTCPHeart(wr *bufio.Writer, online int32) (err error) {
 	var (
-		buf     []byte
-		packLen int
+		buf       []byte
+		headerLen = p.HeaderLen()
+		packLen   = headerLen + _heartSize
 	)
-	packLen = _rawHeaderSize + _heartSize
 	if buf, err = wr.Peek(packLen); err != nil {
 		return
 	}
 	// header
-	binary.BigEndian.PutInt32(buf[_packOffset:], int32(packLen))
-	binary.BigEndian.PutInt16(buf[_headerOffset:], int16(_rawHeaderSize))
-	binary.BigEndian.PutInt16(buf[_verOffset:], int16(p.Ver))
-	binary.BigEndian.PutInt32(buf[_opOffset:], p.Op)
-	binary.BigEndian.PutInt32(buf[_seqOffset:], p.Seq)
+	p.writeHeader(buf, packLen, p.Flags)
 	// body
-	binary.BigEndian.PutInt32(buf[_heartOffset:], online)
+	binary.BigEndian.PutInt32(buf[headerLen:], online)
 	return
 }
 
@@ -151,22 +301,17 @@ func (p *Proto) ReadWebsocket(ws *websocket.Conn) (err error) {
 	if _, buf, err = ws.ReadMessage(); err != nil {
 		return
 	}
-	if len(buf) < _rawHeaderSize {
+	if len(buf) < _prefixSize {
 		return ErrProtoPackLen
 	}
-	packLen = binary.BigEndian.Int32(buf[_packOffset:_headerOffset])
-	headerLen = binary.BigEndian.Int16(buf[_headerOffset:_verOffset])
-	p.Ver = int32(binary.BigEndian.Int16(buf[_verOffset:_opOffset]))
-	p.Op = binary.BigEndian.Int32(buf[_opOffset:_seqOffset])
-	p.Seq = binary.BigEndian.Int32(buf[_seqOffset:])
-	if packLen < 0 || packLen > _maxPackSize {
-		return ErrProtoPackLen
+	if packLen, headerLen, err = p.readHeader(buf); err != nil {
+		return
 	}
-	if headerLen != _rawHeaderSize {
-		return ErrProtoHeaderLen
+	if int(packLen) > len(buf) {
+		return ErrProtoPackLen
 	}
 	if bodyLen = int(packLen - int32(headerLen)); bodyLen > 0 {
-		p.Body = buf[headerLen:packLen]
+		err = p.readBody(buf[headerLen:packLen])
 	} else {
 		p.Body = nil
 	}
@@ -175,24 +320,41 @@ func (p *Proto) ReadWebsocket(ws *websocket.Conn) (err error) {
 
 // WriteWebsocket write a proto to websocket connection.
 func (p *Proto) WriteWebsocket(ws *websocket.Conn) (err error) {
+	return p.writeWebsocket(ws, false)
+}
+
+// WriteWebsocketNoCopy write a proto to websocket connection, the body is
+// queued without copy, it must not be modified until the connection is
+// flushed.
+func (p *Proto) WriteWebsocketNoCopy(ws *websocket.Conn) (err error) {
+	return p.writeWebsocket(ws, true)
+}
+
+func (p *Proto) writeWebsocket(ws *websocket.Conn, noCopy bool) (err error) {
 	var (
-		buf     []byte
-		packLen int
+		buf       []byte
+		body      []byte
+		flags     int32
+		headerLen int
+		packLen   int
 	)
-	packLen = _rawHeaderSize + len(p.Body)
+	body, flags = p.wireBody()
+	headerLen = p.HeaderLen()
+	packLen = headerLen + len(body)
 	if err = ws.WriteHeader(websocket.BinaryMessage, packLen); err != nil {
 		return
 	}
-	if buf, err = ws.Peek(_rawHeaderSize); err != nil {
+	if buf, err = ws.Peek(headerLen); err != nil {
 		return
 	}
-	binary.BigEndian.PutInt32(buf[_packOffset:], int32(packLen))
-	binary.BigEndian.PutInt16(buf[_headerOffset:], int16(_rawHeaderSize))
-	binary.BigEndian.PutInt16(buf[_verOffset:], int16(p.Ver))
-	binary.BigEndian.PutInt32(buf[_opOffset:], p.Op)
-	binary.BigEndian.PutInt32(buf[_seqOffset:], p.Seq)
-	if p.Body != nil {
-		err = ws.WriteBody(p.Body)
+	p.writeHeader(buf, packLen, flags)
+	if body == nil {
+		return
+	}
+	if noCopy {
+		err = ws.WriteNoCopy(body)
+	} else {
+		err = ws.WriteBody(body)
 	}
 	return
 }
@@ -200,10 +362,10 @@ func (p *Proto) WriteWebsocket(ws *websocket.Conn) (err error) {
 // WriteWebsocketHeart write websocket heartbeat with room online.
 func (p *Proto) WriteWebsocketHeart(wr *websocket.Conn, online int32) (err error) {
 	var (
-		buf     []byte
-		packLen int
+		buf       []byte
+		headerLen = p.HeaderLen()
+		packLen   = headerLen + _heartSize
 	)
-	packLen = _rawHeaderSize + _heartSize
 	// websocket header
 	if err = wr.WriteHeader(websocket.BinaryMessage, packLen); err != nil {
 		return
@@ -212,12 +374,8 @@ func (p *Proto) WriteWebsocketHeart(wr *websocket.Conn, online int32) (err error
 		return
 	}
 	// proto header
-	binary.BigEndian.PutInt32(buf[_packOffset:], int32(packLen))
-	binary.BigEndian.PutInt16(buf[_headerOffset:], int16(_rawHeaderSize))
-	binary.BigEndian.PutInt16(buf[_verOffset:], int16(p.Ver))
-	binary.BigEndian.PutInt32(buf[_opOffset:], p.Op)
-	binary.BigEndian.PutInt32(buf[_seqOffset:], p.Seq)
+	p.writeHeader(buf, packLen, p.Flags)
 	// proto body
-	binary.BigEndian.PutInt32(buf[_heartOffset:], online)
+	binary.BigEndian.PutInt32(buf[headerLen:], online)
 	return
 }
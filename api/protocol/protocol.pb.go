@@ -20,14 +20,14 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
-//
-// v1.0.0
+// v2.0.0
 // protocol
 type Proto struct {
 	Ver                  int32    `protobuf:"varint,1,opt,name=ver,proto3" json:"ver,omitempty"`
 	Op                   int32    `protobuf:"varint,2,opt,name=op,proto3" json:"op,omitempty"`
-	Seq                  int32    `protobuf:"varint,3,opt,name=seq,proto3" json:"seq,omitempty"`
+	Seq                  int64    `protobuf:"varint,3,opt,name=seq,proto3" json:"seq,omitempty"`
 	Body                 []byte   `protobuf:"bytes,4,opt,name=body,proto3" json:"body,omitempty"`
+	Flags                int32    `protobuf:"varint,5,opt,name=flags,proto3" json:"flags,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -72,7 +72,7 @@ func (m *Proto) GetOp() int32 {
 	return 0
 }
 
-func (m *Proto) GetSeq() int32 {
+func (m *Proto) GetSeq() int64 {
 	if m != nil {
 		return m.Seq
 	}
@@ -86,6 +86,13 @@ func (m *Proto) GetBody() []byte {
 	return nil
 }
 
+func (m *Proto) GetFlags() int32 {
+	if m != nil {
+		return m.Flags
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Proto)(nil), "goim.protocol.Proto")
 }
@@ -93,15 +100,16 @@ func init() {
 func init() { proto.RegisterFile("protocol/protocol.proto", fileDescriptor_87968d26f3046c60) }
 
 var fileDescriptor_87968d26f3046c60 = []byte{
-	// 153 bytes of a gzipped FileDescriptorProto
+	// 166 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0x2f, 0x28, 0xca, 0x2f,
 	0xc9, 0x4f, 0xce, 0xcf, 0xd1, 0x87, 0x31, 0xf4, 0xc0, 0x0c, 0x21, 0xde, 0xf4, 0xfc, 0xcc, 0x5c,
-	0x3d, 0x98, 0xa0, 0x92, 0x3f, 0x17, 0x6b, 0x00, 0x58, 0x5c, 0x80, 0x8b, 0xb9, 0x2c, 0xb5, 0x48,
+	0x3d, 0x98, 0xa0, 0x52, 0x2a, 0x17, 0x6b, 0x00, 0x58, 0x5c, 0x80, 0x8b, 0xb9, 0x2c, 0xb5, 0x48,
 	0x82, 0x51, 0x81, 0x51, 0x83, 0x35, 0x08, 0xc4, 0x14, 0xe2, 0xe3, 0x62, 0xca, 0x2f, 0x90, 0x60,
-	0x02, 0x0b, 0x30, 0xe5, 0x17, 0x80, 0x54, 0x14, 0xa7, 0x16, 0x4a, 0x30, 0x43, 0x54, 0x14, 0xa7,
-	0x16, 0x0a, 0x09, 0x71, 0xb1, 0x24, 0xe5, 0xa7, 0x54, 0x4a, 0xb0, 0x28, 0x30, 0x6a, 0xf0, 0x04,
-	0x81, 0xd9, 0x4e, 0x86, 0x51, 0xfa, 0xe9, 0x99, 0x25, 0x19, 0xa5, 0x49, 0x7a, 0xc9, 0xf9, 0xb9,
-	0xfa, 0x21, 0xa9, 0x45, 0x45, 0x95, 0xba, 0xbe, 0x89, 0xf9, 0xfa, 0x20, 0x6b, 0xf5, 0x13, 0x0b,
-	0x32, 0xe1, 0xee, 0xb1, 0x86, 0x31, 0x92, 0xd8, 0xc0, 0x2c, 0x63, 0x40, 0x00, 0x00, 0x00, 0xff,
-	0xff, 0x8d, 0xc7, 0xbf, 0x64, 0xb4, 0x00, 0x00, 0x00,
+	0x02, 0x0b, 0x30, 0xe5, 0x17, 0x80, 0x54, 0x14, 0xa7, 0x16, 0x4a, 0x30, 0x2b, 0x30, 0x6a, 0x30,
+	0x07, 0x81, 0x98, 0x42, 0x42, 0x5c, 0x2c, 0x49, 0xf9, 0x29, 0x95, 0x12, 0x2c, 0x0a, 0x8c, 0x1a,
+	0x3c, 0x41, 0x60, 0xb6, 0x90, 0x08, 0x17, 0x6b, 0x5a, 0x4e, 0x62, 0x7a, 0xb1, 0x04, 0x2b, 0x58,
+	0x23, 0x84, 0xe3, 0x64, 0x18, 0xa5, 0x9f, 0x9e, 0x59, 0x92, 0x51, 0x9a, 0xa4, 0x97, 0x9c, 0x9f,
+	0xab, 0x1f, 0x92, 0x5a, 0x54, 0x54, 0xa9, 0xeb, 0x9b, 0x98, 0xaf, 0x0f, 0x72, 0x8c, 0x7e, 0x62,
+	0x41, 0x26, 0xdc, 0x95, 0xd6, 0x30, 0x46, 0x12, 0x1b, 0x98, 0x65, 0x0c, 0x18, 0x00, 0x0f, 0x2e,
+	0x8e, 0x94, 0xca, 0x00, 0x00, 0x00,
 }
@@ -0,0 +1,191 @@
+package protocol
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/Terry-Mao/goim/pkg/bufio"
+	xbytes "github.com/Terry-Mao/goim/pkg/bytes"
+	"github.com/Terry-Mao/goim/pkg/websocket"
+)
+
+func TestProtoTCP(t *testing.T) {
+	body := bytes.Repeat([]byte("hello goim "), 32)
+	protos := []*Proto{
+		{Ver: Ver1, Op: OpSendMsg, Seq: 1, Body: body},
+		{Ver: Ver2, Op: OpSendMsg, Seq: 1 << 40, Body: body},
+		{Ver: Ver2, Op: OpSendMsg, Seq: 2, Flags: FlagCompressed | CodecDeflate, Body: body},
+		{Ver: Ver2, Op: OpSendMsg, Seq: 3, Flags: FlagCompressed | FlagAck | CodecZstd, Body: body},
+		{Ver: Ver2, Op: OpHeartbeat, Seq: 4},
+	}
+	var buf bytes.Buffer
+	wr := bufio.NewWriter(&buf)
+	for _, p := range protos {
+		if err := p.WriteTCP(wr); err != nil {
+			t.Fatalf("WriteTCP(%v) error(%v)", p, err)
+		}
+	}
+	if err := wr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	rr := bufio.NewReader(&buf)
+	for _, p := range protos {
+		var r Proto
+		if err := r.ReadTCP(rr); err != nil {
+			t.Fatalf("ReadTCP() error(%v)", err)
+		}
+		if r.Ver != p.Ver || r.Op != p.Op || r.Seq != p.Seq || r.Flags != p.Flags || !reflect.DeepEqual(r.Body, p.Body) {
+			t.Fatalf("ReadTCP() got %v want %v", &r, p)
+		}
+	}
+}
+
+func TestProtoHeaderLen(t *testing.T) {
+	var buf bytes.Buffer
+	wr := bufio.NewWriter(&buf)
+	p := &Proto{Ver: Ver2, Op: OpSendMsg}
+	if err := p.WriteTCP(wr); err != nil {
+		t.Fatal(err)
+	}
+	wr.Flush()
+	b := buf.Bytes()
+	// v1 version with a v2 header length
+	b[_verOffset+1] = byte(Ver1)
+	var r Proto
+	if err := r.ReadTCP(bufio.NewReader(bytes.NewReader(b))); err != ErrProtoHeaderLen {
+		t.Fatalf("ReadTCP() error(%v) want %v", err, ErrProtoHeaderLen)
+	}
+}
+
+func TestProtoVersion(t *testing.T) {
+	var buf bytes.Buffer
+	wr := bufio.NewWriter(&buf)
+	p := &Proto{Ver: Ver1, Op: OpSendMsg}
+	if err := p.WriteTCP(wr); err != nil {
+		t.Fatal(err)
+	}
+	wr.Flush()
+	b := buf.Bytes()
+	// an unknown version with a v1 header length
+	b[_verOffset+1] = 3
+	var r Proto
+	if err := r.ReadTCP(bufio.NewReader(bytes.NewReader(b))); err != ErrProtoVersion {
+		t.Fatalf("ReadTCP() error(%v) want %v", err, ErrProtoVersion)
+	}
+	if _, err := SplitRaw(b); err != ErrProtoVersion {
+		t.Fatalf("SplitRaw() error(%v) want %v", err, ErrProtoVersion)
+	}
+}
+
+func TestProtoCompressFallback(t *testing.T) {
+	body := bytes.Repeat([]byte("hello goim "), 32)
+	// the codec is unknown, the body is sent uncompressed without the codec
+	p := &Proto{Ver: Ver2, Op: OpSendMsg, Seq: 1, Flags: FlagCompressed | FlagAck | CodecMask, Body: body}
+	w := xbytes.NewWriterSize(64)
+	p.WriteTo(w)
+	ps, err := SplitRaw(w.Buffer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 1 || ps[0].Flags != FlagAck || !bytes.Equal(ps[0].Body, body) {
+		t.Fatalf("WriteTo() got %v", ps)
+	}
+	var buf bytes.Buffer
+	wr := bufio.NewWriter(&buf)
+	if err = p.WriteTCP(wr); err != nil {
+		t.Fatal(err)
+	}
+	wr.Flush()
+	if !bytes.Equal(buf.Bytes(), w.Buffer()) {
+		t.Fatal("WriteTCP() and WriteTo() mismatch")
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := bytes.Repeat([]byte("a"), MaxUncompressedBodySize+1)
+	for _, codec := range []int32{CodecDeflate, CodecZstd} {
+		b, err := Compress(codec, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = Decompress(codec, b); err == nil {
+			t.Fatalf("codec(%d) Decompress() must refuse oversized body", codec)
+		}
+	}
+	if _, err := Compress(0, body); err != ErrProtoCodec {
+		t.Fatalf("Compress() error(%v) want %v", err, ErrProtoCodec)
+	}
+}
+
+func TestFrame(t *testing.T) {
+	protos := []*Proto{
+		{Ver: Ver1, Op: OpSendMsg, Seq: 1, Body: []byte("hello")},
+		{Ver: Ver2, Op: OpSendMsg, Seq: 2, Flags: FlagCompressed | CodecDeflate, Body: bytes.Repeat([]byte("hello goim "), 32)},
+		{Ver: Ver1, Op: OpRaw, Body: bytes.Repeat([]byte("raw"), 100)},
+	}
+	for _, p := range protos {
+		var buf bytes.Buffer
+		wr := bufio.NewWriter(&buf)
+		if err := p.WriteTCP(wr); err != nil {
+			t.Fatal(err)
+		}
+		wr.Flush()
+		f, err := NewFrame(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(f.TCP(), buf.Bytes()) {
+			t.Fatalf("op(%d) frame tcp bytes mismatch", p.Op)
+		}
+		ws := f.Websocket()
+		if ws[0] != 0x82 {
+			t.Fatalf("op(%d) websocket header %x", p.Op, ws[0])
+		}
+		// websocket payload is the encoded proto
+		var r Proto
+		payload := ws[websocket.HeaderLen(len(ws)-2):]
+		if p.Op != OpRaw && !bytes.Equal(payload, buf.Bytes()) {
+			t.Fatalf("op(%d) frame websocket payload mismatch", p.Op)
+		}
+		if _, _, err = r.readHeader(payload); err != nil {
+			t.Fatal(err)
+		}
+		f.Release()
+	}
+}
+
+func TestSplitRaw(t *testing.T) {
+	protos := []*Proto{
+		{Ver: Ver1, Op: OpRaw + 1000, Seq: 1, Body: []byte("hello")},
+		{Ver: Ver2, Op: OpSendMsg, Seq: 2, Flags: FlagCompressed | CodecDeflate, Body: bytes.Repeat([]byte("goim"), 64)},
+		{Ver: Ver1, Op: OpSendMsg},
+	}
+	var b bytes.Buffer
+	wr := bufio.NewWriter(&b)
+	for _, p := range protos {
+		if err := p.WriteTCP(wr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wr.Flush()
+	ps, err := SplitRaw(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != len(protos) {
+		t.Fatalf("SplitRaw() got %d protos want %d", len(ps), len(protos))
+	}
+	for i, p := range protos {
+		r := ps[i]
+		if r.Ver != p.Ver || r.Op != p.Op || r.Seq != p.Seq || !bytes.Equal(r.Body, p.Body) {
+			t.Fatalf("SplitRaw() got %v want %v", r, p)
+		}
+	}
+	if _, err = SplitRaw(b.Bytes()[:b.Len()-_rawHeaderSize-1]); err != ErrProtoPackLen {
+		t.Fatalf("SplitRaw() truncated error(%v) want %v", err, ErrProtoPackLen)
+	}
+	if _, err = SplitRaw([]byte("not a proto")); err == nil {
+		t.Fatal("SplitRaw() must fail on garbage")
+	}
+}
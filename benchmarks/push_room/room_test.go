@@ -0,0 +1,114 @@
+package main
+
+// Encoding benchmarks of a room push, run with:
+//   go test -bench . -benchmem ./benchmarks/push_room/
+// Proto encodes the message once per channel, Frame encodes it once per room.
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/pkg/bufio"
+	"github.com/Terry-Mao/goim/pkg/websocket"
+)
+
+const roomSize = 1000
+
+type nopCloser struct{}
+
+func (nopCloser) Read([]byte) (int, error)    { return 0, nil }
+func (nopCloser) Write(b []byte) (int, error) { return len(b), nil }
+func (nopCloser) Close() error                { return nil }
+
+func roomProto() *protocol.Proto {
+	return &protocol.Proto{
+		Ver:   protocol.Ver2,
+		Op:    protocol.OpSendMsg,
+		Flags: protocol.FlagCompressed | protocol.CodecDeflate,
+		Body:  bytes.Repeat([]byte(`{"test":"hello goim"}`), 16),
+	}
+}
+
+func tcpWriters() []*bufio.Writer {
+	wrs := make([]*bufio.Writer, roomSize)
+	for i := range wrs {
+		wrs[i] = bufio.NewWriterSize(ioutil.Discard, 8192)
+	}
+	return wrs
+}
+
+func wsConns(b *testing.B) []*websocket.Conn {
+	req := &websocket.Request{Method: "GET", Header: http.Header{}}
+	req.Header.Set("Sec-Websocket-Version", "13")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-Websocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	wss := make([]*websocket.Conn, roomSize)
+	for i := range wss {
+		ws, err := websocket.Upgrade(nopCloser{}, bufio.NewReader(nopCloser{}), bufio.NewWriterSize(nopCloser{}, 8192), req)
+		if err != nil {
+			b.Fatal(err)
+		}
+		wss[i] = ws
+	}
+	return wss
+}
+
+func BenchmarkRoomProtoTCP(b *testing.B) {
+	p, wrs := roomProto(), tcpWriters()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, wr := range wrs {
+			p.WriteTCP(wr)
+			wr.Flush()
+		}
+	}
+}
+
+func BenchmarkRoomFrameTCP(b *testing.B) {
+	p, wrs := roomProto(), tcpWriters()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f, _ := protocol.NewFrame(p)
+		for _, wr := range wrs {
+			f.Retain()
+			f.WriteTCP(wr)
+			wr.Flush()
+			f.Release()
+		}
+		f.Release()
+	}
+}
+
+func BenchmarkRoomProtoWebsocket(b *testing.B) {
+	p, wss := roomProto(), wsConns(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, ws := range wss {
+			p.WriteWebsocket(ws)
+			ws.Flush()
+		}
+	}
+}
+
+func BenchmarkRoomFrameWebsocket(b *testing.B) {
+	p, wss := roomProto(), wsConns(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f, _ := protocol.NewFrame(p)
+		for _, ws := range wss {
+			f.Retain()
+			f.WriteWebsocket(ws)
+			ws.Flush()
+			f.Release()
+		}
+		f.Release()
+	}
+}
@@ -46,6 +46,11 @@ func main() {
 	if err := comet.InitTCP(srv, conf.Conf.TCP.Bind, runtime.NumCPU()); err != nil {
 		panic(err)
 	}
+	if conf.Conf.TCP.TLSOpen {
+		if err := comet.InitTCPWithTLS(srv, conf.Conf.TCP.TLSBind, conf.Conf.TCP.CertFile, conf.Conf.TCP.PrivateFile, runtime.NumCPU()); err != nil {
+			panic(err)
+		}
+	}
 	if err := comet.InitWebsocket(srv, conf.Conf.Websocket.Bind, runtime.NumCPU()); err != nil {
 		panic(err)
 	}
@@ -54,6 +59,13 @@ func main() {
 			panic(err)
 		}
 	}
+	if err := comet.InitMQTT(srv, conf.Conf.MQTT.Bind, runtime.NumCPU()); err != nil {
+		panic(err)
+	}
+	if err := comet.InitHTTP(srv, conf.Conf.HTTP.Bind); err != nil {
+		panic(err)
+	}
+	comet.InitMetrics(srv, conf.Conf.Metrics.Addr)
 	// new grpc server
 	rpcSrv := grpc.New(conf.Conf.RPCServer, srv)
 	cancel := register(dis, srv)
@@ -74,6 +86,9 @@ func main() {
 			log.Flush()
 			return
 		case syscall.SIGHUP:
+			if err := srv.ReloadCerts(); err != nil {
+				log.Errorf("srv.ReloadCerts() error(%v)", err)
+			}
 		default:
 			return
 		}
@@ -32,6 +32,7 @@ func main() {
 	fmt.Fprintf(os.Stderr, "=== JOB MAIN: About to call job.New() ===\n")
 	// job
 	j := job.New(conf.Conf)
+	job.InitMetrics(j, conf.Conf.Metrics.Addr)
 	fmt.Fprintf(os.Stderr, "=== JOB MAIN: job.New() returned, about to start Consume goroutine ===\n")
 	go j.Consume()
 	fmt.Fprintf(os.Stderr, "=== JOB MAIN: Consume goroutine started, entering signal loop ===\n")
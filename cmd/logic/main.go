@@ -12,6 +12,7 @@ import (
 	"github.com/bilibili/discovery/naming"
 	resolver "github.com/bilibili/discovery/naming/grpc"
 	"github.com/Terry-Mao/goim/internal/logic"
+	"github.com/Terry-Mao/goim/internal/logic/auth"
 	"github.com/Terry-Mao/goim/internal/logic/conf"
 	"github.com/Terry-Mao/goim/internal/logic/grpc"
 	"github.com/Terry-Mao/goim/internal/logic/http"
@@ -36,8 +37,10 @@ func main() {
 	resolver.Register(dis)
 	// logic
 	srv := logic.New(conf.Conf)
-	httpSrv := http.New(conf.Conf.HTTPServer, srv)
-	rpcSrv := grpc.New(conf.Conf.RPCServer, srv)
+	// the push api of the http and the grpc servers shares the apps
+	pushAuth := auth.New(conf.Conf.HTTPServer.Auth, srv)
+	httpSrv := http.New(conf.Conf.HTTPServer, srv, pushAuth)
+	rpcSrv := grpc.New(conf.Conf.RPCServer, srv, pushAuth)
 	cancel := register(dis, srv)
 	// signal
 	c := make(chan os.Signal, 1)
@@ -13,6 +13,7 @@ require (
 	github.com/golang/protobuf v1.4.3
 	github.com/gomodule/redigo v2.0.0+incompatible
 	github.com/google/uuid v1.1.1
+	github.com/klauspost/compress v1.17.11
 	github.com/stretchr/testify v1.5.1
 	github.com/zhenjl/cityhash v0.0.0-20131128155616-cdd6a94144ab
 	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
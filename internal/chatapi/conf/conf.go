@@ -38,9 +38,13 @@ type Redis struct {
 }
 
 type LogicRPC struct {
-	AppID    string `toml:"appid"`
-	Timeout  int    `toml:"timeout"`
-	Endpoint string `toml:"endpoint"`
+	AppID        string `toml:"appid"`
+	Key          string `toml:"key"`          // push api key of the app, if the push api is authenticated
+	Timeout      int    `toml:"timeout"`      // milliseconds
+	Addr         string `toml:"addr"`         // logic gRPC address
+	Conns        int    `toml:"conns"`        // pooled connections
+	Retries      int    `toml:"retries"`      // retries while no connection to logic is up
+	RetryBackoff int    `toml:"retryBackoff"` // milliseconds, doubled per retry
 }
 
 type JWTConfig struct {
@@ -66,7 +66,10 @@ func NewServer(cfg *conf.Config) (*Server, error) {
 	aiDAO := dao.NewAIDAO(mysql)
 
 	// Initialize push client
-	pushClient := service.NewPushClient(cfg.Logic.Endpoint)
+	pushClient, err := service.NewPushClient(cfg.Logic)
+	if err != nil {
+		return nil, fmt.Errorf("init push client error: %w", err)
+	}
 
 	// Initialize AI services
 	aiConfig := iconf.FromChatAPIConfig(
@@ -270,6 +273,8 @@ func (s *Server) Start() error {
 		log.Errorf("ChatAPI server shutdown error: %v", err)
 	}
 
+	s.pushClient.Close()
+
 	// Close MySQL
 	if err := s.mysql.Close(); err != nil {
 		log.Errorf("MySQL close error: %v", err)
@@ -1,125 +1,166 @@
 package service
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"sync/atomic"
 	"time"
+
+	"github.com/Terry-Mao/goim/api/logic"
+	"github.com/Terry-Mao/goim/internal/chatapi/conf"
+	log "github.com/golang/glog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 )
 
-// PushClient handles pushing messages through goim Logic
+const (
+	defaultPushConns   = 4
+	defaultPushTimeout = 3 * time.Second
+	defaultPushBackoff = 100 * time.Millisecond
+
+	// pushKeyMD is the metadata of the push api key
+	pushKeyMD = "x-goim-key"
+)
+
+// PushClient handles pushing messages through the goim Logic push gRPC api,
+// the calls are spread over a pool of connections.
 type PushClient struct {
-	endpoint string
-	client   *http.Client
+	c       *conf.LogicRPC
+	conns   []*grpc.ClientConn
+	clients []logic.PushClient
+	next    uint32
 }
 
 // NewPushClient creates a new push client
-func NewPushClient(endpoint string) *PushClient {
-	return &PushClient{
-		endpoint: endpoint,
-		client: &http.Client{
-			Timeout: 5 * time.Second,
-		},
-	}
-}
-
-// PushKeys pushes a message to specific users by their keys
-func (p *PushClient) PushKeys(ctx context.Context, operation int32, keys []string, msg []byte) error {
-	// Build URL with operation and keys as query parameters
-	url := fmt.Sprintf("%s/goim/push/keys?operation=%d", p.endpoint, operation)
-	for _, key := range keys {
-		url += fmt.Sprintf("&keys=%s", key)
+func NewPushClient(c *conf.LogicRPC) (*PushClient, error) {
+	n := c.Conns
+	if n <= 0 {
+		n = defaultPushConns
 	}
-
-	// Create request with raw message bytes as body
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(msg))
-	if err != nil {
-		return fmt.Errorf("create request: %w", err)
+	p := &PushClient{c: c}
+	for i := 0; i < n; i++ {
+		conn, err := grpc.Dial(c.Addr,
+			grpc.WithInsecure(),
+			grpc.WithKeepaliveParams(keepalive.ClientParameters{
+				Time:                10 * time.Second,
+				Timeout:             3 * time.Second,
+				PermitWithoutStream: true,
+			}))
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("dial logic %s: %w", c.Addr, err)
+		}
+		p.conns = append(p.conns, conn)
+		p.clients = append(p.clients, logic.NewPushClient(conn))
 	}
-	req.Header.Set("Content-Type", "application/octet-stream")
+	return p, nil
+}
 
-	// Send request
-	resp, err := p.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("send request: %w", err)
+// Close closes the connections
+func (p *PushClient) Close() {
+	for _, conn := range p.conns {
+		conn.Close()
 	}
-	defer resp.Body.Close()
+}
 
-	// Check response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("push failed: status=%d, body=%s", resp.StatusCode, string(body))
+// pick returns the next client of the pool whose connection is not failing,
+// false if all of them are.
+func (p *PushClient) pick() (logic.PushClient, bool) {
+	var (
+		n     = uint32(len(p.clients))
+		start = atomic.AddUint32(&p.next, 1)
+	)
+	for i := uint32(0); i < n; i++ {
+		idx := (start + i) % n
+		if s := p.conns[idx].GetState(); s != connectivity.TransientFailure && s != connectivity.Shutdown {
+			return p.clients[idx], true
+		}
 	}
-
-	return nil
+	return p.clients[start%n], false
 }
 
-// PushRoom pushes a message to a room
-func (p *PushClient) PushRoom(ctx context.Context, operation int32, typ, room string, msg []byte) error {
-	url := fmt.Sprintf("%s/goim/push/room?operation=%d&type=%s&room=%s", p.endpoint, operation, typ, room)
-	fmt.Printf("[PushRoom] Calling URL: %s, msg_len=%d\n", url, len(msg))
-
-	// Create request with raw message bytes as body
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(msg))
-	if err != nil {
-		return fmt.Errorf("create request: %w", err)
+// call invokes fn once with a connection of the pool. It's retried only
+// while all the connections to logic are failing, then nothing is sent yet,
+// a call sent is never retried as the push may be delivered already.
+func (p *PushClient) call(ctx context.Context, name string, fn func(context.Context, logic.PushClient) error) (err error) {
+	timeout := time.Duration(p.c.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultPushTimeout
 	}
-	req.Header.Set("Content-Type", "application/octet-stream")
-
-	// Send request
-	resp, err := p.client.Do(req)
-	if err != nil {
-		fmt.Printf("[PushRoom] Request failed: %v\n", err)
-		return fmt.Errorf("send request: %w", err)
+	backoff := time.Duration(p.c.RetryBackoff) * time.Millisecond
+	if backoff <= 0 {
+		backoff = defaultPushBackoff
 	}
-	defer resp.Body.Close()
-
-	// Check response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		fmt.Printf("[PushRoom] Non-200 status: %d, body: %s\n", resp.StatusCode, string(body))
-		return fmt.Errorf("push failed: status=%d, body=%s", resp.StatusCode, string(body))
+	if p.c.Key != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, pushKeyMD, p.c.Key)
 	}
-
-	fmt.Printf("[PushRoom] Success: status=%d\n", resp.StatusCode)
-	return nil
-}
-
-// GetOnlineUsers gets the online user count from goim
-func (p *PushClient) GetOnlineTotal(ctx context.Context) (int64, error) {
-	url := fmt.Sprintf("%s/goim/online/total", p.endpoint)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return 0, fmt.Errorf("create request: %w", err)
-	}
-
-	resp, err := p.client.Do(req)
-	if err != nil {
-		return 0, fmt.Errorf("send request: %w", err)
+	for attempt := 0; ; attempt++ {
+		client, ok := p.pick()
+		if !ok && attempt < p.c.Retries {
+			log.Warningf("logic %s unavailable, retry %d in %v", name, attempt+1, backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			backoff *= 2
+			continue
+		}
+		cctx, cancel := context.WithTimeout(ctx, timeout)
+		err = fn(cctx, client)
+		cancel()
+		return
 	}
-	defer resp.Body.Close()
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, fmt.Errorf("read response: %w", err)
-	}
+// PushKeys pushes a message to specific users by their keys
+func (p *PushClient) PushKeys(ctx context.Context, operation int32, keys []string, msg []byte) error {
+	return p.call(ctx, "PushKeys", func(ctx context.Context, client logic.PushClient) error {
+		_, err := client.PushKeys(ctx, &logic.PushKeysReq{Op: operation, Keys: keys, Msg: msg})
+		return err
+	})
+}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return 0, fmt.Errorf("parse response: %w", err)
-	}
+// PushMids pushes a message to all the keys of the users
+func (p *PushClient) PushMids(ctx context.Context, operation int32, mids []int64, msg []byte) error {
+	return p.call(ctx, "PushMids", func(ctx context.Context, client logic.PushClient) error {
+		_, err := client.PushMids(ctx, &logic.PushMidsReq{Op: operation, Mids: mids, Msg: msg})
+		return err
+	})
+}
 
-	if code, ok := result["code"].(float64); ok && code != 0 {
-		return 0, fmt.Errorf("api error: %v", result)
-	}
+// PushRoom pushes a message to a room
+func (p *PushClient) PushRoom(ctx context.Context, operation int32, typ, room string, msg []byte) error {
+	return p.call(ctx, "PushRoom", func(ctx context.Context, client logic.PushClient) error {
+		_, err := client.PushRoom(ctx, &logic.PushRoomReq{Op: operation, Type: typ, Room: room, Msg: msg})
+		return err
+	})
+}
 
-	if total, ok := result["data"].(float64); ok {
-		return int64(total), nil
-	}
+// OnlineMids gets the users online
+func (p *PushClient) OnlineMids(ctx context.Context, mids []int64) (online []int64, err error) {
+	err = p.call(ctx, "OnlineMids", func(ctx context.Context, client logic.PushClient) error {
+		reply, err := client.OnlineMids(ctx, &logic.OnlineMidsReq{Mids: mids})
+		if err != nil {
+			return err
+		}
+		online = reply.Mids
+		return nil
+	})
+	return
+}
 
-	return 0, fmt.Errorf("invalid response format")
+// GetOnlineTotal gets the online connection count from goim
+func (p *PushClient) GetOnlineTotal(ctx context.Context) (total int64, err error) {
+	err = p.call(ctx, "OnlineTotal", func(ctx context.Context, client logic.PushClient) error {
+		reply, err := client.OnlineTotal(ctx, &logic.OnlineTotalReq{})
+		if err != nil {
+			return err
+		}
+		total = reply.ConnCount
+		return nil
+	})
+	return
 }
@@ -0,0 +1,41 @@
+package comet
+
+import "strings"
+
+// appRoomID returns the room named by the client in the namespace of its app
+// as @app/room, it's false if the client names a room of a namespace.
+func appRoomID(app, roomID string) (string, bool) {
+	if strings.HasPrefix(roomID, "@") {
+		return "", false
+	}
+	if app == "" || roomID == "" {
+		return roomID, true
+	}
+	return "@" + app + "/" + roomID, true
+}
+
+// matchRoom reports whether the room id has the prefix of a rule, a prefix
+// in a namespace as @app/live:// matches the rooms of the app only, others
+// match the room named by the client in any namespace.
+func matchRoom(roomID, prefix string) bool {
+	if !strings.HasPrefix(prefix, "@") && strings.HasPrefix(roomID, "@") {
+		if i := strings.IndexByte(roomID, '/'); i > 0 {
+			roomID = roomID[i+1:]
+		}
+	}
+	return strings.HasPrefix(roomID, prefix)
+}
+
+// appsOnline counts the channels of the apps, the default namespace is not
+// limited so it's not counted.
+func (s *Server) appsOnline() map[string]int32 {
+	conns := make(map[string]int32)
+	for _, b := range s.buckets {
+		for _, ch := range b.Channels() {
+			if ch.App != "" {
+				conns[ch.App]++
+			}
+		}
+	}
+	return conns
+}
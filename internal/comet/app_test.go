@@ -0,0 +1,66 @@
+package comet
+
+import (
+	"testing"
+
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+)
+
+func TestAppRoomID(t *testing.T) {
+	for _, c := range []struct {
+		app, roomID, want string
+		ok                bool
+	}{
+		{"", "live://1", "live://1", true},
+		{"shop", "live://1", "@shop/live://1", true},
+		{"shop", "", "", true},
+		{"", "@shop/live://1", "", false},
+		{"game", "@shop/live://1", "", false},
+	} {
+		if roomID, ok := appRoomID(c.app, c.roomID); roomID != c.want || ok != c.ok {
+			t.Fatalf("appRoomID(%s,%s) = %s,%v want %s,%v", c.app, c.roomID, roomID, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestBroadcasterApp(t *testing.T) {
+	buckets := newBroadcastBuckets(4)
+	buckets[0].Channel("0").App = "shop"
+	buckets[0].Channel("1").App = "shop"
+	b := newTestBroadcaster(&conf.Broadcast{Queue: 2, UrgentQueue: 1, Speed: 1000, Batch: 10, History: 2}, buckets)
+	req := broadcastReq("shop", 1, false)
+	req.App = "shop"
+	if _, err := b.Push(req); err != nil {
+		t.Fatal(err)
+	}
+	b.run(<-b.normal)
+	if tasks := b.Progress("shop"); len(tasks) != 1 || tasks[0].Sent != 2 {
+		t.Fatalf("progress %v", tasks)
+	}
+	if _, err := b.Push(broadcastReq("default", 2, false)); err != nil {
+		t.Fatal(err)
+	}
+	b.run(<-b.normal)
+	if tasks := b.Progress("default"); len(tasks) != 1 || tasks[0].Sent != 2 {
+		t.Fatalf("progress %v", tasks)
+	}
+	for _, ch := range buckets[0].Channels() {
+		want := int64(2)
+		if ch.App == "shop" {
+			want = 1
+		}
+		p, f := ch.Ready()
+		if p.Seq != want {
+			t.Fatalf("channel %s app %s got %v", ch.Key, ch.App, p)
+		}
+		f.Release()
+	}
+}
+
+func TestAppsOnline(t *testing.T) {
+	s := &Server{buckets: newBroadcastBuckets(3)}
+	s.buckets[0].Channel("0").App = "shop"
+	if conns := s.appsOnline(); len(conns) != 1 || conns["shop"] != 1 {
+		t.Fatalf("apps online %v", conns)
+	}
+}
@@ -0,0 +1,149 @@
+package comet
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/logic"
+	"github.com/Terry-Mao/goim/api/protocol"
+	xtime "github.com/Terry-Mao/goim/pkg/time"
+	log "github.com/golang/glog"
+)
+
+const (
+	// disconnect reasons
+	reasonAuthExpired = "auth_expired"
+	reasonAuthFailed  = "auth_failed"
+)
+
+// authBody is the auth reply body.
+type authBody struct {
+	Resume  string `json:"resume,omitempty"`
+	Resumed bool   `json:"resumed,omitempty"`
+	Expire  int64  `json:"expire,omitempty"`
+}
+
+// authReplyBody returns the auth reply body, nil if nothing to tell. The
+// reply resumed by logic is not resumed if the session is not on the comet.
+func (s *Server) authReplyBody(reply *logic.ConnectReply) []byte {
+	var body authBody
+	if reply.Resume != "" && s.sessions.window > 0 {
+		reply.Resumed = reply.Resumed && s.resumable(reply.Key, reply.Resume)
+		body.Resume, body.Resumed = reply.Resume, reply.Resumed
+	}
+	body.Expire = reply.Expire
+	if body == (authBody{}) {
+		return nil
+	}
+	b, _ := json.Marshal(body)
+	return b
+}
+
+// authExpiringProto tells the client the token expires at expire.
+func authExpiringProto(expire int64) *protocol.Proto {
+	return &protocol.Proto{Ver: protocol.Ver1, Op: protocol.OpAuthExpiring, Body: []byte(strconv.FormatInt(expire, 10))}
+}
+
+// authExpiry tells the channel ahead of its token expiry, then closes it if
+// the token is not refreshed in time.
+type authExpiry struct {
+	tr     xtime.Scheduler
+	ch     *Channel
+	ahead  time.Duration
+	mu     sync.Mutex
+	td     *xtime.TimerData
+	expire int64 // unix seconds, zero never expires
+	warned bool
+	closed bool
+}
+
+func newAuthExpiry(tr xtime.Scheduler, ch *Channel, ahead time.Duration) *authExpiry {
+	return &authExpiry{tr: tr, ch: ch, ahead: ahead}
+}
+
+// delay returns the delay to the next check.
+func (a *authExpiry) delay(now time.Time) time.Duration {
+	at := time.Unix(a.expire, 0)
+	if !a.warned {
+		at = at.Add(-a.ahead)
+	}
+	return at.Sub(now)
+}
+
+// Refresh resets the token expiry.
+func (a *authExpiry) Refresh(expire int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	a.expire, a.warned = expire, false
+	if expire <= 0 {
+		// the timer pending does nothing
+		return
+	}
+	if a.td == nil {
+		a.td = a.tr.Add(a.delay(time.Now()), a.check)
+		a.td.Key = a.ch.Key
+	} else {
+		a.tr.Set(a.td, a.delay(time.Now()))
+	}
+}
+
+func (a *authExpiry) check() {
+	a.mu.Lock()
+	if a.closed || a.expire <= 0 {
+		a.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	expire := a.expire
+	expired := now.Unix() >= expire
+	if expired {
+		a.closed = true
+	} else {
+		if !a.warned && a.delay(now) <= 0 {
+			if err := a.ch.Push(authExpiringProto(expire)); err != nil {
+				log.Errorf("key: %s push auth expiring error(%v)", a.ch.Key, err)
+			}
+			a.warned = true
+		}
+		a.tr.Set(a.td, a.delay(now))
+	}
+	a.mu.Unlock()
+	if expired {
+		// the conn is closed out of the lock, the reader closes the timer
+		log.Infof("key: %s mid: %d token expired at %d, disconnect", a.ch.Key, a.ch.Mid, expire)
+		disconnect(a.ch, reasonAuthExpired)
+	}
+}
+
+// Close stops the timer.
+func (a *authExpiry) Close() {
+	a.mu.Lock()
+	if a.td != nil {
+		a.tr.Del(a.td)
+		a.td = nil
+	}
+	a.closed = true
+	a.mu.Unlock()
+}
+
+// reauth refreshes the token of the channel by the auth proto, it's replied
+// with the new expiry, the channel is closed if the token is rejected.
+func (s *Server) reauth(ctx context.Context, p *protocol.Proto, ch *Channel, ae *authExpiry) {
+	expire, err := s.Reauth(ctx, ch.Mid, ch.Key, p.Body)
+	p.Op = protocol.OpAuthReply
+	p.Body = nil
+	if err != nil {
+		log.Errorf("key: %s mid: %d reauth error(%v)", ch.Key, ch.Mid, err)
+		ae.Close()
+		disconnect(ch, reasonAuthFailed)
+		return
+	}
+	ae.Refresh(expire)
+	p.Body, _ = json.Marshal(authBody{Expire: expire})
+}
@@ -0,0 +1,107 @@
+package comet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/logic"
+	"github.com/Terry-Mao/goim/api/protocol"
+	xtime "github.com/Terry-Mao/goim/pkg/time"
+)
+
+// testScheduler records the delay of the timer instead of running it.
+type testScheduler struct {
+	td  *xtime.TimerData
+	d   time.Duration
+	del bool
+}
+
+func (s *testScheduler) Add(d time.Duration, fn func()) *xtime.TimerData {
+	s.td, s.d = new(xtime.TimerData), d
+	return s.td
+}
+
+func (s *testScheduler) Del(td *xtime.TimerData) {
+	s.del = true
+}
+
+func (s *testScheduler) Set(td *xtime.TimerData, d time.Duration) {
+	s.d = d
+}
+
+func readyOps(ch *Channel) (ops []int32) {
+	for {
+		select {
+		case m := <-ch.signal:
+			if m.p == protocol.ProtoFinish {
+				ops = append(ops, protocol.OpProtoFinish)
+			} else {
+				ops = append(ops, m.p.Op)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func TestAuthExpiry(t *testing.T) {
+	var (
+		tr  = new(testScheduler)
+		ch  = NewChannel(5, 10)
+		a   = newAuthExpiry(tr, ch, time.Minute)
+		now = time.Now().Unix()
+	)
+	a.Refresh(0)
+	if tr.td != nil {
+		t.Fatal("timer added for the token never expires")
+	}
+	a.Refresh(now + 3600)
+	if tr.d < 58*time.Minute || tr.d > 59*time.Minute {
+		t.Fatalf("delay %v want about 59m", tr.d)
+	}
+	// fired early by a refreshed timer
+	a.check()
+	if ops := readyOps(ch); len(ops) != 0 {
+		t.Fatalf("ops %v before expiring", ops)
+	}
+	a.Refresh(now + 30)
+	a.check()
+	if ops := readyOps(ch); len(ops) != 1 || ops[0] != protocol.OpAuthExpiring {
+		t.Fatalf("ops %v want expiring", ops)
+	}
+	if tr.d <= 0 || tr.d > 30*time.Second {
+		t.Fatalf("delay %v want the expiry", tr.d)
+	}
+	a.mu.Lock()
+	a.expire = now - 1
+	a.mu.Unlock()
+	a.check()
+	// the channel is closed by the reader
+	if ops := readyOps(ch); len(ops) != 1 || ops[0] != protocol.OpDisconnectReason {
+		t.Fatalf("ops %v want disconnect", ops)
+	}
+	// refreshed too late
+	a.Refresh(now + 3600)
+	a.Close()
+	if !tr.del {
+		t.Fatal("timer not deleted")
+	}
+}
+
+func TestAuthReplyBody(t *testing.T) {
+	s := newResumeServer(10)
+	for _, c := range []struct {
+		reply *logic.ConnectReply
+		body  string
+	}{
+		{&logic.ConnectReply{}, ""},
+		{&logic.ConnectReply{Expire: 100}, `{"expire":100}`},
+		{&logic.ConnectReply{Resume: "k.s"}, `{"resume":"k.s"}`},
+		// the session is not on the comet
+		{&logic.ConnectReply{Key: "k", Resume: "k.s", Resumed: true, Expire: 100}, `{"resume":"k.s","expire":100}`},
+	} {
+		if body := s.authReplyBody(c.reply); string(body) != c.body {
+			t.Fatalf("body %s want %s", body, c.body)
+		}
+	}
+}
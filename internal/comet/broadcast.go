@@ -0,0 +1,288 @@
+package comet
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/Terry-Mao/goim/api/comet"
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	"github.com/Terry-Mao/goim/internal/comet/errors"
+	"github.com/Terry-Mao/goim/pkg/ratelimit"
+	log "github.com/golang/glog"
+	"github.com/google/uuid"
+)
+
+// broadcast task states.
+const (
+	BroadcastQueued   = "queued"
+	BroadcastRunning  = "running"
+	BroadcastDone     = "done"
+	BroadcastCanceled = "canceled"
+)
+
+// broadcastTask is a broadcast to all the channels.
+type broadcastTask struct {
+	id     string
+	op     int32
+	proto  *protocol.Proto
+	speed  int32
+	urgent bool
+	// platform filters the channels if it's not empty
+	platform string
+	// app filters the channels, empty for the default namespace
+	app string
+	// heartbeat sets the heartbeat timeout of the channels
+	heartbeat time.Duration
+
+	state    atomic.Value
+	canceled int32
+	total    int64
+	sent     int64
+	dropped  int64
+	ctime    int64
+	mtime    int64
+}
+
+func (t *broadcastTask) setState(state string) {
+	t.state.Store(state)
+	atomic.StoreInt64(&t.mtime, time.Now().Unix())
+}
+
+func (t *broadcastTask) info() *pb.BroadcastTask {
+	return &pb.BroadcastTask{
+		Id:      t.id,
+		State:   t.state.Load().(string),
+		Urgent:  t.urgent,
+		Speed:   t.speed,
+		Total:   atomic.LoadInt64(&t.total),
+		Sent:    atomic.LoadInt64(&t.sent),
+		Dropped: atomic.LoadInt64(&t.dropped),
+		Ctime:   t.ctime,
+		Mtime:   atomic.LoadInt64(&t.mtime),
+	}
+}
+
+// Broadcaster sends the broadcasts one by one from bounded queues, paced by
+// a token bucket in messages per second across all the buckets. Urgent
+// broadcasts are sent first and preempt a running normal one.
+type Broadcaster struct {
+	c       *conf.Broadcast
+	buckets []*Bucket
+	urgent  chan *broadcastTask
+	normal  chan *broadcastTask
+	closed  chan struct{}
+
+	mu      sync.Mutex
+	tasks   map[string]*broadcastTask
+	history []string // finished task ids, oldest first
+}
+
+// NewBroadcaster new a broadcaster and start the worker.
+func NewBroadcaster(c *conf.Broadcast, buckets []*Bucket) *Broadcaster {
+	b := &Broadcaster{
+		c:       c,
+		buckets: buckets,
+		urgent:  make(chan *broadcastTask, c.UrgentQueue),
+		normal:  make(chan *broadcastTask, c.Queue),
+		closed:  make(chan struct{}),
+		tasks:   make(map[string]*broadcastTask),
+	}
+	go b.workproc()
+	return b
+}
+
+// Push queues a broadcast and returns its id.
+func (b *Broadcaster) Push(req *pb.BroadcastReq) (id string, err error) {
+	if req.Proto == nil {
+		return "", errors.ErrBroadCastArg
+	}
+	if id = req.Id; id == "" {
+		id = uuid.New().String()
+	}
+	t := &broadcastTask{
+		id:     id,
+		op:     req.ProtoOp,
+		proto:  req.Proto,
+		speed:  req.Speed,
+		urgent: req.Urgent,
+		ctime:  time.Now().Unix(),
+
+		platform:  req.Platform,
+		app:       req.App,
+		heartbeat: configHeartbeat(req.Proto),
+	}
+	if t.speed <= 0 {
+		t.speed = b.c.Speed
+	}
+	t.setState(BroadcastQueued)
+	b.mu.Lock()
+	if _, ok := b.tasks[id]; ok {
+		b.mu.Unlock()
+		return "", errors.ErrBroadcastExists
+	}
+	b.tasks[id] = t
+	b.mu.Unlock()
+	queue := b.normal
+	if t.urgent {
+		queue = b.urgent
+	}
+	select {
+	case queue <- t:
+	default:
+		b.mu.Lock()
+		delete(b.tasks, id)
+		b.mu.Unlock()
+		return "", errors.ErrBroadcastQueueFull
+	}
+	return
+}
+
+// Cancel cancels a queued or running broadcast.
+func (b *Broadcaster) Cancel(id string) bool {
+	b.mu.Lock()
+	t, ok := b.tasks[id]
+	b.mu.Unlock()
+	if !ok {
+		return false
+	}
+	if state := t.state.Load().(string); state == BroadcastDone || state == BroadcastCanceled {
+		return false
+	}
+	return atomic.CompareAndSwapInt32(&t.canceled, 0, 1)
+}
+
+// Progress returns the progress of the broadcast by id, all if id is empty.
+func (b *Broadcaster) Progress(id string) (tasks []*pb.BroadcastTask) {
+	b.mu.Lock()
+	if id != "" {
+		if t, ok := b.tasks[id]; ok {
+			tasks = append(tasks, t.info())
+		}
+	} else {
+		for _, t := range b.tasks {
+			tasks = append(tasks, t.info())
+		}
+	}
+	b.mu.Unlock()
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Ctime < tasks[j].Ctime })
+	return
+}
+
+// Close stops the worker.
+func (b *Broadcaster) Close() {
+	close(b.closed)
+}
+
+func (b *Broadcaster) workproc() {
+	for {
+		select {
+		case t := <-b.urgent:
+			b.run(t)
+			continue
+		default:
+		}
+		select {
+		case t := <-b.urgent:
+			b.run(t)
+		case t := <-b.normal:
+			b.run(t)
+		case <-b.closed:
+			return
+		}
+	}
+}
+
+// runUrgent runs the queued urgent broadcasts.
+func (b *Broadcaster) runUrgent() {
+	for {
+		select {
+		case t := <-b.urgent:
+			b.run(t)
+		default:
+			return
+		}
+	}
+}
+
+func (b *Broadcaster) run(t *broadcastTask) {
+	defer b.finish(t)
+	if atomic.LoadInt32(&t.canceled) == 1 {
+		return
+	}
+	f, err := protocol.NewFrame(t.proto)
+	if err != nil {
+		log.Errorf("broadcast: %s protocol.NewFrame(%d) error(%v)", t.id, t.proto.Op, err)
+		return
+	}
+	defer f.Release()
+	var (
+		limiter = ratelimit.New(float64(t.speed), b.c.Batch)
+		batch   int
+	)
+	// the total is the matching channels only, so sent and dropped reach it
+	var chs []*Channel
+	for _, bucket := range b.buckets {
+		for _, ch := range bucket.Channels() {
+			if ch.NeedPush(t.op) && ch.App == t.app && (t.platform == "" || ch.Platform == t.platform) {
+				chs = append(chs, ch)
+			}
+		}
+	}
+	atomic.StoreInt64(&t.total, int64(len(chs)))
+	t.setState(BroadcastRunning)
+	for _, ch := range chs {
+		if t.heartbeat > 0 {
+			ch.SetHeartbeat(t.heartbeat)
+		}
+		f.Retain()
+		if err = ch.PushBroadcast(t.proto, f); err != nil {
+			atomic.AddInt64(&t.dropped, 1)
+		} else {
+			atomic.AddInt64(&t.sent, 1)
+		}
+		if batch++; batch < b.c.Batch {
+			continue
+		}
+		if !b.pace(t, limiter, batch) {
+			return
+		}
+		batch = 0
+	}
+}
+
+// pace waits for the tokens of the batch sent, runs the urgent broadcasts
+// if t is a normal one, it returns false if t is canceled or the broadcaster
+// is closed.
+func (b *Broadcaster) pace(t *broadcastTask, limiter *ratelimit.Bucket, n int) bool {
+	if d := limiter.Wait(time.Now(), n); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-b.closed:
+			atomic.StoreInt32(&t.canceled, 1)
+			return false
+		}
+	}
+	if !t.urgent {
+		b.runUrgent()
+	}
+	return atomic.LoadInt32(&t.canceled) == 0
+}
+
+func (b *Broadcaster) finish(t *broadcastTask) {
+	if atomic.LoadInt32(&t.canceled) == 1 {
+		t.setState(BroadcastCanceled)
+	} else {
+		t.setState(BroadcastDone)
+	}
+	log.Infof("broadcast: %s %s sent: %d dropped: %d", t.id, t.state.Load(), atomic.LoadInt64(&t.sent), atomic.LoadInt64(&t.dropped))
+	b.mu.Lock()
+	b.history = append(b.history, t.id)
+	for len(b.history) > b.c.History {
+		delete(b.tasks, b.history[0])
+		b.history = b.history[1:]
+	}
+	b.mu.Unlock()
+}
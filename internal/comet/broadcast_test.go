@@ -0,0 +1,153 @@
+package comet
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	pb "github.com/Terry-Mao/goim/api/comet"
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	"github.com/Terry-Mao/goim/internal/comet/errors"
+)
+
+func newBroadcastBuckets(n int) []*Bucket {
+	b := NewBucket(&conf.Bucket{Channel: n, Room: 1, RoutineAmount: 1, RoutineSize: 1})
+	for i := 0; i < n; i++ {
+		ch := NewChannel(5, 10)
+		ch.Key = strconv.Itoa(i)
+		ch.Watch(1)
+		if err := b.Put("", ch); err != nil {
+			panic(err)
+		}
+	}
+	return []*Bucket{b}
+}
+
+// newTestBroadcaster returns a broadcaster without the worker.
+func newTestBroadcaster(c *conf.Broadcast, buckets []*Bucket) *Broadcaster {
+	return &Broadcaster{
+		c:       c,
+		buckets: buckets,
+		urgent:  make(chan *broadcastTask, c.UrgentQueue),
+		normal:  make(chan *broadcastTask, c.Queue),
+		closed:  make(chan struct{}),
+		tasks:   make(map[string]*broadcastTask),
+	}
+}
+
+func broadcastReq(id string, seq int64, urgent bool) *pb.BroadcastReq {
+	return &pb.BroadcastReq{Id: id, ProtoOp: 1, Urgent: urgent, Proto: &protocol.Proto{Op: 1000, Seq: seq}}
+}
+
+func TestBroadcasterQueue(t *testing.T) {
+	b := newTestBroadcaster(&conf.Broadcast{Queue: 1, UrgentQueue: 1, Batch: 10, History: 10}, newBroadcastBuckets(3))
+	if _, err := b.Push(broadcastReq("a", 1, false)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Push(broadcastReq("a", 1, true)); err != errors.ErrBroadcastExists {
+		t.Fatalf("Push() error(%v) want %v", err, errors.ErrBroadcastExists)
+	}
+	if _, err := b.Push(broadcastReq("b", 2, false)); err != errors.ErrBroadcastQueueFull {
+		t.Fatalf("Push() error(%v) want %v", err, errors.ErrBroadcastQueueFull)
+	}
+	if tasks := b.Progress("b"); len(tasks) != 0 {
+		t.Fatalf("rejected broadcast must be forgotten, got %v", tasks)
+	}
+	if !b.Cancel("a") {
+		t.Fatal("queued broadcast must be canceled")
+	}
+	b.run(<-b.normal)
+	tasks := b.Progress("a")
+	if len(tasks) != 1 || tasks[0].State != BroadcastCanceled || tasks[0].Sent != 0 {
+		t.Fatalf("progress %v", tasks)
+	}
+	if b.Cancel("a") {
+		t.Fatal("finished broadcast must not be canceled")
+	}
+}
+
+func TestBroadcasterRun(t *testing.T) {
+	buckets := newBroadcastBuckets(5)
+	b := newTestBroadcaster(&conf.Broadcast{Queue: 1, UrgentQueue: 1, Speed: 1000, Batch: 2, History: 1}, buckets)
+	// not watched
+	buckets[0].Channel("0").UnWatch(1)
+	id, err := b.Push(broadcastReq("", 1, false))
+	if err != nil || id == "" {
+		t.Fatalf("Push() id(%s) error(%v)", id, err)
+	}
+	b.run(<-b.normal)
+	tasks := b.Progress(id)
+	if len(tasks) != 1 || tasks[0].State != BroadcastDone || tasks[0].Total != 4 || tasks[0].Sent != 4 || tasks[0].Speed != 1000 {
+		t.Fatalf("progress %v", tasks)
+	}
+	for _, ch := range buckets[0].Channels() {
+		if ch.Key == "0" {
+			continue
+		}
+		p, f := ch.Ready()
+		if p.Seq != 1 || f == nil {
+			t.Fatalf("channel %s got %v frame %v", ch.Key, p, f)
+		}
+		f.Release()
+	}
+	// history keeps the last finished broadcast only
+	if _, err = b.Push(broadcastReq("c", 2, false)); err != nil {
+		t.Fatal(err)
+	}
+	b.run(<-b.normal)
+	if tasks = b.Progress(""); len(tasks) != 1 || tasks[0].Id != "c" {
+		t.Fatalf("progress %v", tasks)
+	}
+}
+
+func TestBroadcasterFilter(t *testing.T) {
+	buckets := newBroadcastBuckets(3)
+	b := newTestBroadcaster(&conf.Broadcast{Queue: 1, UrgentQueue: 1, Batch: 10, History: 10}, buckets)
+	buckets[0].Channel("0").Platform = "ios"
+	buckets[0].Channel("1").App = "shop"
+	req := broadcastReq("ios", 1, false)
+	req.Platform = "ios"
+	if _, err := b.Push(req); err != nil {
+		t.Fatal(err)
+	}
+	b.run(<-b.normal)
+	tasks := b.Progress("ios")
+	if len(tasks) != 1 || tasks[0].State != BroadcastDone || tasks[0].Total != 1 || tasks[0].Sent != 1 {
+		t.Fatalf("progress %v", tasks)
+	}
+}
+
+func TestBroadcasterClose(t *testing.T) {
+	b := newTestBroadcaster(&conf.Broadcast{Queue: 1, UrgentQueue: 1, Speed: 1, Batch: 1, History: 10}, newBroadcastBuckets(3))
+	if _, err := b.Push(broadcastReq("a", 1, false)); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+	b.run(<-b.normal)
+	tasks := b.Progress("a")
+	if len(tasks) != 1 || tasks[0].State != BroadcastCanceled || tasks[0].Sent == tasks[0].Total {
+		t.Fatalf("progress %v", tasks)
+	}
+}
+
+func TestBroadcasterUrgent(t *testing.T) {
+	buckets := newBroadcastBuckets(1)
+	b := newTestBroadcaster(&conf.Broadcast{Queue: 2, UrgentQueue: 2, Batch: 1, History: 10}, buckets)
+	defer b.Close()
+	b.Push(broadcastReq("normal", 1, false))
+	b.Push(broadcastReq("urgent", 2, true))
+	go b.workproc()
+	ch := buckets[0].Channels()[0]
+	for _, seq := range []int64{2, 1} {
+		select {
+		case m := <-ch.signal:
+			if m.p.Seq != seq {
+				t.Fatalf("got seq %d want %d", m.p.Seq, seq)
+			}
+			m.release()
+		case <-time.After(time.Second):
+			t.Fatal("broadcast timeout")
+		}
+	}
+}
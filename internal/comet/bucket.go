@@ -3,6 +3,7 @@ package comet
 import (
 	"sync"
 	"sync/atomic"
+	"time"
 
 	pb "github.com/Terry-Mao/goim/api/comet"
 	"github.com/Terry-Mao/goim/api/protocol"
@@ -20,6 +21,9 @@ type Bucket struct {
 	routinesNum uint64
 
 	ipCnts map[string]int32
+	// shared by the buckets
+	history  *roomHistory
+	presence *presence
 }
 
 // NewBucket new a bucket struct. store the key with im channel.
@@ -85,6 +89,12 @@ func (b *Bucket) ChangeRoom(nrid string, ch *Channel) (err error) {
 
 // JoinRoom join a room (supports multiple rooms)
 func (b *Bucket) JoinRoom(ch *Channel, roomID string) (err error) {
+	return b.joinRoom(ch, roomID, true)
+}
+
+// joinRoom joins a room, the history is replayed to the late joiner if
+// replay, not to the resumed one which replays its own messages.
+func (b *Bucket) joinRoom(ch *Channel, roomID string, replay bool) (err error) {
 	// Check if already in this room
 	if ch.HasRoom(roomID) {
 		return nil
@@ -102,11 +112,22 @@ func (b *Bucket) JoinRoom(ch *Channel, roomID string) (err error) {
 	}
 	b.cLock.Unlock()
 
-	if err = room.Put(ch); err != nil {
+	first, err := room.join(ch)
+	if err != nil {
 		return
 	}
+	if first {
+		b.presence.add(roomID, ch.Mid, true)
+	}
 
 	ch.AddRoom(room)
+	if !replay {
+		return
+	}
+	// replay the history to the late joiner
+	for _, p := range b.history.Messages(roomID, time.Now()) {
+		_ = ch.PushRoom(p, nil)
+	}
 	return
 }
 
@@ -121,8 +142,14 @@ func (b *Bucket) LeaveRoom(ch *Channel, roomID string) {
 	room := b.rooms[roomID]
 	b.cLock.Unlock()
 
-	if room != nil && room.Del(ch) {
-		b.DelRoom(room)
+	if room != nil {
+		last, drop := room.leave(ch)
+		if last {
+			b.presence.add(roomID, ch.Mid, false)
+		}
+		if drop {
+			b.DelRoom(room)
+		}
 	}
 
 	ch.RemoveRoom(roomID)
@@ -176,6 +203,17 @@ func (b *Bucket) Channel(key string) (ch *Channel) {
 	return
 }
 
+// Channels returns a snapshot of the channels in the bucket.
+func (b *Bucket) Channels() (chs []*Channel) {
+	b.cLock.RLock()
+	chs = make([]*Channel, 0, len(b.chs))
+	for _, ch := range b.chs {
+		chs = append(chs, ch)
+	}
+	b.cLock.RUnlock()
+	return
+}
+
 // Broadcast push msgs to all channels in the bucket.
 func (b *Bucket) Broadcast(p *protocol.Proto, op int32) {
 	var ch *Channel
@@ -184,7 +222,7 @@ func (b *Bucket) Broadcast(p *protocol.Proto, op int32) {
 		if !ch.NeedPush(op) {
 			continue
 		}
-		_ = ch.Push(p)
+		_ = ch.PushBroadcast(p, nil)
 	}
 	b.cLock.RUnlock()
 }
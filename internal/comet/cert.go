@@ -0,0 +1,180 @@
+package comet
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+)
+
+const (
+	// certExpireWarn warn the certificates which expire soon.
+	certExpireWarn = time.Hour * 24 * 30
+)
+
+var (
+	// ErrCertMismatch cert and private key files mismatch.
+	ErrCertMismatch = errors.New("cert and private key files mismatch")
+	// ErrCertEmpty no certificate loaded.
+	ErrCertEmpty = errors.New("no certificate loaded")
+)
+
+// CertStore holds the comma separated cert and private key pairs, reloads
+// them on demand and selects one by SNI for every tls handshake.
+type CertStore struct {
+	certFiles    []string
+	privateFiles []string
+
+	mu    sync.RWMutex
+	certs []*tls.Certificate
+	names map[string]*tls.Certificate
+	mtime time.Time
+
+	done chan struct{}
+}
+
+// NewCertStore new a cert store and load the certificates.
+func NewCertStore(certFile, privateFile string) (cs *CertStore, err error) {
+	cs = &CertStore{
+		certFiles:    strings.Split(certFile, ","),
+		privateFiles: strings.Split(privateFile, ","),
+		done:         make(chan struct{}),
+	}
+	if len(cs.certFiles) != len(cs.privateFiles) {
+		return nil, ErrCertMismatch
+	}
+	if err = cs.Reload(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Reload reloads all the certificates, the old ones keep serving if any
+// of them fails to load.
+func (cs *CertStore) Reload() (err error) {
+	var (
+		certs = make([]*tls.Certificate, 0, len(cs.certFiles))
+		names = make(map[string]*tls.Certificate)
+		mtime = cs.modTime()
+	)
+	for i := range cs.certFiles {
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(cs.certFiles[i], cs.privateFiles[i]); err != nil {
+			log.Errorf("tls.LoadX509KeyPair(%s, %s) error(%v)", cs.certFiles[i], cs.privateFiles[i], err)
+			return
+		}
+		if cert.Leaf == nil {
+			if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				log.Errorf("x509.ParseCertificate(%s) error(%v)", cs.certFiles[i], err)
+				return
+			}
+		}
+		leaf := cert.Leaf
+		if time.Until(leaf.NotAfter) < certExpireWarn {
+			log.Warningf("cert: %s subject: %s expire at: %s", cs.certFiles[i], leaf.Subject.CommonName, leaf.NotAfter)
+		} else {
+			log.Infof("cert: %s subject: %s expire at: %s", cs.certFiles[i], leaf.Subject.CommonName, leaf.NotAfter)
+		}
+		certs = append(certs, &cert)
+		if leaf.Subject.CommonName != "" {
+			if _, ok := names[strings.ToLower(leaf.Subject.CommonName)]; !ok {
+				names[strings.ToLower(leaf.Subject.CommonName)] = &cert
+			}
+		}
+		for _, name := range leaf.DNSNames {
+			if _, ok := names[strings.ToLower(name)]; !ok {
+				names[strings.ToLower(name)] = &cert
+			}
+		}
+	}
+	if len(certs) == 0 {
+		return ErrCertEmpty
+	}
+	cs.mu.Lock()
+	cs.certs = certs
+	cs.names = names
+	cs.mtime = mtime
+	cs.mu.Unlock()
+	return
+}
+
+// GetCertificate returns the certificate matching the SNI server name, the
+// first one if none matches.
+func (cs *CertStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	if len(cs.certs) == 0 {
+		return nil, ErrCertEmpty
+	}
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if cert, ok := cs.names[name]; ok {
+		return cert, nil
+	}
+	// wildcard, replace the first label: a.example.com => *.example.com
+	if idx := strings.IndexByte(name, '.'); idx > 0 {
+		if cert, ok := cs.names["*"+name[idx:]]; ok {
+			return cert, nil
+		}
+	}
+	return cs.certs[0], nil
+}
+
+// TLSConfig returns a tls config serving certificates from the store.
+func (cs *CertStore) TLSConfig() *tls.Config {
+	return &tls.Config{GetCertificate: cs.GetCertificate}
+}
+
+// modTime returns the latest modification time of the cert files.
+func (cs *CertStore) modTime() (mtime time.Time) {
+	for _, files := range [][]string{cs.certFiles, cs.privateFiles} {
+		for _, file := range files {
+			fi, err := os.Stat(file)
+			if err != nil {
+				continue
+			}
+			if fi.ModTime().After(mtime) {
+				mtime = fi.ModTime()
+			}
+		}
+	}
+	return
+}
+
+// changed reports whether any cert file changed since the last reload.
+func (cs *CertStore) changed() bool {
+	cs.mu.RLock()
+	mtime := cs.mtime
+	cs.mu.RUnlock()
+	return cs.modTime().After(mtime)
+}
+
+// watchproc reloads the certificates when the files change.
+func (cs *CertStore) watchproc(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-cs.done:
+			return
+		}
+		if !cs.changed() {
+			continue
+		}
+		if err := cs.Reload(); err != nil {
+			log.Errorf("cert store reload error(%v)", err)
+		} else {
+			log.Infof("cert store reloaded: %v", cs.certFiles)
+		}
+	}
+}
+
+// Close stops polling the files.
+func (cs *CertStore) Close() {
+	close(cs.done)
+}
@@ -0,0 +1,148 @@
+package comet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCert(t *testing.T, dir, name string, serial int64, hosts ...string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		DNSNames:     hosts,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour * 24 * 365),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, name+".pem")
+	keyFile = filepath.Join(dir, name+".key")
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func serialOf(t *testing.T, cs *CertStore, name string) int64 {
+	cert, err := cs.GetCertificate(&tls.ClientHelloInfo{ServerName: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.Leaf.SerialNumber.Int64()
+}
+
+func TestCertStore(t *testing.T) {
+	dir := t.TempDir()
+	c1, k1 := writeCert(t, dir, "a", 1, "a.goim.io")
+	c2, k2 := writeCert(t, dir, "b", 2, "b.goim.io", "*.im.goim.io")
+	cs, err := NewCertStore(c1+","+c2, k1+","+k2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, serial := range map[string]int64{
+		"a.goim.io":       1,
+		"B.goim.io":       2,
+		"x.im.goim.io":    2,
+		"unknown.goim.io": 1,
+	} {
+		if got := serialOf(t, cs, name); got != serial {
+			t.Fatalf("GetCertificate(%s) serial %d want %d", name, got, serial)
+		}
+	}
+	if cs.changed() {
+		t.Fatal("cert store must not change before files rewritten")
+	}
+	// renew
+	writeCert(t, dir, "b", 3, "b.goim.io")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(c2, future, future)
+	if !cs.changed() {
+		t.Fatal("cert store must change after files rewritten")
+	}
+	if err = cs.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := serialOf(t, cs, "b.goim.io"); got != 3 {
+		t.Fatalf("GetCertificate(b.goim.io) serial %d want 3 after reload", got)
+	}
+	// broken files keep serving the old certs
+	os.WriteFile(c2, []byte("broken"), 0600)
+	if err = cs.Reload(); err == nil {
+		t.Fatal("Reload() must fail with broken cert")
+	}
+	if got := serialOf(t, cs, "b.goim.io"); got != 3 {
+		t.Fatalf("GetCertificate(b.goim.io) serial %d want 3 after failed reload", got)
+	}
+}
+
+func TestCertStoreClose(t *testing.T) {
+	c1, k1 := writeCert(t, t.TempDir(), "a", 1, "a.goim.io")
+	cs, err := NewCertStore(c1, k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		cs.watchproc(time.Millisecond)
+		close(done)
+	}()
+	cs.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watchproc must return after Close")
+	}
+}
+
+func TestCertStoreHandshake(t *testing.T) {
+	dir := t.TempDir()
+	c1, k1 := writeCert(t, dir, "a", 1, "a.goim.io")
+	cs, err := NewCertStore(c1, k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", cs.TLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		conn.(*tls.Conn).Handshake()
+		conn.Close()
+	}()
+	conn, err := tls.Dial("tcp", lis.Addr().String(), &tls.Config{ServerName: "a.goim.io", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if cn := conn.ConnectionState().PeerCertificates[0].Subject.CommonName; cn != "a.goim.io" {
+		t.Fatalf("peer cert %s want a.goim.io", cn)
+	}
+}
@@ -2,11 +2,11 @@ package comet
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"sync"
 
 	"github.com/Terry-Mao/goim/api/protocol"
-	"github.com/Terry-Mao/goim/internal/comet/errors"
 	"github.com/Terry-Mao/goim/pkg/bufio"
 )
 
@@ -16,7 +16,7 @@ type Channel struct {
 	Rooms    map[string]*Room // Support multiple rooms
 	roomLock sync.RWMutex // Protect Rooms map
 	CliProto Ring
-	signal   chan *protocol.Proto
+	signal   chan message
 	Writer   bufio.Writer
 	Reader   bufio.Reader
 	Next     *Channel
@@ -25,16 +25,42 @@ type Channel struct {
 	Mid      int64
 	Key      string
 	IP       string
+	Platform string
+	App      string
 	watchOps map[int32]struct{}
 	mutex    sync.RWMutex
+
+	// slow consumer
+	ofConf   *overflowConf
+	ofLock   sync.Mutex
+	overflow []message
+	missed   int
+	drops    uint64
+	kicked   int32
+	conn     net.Conn
+	closer   func()
+
+	// session resume
+	resume   string
+	rsLock   sync.Mutex
+	seq      int64
+	replay   []*protocol.Proto
+	rsMissed int
+	taken    int32
+
+	// heartbeat timeout changed by config update
+	hbTimeout int64
+	beatAt    int64
 }
 
 // NewChannel new a channel.
 func NewChannel(cli, svr int) *Channel {
 	c := new(Channel)
 	c.CliProto.Init(cli)
-	c.signal = make(chan *protocol.Proto, svr)
+	c.signal = make(chan message, svr)
 	c.watchOps = make(map[int32]struct{})
+	// config update is always pushed
+	c.watchOps[protocol.OpConfigUpdate] = struct{}{}
 	c.Rooms = make(map[string]*Room)
 	return c
 }
@@ -70,32 +96,56 @@ func (c *Channel) NeedPush(op int32) bool {
 	return false
 }
 
-// Push server push message.
+// Push server push message to the key.
 func (c *Channel) Push(p *protocol.Proto) (err error) {
-	fmt.Fprintf(os.Stderr, "=== Channel Push: key=%s protoOp=%d bodyLen=%d ===\n", c.Key, p.Op, len(p.Body))
-	select {
-	case c.signal <- p:
-		fmt.Fprintf(os.Stderr, "=== Channel Push: SUCCESS sent to signal ===\n")
-	default:
-		fmt.Fprintf(os.Stderr, "=== Channel Push: FAILED signal full ===\n")
-		err = errors.ErrSignalFullMsgDropped
+	var policy string
+	if c.ofConf != nil {
+		policy = c.ofConf.key
 	}
-	return
+	return c.push(message{p: p}, policy)
+}
+
+// PushRoom server push room message, the frame encoded once for the room
+// is written instead of the proto if it's not nil, the reference of the
+// frame is taken over by the channel.
+func (c *Channel) PushRoom(p *protocol.Proto, f *protocol.Frame) (err error) {
+	var policy string
+	if c.ofConf != nil {
+		policy = c.ofConf.room
+	}
+	return c.push(message{p: p, f: f}, policy)
+}
+
+// PushBroadcast server push broadcast message, the frame is written
+// instead of the proto if it's not nil like PushRoom.
+func (c *Channel) PushBroadcast(p *protocol.Proto, f *protocol.Frame) (err error) {
+	var policy string
+	if c.ofConf != nil {
+		policy = c.ofConf.broadcast
+	}
+	return c.push(message{p: p, f: f}, policy)
+}
+
+// SetOverflow sets the slow consumer policy and the conn closed by it.
+func (c *Channel) SetOverflow(ofc *overflowConf, conn net.Conn) {
+	c.ofConf = ofc
+	c.conn = conn
 }
 
 // Ready check the channel ready or close?
-func (c *Channel) Ready() *protocol.Proto {
-	return <-c.signal
+func (c *Channel) Ready() (*protocol.Proto, *protocol.Frame) {
+	m := <-c.signal
+	return m.p, m.f
 }
 
 // Signal send signal to the channel, protocol ready.
 func (c *Channel) Signal() {
-	c.signal <- protocol.ProtoReady
+	c.signal <- message{p: protocol.ProtoReady}
 }
 
 // Close close the channel.
 func (c *Channel) Close() {
-	c.signal <- protocol.ProtoFinish
+	c.signal <- message{p: protocol.ProtoFinish}
 }
 
 // AddRoom add a room to the channel
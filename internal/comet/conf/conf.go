@@ -84,16 +84,60 @@ func Default() *Config {
 			Writer:       32,
 			WriteBuf:     1024,
 			WriteBufSize: 8192,
+			CertReload:   xtime.Duration(time.Minute),
 		},
 		Websocket: &Websocket{
-			Bind: []string{":3102"},
+			Bind:       []string{":3102"},
+			CertReload: xtime.Duration(time.Minute),
+		},
+		MQTT: &MQTT{},
+		HTTP: &HTTP{
+			PollTimeout: xtime.Duration(time.Second * 25),
+			Ping:        xtime.Duration(time.Second * 25),
 		},
 		Protocol: &Protocol{
-			Timer:            32,
-			TimerSize:        2048,
-			CliProto:         5,
-			SvrProto:         10,
-			HandshakeTimeout: xtime.Duration(time.Second * 5),
+			Timer:             32,
+			TimerSize:         2048,
+			TimerType:         "heap",
+			TimerTick:         xtime.Duration(time.Millisecond * 100),
+			CliProto:          5,
+			SvrProto:          10,
+			HandshakeTimeout:  xtime.Duration(time.Second * 5),
+			HeartbeatRate:     0,
+			HeartbeatBurst:    3,
+			RoomRate:          0,
+			RoomBurst:         10,
+			OpRate:            0,
+			OpBurst:           50,
+			RateAbuse:         0,
+			RateAbuseWindow:   xtime.Duration(time.Minute),
+			OverflowSize:      64,
+			KeyOverflow:       "spill",
+			RoomOverflow:      "drop_oldest",
+			BroadcastOverflow: "spill",
+			ResumeWindow:      xtime.Duration(time.Second * 30),
+			ResumeBuffer:      64,
+			AuthExpiring:      xtime.Duration(time.Minute),
+		},
+		Metrics: &Metrics{},
+		Broadcast: &Broadcast{
+			Queue:       64,
+			UrgentQueue: 16,
+			Speed:       0,
+			Batch:       100,
+			History:     100,
+		},
+		Limit: &Limit{
+			Policy: "reject",
+		},
+		RoomHistory: &RoomHistory{
+			Idle:     xtime.Duration(time.Minute * 10),
+			MaxRooms: 10000,
+		},
+		Presence: &Presence{
+			Batch: 100,
+			Flush: xtime.Duration(time.Second),
+			Queue: 1024,
 		},
 		Bucket: &Bucket{
 			Size:          32,
@@ -107,16 +151,23 @@ func Default() *Config {
 
 // Config is comet config.
 type Config struct {
-	Debug     bool
-	Env       *Env
-	Discovery *naming.Config
-	TCP       *TCP
-	Websocket *Websocket
-	Protocol  *Protocol
-	Bucket    *Bucket
-	RPCClient *RPCClient
-	RPCServer *RPCServer
-	Whitelist *Whitelist
+	Debug       bool
+	Env         *Env
+	Discovery   *naming.Config
+	TCP         *TCP
+	Websocket   *Websocket
+	MQTT        *MQTT
+	HTTP        *HTTP
+	Protocol    *Protocol
+	Bucket      *Bucket
+	Limit       *Limit
+	Metrics     *Metrics
+	Broadcast   *Broadcast
+	RoomHistory *RoomHistory
+	Presence    *Presence
+	RPCClient   *RPCClient
+	RPCServer   *RPCServer
+	Whitelist   *Whitelist
 }
 
 // Env is env config.
@@ -160,6 +211,16 @@ type TCP struct {
 	Writer       int
 	WriteBuf     int
 	WriteBufSize int
+	// tls
+	TLSOpen     bool
+	TLSBind     []string
+	CertFile    string
+	PrivateFile string
+	CertReload  xtime.Duration
+	// PROXY protocol v1/v2 of the trusted proxies, it can't be enabled
+	// without any
+	ProxyProtocol bool
+	ProxyTrusted  []string
 }
 
 // Websocket is websocket config.
@@ -169,15 +230,108 @@ type Websocket struct {
 	TLSBind     []string
 	CertFile    string
 	PrivateFile string
+	CertReload  xtime.Duration
+	// PROXY protocol v1/v2 of the trusted proxies, it can't be enabled
+	// without any
+	ProxyProtocol bool
+	ProxyTrusted  []string
+}
+
+// MQTT is MQTT 3.1.1 listener config, an empty bind disables it.
+type MQTT struct {
+	Bind []string
+}
+
+// HTTP is the long polling and server-sent events config, an empty bind
+// disables it. Ping is the interval of the comments keeping the stream.
+type HTTP struct {
+	Bind        []string
+	PollTimeout xtime.Duration
+	Ping        xtime.Duration
+}
+
+// Broadcast is broadcast queue config, Speed is the default messages per
+// second across buckets, zero means unlimited.
+type Broadcast struct {
+	Queue       int
+	UrgentQueue int
+	Speed       int32
+	Batch       int
+	History     int
+}
+
+// Metrics is metrics config.
+type Metrics struct {
+	Addr string
+}
+
+// Limit is connection limit config, zero means unlimited.
+type Limit struct {
+	IPMax          int
+	MidPlatformMax int
+	// Policy reject or evict
+	Policy string
 }
 
 // Protocol is protocol config.
 type Protocol struct {
-	Timer            int
-	TimerSize        int
+	Timer     int
+	TimerSize int
+	// TimerType heap or wheel, the wheel expires in TimerTick precision
+	TimerType        string
+	TimerTick        xtime.Duration
 	SvrProto         int
 	CliProto         int
 	HandshakeTimeout xtime.Duration
+	// upstream rate limit per channel, zero rate means unlimited, zero
+	// RateAbuse never disconnects
+	HeartbeatRate   float64
+	HeartbeatBurst  int
+	RoomRate        float64
+	RoomBurst       int
+	OpRate          float64
+	OpBurst         int
+	RateAbuse       int
+	RateAbuseWindow xtime.Duration
+	// slow consumer policy per op class: drop, spill, drop_oldest or disconnect
+	OverflowSize      int
+	KeyOverflow       string
+	RoomOverflow      string
+	BroadcastOverflow string
+	// keep the disconnected session for ResumeWindow with at most
+	// ResumeBuffer messages not acked, zero window disables resume
+	ResumeWindow xtime.Duration
+	ResumeBuffer int
+	// tell the client AuthExpiring ahead of the token expiry
+	AuthExpiring xtime.Duration
+}
+
+// RoomHistory is the room history replayed on join, a room keeps it by the
+// longest prefix matched, none if no prefix matches. The history of a room
+// not pushed or joined for Idle is evicted, at most MaxRooms are kept.
+type RoomHistory struct {
+	Idle     xtime.Duration
+	MaxRooms int
+	Rooms    []*HistoryRoom
+}
+
+// HistoryRoom is the history of the rooms by the prefix, the last Size
+// messages in Window and Bytes, zero Window or Bytes means unlimited.
+type HistoryRoom struct {
+	Prefix string
+	Size   int
+	Window xtime.Duration
+	Bytes  int
+}
+
+// Presence is the room join/leave events reported to logic, the rooms with
+// a prefix in Rooms report them in Batch or every Flush, the events over the
+// Queue are dropped.
+type Presence struct {
+	Rooms []string
+	Batch int
+	Flush xtime.Duration
+	Queue int
 }
 
 // Bucket is bucket config.
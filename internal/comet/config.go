@@ -0,0 +1,59 @@
+package comet
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/protocol"
+	log "github.com/golang/glog"
+)
+
+// clientConfig is the part of the runtime config pushed to the clients
+// which comet follows.
+type clientConfig struct {
+	Heartbeat    int32 `json:"heartbeat"`
+	HeartbeatMax int32 `json:"heartbeat_max"`
+}
+
+// configHeartbeat returns the heartbeat timeout of the config update proto,
+// zero if it's not changed.
+func configHeartbeat(p *protocol.Proto) time.Duration {
+	if p.Op != protocol.OpConfigUpdate {
+		return 0
+	}
+	var c clientConfig
+	if err := json.Unmarshal(p.Body, &c); err != nil {
+		log.Errorf("config update json.Unmarshal(%s) error(%v)", p.Body, err)
+		return 0
+	}
+	if c.Heartbeat <= 0 || c.HeartbeatMax <= 0 {
+		return 0
+	}
+	return time.Duration(c.Heartbeat) * time.Duration(c.HeartbeatMax) * time.Second
+}
+
+// SetHeartbeat sets the heartbeat timeout of the channel.
+func (c *Channel) SetHeartbeat(d time.Duration) {
+	atomic.StoreInt64(&c.hbTimeout, int64(d))
+}
+
+// Heartbeat returns the heartbeat timeout of the channel.
+func (c *Channel) Heartbeat() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.hbTimeout))
+}
+
+// beat records the heartbeat received.
+func (c *Channel) beat(now time.Time) {
+	atomic.StoreInt64(&c.beatAt, now.UnixNano())
+}
+
+// heartbeatLeft returns the time left before the heartbeat timeout, it's not
+// positive if timed out or in handshake.
+func (c *Channel) heartbeatLeft(now time.Time) time.Duration {
+	at := atomic.LoadInt64(&c.beatAt)
+	if at == 0 {
+		return 0
+	}
+	return time.Duration(at + atomic.LoadInt64(&c.hbTimeout) - now.UnixNano())
+}
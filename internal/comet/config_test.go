@@ -0,0 +1,84 @@
+package comet
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/Terry-Mao/goim/api/comet"
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+)
+
+func TestConfigHeartbeat(t *testing.T) {
+	for _, c := range []struct {
+		p *protocol.Proto
+		d time.Duration
+	}{
+		{&protocol.Proto{Op: protocol.OpConfigUpdate, Body: []byte(`{"heartbeat":60,"heartbeat_max":3}`)}, 3 * time.Minute},
+		{&protocol.Proto{Op: protocol.OpConfigUpdate, Body: []byte(`{"features":{"typing":true}}`)}, 0},
+		{&protocol.Proto{Op: protocol.OpConfigUpdate, Body: []byte(`{`)}, 0},
+		{&protocol.Proto{Op: protocol.OpRaw, Body: []byte(`{"heartbeat":60,"heartbeat_max":3}`)}, 0},
+	} {
+		if d := configHeartbeat(c.p); d != c.d {
+			t.Fatalf("configHeartbeat(%s) = %v want %v", c.p.Body, d, c.d)
+		}
+	}
+}
+
+func TestChannelHeartbeat(t *testing.T) {
+	ch := NewChannel(5, 10)
+	now := time.Now()
+	if d := ch.heartbeatLeft(now); d > 0 {
+		t.Fatalf("heartbeat left %v in handshake", d)
+	}
+	ch.SetHeartbeat(time.Minute)
+	ch.beat(now)
+	ch.SetHeartbeat(3 * time.Minute)
+	if d := ch.heartbeatLeft(now.Add(time.Minute)); d != 2*time.Minute {
+		t.Fatalf("heartbeat left %v want 2m", d)
+	}
+	if d := ch.heartbeatLeft(now.Add(4 * time.Minute)); d > 0 {
+		t.Fatalf("heartbeat left %v after timeout", d)
+	}
+}
+
+func TestBroadcastConfig(t *testing.T) {
+	buckets := newBroadcastBuckets(3)
+	for _, ch := range buckets[0].Channels() {
+		ch.SetHeartbeat(time.Minute)
+		if ch.Key != "0" {
+			ch.Platform = "android"
+		}
+	}
+	b := newTestBroadcaster(&conf.Broadcast{Queue: 1, UrgentQueue: 1, Batch: 10, History: 10}, buckets)
+	// config update is not watched by the client
+	_, err := b.Push(&pb.BroadcastReq{
+		ProtoOp:  protocol.OpConfigUpdate,
+		Platform: "android",
+		Proto:    &protocol.Proto{Op: protocol.OpConfigUpdate, Body: []byte(`{"heartbeat":60,"heartbeat_max":3}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.run(<-b.normal)
+	for _, ch := range buckets[0].Channels() {
+		want := 3 * time.Minute
+		if ch.Key == "0" {
+			want = time.Minute
+		}
+		if d := ch.Heartbeat(); d != want {
+			t.Fatalf("channel %s heartbeat %v want %v", ch.Key, d, want)
+		}
+		select {
+		case m := <-ch.signal:
+			if ch.Key == "0" || m.p.Op != protocol.OpConfigUpdate {
+				t.Fatalf("channel %s got op %d", ch.Key, m.p.Op)
+			}
+			m.release()
+		default:
+			if ch.Key != "0" {
+				t.Fatalf("channel %s got nothing", ch.Key)
+			}
+		}
+	}
+}
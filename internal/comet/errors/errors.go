@@ -25,7 +25,14 @@ var (
 	// bucket
 	ErrBroadCastArg     = errors.New("rpc broadcast arg error")
 	ErrBroadCastRoomArg = errors.New("rpc broadcast  room arg error")
+	ErrRoomMembersArg   = errors.New("rpc room members arg error")
+	// broadcast
+	ErrBroadcastQueueFull = errors.New("broadcast queue full")
+	ErrBroadcastExists    = errors.New("broadcast id exists")
 
+	// limit
+	ErrConnIPLimit     = errors.New("connections per ip over limit")
+	ErrConnDeviceLimit = errors.New("connections per mid and platform over limit")
 	// room
 	ErrRoomDroped = errors.New("room droped")
 	// rpc
@@ -60,18 +60,10 @@ func (s *server) PushMsg(ctx context.Context, req *pb.PushMsgReq) (reply *pb.Pus
 			continue
 		}
 		fmt.Fprintf(os.Stderr, "=== COMET PushMsg: got bucket for key=%s ===\n", key)
-		if channel := bucket.Channel(key); channel != nil {
-			fmt.Fprintf(os.Stderr, "=== COMET PushMsg: got channel for key=%s, NeedPush=%v ===\n", key, channel.NeedPush(req.ProtoOp))
-			if !channel.NeedPush(req.ProtoOp) {
-				continue
-			}
-			if err = channel.Push(req.Proto); err != nil {
-				fmt.Fprintf(os.Stderr, "=== COMET PushMsg: Push error for key=%s: %v ===\n", key, err)
-				return
-			}
-			fmt.Fprintf(os.Stderr, "=== COMET PushMsg: Push SUCCESS for key=%s ===\n", key)
-		} else {
-			fmt.Fprintf(os.Stderr, "=== COMET PushMsg: channel is nil for key=%s ===\n", key)
+		// parked sessions buffer the message for resume
+		if err = s.srv.PushKey(key, req.ProtoOp, req.Proto); err != nil {
+			fmt.Fprintf(os.Stderr, "=== COMET PushMsg: Push error for key=%s: %v ===\n", key, err)
+			return
 		}
 	}
 	return &pb.PushMsgReply{}, nil
@@ -82,17 +74,21 @@ func (s *server) Broadcast(ctx context.Context, req *pb.BroadcastReq) (*pb.Broad
 	if req.Proto == nil {
 		return nil, errors.ErrBroadCastArg
 	}
-	// TODO use broadcast queue
-	go func() {
-		for _, bucket := range s.srv.Buckets() {
-			bucket.Broadcast(req.GetProto(), req.ProtoOp)
-			if req.Speed > 0 {
-				t := bucket.ChannelCount() / int(req.Speed)
-				time.Sleep(time.Duration(t) * time.Second)
-			}
-		}
-	}()
-	return &pb.BroadcastReply{}, nil
+	id, err := s.srv.Broadcaster().Push(req)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.BroadcastReply{Id: id}, nil
+}
+
+// CancelBroadcast cancel a queued or running broadcast.
+func (s *server) CancelBroadcast(ctx context.Context, req *pb.CancelBroadcastReq) (*pb.CancelBroadcastReply, error) {
+	return &pb.CancelBroadcastReply{Canceled: s.srv.Broadcaster().Cancel(req.Id)}, nil
+}
+
+// BroadcastProgress get the progress of broadcasts.
+func (s *server) BroadcastProgress(ctx context.Context, req *pb.BroadcastProgressReq) (*pb.BroadcastProgressReply, error) {
+	return &pb.BroadcastProgressReply{Tasks: s.srv.Broadcaster().Progress(req.Id)}, nil
 }
 
 // BroadcastRoom broadcast msg to specified room.
@@ -100,12 +96,18 @@ func (s *server) BroadcastRoom(ctx context.Context, req *pb.BroadcastRoomReq) (*
 	if req.Proto == nil || req.RoomID == "" {
 		return nil, errors.ErrBroadCastRoomArg
 	}
-	for _, bucket := range s.srv.Buckets() {
-		bucket.BroadcastRoom(req)
-	}
+	s.srv.BroadcastRoom(req)
 	return &pb.BroadcastRoomReply{}, nil
 }
 
+// RoomMembers gets the mids in the room.
+func (s *server) RoomMembers(ctx context.Context, req *pb.RoomMembersReq) (*pb.RoomMembersReply, error) {
+	if req.RoomID == "" {
+		return nil, errors.ErrRoomMembersArg
+	}
+	return &pb.RoomMembersReply{Mids: s.srv.RoomMembers(req.RoomID, req.Cursor, int(req.Size))}, nil
+}
+
 // Rooms gets all the room ids for the server.
 func (s *server) Rooms(ctx context.Context, req *pb.RoomsReq) (*pb.RoomsReply, error) {
 	var (
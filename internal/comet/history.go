@@ -0,0 +1,174 @@
+package comet
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	log "github.com/golang/glog"
+)
+
+// roomHistory keeps the last messages of the rooms for the late joiners, it's
+// shared by the buckets so that a room keeps it without any channel.
+type roomHistory struct {
+	c     *conf.RoomHistory
+	mu    sync.Mutex
+	rooms map[string]*historyRing
+	done  chan struct{}
+}
+
+// historyRing is the messages of a room, oldest first.
+type historyRing struct {
+	rule  *conf.HistoryRoom
+	msgs  []historyMsg
+	bytes int
+	last  time.Time // last pushed or joined
+}
+
+type historyMsg struct {
+	p  *protocol.Proto
+	at time.Time
+}
+
+// newRoomHistory returns nil if no room keeps the history.
+func newRoomHistory(c *conf.RoomHistory) *roomHistory {
+	if c == nil || len(c.Rooms) == 0 {
+		return nil
+	}
+	return &roomHistory{c: c, rooms: make(map[string]*historyRing), done: make(chan struct{})}
+}
+
+// rule returns the rule of the longest prefix matched, nil if none.
+func (h *roomHistory) rule(roomID string) (r *conf.HistoryRoom) {
+	for _, hr := range h.c.Rooms {
+		if hr.Size > 0 && matchRoom(roomID, hr.Prefix) && (r == nil || len(hr.Prefix) > len(r.Prefix)) {
+			r = hr
+		}
+	}
+	return
+}
+
+// Push keeps the message of the room, a raw batch is kept as the messages
+// in it so that the bounds count the messages.
+func (h *roomHistory) Push(roomID string, p *protocol.Proto, now time.Time) {
+	if h == nil {
+		return
+	}
+	ps := []*protocol.Proto{p}
+	if p.Op == protocol.OpRaw {
+		var err error
+		if ps, err = protocol.SplitRaw(p.Body); err != nil {
+			log.Errorf("room: %s history split raw error(%v)", roomID, err)
+			return
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.rooms[roomID]
+	if !ok {
+		rule := h.rule(roomID)
+		if rule == nil {
+			return
+		}
+		if h.c.MaxRooms > 0 && len(h.rooms) >= h.c.MaxRooms {
+			h.evict(now)
+			if len(h.rooms) >= h.c.MaxRooms {
+				return
+			}
+		}
+		r = &historyRing{rule: rule}
+		h.rooms[roomID] = r
+	}
+	for _, p := range ps {
+		r.msgs = append(r.msgs, historyMsg{p: p, at: now})
+		r.bytes += len(p.Body)
+	}
+	r.last = now
+	r.trim(now)
+}
+
+// Messages returns the messages of the room to replay.
+func (h *roomHistory) Messages(roomID string, now time.Time) (ps []*protocol.Proto) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.rooms[roomID]
+	if !ok {
+		return
+	}
+	r.last = now
+	r.trim(now)
+	ps = make([]*protocol.Proto, 0, len(r.msgs))
+	for _, m := range r.msgs {
+		ps = append(ps, m.p)
+	}
+	return
+}
+
+// trim drops the oldest messages over the bounds.
+func (r *historyRing) trim(now time.Time) {
+	var (
+		n      int
+		window = time.Duration(r.rule.Window)
+	)
+	for n < len(r.msgs) {
+		m := r.msgs[n]
+		if len(r.msgs)-n <= r.rule.Size &&
+			(r.rule.Bytes <= 0 || r.bytes <= r.rule.Bytes) &&
+			(window <= 0 || now.Sub(m.at) <= window) {
+			break
+		}
+		r.bytes -= len(m.p.Body)
+		n++
+	}
+	if n > 0 {
+		for i := 0; i < n; i++ {
+			r.msgs[i] = historyMsg{}
+		}
+		r.msgs = append(r.msgs[:0], r.msgs[n:]...)
+	}
+}
+
+// evict drops the rooms idle, must be called with the lock.
+func (h *roomHistory) evict(now time.Time) {
+	idle := time.Duration(h.c.Idle)
+	if idle <= 0 {
+		return
+	}
+	for id, r := range h.rooms {
+		if now.Sub(r.last) > idle {
+			delete(h.rooms, id)
+		}
+	}
+}
+
+// evictproc drops the rooms idle periodically till the history is closed.
+func (h *roomHistory) evictproc() {
+	idle := time.Duration(h.c.Idle)
+	if idle <= 0 {
+		return
+	}
+	ticker := time.NewTicker(idle / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-h.done:
+			return
+		}
+		h.mu.Lock()
+		h.evict(time.Now())
+		h.mu.Unlock()
+	}
+}
+
+// Close stops the eviction.
+func (h *roomHistory) Close() {
+	if h == nil {
+		return
+	}
+	close(h.done)
+}
@@ -0,0 +1,185 @@
+package comet
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/Terry-Mao/goim/api/comet"
+	"github.com/Terry-Mao/goim/api/logic"
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	"github.com/Terry-Mao/goim/pkg/bytes"
+	xtime "github.com/Terry-Mao/goim/pkg/time"
+)
+
+func newTestHistory(rooms ...*conf.HistoryRoom) *roomHistory {
+	return newRoomHistory(&conf.RoomHistory{Idle: xtime.Duration(time.Minute), MaxRooms: 2, Rooms: rooms})
+}
+
+func historyOps(ps []*protocol.Proto) (ops []int32) {
+	for _, p := range ps {
+		ops = append(ops, p.Op)
+	}
+	return
+}
+
+func equalOps(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRoomHistoryRule(t *testing.T) {
+	if h := newRoomHistory(conf.Default().RoomHistory); h != nil {
+		t.Fatal("history must be disabled by default")
+	}
+	rules := []*conf.HistoryRoom{
+		{Prefix: "live://", Size: 2},
+		{Prefix: "live://big/", Size: 3},
+		{Prefix: "group://", Size: 0},
+		{Prefix: "@shop/live://", Size: 1},
+	}
+	h := newTestHistory(rules...)
+	now := time.Now()
+	for _, id := range []string{"live://1", "live://big/1", "group://1", "chat://1"} {
+		for op := int32(1); op <= 4; op++ {
+			h.Push(id, &protocol.Proto{Op: op}, now)
+		}
+	}
+	for id, want := range map[string][]int32{
+		"live://1":     {3, 4},
+		"live://big/1": {2, 3, 4},
+		"group://1":    nil,
+		"chat://1":     nil,
+	} {
+		if ops := historyOps(h.Messages(id, now)); !equalOps(ops, want) {
+			t.Fatalf("room: %s history %v want %v", id, ops, want)
+		}
+	}
+	// the rooms of the apps
+	h = newTestHistory(rules...)
+	for _, id := range []string{"@game/live://big/1", "@shop/live://1"} {
+		for op := int32(1); op <= 4; op++ {
+			h.Push(id, &protocol.Proto{Op: op}, now)
+		}
+	}
+	for id, want := range map[string][]int32{
+		"@game/live://big/1": {2, 3, 4},
+		"@shop/live://1":     {4},
+	} {
+		if ops := historyOps(h.Messages(id, now)); !equalOps(ops, want) {
+			t.Fatalf("room: %s history %v want %v", id, ops, want)
+		}
+	}
+}
+
+func TestRoomHistoryTrim(t *testing.T) {
+	h := newTestHistory(&conf.HistoryRoom{Prefix: "live://", Size: 10, Window: xtime.Duration(time.Minute), Bytes: 8})
+	now := time.Now()
+	h.Push("live://1", &protocol.Proto{Op: 1, Body: []byte("1234")}, now.Add(-2*time.Minute))
+	h.Push("live://1", &protocol.Proto{Op: 2, Body: []byte("1234")}, now)
+	h.Push("live://1", &protocol.Proto{Op: 3, Body: []byte("1234")}, now)
+	// op 1 is out of the window, op 2 over the bytes with op 3
+	if ops := historyOps(h.Messages("live://1", now)); !equalOps(ops, []int32{2, 3}) {
+		t.Fatalf("history %v", ops)
+	}
+	h.Push("live://1", &protocol.Proto{Op: 4, Body: []byte("1234")}, now)
+	if ops := historyOps(h.Messages("live://1", now)); !equalOps(ops, []int32{3, 4}) {
+		t.Fatalf("history %v", ops)
+	}
+	if ops := historyOps(h.Messages("live://1", now.Add(2*time.Minute))); len(ops) != 0 {
+		t.Fatalf("history %v out of the window", ops)
+	}
+}
+
+func TestRoomHistoryEvict(t *testing.T) {
+	h := newTestHistory(&conf.HistoryRoom{Prefix: "live://", Size: 10})
+	now := time.Now()
+	h.Push("live://1", &protocol.Proto{Op: 1}, now.Add(-2*time.Minute))
+	h.Push("live://2", &protocol.Proto{Op: 2}, now)
+	// full, live://1 is idle
+	h.Push("live://3", &protocol.Proto{Op: 3}, now)
+	if len(h.Messages("live://1", now)) != 0 || len(h.Messages("live://3", now)) != 1 {
+		t.Fatal("idle room must be evicted for the new one")
+	}
+	// full, none is idle
+	h.Push("live://4", &protocol.Proto{Op: 4}, now)
+	if len(h.Messages("live://4", now)) != 0 {
+		t.Fatal("room must not be kept over max rooms")
+	}
+}
+
+func TestRoomHistoryClose(t *testing.T) {
+	h := newRoomHistory(&conf.RoomHistory{Idle: xtime.Duration(time.Millisecond), MaxRooms: 2, Rooms: []*conf.HistoryRoom{{Prefix: "live://", Size: 10}}})
+	done := make(chan struct{})
+	go func() {
+		h.evictproc()
+		close(done)
+	}()
+	h.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("evictproc must return after Close")
+	}
+}
+
+func TestJoinRoomHistory(t *testing.T) {
+	s := newResumeServer(10)
+	s.history = newTestHistory(&conf.HistoryRoom{Prefix: "live://", Size: 2})
+	s.buckets[0].history = s.history
+	for op := int32(1000); op < 1003; op++ {
+		s.BroadcastRoom(&pb.BroadcastRoomReq{RoomID: "live://1", Proto: &protocol.Proto{Op: op}})
+	}
+	ch := NewChannel(5, 5)
+	ch.Key = "k"
+	if err := s.Bucket("k").Put("live://1", ch); err != nil {
+		t.Fatal(err)
+	}
+	if ops := readyOps(ch); !equalOps(ops, []int32{1001, 1002}) {
+		t.Fatalf("replayed %v", ops)
+	}
+}
+
+func TestRoomHistoryRaw(t *testing.T) {
+	h := newTestHistory(&conf.HistoryRoom{Prefix: "live://", Size: 2})
+	w := bytes.NewWriterSize(64)
+	for op := int32(1); op <= 3; op++ {
+		(&protocol.Proto{Ver: protocol.Ver1, Op: op, Body: []byte("1")}).WriteTo(w)
+	}
+	now := time.Now()
+	h.Push("live://1", &protocol.Proto{Op: protocol.OpRaw, Body: w.Buffer()}, now)
+	// the size counts the messages in the batch
+	if ops := historyOps(h.Messages("live://1", now)); !equalOps(ops, []int32{2, 3}) {
+		t.Fatalf("history %v", ops)
+	}
+}
+
+func TestResumeNoHistory(t *testing.T) {
+	s := newResumeServer(10)
+	s.history = newTestHistory(&conf.HistoryRoom{Prefix: "live://", Size: 2})
+	s.buckets[0].history = s.history
+	reply := &logic.ConnectReply{Mid: 1, Key: "k", RoomID: "live://1", Accepts: []int32{1000}, Resume: "k.s"}
+	ch := newResumeChannel(s, reply)
+	s.BroadcastRoom(&pb.BroadcastRoomReq{RoomID: "live://1", Proto: &protocol.Proto{Op: 1000}})
+	readySeqs(ch)
+	rooms := channelRooms(ch)
+	s.Bucket("k").Del(ch)
+	if !s.park(ch, rooms) {
+		t.Fatal("resumable channel must be parked")
+	}
+	reply.Resumed = true
+	nch := newResumeChannel(s, reply)
+	if ops := readyOps(nch); len(ops) != 0 {
+		t.Fatalf("resumed channel replayed the history %v", ops)
+	}
+	if !nch.HasRoom("live://1") {
+		t.Fatal("room must be restored")
+	}
+}
@@ -0,0 +1,181 @@
+package comet
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	"github.com/Terry-Mao/goim/internal/comet/errors"
+	log "github.com/golang/glog"
+)
+
+const (
+	// LimitPolicyReject reject the new connection over the limit.
+	LimitPolicyReject = "reject"
+	// LimitPolicyEvict evict the oldest connection over the limit.
+	LimitPolicyEvict = "evict"
+
+	// disconnect reasons
+	reasonIPLimit     = "ip_limit"
+	reasonDeviceLimit = "device_limit"
+
+	// _disconnectWait is the time to write the disconnect reason.
+	_disconnectWait = time.Second
+)
+
+// connLimiter limits the connections per ip and per mid and platform across
+// all the buckets, channels are kept in connecting order.
+type connLimiter struct {
+	c    *conf.Limit
+	mu   sync.Mutex
+	ips  map[string][]*Channel
+	mids map[string][]*Channel
+}
+
+func newConnLimiter(c *conf.Limit) *connLimiter {
+	return &connLimiter{
+		c:    c,
+		ips:  make(map[string][]*Channel),
+		mids: make(map[string][]*Channel),
+	}
+}
+
+func midPlatformKey(ch *Channel) string {
+	if ch.Mid == 0 {
+		return ""
+	}
+	key := strconv.FormatInt(ch.Mid, 10) + "_" + ch.Platform
+	if ch.App != "" {
+		// the same mid in another app is another user
+		key = "@" + ch.App + "/" + key
+	}
+	return key
+}
+
+// CheckIP checks the ip limit of the reject policy before the auth, so that
+// the connection over it costs no auth, it's checked again by Acquire.
+func (l *connLimiter) CheckIP(ip string) (reason string, err error) {
+	if l == nil || l.c == nil || l.c.IPMax <= 0 || l.c.Policy == LimitPolicyEvict {
+		return
+	}
+	l.mu.Lock()
+	n := len(l.ips[ip])
+	l.mu.Unlock()
+	if n >= l.c.IPMax {
+		stats.Add(statConnIPLimit, 1)
+		return reasonIPLimit, errors.ErrConnIPLimit
+	}
+	return
+}
+
+// Acquire counts the channel in, with the reject policy it returns the
+// disconnect reason if a limit is reached, with the evict policy the oldest
+// channels are disconnected instead.
+func (l *connLimiter) Acquire(ch *Channel) (reason string, err error) {
+	if l == nil || l.c == nil {
+		return
+	}
+	var (
+		evicts []*Channel
+		evict  = l.c.Policy == LimitPolicyEvict
+		mkey   = midPlatformKey(ch)
+	)
+	l.mu.Lock()
+	ipChs := l.ips[ch.IP]
+	midChs := l.mids[mkey]
+	if l.c.IPMax > 0 && len(ipChs) >= l.c.IPMax {
+		if !evict {
+			l.mu.Unlock()
+			stats.Add(statConnIPLimit, 1)
+			return reasonIPLimit, errors.ErrConnIPLimit
+		}
+		evicts = append(evicts, ipChs[:len(ipChs)-l.c.IPMax+1]...)
+	}
+	if mkey != "" && l.c.MidPlatformMax > 0 && len(midChs) >= l.c.MidPlatformMax {
+		if !evict {
+			l.mu.Unlock()
+			stats.Add(statConnDeviceLimit, 1)
+			return reasonDeviceLimit, errors.ErrConnDeviceLimit
+		}
+		evicts = append(evicts, midChs[:len(midChs)-l.c.MidPlatformMax+1]...)
+	}
+	// a channel over both limits is evicted once
+	seen := make(map[*Channel]struct{}, len(evicts))
+	for i, och := range evicts {
+		if _, ok := seen[och]; ok {
+			evicts[i] = nil
+			continue
+		}
+		seen[och] = struct{}{}
+		l.del(och)
+	}
+	l.ips[ch.IP] = append(l.ips[ch.IP], ch)
+	if mkey != "" {
+		l.mids[mkey] = append(l.mids[mkey], ch)
+	}
+	l.mu.Unlock()
+	for _, och := range evicts {
+		if och == nil {
+			continue
+		}
+		reason := reasonIPLimit
+		if mkey != "" && midPlatformKey(och) == mkey {
+			reason = reasonDeviceLimit
+		}
+		stats.Add(statConnEvicted, 1)
+		log.Infof("key: %s mid: %d ip: %s evicted by key: %s reason: %s", och.Key, och.Mid, och.IP, ch.Key, reason)
+		disconnect(och, reason)
+	}
+	return
+}
+
+// Release counts the channel out.
+func (l *connLimiter) Release(ch *Channel) {
+	if l == nil || l.c == nil {
+		return
+	}
+	l.mu.Lock()
+	l.del(ch)
+	l.mu.Unlock()
+}
+
+func (l *connLimiter) del(ch *Channel) {
+	l.ips[ch.IP] = delChannel(l.ips[ch.IP], ch)
+	if len(l.ips[ch.IP]) == 0 {
+		delete(l.ips, ch.IP)
+	}
+	if mkey := midPlatformKey(ch); mkey != "" {
+		if l.mids[mkey] = delChannel(l.mids[mkey], ch); len(l.mids[mkey]) == 0 {
+			delete(l.mids, mkey)
+		}
+	}
+}
+
+func delChannel(chs []*Channel, ch *Channel) []*Channel {
+	for i, c := range chs {
+		if c == ch {
+			return append(chs[:i], chs[i+1:]...)
+		}
+	}
+	return chs
+}
+
+// disconnectProto returns the proto telling the client why it's closed.
+func disconnectProto(reason string) *protocol.Proto {
+	return &protocol.Proto{Ver: protocol.Ver1, Op: protocol.OpDisconnectReason, Body: []byte(reason)}
+}
+
+// disconnect sends the reason to the channel then closes its conn, the
+// dispatch goroutine closes the conn once the reason is written, or the
+// deadline does if the client doesn't read. It never blocks, the channel is
+// closed by the reader goroutine only.
+func disconnect(ch *Channel, reason string) {
+	if err := ch.Push(disconnectProto(reason)); err != nil {
+		log.Errorf("key: %s push disconnect reason error(%v)", ch.Key, err)
+		ch.closeConn(0)
+		return
+	}
+	ch.closeConn(_disconnectWait)
+}
@@ -0,0 +1,156 @@
+package comet
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/logic"
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	"github.com/Terry-Mao/goim/internal/comet/errors"
+	"github.com/Terry-Mao/goim/pkg/bufio"
+	"github.com/Terry-Mao/goim/pkg/bytes"
+	"google.golang.org/grpc"
+)
+
+func newLimitChannel(key, ip string, mid int64, platform string) *Channel {
+	ch := NewChannel(5, 10)
+	ch.Key, ch.IP, ch.Mid, ch.Platform = key, ip, mid, platform
+	return ch
+}
+
+func TestConnLimiterReject(t *testing.T) {
+	l := newConnLimiter(&conf.Limit{IPMax: 2, MidPlatformMax: 1, Policy: LimitPolicyReject})
+	a := newLimitChannel("a", "1.1.1.1", 1, "web")
+	if _, err := l.Acquire(a); err != nil {
+		t.Fatal(err)
+	}
+	// same mid other platform
+	if _, err := l.Acquire(newLimitChannel("b", "1.1.1.1", 1, "ios")); err != nil {
+		t.Fatal(err)
+	}
+	// the ip is checked before the auth
+	if reason, err := l.CheckIP("1.1.1.1"); err != errors.ErrConnIPLimit || reason != reasonIPLimit {
+		t.Fatalf("CheckIP() error(%v) reason(%s)", err, reason)
+	}
+	if _, err := l.CheckIP("2.2.2.2"); err != nil {
+		t.Fatal(err)
+	}
+	if reason, err := l.Acquire(newLimitChannel("c", "1.1.1.1", 2, "web")); err != errors.ErrConnIPLimit || reason != reasonIPLimit {
+		t.Fatalf("Acquire() error(%v) reason(%s)", err, reason)
+	}
+	if _, err := l.Acquire(newLimitChannel("d", "2.2.2.2", 1, "web")); err != errors.ErrConnDeviceLimit {
+		t.Fatalf("Acquire() error(%v) want %v", err, errors.ErrConnDeviceLimit)
+	}
+	l.Release(a)
+	if _, err := l.Acquire(newLimitChannel("d", "2.2.2.2", 1, "web")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnLimiterEvict(t *testing.T) {
+	l := newConnLimiter(&conf.Limit{MidPlatformMax: 1, Policy: LimitPolicyEvict})
+	old := newLimitChannel("a", "1.1.1.1", 1, "web")
+	if _, err := l.Acquire(old); err != nil {
+		t.Fatal(err)
+	}
+	// anonymous connections have no device limit
+	for _, key := range []string{"x", "y"} {
+		if _, err := l.Acquire(newLimitChannel(key, "1.1.1.1", 0, "web")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := l.Acquire(newLimitChannel("b", "2.2.2.2", 1, "web")); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := old.Ready()
+	if p.Op != protocol.OpDisconnectReason || string(p.Body) != reasonDeviceLimit {
+		t.Fatalf("evicted channel got %v", p)
+	}
+	if len(old.signal) != 0 {
+		t.Fatal("evicted channel must be closed by its reader")
+	}
+	if n := len(l.mids["1_web"]); n != 1 {
+		t.Fatalf("mid channels %d want 1", n)
+	}
+}
+
+// blockLogic keeps the reader in Receive till released.
+type blockLogic struct {
+	*testLogic
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (l *blockLogic) Receive(ctx context.Context, in *logic.ReceiveReq, opts ...grpc.CallOption) (*logic.ReceiveReply, error) {
+	l.entered <- struct{}{}
+	<-l.release
+	return &logic.ReceiveReply{}, nil
+}
+
+func TestDisconnectReader(t *testing.T) {
+	s, tl := newTestServer()
+	l := &blockLogic{testLogic: tl, entered: make(chan struct{}), release: make(chan struct{})}
+	s.rpcClient = l
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		s.ServeTCP(conn, bytes.NewPool(1, 1024), bytes.NewPool(1, 1024), s.round.Timer(0))
+	}()
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var (
+		rr = bufio.NewReader(conn)
+		wr = bufio.NewWriter(conn)
+		p  = &protocol.Proto{Ver: protocol.Ver1, Op: protocol.OpAuth, Body: []byte("token")}
+	)
+	if err = p.WriteTCP(wr); err != nil {
+		t.Fatal(err)
+	}
+	if err = wr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if err = p.ReadTCP(rr); err != nil || p.Op != protocol.OpAuthReply {
+		t.Fatalf("auth reply %v error(%v)", p, err)
+	}
+	// the reader is kept in logic till the dispatch is done
+	p = &protocol.Proto{Ver: protocol.Ver1, Op: 1000}
+	if err = p.WriteTCP(wr); err == nil {
+		err = wr.Flush()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-l.entered
+	ch := s.Bucket("k").Channel("k")
+	disconnect(ch, reasonAuthExpired)
+	if err = p.ReadTCP(rr); err != nil || p.Op != protocol.OpDisconnectReason || string(p.Body) != reasonAuthExpired {
+		t.Fatalf("disconnect reason %v error(%v)", p, err)
+	}
+	// the pushes fill the signal after the dispatch is done
+	for i := 0; i < s.c.Protocol.SvrProto; i++ {
+		_ = ch.Push(&protocol.Proto{Ver: protocol.Ver1, Op: 1000})
+	}
+	close(l.release)
+	select {
+	case key := <-l.disconnected:
+		if key != "k" {
+			t.Fatalf("disconnected %s want k", key)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("reader not exited")
+	}
+}
@@ -0,0 +1,129 @@
+package comet
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Terry-Mao/goim/pkg/proxyproto"
+	log "github.com/golang/glog"
+)
+
+// listenConf is the per listener conn upgrade: PROXY protocol then tls.
+type listenConf struct {
+	proxy   bool
+	trusted []*net.IPNet
+	tls     *tls.Config
+}
+
+// errProxyTrusted the PROXY protocol is enabled without the trusted proxies.
+var errProxyTrusted = errors.New("proxy protocol needs the trusted proxies")
+
+// newListenConf trusts the PROXY header of the peers in the trusted CIDRs
+// only, the PROXY protocol can't be enabled without any.
+func newListenConf(proxy bool, trusted []string, tlsCfg *tls.Config) (lc *listenConf, err error) {
+	if proxy && len(trusted) == 0 {
+		log.Errorf("proxy protocol enabled without trusted proxies")
+		return nil, errProxyTrusted
+	}
+	lc = &listenConf{proxy: proxy, tls: tlsCfg}
+	for _, cidr := range trusted {
+		var ipnet *net.IPNet
+		if !strings.Contains(cidr, "/") {
+			if strings.Contains(cidr, ":") {
+				cidr += "/128"
+			} else {
+				cidr += "/32"
+			}
+		}
+		if _, ipnet, err = net.ParseCIDR(cidr); err != nil {
+			log.Errorf("net.ParseCIDR(%s) error(%v)", cidr, err)
+			return
+		}
+		lc.trusted = append(lc.trusted, ipnet)
+	}
+	return
+}
+
+// trust reports whether the PROXY header sent by addr is honored, an empty
+// trusted list trusts no peer.
+func (lc *listenConf) trust(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, ipnet := range lc.trusted {
+		if ipnet.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// upgrade reads the PROXY header from trusted peers within timeout and wraps
+// the conn with tls if it's enabled, the tls handshake is done lazily by the
+// first read under the protocol handshake timer.
+func (lc *listenConf) upgrade(conn *net.TCPConn, timeout time.Duration) (c net.Conn, err error) {
+	c = conn
+	if lc.proxy && lc.trust(conn.RemoteAddr()) {
+		pc := proxyproto.NewConn(conn)
+		_ = conn.SetReadDeadline(time.Now().Add(timeout))
+		if err = pc.Handshake(); err != nil {
+			return
+		}
+		_ = conn.SetReadDeadline(time.Time{})
+		c = pc
+	}
+	if lc.tls != nil {
+		c = tls.Server(c, lc.tls)
+	}
+	return
+}
+
+// loadTLSConfig loads the comma separated cert and private key pairs into a
+// reloadable cert store, the files are polled every reload if it's set.
+func (s *Server) loadTLSConfig(certFile, privateFile string, reload time.Duration) (tlsCfg *tls.Config, err error) {
+	var cs *CertStore
+	if cs, err = NewCertStore(certFile, privateFile); err != nil {
+		log.Errorf("NewCertStore(%s, %s) error(%v)", certFile, privateFile, err)
+		return
+	}
+	if reload > 0 {
+		go cs.watchproc(reload)
+	}
+	s.certMu.Lock()
+	s.certs = append(s.certs, cs)
+	s.certMu.Unlock()
+	return cs.TLSConfig(), nil
+}
+
+// ReloadCerts reloads the tls certificates of all the listeners.
+func (s *Server) ReloadCerts() (err error) {
+	s.certMu.Lock()
+	certs := s.certs
+	s.certMu.Unlock()
+	for _, cs := range certs {
+		if e := cs.Reload(); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+// setSockopt applies the tcp options to an accepted conn.
+func setSockopt(s *Server, conn *net.TCPConn) (err error) {
+	if err = conn.SetKeepAlive(s.c.TCP.KeepAlive); err != nil {
+		log.Errorf("conn.SetKeepAlive() error(%v)", err)
+		return
+	}
+	if err = conn.SetReadBuffer(s.c.TCP.Rcvbuf); err != nil {
+		log.Errorf("conn.SetReadBuffer() error(%v)", err)
+		return
+	}
+	if err = conn.SetWriteBuffer(s.c.TCP.Sndbuf); err != nil {
+		log.Errorf("conn.SetWriteBuffer() error(%v)", err)
+	}
+	return
+}
@@ -0,0 +1,69 @@
+package comet
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// proxyConn accepts a conn sending the PROXY header from the client ip and
+// upgrades it by lc.
+func proxyConn(t *testing.T, lc *listenConf) (net.Conn, error) {
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte("PROXY TCP4 1.2.3.4 127.0.0.1 1111 2222\r\nping"))
+		time.Sleep(time.Second)
+		conn.Close()
+	}()
+	conn, err := l.AcceptTCP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return lc.upgrade(conn, time.Second)
+}
+
+func TestListenConfProxy(t *testing.T) {
+	if _, err := newListenConf(true, nil, nil); err != errProxyTrusted {
+		t.Fatalf("proxy without trusted error(%v)", err)
+	}
+	lc, err := newListenConf(true, []string{"127.0.0.1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := proxyConn(t, lc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip := c.RemoteAddr().(*net.TCPAddr).IP.String(); ip != "1.2.3.4" {
+		t.Fatalf("trusted proxy remote ip %s", ip)
+	}
+}
+
+func TestListenConfUntrusted(t *testing.T) {
+	lc, err := newListenConf(true, []string{"10.0.0.0/8"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := proxyConn(t, lc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the header is not honored, it's read as the payload
+	if ip := c.RemoteAddr().(*net.TCPAddr).IP.String(); ip != "127.0.0.1" {
+		t.Fatalf("untrusted peer remote ip %s", ip)
+	}
+	buf := make([]byte, 5)
+	if _, err = io.ReadFull(c, buf); err != nil || string(buf) != "PROXY" {
+		t.Fatalf("untrusted peer read %q error(%v)", buf, err)
+	}
+}
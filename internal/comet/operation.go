@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"time"
 
 	"github.com/Terry-Mao/goim/api/logic"
 	"github.com/Terry-Mao/goim/api/protocol"
@@ -16,19 +15,20 @@ import (
 )
 
 // Connect connected a connection.
-func (s *Server) Connect(c context.Context, p *protocol.Proto, cookie string) (mid int64, key, rid string, accepts []int32, heartbeat time.Duration, err error) {
+func (s *Server) Connect(c context.Context, p *protocol.Proto, cookie, ip string) (reply *logic.ConnectReply, err error) {
 	fmt.Fprintf(os.Stderr, "=== Comet Connect START: serverID=%s token=%s ===\n", s.serverID, string(p.Body))
-	reply, err := s.rpcClient.Connect(c, &logic.ConnectReq{
+	reply, err = s.rpcClient.Connect(c, &logic.ConnectReq{
 		Server: s.serverID,
 		Cookie: cookie,
 		Token:  p.Body,
+		Ip:     ip,
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "=== Comet Connect gRPC error: %v ===\n", err)
 		return
 	}
 	fmt.Fprintf(os.Stderr, "=== Comet Connect success: mid=%d key=%s roomID=%s ===\n", reply.Mid, reply.Key, reply.RoomID)
-	return reply.Mid, reply.Key, reply.RoomID, reply.Accepts, time.Duration(reply.Heartbeat), nil
+	return
 }
 
 // Disconnect disconnected a connection.
@@ -51,11 +51,27 @@ func (s *Server) Heartbeat(ctx context.Context, mid int64, key string) (err erro
 	return
 }
 
+// Reauth refreshes the token of a connection session.
+func (s *Server) Reauth(ctx context.Context, mid int64, key string, token []byte) (expire int64, err error) {
+	reply, err := s.rpcClient.Reauth(ctx, &logic.ReauthReq{
+		Server: s.serverID,
+		Mid:    mid,
+		Key:    key,
+		Token:  token,
+	})
+	if err != nil {
+		return
+	}
+	return reply.Expire, nil
+}
+
 // RenewOnline renew room online.
-func (s *Server) RenewOnline(ctx context.Context, serverID string, roomCount map[string]int32) (allRoom map[string]int32, err error) {
+func (s *Server) RenewOnline(ctx context.Context, serverID string, roomCount map[string]int32, roomUsers map[string]*logic.RoomUsers, appConns map[string]int32) (allRoom map[string]int32, err error) {
 	reply, err := s.rpcClient.RenewOnline(ctx, &logic.OnlineReq{
 		Server:    s.serverID,
 		RoomCount: roomCount,
+		RoomUsers: roomUsers,
+		AppConns:  appConns,
 	}, grpc.UseCompressor(gzip.Name))
 	if err != nil {
 		return
@@ -73,8 +89,10 @@ func (s *Server) Receive(ctx context.Context, mid int64, p *protocol.Proto) (err
 func (s *Server) Operate(ctx context.Context, p *protocol.Proto, ch *Channel, b *Bucket) error {
 	switch p.Op {
 	case protocol.OpChangeRoom:
-		if err := b.ChangeRoom(string(p.Body), ch); err != nil {
-			log.Errorf("b.ChangeRoom(%s) error(%v)", p.Body, err)
+		if roomID, ok := appRoomID(ch.App, string(p.Body)); !ok {
+			log.Warningf("key:%s change to room:%s of a namespace", ch.Key, p.Body)
+		} else if err := b.ChangeRoom(roomID, ch); err != nil {
+			log.Errorf("b.ChangeRoom(%s) error(%v)", roomID, err)
 		}
 		p.Op = protocol.OpChangeRoomReply
 	case protocol.OpSub:
@@ -87,6 +105,10 @@ func (s *Server) Operate(ctx context.Context, p *protocol.Proto, ch *Channel, b
 			ch.UnWatch(ops...)
 		}
 		p.Op = protocol.OpUnsubReply
+	case protocol.OpAck:
+		ch.ack(p.Seq)
+		p.Op = protocol.OpAckReply
+		p.Body = nil
 	default:
 		// TODO ack ok&failed
 		if err := s.Receive(ctx, ch.Mid, p); err != nil {
@@ -0,0 +1,199 @@
+package comet
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	"github.com/Terry-Mao/goim/internal/comet/errors"
+	"github.com/Terry-Mao/goim/pkg/bufio"
+	"github.com/Terry-Mao/goim/pkg/websocket"
+	log "github.com/golang/glog"
+)
+
+// slow consumer policies when the signal channel is full.
+const (
+	// OverflowDrop drops the new message.
+	OverflowDrop = "drop"
+	// OverflowSpill spills to the bounded overflow queue, drops the new
+	// message if the queue is full.
+	OverflowSpill = "spill"
+	// OverflowDropOldest spills to the bounded overflow queue, drops the
+	// oldest queued message if the queue is full.
+	OverflowDropOldest = "drop_oldest"
+	// OverflowDisconnect disconnects the slow consumer.
+	OverflowDisconnect = "disconnect"
+
+	reasonSlowConsumer = "slow_consumer"
+)
+
+// overflowConf is the slow consumer policy per op class.
+type overflowConf struct {
+	size      int
+	key       string
+	room      string
+	broadcast string
+}
+
+func newOverflowConf(c *conf.Protocol) *overflowConf {
+	return &overflowConf{
+		size:      c.OverflowSize,
+		key:       c.KeyOverflow,
+		room:      c.RoomOverflow,
+		broadcast: c.BroadcastOverflow,
+	}
+}
+
+// message is a proto or a pre-encoded frame of the proto sent to the
+// dispatch goroutine.
+type message struct {
+	p *protocol.Proto
+	f *protocol.Frame
+}
+
+// writeTCP writes the frame if any or encodes the proto, the body is queued
+// without copy, the frame is appended to fs to be released after flushed.
+func (m message) writeTCP(wr *bufio.Writer, fs []*protocol.Frame) ([]*protocol.Frame, error) {
+	if m.f != nil {
+		return append(fs, m.f), m.f.WriteTCP(wr)
+	}
+	return fs, m.p.WriteTCPNoCopy(wr)
+}
+
+// writeWebsocket writes the frame if any or encodes the proto, the body is
+// queued without copy, the frame is appended to fs to be released after
+// flushed.
+func (m message) writeWebsocket(ws *websocket.Conn, fs []*protocol.Frame) ([]*protocol.Frame, error) {
+	if m.f != nil {
+		return append(fs, m.f), m.f.WriteWebsocket(ws)
+	}
+	return fs, m.p.WriteWebsocketNoCopy(ws)
+}
+
+// releaseFrames releases the frames written and flushed.
+func releaseFrames(fs []*protocol.Frame) []*protocol.Frame {
+	for i, f := range fs {
+		f.Release()
+		fs[i] = nil
+	}
+	return fs[:0]
+}
+
+// release releases the frame of a message which is never written.
+func (m message) release() {
+	if m.f != nil {
+		m.f.Release()
+	}
+}
+
+// push pushes the message to the channel with the policy.
+func (c *Channel) push(m message, policy string) (err error) {
+	c.ofLock.Lock()
+	// keep the order, once spilled all the messages go to the queue until
+	// the dispatch goroutine drains it.
+	if len(c.overflow) == 0 {
+		select {
+		case c.signal <- m:
+			c.ofLock.Unlock()
+			return
+		default:
+		}
+	}
+	if c.ofConf == nil || c.ofConf.size <= 0 {
+		policy = OverflowDrop
+	}
+	switch policy {
+	case OverflowSpill, OverflowDropOldest:
+		if len(c.overflow) < c.ofConf.size {
+			c.overflow = append(c.overflow, m)
+			c.ofLock.Unlock()
+			stats.Add(statPushSpilled, 1)
+			return
+		}
+		if policy == OverflowDropOldest {
+			c.overflow[0].release()
+			copy(c.overflow, c.overflow[1:])
+			c.overflow[len(c.overflow)-1] = m
+		} else {
+			m.release()
+			err = errors.ErrSignalFullMsgDropped
+		}
+		c.missed++
+		c.ofLock.Unlock()
+	case OverflowDisconnect:
+		c.ofLock.Unlock()
+		m.release()
+		c.kick()
+		return errors.ErrSignalFullMsgDropped
+	default:
+		c.missed++
+		c.ofLock.Unlock()
+		m.release()
+		err = errors.ErrSignalFullMsgDropped
+	}
+	c.addDrops(1)
+	stats.Add(statPushDropped, 1)
+	return
+}
+
+// drainOverflow takes the spilled messages, ended with a missed messages
+// notification if any message was dropped since the last call.
+func (c *Channel) drainOverflow() (ms []message) {
+	c.ofLock.Lock()
+	if len(c.overflow) == 0 && c.missed == 0 {
+		c.ofLock.Unlock()
+		return
+	}
+	ms = c.overflow
+	c.overflow = nil
+	if c.missed > 0 {
+		ms = append(ms, message{p: missedProto(c.missed)})
+		c.missed = 0
+	}
+	c.ofLock.Unlock()
+	return
+}
+
+// addDrops counts the messages dropped for the channel.
+func (c *Channel) addDrops(n uint64) {
+	drops := atomic.AddUint64(&c.drops, n)
+	atomic.AddUint64(&channelDrops, n)
+	topDrops.add(c.Key, drops)
+}
+
+// Drops returns the count of the messages dropped for the channel.
+func (c *Channel) Drops() uint64 {
+	return atomic.LoadUint64(&c.drops)
+}
+
+// kick closes the conn of the slow consumer.
+func (c *Channel) kick() {
+	if !c.closeConn(0) {
+		return
+	}
+	stats.Add(statSlowConsumer, 1)
+	log.Warningf("key: %s mid: %d ip: %s %s, disconnect", c.Key, c.Mid, c.IP, reasonSlowConsumer)
+}
+
+// closeConn closes the conn once after d, the deadline fails the pending
+// read and write without blocking the caller, then the reader goroutine
+// closes the channel. The channel without a conn is closed by its closer.
+func (c *Channel) closeConn(d time.Duration) bool {
+	if !atomic.CompareAndSwapInt32(&c.kicked, 0, 1) {
+		return false
+	}
+	if c.conn != nil {
+		_ = c.conn.SetDeadline(time.Now().Add(d))
+	} else if c.closer != nil {
+		c.closer()
+	}
+	return true
+}
+
+// missedProto tells the client n messages were dropped, it should resync
+// through history.
+func missedProto(n int) *protocol.Proto {
+	return &protocol.Proto{Ver: protocol.Ver1, Op: protocol.OpMissedMessages, Body: []byte(strconv.Itoa(n))}
+}
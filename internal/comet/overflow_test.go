@@ -0,0 +1,112 @@
+package comet
+
+import (
+	"encoding/json"
+	"expvar"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/errors"
+)
+
+func newOverflowChannel(ofc *overflowConf) *Channel {
+	ch := NewChannel(5, 1)
+	ch.SetOverflow(ofc, nil)
+	return ch
+}
+
+func seqs(ms []message) (res []int64) {
+	for _, m := range ms {
+		res = append(res, m.p.Seq)
+	}
+	return
+}
+
+func TestChannelOverflowSpill(t *testing.T) {
+	ch := newOverflowChannel(&overflowConf{size: 2, key: OverflowSpill})
+	ch.Key = "spill"
+	drops := atomic.LoadUint64(&channelDrops)
+	for i := int64(1); i <= 3; i++ {
+		if err := ch.Push(&protocol.Proto{Seq: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ch.Push(&protocol.Proto{Seq: 4}); err != errors.ErrSignalFullMsgDropped {
+		t.Fatalf("Push() error(%v) want %v", err, errors.ErrSignalFullMsgDropped)
+	}
+	if p, _ := ch.Ready(); p.Seq != 1 {
+		t.Fatalf("signal seq %d want 1", p.Seq)
+	}
+	// keep order, the signal has space but the queue is not drained
+	ch.Push(&protocol.Proto{Seq: 5})
+	if len(ch.signal) != 0 {
+		t.Fatal("message must be queued after the spilled ones")
+	}
+	ps := ch.drainOverflow()
+	if got := seqs(ps); len(got) != 3 || got[0] != 2 || got[1] != 3 {
+		t.Fatalf("overflow %v", got)
+	}
+	if last := ps[len(ps)-1].p; last.Op != protocol.OpMissedMessages || string(last.Body) != "2" {
+		t.Fatalf("missed proto %v", last)
+	}
+	if ch.Drops() != 2 {
+		t.Fatalf("drops %d want 2", ch.Drops())
+	}
+	if n := atomic.LoadUint64(&channelDrops) - drops; n != 2 {
+		t.Fatalf("all channel drops %d want 2", n)
+	}
+	var top map[string]uint64
+	if err := json.Unmarshal([]byte(expvar.Get("comet_channel_top_drops").String()), &top); err != nil {
+		t.Fatal(err)
+	}
+	if top["spill"] != 2 {
+		t.Fatalf("key drops %v want 2", top)
+	}
+	if ps = ch.drainOverflow(); ps != nil {
+		t.Fatalf("overflow must be drained, got %v", ps)
+	}
+}
+
+func TestChannelOverflowDropOldest(t *testing.T) {
+	ch := newOverflowChannel(&overflowConf{size: 2, room: OverflowDropOldest})
+	for i := int64(1); i <= 5; i++ {
+		if err := ch.PushRoom(&protocol.Proto{Seq: i}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ps := ch.drainOverflow()
+	if got := seqs(ps); len(got) != 3 || got[0] != 4 || got[1] != 5 {
+		t.Fatalf("overflow %v", got)
+	}
+}
+
+func TestChannelOverflowDisconnect(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	ch := NewChannel(5, 1)
+	ch.SetOverflow(&overflowConf{size: 2, broadcast: OverflowDisconnect}, c1)
+	ch.PushBroadcast(&protocol.Proto{Seq: 1}, nil)
+	if err := ch.PushBroadcast(&protocol.Proto{Seq: 2}, nil); err == nil {
+		t.Fatal("slow consumer must be disconnected")
+	}
+	if _, err := c1.Write([]byte("x")); err == nil {
+		t.Fatal("kicked conn write must fail")
+	}
+}
+
+func TestDropTop(t *testing.T) {
+	top := newDropTop(2)
+	top.add("a", 1)
+	top.add("b", 3)
+	// fewer drops than the least kept
+	top.add("c", 1)
+	top.add("a", 2)
+	top.add("d", 4)
+	top.add("e", 3)
+	drops := top.drops().(map[string]uint64)
+	if len(drops) != 2 || drops["d"] != 4 || drops["b"] != 3 {
+		t.Fatalf("drops %v", drops)
+	}
+}
@@ -0,0 +1,115 @@
+package comet
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/logic"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	log "github.com/golang/glog"
+)
+
+// presence reports the room join/leave events of the mids to logic in
+// batch, it's shared by the buckets.
+type presence struct {
+	c      *conf.Presence
+	events chan *logic.PresenceEvent
+	mu     sync.Mutex
+	// the buckets of the mids in the rooms
+	mids map[string]map[int64]int
+}
+
+// newPresence returns nil if no room reports the events.
+func newPresence(c *conf.Presence) *presence {
+	if c == nil || len(c.Rooms) == 0 {
+		return nil
+	}
+	return &presence{
+		c:      c,
+		events: make(chan *logic.PresenceEvent, c.Queue),
+		mids:   make(map[string]map[int64]int),
+	}
+}
+
+// reports reports whether the room reports the events.
+func (p *presence) reports(roomID string) bool {
+	for _, prefix := range p.c.Rooms {
+		if matchRoom(roomID, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds the event of the mid joining or leaving the room in a bucket if
+// the room reports, the guests are not. The channels of a mid are in the
+// different buckets, the mid joins with its first bucket and leaves with
+// its last one.
+func (p *presence) add(roomID string, mid int64, join bool) {
+	if p == nil || mid == 0 || !p.reports(roomID) {
+		return
+	}
+	p.mu.Lock()
+	mids := p.mids[roomID]
+	if mids == nil {
+		mids = make(map[int64]int)
+		p.mids[roomID] = mids
+	}
+	n := mids[mid]
+	if join {
+		n++
+	} else {
+		n--
+	}
+	if n > 0 {
+		mids[mid] = n
+	} else {
+		delete(mids, mid)
+	}
+	if len(mids) == 0 {
+		delete(p.mids, roomID)
+	}
+	p.mu.Unlock()
+	if (join && n > 1) || (!join && n > 0) {
+		// in the room by another bucket
+		return
+	}
+	select {
+	case p.events <- &logic.PresenceEvent{RoomID: roomID, Mid: mid, Join: join}:
+	default:
+		stats.Add(statPresenceDropped, 1)
+	}
+}
+
+// proc reports the events in batch or every flush.
+func (p *presence) proc(report func([]*logic.PresenceEvent)) {
+	var (
+		events = make([]*logic.PresenceEvent, 0, p.c.Batch)
+		ticker = time.NewTicker(time.Duration(p.c.Flush))
+	)
+	defer ticker.Stop()
+	for {
+		select {
+		case e := <-p.events:
+			if events = append(events, e); len(events) < p.c.Batch {
+				continue
+			}
+		case <-ticker.C:
+			if len(events) == 0 {
+				continue
+			}
+		}
+		report(events)
+		events = make([]*logic.PresenceEvent, 0, p.c.Batch)
+	}
+}
+
+// reportPresence reports the room join/leave events to logic.
+func (s *Server) reportPresence(events []*logic.PresenceEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.c.RPCClient.Timeout))
+	defer cancel()
+	if _, err := s.rpcClient.Presence(ctx, &logic.PresenceReq{Server: s.serverID, Events: events}); err != nil {
+		log.Errorf("report presence events:%d error(%v)", len(events), err)
+	}
+}
@@ -0,0 +1,119 @@
+package comet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/logic"
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	xtime "github.com/Terry-Mao/goim/pkg/time"
+)
+
+func presenceEvents(p *presence) (events []logic.PresenceEvent) {
+	for {
+		select {
+		case e := <-p.events:
+			events = append(events, *e)
+		default:
+			return
+		}
+	}
+}
+
+func TestPresence(t *testing.T) {
+	if p := newPresence(conf.Default().Presence); p != nil {
+		t.Fatal("presence must be disabled by default")
+	}
+	p := newPresence(&conf.Presence{Rooms: []string{"live://"}, Batch: 10, Flush: xtime.Duration(time.Second), Queue: 10})
+	b := NewBucket(&conf.Bucket{Channel: 10, Room: 10, RoutineAmount: 1, RoutineSize: 1})
+	b.presence = p
+	var (
+		tab1  = newRoomChannel("k1", 1)
+		tab2  = newRoomChannel("k2", 1)
+		guest = newRoomChannel("k3", 0)
+		group = newRoomChannel("k4", 2)
+		shop  = newRoomChannel("k5", 3)
+	)
+	_ = b.Put("live://1", tab1)
+	_ = b.Put("live://1", tab2)
+	_ = b.Put("live://1", guest)
+	_ = b.Put("group://1", group)
+	b.Del(tab1)
+	b.Del(guest)
+	b.Del(group)
+	if events := presenceEvents(p); len(events) != 1 || events[0].RoomID != "live://1" || events[0].Mid != 1 || !events[0].Join {
+		t.Fatalf("events %+v", events)
+	}
+	// the room of an app
+	_ = b.Put("@shop/live://1", shop)
+	if events := presenceEvents(p); len(events) != 1 || events[0].RoomID != "@shop/live://1" || events[0].Mid != 3 || !events[0].Join {
+		t.Fatalf("app events %+v", events)
+	}
+	b.Del(tab2)
+	if events := presenceEvents(p); len(events) != 1 || events[0].Mid != 1 || events[0].Join {
+		t.Fatalf("events %+v", events)
+	}
+}
+
+func TestPresenceProc(t *testing.T) {
+	p := newPresence(&conf.Presence{Rooms: []string{"live://"}, Batch: 2, Flush: xtime.Duration(20 * time.Millisecond), Queue: 10})
+	reported := make(chan int, 2)
+	go p.proc(func(events []*logic.PresenceEvent) { reported <- len(events) })
+	for mid := int64(1); mid <= 3; mid++ {
+		p.add("live://1", mid, true)
+	}
+	// a full batch, then the rest flushed
+	for _, want := range []int{2, 1} {
+		select {
+		case n := <-reported:
+			if n != want {
+				t.Fatalf("reported %d want %d", n, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("events must be reported")
+		}
+	}
+}
+
+func TestRoomPushPresence(t *testing.T) {
+	r := NewRoom("live://1")
+	sub := newRoomChannel("k1", 1)
+	sub.Watch(protocol.OpPresence)
+	other := newRoomChannel("k2", 2)
+	_ = r.Put(sub)
+	_ = r.Put(other)
+	r.Push(&protocol.Proto{Ver: 1, Op: protocol.OpPresence, Body: []byte(`{}`)})
+	r.Push(&protocol.Proto{Ver: 1, Op: protocol.OpRaw})
+	if ops := readyOps(sub); !equalOps(ops, []int32{protocol.OpPresence, protocol.OpRaw}) {
+		t.Fatalf("subscriber ops %v", ops)
+	}
+	if ops := readyOps(other); !equalOps(ops, []int32{protocol.OpRaw}) {
+		t.Fatalf("ops %v", ops)
+	}
+}
+
+func TestPresenceBuckets(t *testing.T) {
+	p := newPresence(&conf.Presence{Rooms: []string{"live://"}, Batch: 10, Flush: xtime.Duration(time.Second), Queue: 10})
+	b1 := NewBucket(&conf.Bucket{Channel: 10, Room: 10, RoutineAmount: 1, RoutineSize: 1})
+	b2 := NewBucket(&conf.Bucket{Channel: 10, Room: 10, RoutineAmount: 1, RoutineSize: 1})
+	b1.presence, b2.presence = p, p
+	// the tabs of a mid are bucketed by their keys
+	tab1, tab2 := newRoomChannel("k1", 1), newRoomChannel("k2", 1)
+	_ = b1.Put("live://1", tab1)
+	_ = b2.Put("live://1", tab2)
+	if events := presenceEvents(p); len(events) != 1 || !events[0].Join {
+		t.Fatalf("events %+v want a join", events)
+	}
+	b1.Del(tab1)
+	if events := presenceEvents(p); len(events) != 0 {
+		t.Fatalf("events %+v while in the room by a tab", events)
+	}
+	b2.Del(tab2)
+	if events := presenceEvents(p); len(events) != 1 || events[0].Mid != 1 || events[0].Join {
+		t.Fatalf("events %+v want a leave", events)
+	}
+	if len(p.mids) != 0 {
+		t.Fatalf("mids %v left", p.mids)
+	}
+}
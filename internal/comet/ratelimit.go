@@ -0,0 +1,76 @@
+package comet
+
+import (
+	"time"
+
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	"github.com/Terry-Mao/goim/pkg/ratelimit"
+	log "github.com/golang/glog"
+)
+
+const reasonRateLimit = "rate_limit"
+
+// opLimiter limits the upstream protos of a channel with separate budgets
+// for heartbeats, room changes and business ops, it's used by the reader
+// goroutine only.
+type opLimiter struct {
+	c         *conf.Protocol
+	heartbeat *ratelimit.Bucket
+	room      *ratelimit.Bucket
+	op        *ratelimit.Bucket
+	// abuse
+	violations int
+	since      time.Time
+	closing    bool
+}
+
+func newOpLimiter(c *conf.Protocol) *opLimiter {
+	return &opLimiter{
+		c:         c,
+		heartbeat: ratelimit.New(c.HeartbeatRate, c.HeartbeatBurst),
+		room:      ratelimit.New(c.RoomRate, c.RoomBurst),
+		op:        ratelimit.New(c.OpRate, c.OpBurst),
+	}
+}
+
+// check checks the upstream proto against the budgets, an over budget proto
+// is turned into a rate limited warning in place. A persistent abuser is
+// sent a disconnect reason, then the protos must be ignored until the
+// dispatch goroutine closes the conn.
+func (l *opLimiter) check(ch *Channel, p *protocol.Proto) (pass, closing bool) {
+	if l.closing {
+		return false, true
+	}
+	var (
+		now    = time.Now()
+		bucket *ratelimit.Bucket
+		name   string
+	)
+	switch p.Op {
+	case protocol.OpHeartbeat:
+		bucket, name = l.heartbeat, statRateHeartbeat
+	case protocol.OpChangeRoom, protocol.OpSub, protocol.OpUnsub:
+		bucket, name = l.room, statRateRoom
+	default:
+		bucket, name = l.op, statRateOp
+	}
+	if bucket.AllowN(now, 1) {
+		return true, false
+	}
+	stats.Add(name, 1)
+	if now.Sub(l.since) > time.Duration(l.c.RateAbuseWindow) {
+		l.violations = 0
+		l.since = now
+	}
+	if l.violations++; l.c.RateAbuse > 0 && l.violations >= l.c.RateAbuse {
+		log.Warningf("key: %s mid: %d ip: %s op: %d rate limit abuse, disconnect", ch.Key, ch.Mid, ch.IP, p.Op)
+		stats.Add(statRateDisconnect, 1)
+		l.closing = true
+		disconnect(ch, reasonRateLimit)
+		return false, true
+	}
+	p.Op = protocol.OpRateLimited
+	p.Body = nil
+	return false, false
+}
@@ -0,0 +1,48 @@
+package comet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	xtime "github.com/Terry-Mao/goim/pkg/time"
+)
+
+func TestOpLimiter(t *testing.T) {
+	var (
+		ch = newLimitChannel("a", "1.1.1.1", 1, "web")
+		l  = newOpLimiter(&conf.Protocol{
+			HeartbeatRate:   0.001,
+			HeartbeatBurst:  1,
+			OpRate:          0.001,
+			OpBurst:         2,
+			RateAbuse:       3,
+			RateAbuseWindow: xtime.Duration(time.Minute),
+		})
+	)
+	// separate budgets
+	for _, op := range []int32{protocol.OpHeartbeat, protocol.OpSendMsg, protocol.OpSendMsg, protocol.OpChangeRoom} {
+		if pass, _ := l.check(ch, &protocol.Proto{Op: op}); !pass {
+			t.Fatalf("op(%d) must pass", op)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		p := &protocol.Proto{Op: protocol.OpSendMsg, Seq: 7, Body: []byte("msg")}
+		if pass, closing := l.check(ch, p); pass || closing {
+			t.Fatalf("over budget op must be warned")
+		}
+		if p.Op != protocol.OpRateLimited || p.Seq != 7 || p.Body != nil {
+			t.Fatalf("warning proto %v", p)
+		}
+	}
+	if _, closing := l.check(ch, &protocol.Proto{Op: protocol.OpHeartbeat}); !closing {
+		t.Fatal("persistent abuse must be disconnected")
+	}
+	if p, _ := ch.Ready(); p.Op != protocol.OpDisconnectReason || string(p.Body) != reasonRateLimit {
+		t.Fatalf("disconnect reason proto %v", p)
+	}
+	if _, closing := l.check(ch, &protocol.Proto{Op: protocol.OpSendMsg}); !closing {
+		t.Fatal("closing channel must ignore protos")
+	}
+}
@@ -0,0 +1,263 @@
+package comet
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/logic"
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	log "github.com/golang/glog"
+)
+
+// session is a channel parked after disconnected, waiting for the client to
+// resume it within the window, the messages pushed to the key are buffered.
+type session struct {
+	mid     int64
+	key     string
+	resume  string
+	rooms   []string
+	accepts map[int32]struct{}
+	seq     int64
+	replay  []*protocol.Proto
+	missed  int
+	timer   *time.Timer
+}
+
+// sessionStore keeps the parked sessions by key.
+type sessionStore struct {
+	window time.Duration
+	size   int
+	mu     sync.Mutex
+	parked map[string]*session
+}
+
+func newSessionStore(c *conf.Protocol) *sessionStore {
+	return &sessionStore{
+		window: time.Duration(c.ResumeWindow),
+		size:   c.ResumeBuffer,
+		parked: make(map[string]*session),
+	}
+}
+
+// push buffers the proto if the session of the key is parked.
+func (ss *sessionStore) push(key string, op int32, p *protocol.Proto) {
+	ss.mu.Lock()
+	if sess, ok := ss.parked[key]; ok {
+		if _, ok = sess.accepts[op]; ok {
+			sess.seq++
+			sess.replay, sess.missed = appendReplay(sess.replay, sess.missed, ss.size, seqProto(p, sess.seq))
+		}
+	}
+	ss.mu.Unlock()
+}
+
+// take removes the parked session of the key if the token matches.
+func (ss *sessionStore) take(key, resume string) (sess *session) {
+	ss.mu.Lock()
+	if sess = ss.parked[key]; sess != nil {
+		if sess.resume == resume {
+			delete(ss.parked, key)
+			sess.timer.Stop()
+		} else {
+			sess = nil
+		}
+	}
+	ss.mu.Unlock()
+	return
+}
+
+// expire removes the session if it's still parked.
+func (ss *sessionStore) expire(sess *session) (ok bool) {
+	ss.mu.Lock()
+	if ss.parked[sess.key] == sess {
+		delete(ss.parked, sess.key)
+		ok = true
+	}
+	ss.mu.Unlock()
+	return
+}
+
+// appendReplay appends the proto to the bounded replay buffer, the oldest
+// one is dropped and counted in missed if it's full.
+func appendReplay(replay []*protocol.Proto, missed, size int, p *protocol.Proto) ([]*protocol.Proto, int) {
+	if size <= 0 {
+		return replay, missed + 1
+	}
+	if len(replay) >= size {
+		copy(replay, replay[1:])
+		replay = replay[:len(replay)-1]
+		missed++
+	}
+	return append(replay, p), missed
+}
+
+// seqProto returns a copy of the proto with the seq of the key.
+func seqProto(p *protocol.Proto, seq int64) *protocol.Proto {
+	np := *p
+	np.Seq = seq
+	return &np
+}
+
+// pushKey pushes a message to the key, it's buffered for replay until acked
+// if the channel is resumable.
+func (c *Channel) pushKey(p *protocol.Proto, size int) (err error) {
+	if c.resume == "" {
+		return c.Push(p)
+	}
+	c.rsLock.Lock()
+	c.seq++
+	p = seqProto(p, c.seq)
+	c.replay, c.rsMissed = appendReplay(c.replay, c.rsMissed, size, p)
+	c.rsLock.Unlock()
+	return c.Push(p)
+}
+
+// ack drops the buffered messages up to seq.
+func (c *Channel) ack(seq int64) {
+	c.rsLock.Lock()
+	i := 0
+	for i < len(c.replay) && c.replay[i].Seq <= seq {
+		i++
+	}
+	c.replay = append(c.replay[:0], c.replay[i:]...)
+	c.rsMissed = 0
+	c.rsLock.Unlock()
+}
+
+// PushKey pushes the proto to the channel of the key, or buffers it if the
+// session of the key is parked.
+func (s *Server) PushKey(key string, op int32, p *protocol.Proto) (err error) {
+	if ch := s.Bucket(key).Channel(key); ch != nil {
+		if !ch.NeedPush(op) {
+			return
+		}
+		return ch.pushKey(p, s.sessions.size)
+	}
+	s.sessions.push(key, op, p)
+	return
+}
+
+// resumable reports whether the session of the key is parked or kept by the
+// old channel on the comet, the session resumed by logic may be on another
+// comet or lost by a restart.
+func (s *Server) resumable(key, resume string) bool {
+	if old := s.Bucket(key).Channel(key); old != nil && old.resume == resume {
+		return true
+	}
+	ss := s.sessions
+	ss.mu.Lock()
+	sess := ss.parked[key]
+	ss.mu.Unlock()
+	return sess != nil && sess.resume == resume
+}
+
+// resumeChannel makes the channel resumable and restores the session parked
+// or taken over from the old channel of the key, then replays the messages
+// not acked. It must be called before the channel is put into the bucket.
+func (s *Server) resumeChannel(b *Bucket, ch *Channel, reply *logic.ConnectReply) {
+	if reply.Resume == "" || s.sessions.window <= 0 {
+		return
+	}
+	ch.resume = reply.Resume
+	if !reply.Resumed {
+		return
+	}
+	var (
+		rooms   []string
+		accepts []int32
+		missed  int
+	)
+	if old := b.Channel(ch.Key); old != nil && old.resume == reply.Resume {
+		// the old connection is not closed yet
+		atomic.StoreInt32(&old.taken, 1)
+		for rid := range old.GetRooms() {
+			rooms = append(rooms, rid)
+		}
+		old.mutex.RLock()
+		for op := range old.watchOps {
+			accepts = append(accepts, op)
+		}
+		old.mutex.RUnlock()
+		old.rsLock.Lock()
+		ch.seq, ch.replay, missed = old.seq, append([]*protocol.Proto(nil), old.replay...), old.rsMissed
+		old.rsLock.Unlock()
+	} else if sess := s.sessions.take(ch.Key, reply.Resume); sess != nil {
+		rooms = sess.rooms
+		for op := range sess.accepts {
+			accepts = append(accepts, op)
+		}
+		ch.seq, ch.replay, missed = sess.seq, sess.replay, sess.missed
+	} else {
+		// the session is gone since the reply, the client resyncs through
+		// history
+		_ = ch.Push(missedProto(0))
+		return
+	}
+	ch.Watch(accepts...)
+	for _, rid := range rooms {
+		if err := b.joinRoom(ch, rid, false); err != nil {
+			log.Errorf("key: %s resume join room: %s error(%v)", ch.Key, rid, err)
+		}
+	}
+	if missed > 0 {
+		_ = ch.Push(missedProto(missed))
+	}
+	for _, p := range ch.replay {
+		_ = ch.Push(p)
+	}
+	log.Infof("key: %s mid: %d resumed, rooms: %v replay: %d missed: %d", ch.Key, ch.Mid, rooms, len(ch.replay), missed)
+}
+
+// park parks the disconnected channel for resume with its rooms, it returns
+// false if the session should be disconnected now.
+func (s *Server) park(ch *Channel, rooms []string) bool {
+	if atomic.LoadInt32(&ch.taken) == 1 {
+		// resumed by a new connection
+		return true
+	}
+	if ch.resume == "" || s.sessions.window <= 0 {
+		return false
+	}
+	sess := &session{
+		mid:     ch.Mid,
+		key:     ch.Key,
+		resume:  ch.resume,
+		rooms:   rooms,
+		accepts: make(map[int32]struct{}),
+	}
+	ch.mutex.RLock()
+	for op := range ch.watchOps {
+		sess.accepts[op] = struct{}{}
+	}
+	ch.mutex.RUnlock()
+	ch.rsLock.Lock()
+	sess.seq, sess.replay, sess.missed = ch.seq, ch.replay, ch.rsMissed
+	ch.rsLock.Unlock()
+	ss := s.sessions
+	ss.mu.Lock()
+	if old, ok := ss.parked[sess.key]; ok {
+		old.timer.Stop()
+	}
+	ss.parked[sess.key] = sess
+	sess.timer = time.AfterFunc(ss.window, func() {
+		if !ss.expire(sess) {
+			return
+		}
+		if err := s.Disconnect(context.Background(), sess.mid, sess.key); err != nil {
+			log.Errorf("key: %s mid: %d resume expired disconnect error(%v)", sess.key, sess.mid, err)
+		}
+	})
+	ss.mu.Unlock()
+	return true
+}
+
+// channelRooms returns the room ids of the channel.
+func channelRooms(ch *Channel) (rooms []string) {
+	for rid := range ch.GetRooms() {
+		rooms = append(rooms, rid)
+	}
+	return
+}
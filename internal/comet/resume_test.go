@@ -0,0 +1,182 @@
+package comet
+
+import (
+	"testing"
+
+	"github.com/Terry-Mao/goim/api/logic"
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+)
+
+func newResumeServer(size int) *Server {
+	if conf.Conf == nil {
+		conf.Conf = conf.Default()
+	}
+	b := NewBucket(&conf.Bucket{Channel: 10, Room: 10, RoutineAmount: 1, RoutineSize: 1})
+	return &Server{
+		buckets:   []*Bucket{b},
+		bucketIdx: 1,
+		sessions: newSessionStore(&conf.Protocol{
+			ResumeWindow: conf.Default().Protocol.ResumeWindow,
+			ResumeBuffer: size,
+		}),
+	}
+}
+
+func newResumeChannel(s *Server, reply *logic.ConnectReply) *Channel {
+	ch := NewChannel(5, 64)
+	ch.Mid, ch.Key = reply.Mid, reply.Key
+	ch.Watch(reply.Accepts...)
+	b := s.Bucket(ch.Key)
+	s.resumeChannel(b, ch, reply)
+	if err := b.Put(reply.RoomID, ch); err != nil {
+		panic(err)
+	}
+	return ch
+}
+
+// readySeqs returns the seqs of the messages signaled, missed messages as -n.
+func readySeqs(ch *Channel) (seqs []int64) {
+	for {
+		select {
+		case m := <-ch.signal:
+			switch {
+			case m.p == protocol.ProtoFinish:
+			case m.p.Op == protocol.OpMissedMessages:
+				seqs = append(seqs, -int64(len(m.p.Body)))
+			default:
+				seqs = append(seqs, m.p.Seq)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func equalSeqs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestResumeParked(t *testing.T) {
+	s := newResumeServer(3)
+	reply := &logic.ConnectReply{Mid: 1, Key: "k", RoomID: "live://1", Accepts: []int32{1000}, Resume: "k.s"}
+	ch := newResumeChannel(s, reply)
+	for i := 0; i < 3; i++ {
+		if err := s.PushKey("k", 1000, &protocol.Proto{Op: 1000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// not watched
+	_ = s.PushKey("k", 1001, &protocol.Proto{Op: 1001})
+	if seqs := readySeqs(ch); !equalSeqs(seqs, []int64{1, 2, 3}) {
+		t.Fatalf("pushed %v", seqs)
+	}
+	ch.ack(2)
+	rooms := channelRooms(ch)
+	s.Bucket("k").Del(ch)
+	if !s.park(ch, rooms) {
+		t.Fatal("resumable channel must be parked")
+	}
+	// buffered while parked, seq 3 not acked yet
+	for i := 0; i < 3; i++ {
+		_ = s.PushKey("k", 1000, &protocol.Proto{Op: 1000})
+	}
+	if sess := s.sessions.take("k", "k.bad"); sess != nil {
+		t.Fatal("session taken with a wrong token")
+	}
+	reply.Resumed = true
+	nch := newResumeChannel(s, reply)
+	if seqs := readySeqs(nch); !equalSeqs(seqs, []int64{-1, 4, 5, 6}) {
+		t.Fatalf("replayed %v", seqs)
+	}
+	if !nch.HasRoom("live://1") || !nch.NeedPush(1000) {
+		t.Fatal("rooms and ops must be restored")
+	}
+	if len(s.sessions.parked) != 0 {
+		t.Fatalf("parked %d want 0", len(s.sessions.parked))
+	}
+	_ = s.PushKey("k", 1000, &protocol.Proto{Op: 1000})
+	if seqs := readySeqs(nch); !equalSeqs(seqs, []int64{7}) {
+		t.Fatalf("pushed %v", seqs)
+	}
+}
+
+func TestResumeTakeOver(t *testing.T) {
+	s := newResumeServer(10)
+	reply := &logic.ConnectReply{Mid: 1, Key: "k", RoomID: "live://1", Accepts: []int32{1000}, Resume: "k.s"}
+	ch := newResumeChannel(s, reply)
+	_ = s.PushKey("k", 1000, &protocol.Proto{Op: 1000})
+	_ = s.PushKey("k", 1000, &protocol.Proto{Op: 1000})
+	readySeqs(ch)
+	ch.ack(1)
+	// the client reconnects before the old connection is found dead
+	reply.Resumed = true
+	nch := newResumeChannel(s, reply)
+	if seqs := readySeqs(nch); !equalSeqs(seqs, []int64{2}) {
+		t.Fatalf("replayed %v", seqs)
+	}
+	rooms := channelRooms(ch)
+	s.Bucket("k").Del(ch)
+	if !s.park(ch, rooms) {
+		t.Fatal("taken channel must not be disconnected")
+	}
+	if len(s.sessions.parked) != 0 {
+		t.Fatal("taken channel must not be parked")
+	}
+	if s.Bucket("k").Channel("k") != nch || !nch.HasRoom("live://1") {
+		t.Fatal("new channel must stay in the bucket and room")
+	}
+}
+
+func TestResumeDisabled(t *testing.T) {
+	s := newResumeServer(10)
+	s.sessions.window = 0
+	reply := &logic.ConnectReply{Mid: 1, Key: "k", Resume: "k.s"}
+	ch := newResumeChannel(s, reply)
+	if body := s.authReplyBody(reply); body != nil {
+		t.Fatalf("resume body %s", body)
+	}
+	_ = s.PushKey("k", 0, &protocol.Proto{})
+	if s.park(ch, nil) {
+		t.Fatal("channel parked with resume disabled")
+	}
+	if len(ch.replay) != 0 {
+		t.Fatal("message buffered with resume disabled")
+	}
+}
+
+func TestResumeNotParked(t *testing.T) {
+	s := newResumeServer(10)
+	// resumed by logic, the session is on another comet
+	reply := &logic.ConnectReply{Mid: 1, Key: "k", RoomID: "live://1", Accepts: []int32{1000}, Resume: "k.s", Resumed: true}
+	if body := s.authReplyBody(reply); string(body) != `{"resume":"k.s"}` || reply.Resumed {
+		t.Fatalf("resume body %s", body)
+	}
+	ch := newResumeChannel(s, reply)
+	if seqs := readySeqs(ch); len(seqs) != 0 {
+		t.Fatalf("not resumed channel got %v", seqs)
+	}
+	rooms := channelRooms(ch)
+	s.Bucket("k").Del(ch)
+	if !s.park(ch, rooms) {
+		t.Fatal("resumable channel must be parked")
+	}
+	reply.Resumed = true
+	if body := s.authReplyBody(reply); string(body) != `{"resume":"k.s","resumed":true}` || !reply.Resumed {
+		t.Fatalf("resume body %s", body)
+	}
+	// the session is gone since the reply
+	s.sessions.take("k", "k.s")
+	nch := newResumeChannel(s, reply)
+	if p, _ := nch.Ready(); p.Op != protocol.OpMissedMessages {
+		t.Fatalf("lost session got %v want missed", p)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/Terry-Mao/goim/api/protocol"
 	"github.com/Terry-Mao/goim/internal/comet/errors"
+	log "github.com/golang/glog"
 )
 
 // Room is a room and store channel room info.
@@ -14,6 +15,8 @@ type Room struct {
 	channels  map[*Channel]struct{} // Use map for multi-room support
 	next      *Channel              // Kept for backwards compatibility
 	drop      bool
+	mids      map[int64]int32 // mid -> channels, the guests are not in
+	guests    int32
 	Online    int32 // dirty read is ok
 	AllOnline int32
 }
@@ -24,6 +27,7 @@ func NewRoom(id string) (r *Room) {
 	r.ID = id
 	r.drop = false
 	r.channels = make(map[*Channel]struct{})
+	r.mids = make(map[int64]int32)
 	r.next = nil
 	r.Online = 0
 	return
@@ -31,6 +35,13 @@ func NewRoom(id string) (r *Room) {
 
 // Put put channel into the room.
 func (r *Room) Put(ch *Channel) (err error) {
+	_, err = r.join(ch)
+	return
+}
+
+// join puts the channel into the room, first reports whether it's the first
+// channel of the mid in the room.
+func (r *Room) join(ch *Channel) (first bool, err error) {
 	r.rLock.Lock()
 	if !r.drop {
 		// Check if already in room
@@ -40,6 +51,12 @@ func (r *Room) Put(ch *Channel) (err error) {
 			ch.Next = r.next
 			r.next = ch
 			r.Online++
+			if ch.Mid != 0 {
+				r.mids[ch.Mid]++
+				first = r.mids[ch.Mid] == 1
+			} else {
+				r.guests++
+			}
 		}
 	} else {
 		err = errors.ErrRoomDroped
@@ -50,6 +67,13 @@ func (r *Room) Put(ch *Channel) (err error) {
 
 // Del delete channel from the room.
 func (r *Room) Del(ch *Channel) bool {
+	_, drop := r.leave(ch)
+	return drop
+}
+
+// leave deletes the channel from the room, last reports whether it's the
+// last channel of the mid in the room.
+func (r *Room) leave(ch *Channel) (last, drop bool) {
 	r.rLock.Lock()
 	if _, exists := r.channels[ch]; exists {
 		delete(r.channels, ch)
@@ -70,19 +94,40 @@ func (r *Room) Del(ch *Channel) bool {
 			prev = curr
 		}
 		r.Online--
+		if ch.Mid == 0 {
+			r.guests--
+		} else if r.mids[ch.Mid] > 1 {
+			r.mids[ch.Mid]--
+		} else {
+			delete(r.mids, ch.Mid)
+			last = true
+		}
 	}
 	r.drop = r.Online == 0
+	drop = r.drop
 	r.rLock.Unlock()
-	return r.drop
+	return
 }
 
-// Push push msg to the room, if chan full discard it.
+// Push push msg to the room, the msg is encoded once into a frame shared
+// by all the channels. The presence events are pushed to the channels
+// subscribed them only.
 func (r *Room) Push(p *protocol.Proto) {
+	f, err := protocol.NewFrame(p)
+	if err != nil {
+		log.Errorf("room: %s protocol.NewFrame(%d) error(%v)", r.ID, p.Op, err)
+		return
+	}
 	r.rLock.RLock()
 	for ch := range r.channels {
-		_ = ch.Push(p)
+		if p.Op == protocol.OpPresence && !ch.NeedPush(p.Op) {
+			continue
+		}
+		f.Retain()
+		_ = ch.PushRoom(p, f)
 	}
 	r.rLock.RUnlock()
+	f.Release()
 }
 
 // Close close the room.
@@ -94,6 +139,25 @@ func (r *Room) Close() {
 	r.rLock.RUnlock()
 }
 
+// Users returns the distinct users in the room, a guest counts as one.
+func (r *Room) Users() int32 {
+	r.rLock.RLock()
+	n := int32(len(r.mids)) + r.guests
+	r.rLock.RUnlock()
+	return n
+}
+
+// addMids adds the mids in the room to the set, returns the guests.
+func (r *Room) addMids(mids map[int64]struct{}) int32 {
+	r.rLock.RLock()
+	for mid := range r.mids {
+		mids[mid] = struct{}{}
+	}
+	guests := r.guests
+	r.rLock.RUnlock()
+	return guests
+}
+
 // OnlineNum the room all online.
 func (r *Room) OnlineNum() int32 {
 	if r.AllOnline > 0 {
@@ -0,0 +1,74 @@
+package comet
+
+import (
+	"testing"
+
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	"github.com/Terry-Mao/goim/pkg/hll"
+)
+
+func newRoomChannel(key string, mid int64) *Channel {
+	ch := NewChannel(5, 5)
+	ch.Key = key
+	ch.Mid = mid
+	return ch
+}
+
+func TestRoomUsers(t *testing.T) {
+	r := NewRoom("live://1")
+	var (
+		tab1  = newRoomChannel("k1", 1)
+		tab2  = newRoomChannel("k2", 1)
+		other = newRoomChannel("k3", 2)
+		guest = newRoomChannel("k4", 0)
+	)
+	for _, ch := range []*Channel{tab1, tab2, other, guest, tab1} {
+		if err := r.Put(ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r.Online != 4 || r.Users() != 3 {
+		t.Fatalf("online %d users %d", r.Online, r.Users())
+	}
+	r.Del(tab1)
+	r.Del(guest)
+	if r.Online != 2 || r.Users() != 2 {
+		t.Fatalf("online %d users %d", r.Online, r.Users())
+	}
+	r.Del(tab2)
+	if r.Users() != 1 {
+		t.Fatalf("users %d", r.Users())
+	}
+}
+
+func TestRoomsOnline(t *testing.T) {
+	c := &conf.Bucket{Channel: 10, Room: 10, RoutineAmount: 1, RoutineSize: 1}
+	s := &Server{buckets: []*Bucket{NewBucket(c), NewBucket(c)}, bucketIdx: 2}
+	// the tabs of mid 1 are in the different buckets
+	for i, ch := range []*Channel{newRoomChannel("k1", 1), newRoomChannel("k2", 1), newRoomChannel("k3", 3), newRoomChannel("k4", 0)} {
+		if err := s.buckets[i%2].Put("live://1", ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = s.buckets[0].Put("live://2", newRoomChannel("k5", 2))
+	roomCount, roomUsers := s.roomsOnline()
+	users := func(roomID string) int64 {
+		var mids hll.Sketch
+		if err := mids.Merge(roomUsers[roomID].Mids); err != nil {
+			t.Fatal(err)
+		}
+		return mids.Count() + int64(roomUsers[roomID].Guests)
+	}
+	if roomCount["live://1"] != 4 || users("live://1") != 3 || roomCount["live://2"] != 1 || users("live://2") != 1 {
+		t.Fatalf("count %v users %v", roomCount, roomUsers)
+	}
+	if mids := s.RoomMembers("live://1", 0, 10); len(mids) != 2 || mids[0] != 1 || mids[1] != 3 {
+		t.Fatalf("members %v", mids)
+	}
+	if mids := s.RoomMembers("live://1", 1, 10); len(mids) != 1 || mids[0] != 3 {
+		t.Fatalf("members after 1 %v", mids)
+	}
+	if mids := s.RoomMembers("live://1", 0, 1); len(mids) != 1 || mids[0] != 1 {
+		t.Fatalf("members of size 1 %v", mids)
+	}
+}
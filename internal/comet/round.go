@@ -1,15 +1,25 @@
 package comet
 
 import (
+	itime "time"
+
 	"github.com/Terry-Mao/goim/internal/comet/conf"
 	"github.com/Terry-Mao/goim/pkg/bytes"
 	"github.com/Terry-Mao/goim/pkg/time"
 )
 
+// timer types.
+const (
+	TimerHeap  = "heap"
+	TimerWheel = "wheel"
+)
+
 // RoundOptions round options.
 type RoundOptions struct {
 	Timer        int
 	TimerSize    int
+	TimerType    string
+	TimerTick    itime.Duration
 	Reader       int
 	ReadBuf      int
 	ReadBufSize  int
@@ -22,7 +32,7 @@ type RoundOptions struct {
 type Round struct {
 	readers []bytes.Pool
 	writers []bytes.Pool
-	timers  []time.Timer
+	timers  []time.Scheduler
 	options RoundOptions
 }
 
@@ -39,6 +49,8 @@ func NewRound(c *conf.Config) (r *Round) {
 			WriteBufSize: c.TCP.WriteBufSize,
 			Timer:        c.Protocol.Timer,
 			TimerSize:    c.Protocol.TimerSize,
+			TimerType:    c.Protocol.TimerType,
+			TimerTick:    itime.Duration(c.Protocol.TimerTick),
 		}}
 	// reader
 	r.readers = make([]bytes.Pool, r.options.Reader)
@@ -51,16 +63,29 @@ func NewRound(c *conf.Config) (r *Round) {
 		r.writers[i].Init(r.options.WriteBuf, r.options.WriteBufSize)
 	}
 	// timer
-	r.timers = make([]time.Timer, r.options.Timer)
+	r.timers = make([]time.Scheduler, r.options.Timer)
 	for i = 0; i < r.options.Timer; i++ {
-		r.timers[i].Init(r.options.TimerSize)
+		if r.options.TimerType == TimerWheel {
+			r.timers[i] = time.NewWheel(r.options.TimerSize, r.options.TimerTick)
+		} else {
+			r.timers[i] = time.NewTimer(r.options.TimerSize)
+		}
 	}
 	return
 }
 
+// Close stops the timing wheels.
+func (r *Round) Close() {
+	for _, t := range r.timers {
+		if w, ok := t.(*time.Wheel); ok {
+			w.Stop()
+		}
+	}
+}
+
 // Timer get a timer.
-func (r *Round) Timer(rn int) *time.Timer {
-	return &(r.timers[rn%r.options.Timer])
+func (r *Round) Timer(rn int) time.Scheduler {
+	return r.timers[rn%r.options.Timer]
 }
 
 // Reader get a reader memory buffer.
@@ -3,10 +3,15 @@ package comet
 import (
 	"context"
 	"math/rand"
+	"sort"
+	"sync"
 	"time"
 
+	pb "github.com/Terry-Mao/goim/api/comet"
 	"github.com/Terry-Mao/goim/api/logic"
+	"github.com/Terry-Mao/goim/api/protocol"
 	"github.com/Terry-Mao/goim/internal/comet/conf"
+	"github.com/Terry-Mao/goim/pkg/hll"
 	log "github.com/golang/glog"
 	"github.com/zhenjl/cityhash"
 	"google.golang.org/grpc"
@@ -60,6 +65,15 @@ type Server struct {
 
 	serverID  string
 	rpcClient logic.LogicClient
+	limiter   *connLimiter
+	overflow  *overflowConf
+	broadcast *Broadcaster
+	sessions  *sessionStore
+	history   *roomHistory
+	presence  *presence
+
+	certMu sync.Mutex
+	certs  []*CertStore // tls listener certs
 }
 
 // NewServer returns a new Server.
@@ -68,14 +82,28 @@ func NewServer(c *conf.Config) *Server {
 		c:         c,
 		round:     NewRound(c),
 		rpcClient: newLogicClient(c.RPCClient),
+		limiter:   newConnLimiter(c.Limit),
+		overflow:  newOverflowConf(c.Protocol),
+		sessions:  newSessionStore(c.Protocol),
+		history:   newRoomHistory(c.RoomHistory),
+		presence:  newPresence(c.Presence),
 	}
 	// init bucket
 	s.buckets = make([]*Bucket, c.Bucket.Size)
 	s.bucketIdx = uint32(c.Bucket.Size)
 	for i := 0; i < c.Bucket.Size; i++ {
 		s.buckets[i] = NewBucket(c.Bucket)
+		s.buckets[i].history = s.history
+		s.buckets[i].presence = s.presence
+	}
+	if s.history != nil {
+		go s.history.evictproc()
+	}
+	if s.presence != nil {
+		go s.presence.proc(s.reportPresence)
 	}
 	s.serverID = c.Env.Host
+	s.broadcast = NewBroadcaster(c.Broadcast, s.buckets)
 	go s.onlineproc()
 	return s
 }
@@ -85,6 +113,17 @@ func (s *Server) Buckets() []*Bucket {
 	return s.buckets
 }
 
+// BroadcastRoom broadcasts the message to the room, it's kept in the room
+// history if any but the presence events.
+func (s *Server) BroadcastRoom(req *pb.BroadcastRoomReq) {
+	if req.Proto.Op != protocol.OpPresence {
+		s.history.Push(req.RoomID, req.Proto, time.Now())
+	}
+	for _, bucket := range s.buckets {
+		bucket.BroadcastRoom(req)
+	}
+}
+
 // Bucket get the bucket by subkey.
 func (s *Server) Bucket(subKey string) *Bucket {
 	idx := cityhash.CityHash32([]byte(subKey), uint32(len(subKey))) % s.bucketIdx
@@ -101,6 +140,74 @@ func (s *Server) RandServerHearbeat() time.Duration {
 
 // Close close the server.
 func (s *Server) Close() (err error) {
+	s.broadcast.Close()
+	s.certMu.Lock()
+	for _, cs := range s.certs {
+		cs.Close()
+	}
+	s.certs = nil
+	s.certMu.Unlock()
+	s.history.Close()
+	s.round.Close()
+	return
+}
+
+// Broadcaster returns the broadcast queue.
+func (s *Server) Broadcaster() *Broadcaster {
+	return s.broadcast
+}
+
+// roomsOnline returns the connections and the users of the rooms, the
+// channels of a mid may be in the different buckets.
+func (s *Server) roomsOnline() (roomCount map[string]int32, roomUsers map[string]*logic.RoomUsers) {
+	rooms := make(map[string][]*Room)
+	for _, b := range s.buckets {
+		b.cLock.RLock()
+		for roomID, room := range b.rooms {
+			if room.Online > 0 {
+				rooms[roomID] = append(rooms[roomID], room)
+			}
+		}
+		b.cLock.RUnlock()
+	}
+	roomCount = make(map[string]int32, len(rooms))
+	roomUsers = make(map[string]*logic.RoomUsers, len(rooms))
+	for roomID, rs := range rooms {
+		var (
+			guests int32
+			mids   = make(map[int64]struct{})
+			sketch hll.Sketch
+		)
+		for _, room := range rs {
+			roomCount[roomID] += room.Online
+			guests += room.addMids(mids)
+		}
+		for mid := range mids {
+			sketch.Add(mid)
+		}
+		roomUsers[roomID] = &logic.RoomUsers{Mids: sketch.Bytes(), Guests: guests}
+	}
+	return
+}
+
+// RoomMembers returns the mids greater than the cursor in the room in
+// ascending order, size at most.
+func (s *Server) RoomMembers(roomID string, cursor int64, size int) (mids []int64) {
+	set := make(map[int64]struct{})
+	for _, b := range s.buckets {
+		if room := b.Room(roomID); room != nil {
+			room.addMids(set)
+		}
+	}
+	for mid := range set {
+		if mid > cursor {
+			mids = append(mids, mid)
+		}
+	}
+	sort.Slice(mids, func(i, j int) bool { return mids[i] < mids[j] })
+	if size > 0 && len(mids) > size {
+		mids = mids[:size]
+	}
 	return
 }
 
@@ -110,13 +217,8 @@ func (s *Server) onlineproc() {
 			allRoomsCount map[string]int32
 			err           error
 		)
-		roomCount := make(map[string]int32)
-		for _, bucket := range s.buckets {
-			for roomID, count := range bucket.RoomsCount() {
-				roomCount[roomID] += count
-			}
-		}
-		if allRoomsCount, err = s.RenewOnline(context.Background(), s.serverID, roomCount); err != nil {
+		roomCount, roomUsers := s.roomsOnline()
+		if allRoomsCount, err = s.RenewOnline(context.Background(), s.serverID, roomCount, roomUsers, s.appsOnline()); err != nil {
 			time.Sleep(time.Second)
 			continue
 		}
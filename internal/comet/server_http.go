@@ -0,0 +1,566 @@
+package comet
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/logic"
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	xtime "github.com/Terry-Mao/goim/pkg/time"
+	log "github.com/golang/glog"
+)
+
+// httpProto is the json of a proto, the body is the raw string.
+type httpProto struct {
+	Op   int32  `json:"op"`
+	Seq  int64  `json:"seq,omitempty"`
+	Body string `json:"body,omitempty"`
+}
+
+func newHTTPProto(p *protocol.Proto) httpProto {
+	return httpProto{Op: p.Op, Seq: p.Seq, Body: string(p.Body)}
+}
+
+// httpConnectReply is the reply of the connect, the key and the sid are
+// required by the other requests.
+type httpConnectReply struct {
+	Key       string `json:"key"`
+	Sid       string `json:"sid"`
+	Heartbeat int64  `json:"heartbeat"` // seconds
+	Expire    int64  `json:"expire,omitempty"`
+}
+
+// InitHTTP listen all http.bind and serve the long polling and server-sent
+// events transport.
+func InitHTTP(server *Server, addrs []string) (err error) {
+	var (
+		bind     string
+		listener net.Listener
+		h        = newHTTPServer(server)
+	)
+	for _, bind = range addrs {
+		if listener, err = net.Listen("tcp", bind); err != nil {
+			log.Errorf("net.Listen(tcp, %s) error(%v)", bind, err)
+			return
+		}
+		log.Infof("start http listen: %s", bind)
+		srv := &http.Server{Handler: h, ReadHeaderTimeout: time.Duration(server.c.Protocol.HandshakeTimeout)}
+		go func(bind string, lis net.Listener) {
+			if err := srv.Serve(lis); err != nil {
+				log.Errorf("http.Serve(%s) error(%v)", bind, err)
+			}
+		}(bind, listener)
+	}
+	return
+}
+
+// httpServer serves the channels without a connection, a session is found
+// by the key, so the load balancer must route the requests by the key.
+type httpServer struct {
+	s     *Server
+	mux   *http.ServeMux
+	round uint32
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+func newHTTPServer(s *Server) *httpServer {
+	h := &httpServer{s: s, sessions: make(map[string]*httpSession)}
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc("/sub/connect", allowMethod(http.MethodPost, h.connect))
+	h.mux.HandleFunc("/sub/events", allowMethod(http.MethodGet, h.events))
+	h.mux.HandleFunc("/sub/poll", allowMethod(http.MethodGet, h.poll))
+	h.mux.HandleFunc("/sub/op", allowMethod(http.MethodPost, h.operate))
+	h.mux.HandleFunc("/sub/close", allowMethod(http.MethodPost, h.close))
+	return h
+}
+
+// allowMethod rejects the requests of the other methods.
+func allowMethod(method string, fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		fn(w, r)
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("json.Encode(%v) error(%v)", v, err)
+	}
+}
+
+func newSid() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// connect connects the token in the body as the auth proto.
+func (h *httpServer) connect(w http.ResponseWriter, r *http.Request) {
+	token, err := io.ReadAll(io.LimitReader(r.Body, int64(protocol.MaxBodySize)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	if reason, err := h.s.limiter.CheckIP(ip); err != nil {
+		http.Error(w, reason, http.StatusTooManyRequests)
+		return
+	}
+	reply, err := h.s.Connect(r.Context(), &protocol.Proto{Op: protocol.OpAuth, Body: token}, r.Header.Get("Cookie"), ip)
+	if err != nil {
+		log.Errorf("http Connect(ip:%v).err(%v)", ip, err)
+		http.Error(w, "auth failed", http.StatusUnauthorized)
+		return
+	}
+	sess, reason, err := h.open(reply, ip)
+	if err != nil {
+		log.Errorf("key: %s http connect error(%v)", reply.Key, err)
+		http.Error(w, reason, http.StatusTooManyRequests)
+		return
+	}
+	writeJSON(w, http.StatusOK, &httpConnectReply{
+		Key:       reply.Key,
+		Sid:       sess.sid,
+		Heartbeat: int64(sess.ch.Heartbeat() / time.Second),
+		Expire:    reply.Expire,
+	})
+}
+
+// open puts the channel connected into the bucket, it's served by the
+// requests carrying the key and the sid until idle for a heartbeat.
+func (h *httpServer) open(reply *logic.ConnectReply, ip string) (sess *httpSession, reason string, err error) {
+	var (
+		s  = h.s
+		tr = s.round.Timer(int(atomic.AddUint32(&h.round, 1)))
+		ch = NewChannel(s.c.Protocol.CliProto, s.c.Protocol.SvrProto)
+	)
+	ch.SetOverflow(s.overflow, nil)
+	ch.Mid, ch.Key, ch.Platform, ch.App, ch.IP = reply.Mid, reply.Key, reply.Platform, reply.App, ip
+	if reason, err = s.limiter.Acquire(ch); err != nil {
+		_ = s.Disconnect(context.Background(), ch.Mid, ch.Key)
+		return
+	}
+	ch.Watch(reply.Accepts...)
+	b := s.Bucket(ch.Key)
+	if err = b.Put(reply.RoomID, ch); err != nil {
+		b.Del(ch)
+		s.limiter.Release(ch)
+		_ = s.Disconnect(context.Background(), ch.Mid, ch.Key)
+		return
+	}
+	sess = &httpSession{
+		h:               h,
+		ch:              ch,
+		b:               b,
+		tr:              tr,
+		ol:              newOpLimiter(s.c.Protocol),
+		sid:             newSid(),
+		size:            s.c.Protocol.SvrProto + s.c.Protocol.OverflowSize,
+		serverHeartbeat: s.RandServerHearbeat(),
+		lastHb:          time.Now(),
+		wake:            make(chan struct{}, 1),
+		done:            make(chan struct{}),
+	}
+	ch.closer = sess.close
+	hb := time.Duration(reply.Heartbeat)
+	ch.SetHeartbeat(hb)
+	ch.beat(time.Now())
+	sess.trd = tr.Add(hb, sess.expire)
+	sess.trd.Key = ch.Key
+	sess.ae = newAuthExpiry(tr, ch, time.Duration(s.c.Protocol.AuthExpiring))
+	sess.ae.Refresh(reply.Expire)
+	h.mu.Lock()
+	h.sessions[ch.Key] = sess
+	h.mu.Unlock()
+	go sess.dispatch()
+	if conf.Conf.Debug {
+		log.Infof("http connnected key:%s mid:%d", ch.Key, ch.Mid)
+	}
+	return
+}
+
+// session returns the session of the request, nil if not found.
+func (h *httpServer) session(w http.ResponseWriter, r *http.Request) *httpSession {
+	key, sid := r.URL.Query().Get("key"), r.URL.Query().Get("sid")
+	h.mu.Lock()
+	sess := h.sessions[key]
+	h.mu.Unlock()
+	if sess == nil || subtle.ConstantTimeCompare([]byte(sess.sid), []byte(sid)) != 1 {
+		// connect again, it may be served by another comet
+		http.Error(w, "session not found", http.StatusNotFound)
+		return nil
+	}
+	return sess
+}
+
+// events streams the messages as server-sent events, the event is the op,
+// the id is the seq.
+func (h *httpServer) events(w http.ResponseWriter, r *http.Request) {
+	sess := h.session(w, r)
+	if sess == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	if !sess.attach() {
+		http.Error(w, "session is read by another request", http.StatusConflict)
+		return
+	}
+	defer sess.detach()
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	ctx := r.Context()
+	sess.touch(ctx)
+	ping := time.NewTicker(time.Duration(h.s.c.HTTP.Ping))
+	defer ping.Stop()
+	for {
+		for _, p := range sess.take() {
+			if err := writeEvent(w, p); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+		select {
+		case <-sess.wake:
+		case <-ping.C:
+			if _, err := io.WriteString(w, ":ping\n\n"); err != nil {
+				return
+			}
+			sess.touch(ctx)
+		case <-sess.done:
+			for _, p := range sess.take() {
+				_ = writeEvent(w, p)
+			}
+			flusher.Flush()
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes the proto as an event, a line of the body per data.
+func writeEvent(w io.Writer, p *protocol.Proto) (err error) {
+	b := make([]byte, 0, len(p.Body)+32)
+	b = append(b, "event: "...)
+	b = strconv.AppendInt(b, int64(p.Op), 10)
+	if p.Seq != 0 {
+		b = append(b, "\nid: "...)
+		b = strconv.AppendInt(b, p.Seq, 10)
+	}
+	body := p.Body
+	for {
+		b = append(b, "\ndata: "...)
+		i := bytes.IndexByte(body, '\n')
+		if i < 0 {
+			b = append(b, body...)
+			break
+		}
+		b = append(b, body[:i]...)
+		body = body[i+1:]
+	}
+	b = append(b, "\n\n"...)
+	_, err = w.Write(b)
+	return
+}
+
+// poll replies the messages as a json array once any, or an empty array
+// after the poll timeout.
+func (h *httpServer) poll(w http.ResponseWriter, r *http.Request) {
+	sess := h.session(w, r)
+	if sess == nil {
+		return
+	}
+	if !sess.attach() {
+		http.Error(w, "session is read by another request", http.StatusConflict)
+		return
+	}
+	defer sess.detach()
+	ctx := r.Context()
+	sess.touch(ctx)
+	ps := sess.wait(ctx, time.Duration(h.s.c.HTTP.PollTimeout))
+	hps := make([]httpProto, 0, len(ps))
+	for _, p := range ps {
+		hps = append(hps, newHTTPProto(p))
+	}
+	writeJSON(w, http.StatusOK, hps)
+}
+
+// operate operates the proto in the body, it's replied as the tcp.
+func (h *httpServer) operate(w http.ResponseWriter, r *http.Request) {
+	sess := h.session(w, r)
+	if sess == nil {
+		return
+	}
+	var hp httpProto
+	if err := json.NewDecoder(io.LimitReader(r.Body, int64(protocol.MaxBodySize)*2)).Decode(&hp); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	sess.touch(ctx)
+	p := &protocol.Proto{Ver: protocol.Ver1, Op: hp.Op, Seq: hp.Seq, Body: []byte(hp.Body)}
+	sess.opMu.Lock()
+	pass, closing := sess.ol.check(sess.ch, p)
+	sess.opMu.Unlock()
+	if closing {
+		http.Error(w, reasonRateLimit, http.StatusTooManyRequests)
+		return
+	}
+	if pass {
+		switch p.Op {
+		case protocol.OpHeartbeat:
+			p.Op = protocol.OpHeartbeatReply
+			p.Body = nil
+		case protocol.OpAuth:
+			h.s.reauth(ctx, p, sess.ch, sess.ae)
+		default:
+			_ = h.s.Operate(ctx, p, sess.ch, sess.b)
+		}
+	}
+	writeJSON(w, http.StatusOK, newHTTPProto(p))
+}
+
+// close disconnects the session.
+func (h *httpServer) close(w http.ResponseWriter, r *http.Request) {
+	sess := h.session(w, r)
+	if sess == nil {
+		return
+	}
+	sess.close()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// httpSession queues the messages of the channel for the requests.
+type httpSession struct {
+	h   *httpServer
+	ch  *Channel
+	b   *Bucket
+	tr  xtime.Scheduler
+	trd *xtime.TimerData
+	ae  *authExpiry
+	sid string
+	// the queue is at most size, the oldest are dropped
+	size            int
+	serverHeartbeat time.Duration
+
+	opMu sync.Mutex
+	ol   *opLimiter
+
+	mu      sync.Mutex
+	queue   []*protocol.Proto
+	missed  int
+	reading bool
+	lastHb  time.Time
+	closing bool
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+// dispatch takes the messages of the channel so that pushers never block,
+// it blocks; the caller typically invokes it in a go statement.
+func (sess *httpSession) dispatch() {
+	ch := sess.ch
+	for {
+		p, f := ch.Ready()
+		// the frame is not used
+		message{p: p, f: f}.release()
+		if p == protocol.ProtoFinish {
+			break
+		}
+		if p != protocol.ProtoReady {
+			sess.push(p)
+		}
+		// drain the spilled messages once the signal channel is empty
+		if len(ch.signal) == 0 {
+			for _, m := range ch.drainOverflow() {
+				m.release()
+				sess.push(m.p)
+			}
+		}
+	}
+	for _, m := range ch.drainOverflow() {
+		m.release()
+	}
+	sess.finish()
+}
+
+// push queues the message, the protos packed in a raw body one by one.
+func (sess *httpSession) push(p *protocol.Proto) {
+	ps := []*protocol.Proto{p}
+	if p.Op == protocol.OpRaw {
+		if raw, err := protocol.SplitRaw(p.Body); err == nil {
+			ps = raw
+		}
+	}
+	sess.mu.Lock()
+	sess.queue = append(sess.queue, ps...)
+	if n := len(sess.queue) - sess.size; n > 0 {
+		sess.queue = append(sess.queue[:0], sess.queue[n:]...)
+		sess.missed += n
+		sess.ch.addDrops(uint64(n))
+	}
+	sess.mu.Unlock()
+	select {
+	case sess.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take takes the messages queued, headed by a missed messages notification
+// if any message was dropped.
+func (sess *httpSession) take() (ps []*protocol.Proto) {
+	sess.mu.Lock()
+	if sess.missed > 0 {
+		ps = append(ps, missedProto(sess.missed))
+		sess.missed = 0
+	}
+	ps = append(ps, sess.queue...)
+	sess.queue = nil
+	sess.mu.Unlock()
+	return
+}
+
+// wait waits for the messages until the timeout.
+func (sess *httpSession) wait(ctx context.Context, timeout time.Duration) (ps []*protocol.Proto) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		if ps = sess.take(); len(ps) > 0 {
+			return
+		}
+		select {
+		case <-sess.wake:
+		case <-sess.done:
+			return sess.take()
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			return
+		}
+	}
+}
+
+// attach marks the session read, false if it's read by another request.
+func (sess *httpSession) attach() bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.reading {
+		return false
+	}
+	sess.reading = true
+	return true
+}
+
+// detach unmarks the session read, the idle timeout starts.
+func (sess *httpSession) detach() {
+	sess.mu.Lock()
+	sess.reading = false
+	sess.mu.Unlock()
+	sess.ch.beat(time.Now())
+}
+
+// touch keeps the session alive, the heartbeat is sent to logic for a long
+// time.
+func (sess *httpSession) touch(ctx context.Context) {
+	now := time.Now()
+	sess.ch.beat(now)
+	sess.mu.Lock()
+	due := now.Sub(sess.lastHb) > sess.serverHeartbeat
+	if due {
+		sess.lastHb = now
+	}
+	sess.mu.Unlock()
+	if due {
+		if err := sess.h.s.Heartbeat(ctx, sess.ch.Mid, sess.ch.Key); err != nil {
+			log.Errorf("key: %s http heartbeat error(%v)", sess.ch.Key, err)
+		}
+	}
+}
+
+// expire closes the session idle for a heartbeat.
+func (sess *httpSession) expire() {
+	sess.mu.Lock()
+	reading := sess.reading
+	sess.mu.Unlock()
+	if reading {
+		sess.tr.Set(sess.trd, sess.ch.Heartbeat())
+		return
+	}
+	if d := sess.ch.heartbeatLeft(time.Now()); d > 0 {
+		sess.tr.Set(sess.trd, d)
+		return
+	}
+	log.Errorf("key: %s remoteIP: %s http session idle timeout", sess.ch.Key, sess.ch.IP)
+	sess.close()
+}
+
+// close closes the channel once, the session is finished by dispatch.
+func (sess *httpSession) close() {
+	sess.mu.Lock()
+	closing := sess.closing
+	sess.closing = true
+	sess.mu.Unlock()
+	if !closing {
+		sess.ch.Close()
+	}
+}
+
+// finish releases the session and tells logic.
+func (sess *httpSession) finish() {
+	var (
+		h  = sess.h
+		ch = sess.ch
+	)
+	sess.mu.Lock()
+	sess.closing = true
+	sess.mu.Unlock()
+	close(sess.done)
+	h.mu.Lock()
+	if h.sessions[ch.Key] == sess {
+		delete(h.sessions, ch.Key)
+	}
+	h.mu.Unlock()
+	sess.b.Del(ch)
+	h.s.limiter.Release(ch)
+	sess.tr.Del(sess.trd)
+	sess.ae.Close()
+	if err := h.s.Disconnect(context.Background(), ch.Mid, ch.Key); err != nil {
+		log.Errorf("key: %s mid: %d operator do disconnect error(%v)", ch.Key, ch.Mid, err)
+	}
+	if conf.Conf.Debug {
+		log.Infof("http disconnected key: %s mid: %d", ch.Key, ch.Mid)
+	}
+}
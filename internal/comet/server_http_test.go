@@ -0,0 +1,171 @@
+package comet
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/pkg/bytes"
+	xtime "github.com/Terry-Mao/goim/pkg/time"
+)
+
+func newHTTPTestServer(t *testing.T) (*Server, *testLogic, *httptest.Server) {
+	s, l := newTestServer()
+	s.c.HTTP.PollTimeout = xtime.Duration(100 * time.Millisecond)
+	ts := httptest.NewServer(newHTTPServer(s))
+	t.Cleanup(ts.Close)
+	return s, l, ts
+}
+
+func httpConnect(t *testing.T, ts *httptest.Server) url.Values {
+	resp, err := http.Post(ts.URL+"/sub/connect", "text/plain", strings.NewReader("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var reply httpConnectReply
+	if err = json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Key != "k" || reply.Sid == "" || reply.Heartbeat != 60 {
+		t.Fatalf("connect reply %+v", reply)
+	}
+	return url.Values{"key": {reply.Key}, "sid": {reply.Sid}}
+}
+
+func httpPoll(t *testing.T, ts *httptest.Server, q url.Values) (ps []httpProto) {
+	resp, err := http.Get(ts.URL + "/sub/poll?" + q.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("poll status %d", resp.StatusCode)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&ps); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func httpOperate(t *testing.T, ts *httptest.Server, q url.Values, p httpProto) (reply httpProto) {
+	b, _ := json.Marshal(p)
+	resp, err := http.Post(ts.URL+"/sub/op?"+q.Encode(), "application/json", strings.NewReader(string(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err = json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func roomBody(op int32, body string) []byte {
+	w := bytes.NewWriterSize(64)
+	(&protocol.Proto{Ver: 1, Op: op, Body: []byte(body)}).WriteTo(w)
+	return w.Buffer()
+}
+
+func TestHTTPPoll(t *testing.T) {
+	s, l, ts := newHTTPTestServer(t)
+	if resp, err := http.Post(ts.URL+"/sub/connect", "text/plain", strings.NewReader("bad")); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("bad token connected %v", err)
+	}
+	if resp, err := http.Get(ts.URL + "/sub/connect"); err != nil || resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("connect by get %v", err)
+	}
+	q := httpConnect(t, ts)
+	// nothing to poll
+	if ps := httpPoll(t, ts, q); len(ps) != 0 {
+		t.Fatalf("polled %v", ps)
+	}
+	if reply := httpOperate(t, ts, q, httpProto{Op: protocol.OpHeartbeat, Seq: 1}); reply.Op != protocol.OpHeartbeatReply || reply.Seq != 1 {
+		t.Fatalf("heartbeat reply %+v", reply)
+	}
+	httpOperate(t, ts, q, httpProto{Op: 1002, Body: "up"})
+	if p := <-l.received; p.Op != 1002 || string(p.Body) != "up" {
+		t.Fatalf("received %v", p)
+	}
+	_ = s.PushKey("k", 1000, &protocol.Proto{Ver: 1, Op: protocol.OpRaw, Body: []byte(`{"msg":1}`)})
+	s.Bucket("k").Room("live://1").Push(&protocol.Proto{Ver: 1, Op: protocol.OpRaw, Body: roomBody(1001, "room")})
+	var ps []httpProto
+	for deadline := time.Now().Add(time.Second); len(ps) < 2 && time.Now().Before(deadline); {
+		ps = append(ps, httpPoll(t, ts, q)...)
+	}
+	if len(ps) != 2 || ps[0].Op != protocol.OpRaw || ps[0].Body != `{"msg":1}` || ps[1].Op != 1001 || ps[1].Body != "room" {
+		t.Fatalf("polled %+v", ps)
+	}
+	// another session
+	bad := url.Values{"key": {"k"}, "sid": {"bad"}}
+	if resp, err := http.Get(ts.URL + "/sub/poll?" + bad.Encode()); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("polled with a wrong sid %v", err)
+	}
+	if resp, err := http.Post(ts.URL+"/sub/close?"+q.Encode(), "", nil); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("close %v", err)
+	}
+	select {
+	case key := <-l.disconnected:
+		if key != "k" {
+			t.Fatalf("disconnected %s", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("logic must be told the disconnect")
+	}
+	if s.Bucket("k").Channel("k") != nil {
+		t.Fatal("channel must be deleted")
+	}
+}
+
+func TestHTTPEvents(t *testing.T) {
+	s, l, ts := newHTTPTestServer(t)
+	q := httpConnect(t, ts)
+	resp, err := http.Get(ts.URL + "/sub/events?" + q.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %s", ct)
+	}
+	// the session is read by the stream
+	if resp, err := http.Get(ts.URL + "/sub/poll?" + q.Encode()); err != nil || resp.StatusCode != http.StatusConflict {
+		t.Fatalf("polled while streaming %v", err)
+	}
+	_ = s.PushKey("k", 1000, &protocol.Proto{Ver: 1, Op: protocol.OpRaw, Body: []byte("a\nb")})
+	disconnect(s.Bucket("k").Channel("k"), reasonAuthExpired)
+	var lines []string
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	want := []string{"event: 9", "data: a", "data: b", "", "event: 18", "data: " + reasonAuthExpired, ""}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("events %q want %q", lines, want)
+	}
+	<-l.disconnected
+}
+
+func TestHTTPSessionQueue(t *testing.T) {
+	sess := &httpSession{ch: NewChannel(1, 1), size: 2, wake: make(chan struct{}, 1)}
+	for op := int32(1000); op < 1003; op++ {
+		sess.push(&protocol.Proto{Op: op})
+	}
+	sess.push(&protocol.Proto{Op: protocol.OpRaw, Body: append(roomBody(1003, "a"), roomBody(1004, "b")...)})
+	ps := sess.take()
+	if len(ps) != 3 || ps[0].Op != protocol.OpMissedMessages || string(ps[0].Body) != "3" || ps[1].Op != 1003 || ps[2].Op != 1004 {
+		t.Fatalf("taken %v", ps)
+	}
+	if sess.ch.Drops() != 3 {
+		t.Fatalf("drops %d want 3", sess.ch.Drops())
+	}
+	if ps = sess.take(); len(ps) != 0 {
+		t.Fatalf("taken %v", ps)
+	}
+}
@@ -0,0 +1,460 @@
+package comet
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/logic"
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	"github.com/Terry-Mao/goim/internal/comet/errors"
+	"github.com/Terry-Mao/goim/pkg/bufio"
+	"github.com/Terry-Mao/goim/pkg/bytes"
+	"github.com/Terry-Mao/goim/pkg/mqtt"
+	xtime "github.com/Terry-Mao/goim/pkg/time"
+	log "github.com/golang/glog"
+)
+
+const (
+	// mqttTopicPrefix the topics of ops, others are rooms
+	mqttTopicPrefix = "goim/"
+)
+
+// mqttTopic returns the topic of the op.
+func mqttTopic(op int32) string {
+	return mqttTopicPrefix + strconv.FormatInt(int64(op), 10)
+}
+
+// mqttTopicOp returns the op of the topic, false if it's a room.
+func mqttTopicOp(topic string) (op int32, ok bool) {
+	if !strings.HasPrefix(topic, mqttTopicPrefix) {
+		return
+	}
+	v, err := strconv.ParseInt(topic[len(mqttTopicPrefix):], 10, 32)
+	if err != nil {
+		return
+	}
+	return int32(v), true
+}
+
+// InitMQTT listen all mqtt.bind and start accept connections.
+func InitMQTT(server *Server, addrs []string, accept int) (err error) {
+	var (
+		bind     string
+		listener *net.TCPListener
+		addr     *net.TCPAddr
+	)
+	for _, bind = range addrs {
+		if addr, err = net.ResolveTCPAddr("tcp", bind); err != nil {
+			log.Errorf("net.ResolveTCPAddr(tcp, %s) error(%v)", bind, err)
+			return
+		}
+		if listener, err = net.ListenTCP("tcp", addr); err != nil {
+			log.Errorf("net.ListenTCP(tcp, %s) error(%v)", bind, err)
+			return
+		}
+		log.Infof("start mqtt listen: %s", bind)
+		// split N core accept
+		for i := 0; i < accept; i++ {
+			go acceptMQTT(server, listener)
+		}
+	}
+	return
+}
+
+func acceptMQTT(server *Server, lis *net.TCPListener) {
+	var (
+		conn *net.TCPConn
+		err  error
+		r    int
+	)
+	for {
+		if conn, err = lis.AcceptTCP(); err != nil {
+			// if listener close then return
+			log.Errorf("listener.Accept(\"%s\") error(%v)", lis.Addr().String(), err)
+			return
+		}
+		if err = setSockopt(server, conn); err != nil {
+			return
+		}
+		go serveMQTT(server, conn, r)
+		if r++; r == maxInt {
+			r = 0
+		}
+	}
+}
+
+func serveMQTT(s *Server, conn *net.TCPConn, r int) {
+	var (
+		// timer
+		tr = s.round.Timer(r)
+		rp = s.round.Reader(r)
+		wp = s.round.Writer(r)
+	)
+	if conf.Conf.Debug {
+		log.Infof("start mqtt serve \"%s\" with \"%s\"", conn.LocalAddr().String(), conn.RemoteAddr().String())
+	}
+	s.ServeMQTT(conn, rp, wp, tr)
+}
+
+// ServeMQTT serve a mqtt connection, the CONNECT password or username is
+// the token, rooms are subscribed by topic, ops by goim/<op>.
+func (s *Server) ServeMQTT(conn net.Conn, rp, wp *bytes.Pool, tr xtime.Scheduler) {
+	var (
+		err    error
+		rid    string
+		reason string
+		code   byte
+		reply  *logic.ConnectReply
+		hb     time.Duration
+		ka     time.Duration
+		white  bool
+		p      *protocol.Proto
+		pk     mqtt.Packet
+		b      *Bucket
+		trd    *xtime.TimerData
+		lastHb = time.Now()
+		rb     = rp.Get()
+		wb     = wp.Get()
+		ch     = NewChannel(s.c.Protocol.CliProto, s.c.Protocol.SvrProto)
+		rr     = &ch.Reader
+		wr     = &ch.Writer
+	)
+	ch.SetOverflow(s.overflow, conn)
+	ch.Reader.ResetBuffer(conn, rb.Bytes())
+	ch.Writer.ResetBuffer(conn, wb.Bytes())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// handshake
+	step := 0
+	trd = tr.Add(time.Duration(s.c.Protocol.HandshakeTimeout), func() {
+		// the heartbeat timeout may be extended by config update
+		if d := ch.heartbeatLeft(time.Now()); d > 0 {
+			tr.Set(trd, d)
+			return
+		}
+		conn.Close()
+		log.Errorf("key: %s remoteIP: %s step: %d mqtt handshake timeout", ch.Key, conn.RemoteAddr().String(), step)
+	})
+	ch.IP, _, _ = net.SplitHostPort(conn.RemoteAddr().String())
+	step = 1
+	if reply, ka, code, err = s.authMQTT(ctx, rr, ch.IP); err == nil {
+		ch.Mid, ch.Key, ch.Platform, ch.App = reply.Mid, reply.Key, reply.Platform, reply.App
+		rid, hb = reply.RoomID, time.Duration(reply.Heartbeat)
+		// the client pings every keep alive, allow it half more as the spec
+		if ka = ka * 3 / 2; ka > hb {
+			hb = ka
+		}
+		if reason, err = s.limiter.Acquire(ch); err != nil {
+			code = mqtt.RefusedServerUnavailable
+			_ = s.Disconnect(ctx, ch.Mid, ch.Key)
+			log.Errorf("key: %s mqtt connect refused: %s", ch.Key, reason)
+		} else {
+			ch.Watch(reply.Accepts...)
+			b = s.Bucket(ch.Key)
+			err = b.Put(rid, ch)
+			if conf.Conf.Debug {
+				log.Infof("mqtt connnected key:%s mid:%d", ch.Key, ch.Mid)
+			}
+		}
+	}
+	if code != mqtt.Accepted || err == nil {
+		// the connection is refused with a code
+		if err1 := mqtt.WritePacket(wr, &mqtt.Connack{Code: code}); err1 == nil {
+			_ = wr.Flush()
+		}
+	}
+	step = 2
+	if err != nil {
+		s.limiter.Release(ch)
+		conn.Close()
+		rp.Put(rb)
+		wp.Put(wb)
+		tr.Del(trd)
+		log.Errorf("key: %s mqtt handshake failed error(%v)", ch.Key, err)
+		return
+	}
+	trd.Key = ch.Key
+	ch.SetHeartbeat(hb)
+	ch.beat(time.Now())
+	tr.Set(trd, hb)
+	ae := newAuthExpiry(tr, ch, time.Duration(s.c.Protocol.AuthExpiring))
+	ae.Refresh(reply.Expire)
+	white = whitelist.Contains(ch.Mid)
+	if white {
+		whitelist.Printf("key: %s[%s] mqtt auth\n", ch.Key, rid)
+	}
+	step = 3
+	// hanshake ok start dispatch goroutine
+	go s.dispatchMQTT(conn, wr, wp, wb, ch)
+	serverHeartbeat := s.RandServerHearbeat()
+	ol := newOpLimiter(s.c.Protocol)
+	for {
+		if p, err = ch.CliProto.Set(); err != nil {
+			break
+		}
+		if pk, err = mqtt.ReadPacket(rr); err != nil {
+			break
+		}
+		if white {
+			whitelist.Printf("key: %s read packet:%T\n", ch.Key, pk)
+		}
+		// any control packet keeps the connection alive
+		ch.beat(time.Now())
+		tr.Set(trd, ch.Heartbeat())
+		if _, ok := pk.(*mqtt.Pingreq); ok {
+			// NOTE: send server heartbeat for a long time
+			if now := time.Now(); now.Sub(lastHb) > serverHeartbeat {
+				if err1 := s.Heartbeat(ctx, ch.Mid, ch.Key); err1 == nil {
+					lastHb = now
+				}
+			}
+			step++
+		}
+		if pk, err = s.operateMQTT(ctx, pk, p, ch, b, ol); err != nil {
+			break
+		}
+		if pk == nil {
+			// nothing to reply, the proto is reused
+			continue
+		}
+		p.Op = protocol.OpRaw
+		p.Body = mqtt.AppendPacket(nil, pk)
+		ch.CliProto.SetAdv()
+		ch.Signal()
+	}
+	if white {
+		whitelist.Printf("key: %s server mqtt error(%v)\n", ch.Key, err)
+	}
+	if err != nil && err != io.EOF && !strings.Contains(err.Error(), "closed") {
+		log.Errorf("key: %s server mqtt failed error(%v)", ch.Key, err)
+	}
+	b.Del(ch)
+	s.limiter.Release(ch)
+	tr.Del(trd)
+	ae.Close()
+	rp.Put(rb)
+	conn.Close()
+	ch.Close()
+	if err = s.Disconnect(ctx, ch.Mid, ch.Key); err != nil {
+		log.Errorf("key: %s mid: %d operator do disconnect error(%v)", ch.Key, ch.Mid, err)
+	}
+	if conf.Conf.Debug {
+		log.Infof("mqtt disconnected key: %s mid: %d", ch.Key, ch.Mid)
+	}
+}
+
+// authMQTT reads the CONNECT packet and connects to logic, the code is the
+// CONNACK return code if the connection is refused.
+func (s *Server) authMQTT(ctx context.Context, rr *bufio.Reader, ip string) (reply *logic.ConnectReply, keepAlive time.Duration, code byte, err error) {
+	var pk mqtt.Packet
+	if pk, err = mqtt.ReadPacket(rr); err != nil && err != mqtt.ErrProtocolLevel {
+		return
+	}
+	c, ok := pk.(*mqtt.Connect)
+	if !ok {
+		log.Errorf("mqtt request packet(%T) not connect", pk)
+		return nil, 0, 0, errors.ErrHandshake
+	}
+	if err == mqtt.ErrProtocolLevel {
+		return nil, 0, mqtt.RefusedProtocolVersion, err
+	}
+	var reason string
+	if reason, err = s.limiter.CheckIP(ip); err != nil {
+		log.Errorf("mqtt connect refused: %s ip: %s", reason, ip)
+		return nil, 0, mqtt.RefusedServerUnavailable, err
+	}
+	token := c.Password
+	if len(token) == 0 {
+		token = []byte(c.Username)
+	}
+	if reply, err = s.Connect(ctx, &protocol.Proto{Op: protocol.OpAuth, Body: token}, "", ip); err != nil {
+		log.Errorf("authMQTT.Connect(ip:%v).err(%v)", ip, err)
+		return nil, 0, mqtt.RefusedBadUsernamePassword, err
+	}
+	keepAlive = time.Duration(c.KeepAlive) * time.Second
+	return
+}
+
+// operateMQTT operates the packet read, the reply is nil if nothing to send.
+func (s *Server) operateMQTT(ctx context.Context, pk mqtt.Packet, p *protocol.Proto, ch *Channel, b *Bucket, ol *opLimiter) (reply mqtt.Packet, err error) {
+	switch pk := pk.(type) {
+	case *mqtt.Pingreq:
+		p.Op, p.Body = protocol.OpHeartbeat, nil
+		if pass, _ := ol.check(ch, p); pass {
+			reply = &mqtt.Pingresp{}
+		}
+	case *mqtt.Publish:
+		op, ok := mqttTopicOp(pk.Topic)
+		if !ok {
+			log.Warningf("key: %s mqtt publish topic: %s ignored", ch.Key, pk.Topic)
+			break
+		}
+		p.Op, p.Body = op, pk.Payload
+		if pass, _ := ol.check(ch, p); !pass {
+			// over budget, a qos 1 publish is redelivered on reconnect
+			p.Body = nil
+			break
+		}
+		if err = s.Operate(ctx, p, ch, b); err != nil {
+			break
+		}
+		if pk.QoS > 0 {
+			reply = &mqtt.Puback{ID: pk.ID}
+		}
+	case *mqtt.Subscribe:
+		p.Op, p.Body = protocol.OpChangeRoom, nil
+		pass, _ := ol.check(ch, p)
+		codes := make([]byte, len(pk.Topics))
+		for i, topic := range pk.Topics {
+			if !pass || !subscribeMQTT(ch, b, topic) {
+				codes[i] = mqtt.SubackFailure
+			}
+		}
+		reply = &mqtt.Suback{ID: pk.ID, Codes: codes}
+	case *mqtt.Unsubscribe:
+		p.Op, p.Body = protocol.OpChangeRoom, nil
+		if pass, _ := ol.check(ch, p); pass {
+			for _, topic := range pk.Topics {
+				if op, ok := mqttTopicOp(topic); ok {
+					ch.UnWatch(op)
+				} else if roomID, ok := appRoomID(ch.App, topic); ok {
+					b.LeaveRoom(ch, roomID)
+				}
+			}
+		}
+		reply = &mqtt.Unsuback{ID: pk.ID}
+	case *mqtt.Puback:
+		// the server publishes at qos 0
+	case *mqtt.Disconnect:
+		err = io.EOF
+	default:
+		log.Errorf("key: %s mqtt packet(%T) not valid", ch.Key, pk)
+		err = errors.ErrOperation
+	}
+	return
+}
+
+// subscribeMQTT watches the op or joins the room of the topic, messages are
+// published at qos 0.
+func subscribeMQTT(ch *Channel, b *Bucket, topic string) bool {
+	if strings.ContainsAny(topic, "+#") {
+		// wildcards are not supported
+		return false
+	}
+	if op, ok := mqttTopicOp(topic); ok {
+		ch.Watch(op)
+		return true
+	}
+	roomID, ok := appRoomID(ch.App, topic)
+	if !ok {
+		return false
+	}
+	if err := b.JoinRoom(ch, roomID); err != nil {
+		log.Errorf("b.JoinRoom(%s) error(%v)", roomID, err)
+		return false
+	}
+	return true
+}
+
+// writeMQTT publishes the server proto to the topic of its op, the protos
+// packed in a raw body are published one by one.
+func writeMQTT(wr *bufio.Writer, p *protocol.Proto) (err error) {
+	if p.Op == protocol.OpRaw {
+		if ps, err1 := protocol.SplitRaw(p.Body); err1 == nil {
+			for _, rp := range ps {
+				if err = mqtt.WritePacket(wr, &mqtt.Publish{Topic: mqttTopic(rp.Op), Payload: rp.Body}); err != nil {
+					return
+				}
+			}
+			return
+		}
+	}
+	return mqtt.WritePacket(wr, &mqtt.Publish{Topic: mqttTopic(p.Op), Payload: p.Body})
+}
+
+// dispatchMQTT writes the replies and the server protos, it blocks; the
+// caller typically invokes it in a go statement.
+func (s *Server) dispatchMQTT(conn net.Conn, wr *bufio.Writer, wp *bytes.Pool, wb *bytes.Buffer, ch *Channel) {
+	var (
+		err    error
+		finish bool
+	)
+	if conf.Conf.Debug {
+		log.Infof("key: %s start dispatch mqtt goroutine", ch.Key)
+	}
+	for {
+		var p, f = ch.Ready()
+		switch p {
+		case protocol.ProtoFinish:
+			if conf.Conf.Debug {
+				log.Infof("key: %s wakeup exit dispatch goroutine", ch.Key)
+			}
+			finish = true
+			goto failed
+		case protocol.ProtoReady:
+			// fetch message from svrbox(client send)
+			for {
+				if p, err = ch.CliProto.Get(); err != nil {
+					break
+				}
+				// the body is the encoded packet
+				if _, err = wr.Write(p.Body); err != nil {
+					goto failed
+				}
+				p.Body = nil // avoid memory leak
+				ch.CliProto.GetAdv()
+			}
+		default:
+			// server send, the frame is not used
+			message{p: p, f: f}.release()
+			if err = writeMQTT(wr, p); err != nil {
+				goto failed
+			}
+			if conf.Conf.Debug {
+				log.Infof("mqtt sent a message key:%s mid:%d proto:%+v", ch.Key, ch.Mid, p)
+			}
+			// the conn is closed once the disconnect reason is sent, the
+			// messages are discarded till the reader finishes
+			if p.Op == protocol.OpDisconnectReason {
+				err = wr.Flush()
+				goto failed
+			}
+		}
+		// drain the spilled messages once the signal channel is empty
+		if len(ch.signal) == 0 {
+			for _, m := range ch.drainOverflow() {
+				m.release()
+				if err = writeMQTT(wr, m.p); err != nil {
+					goto failed
+				}
+			}
+		}
+		// only hungry flush response
+		if err = wr.Flush(); err != nil {
+			break
+		}
+	}
+failed:
+	if err != nil {
+		log.Errorf("key: %s dispatch mqtt error(%v)", ch.Key, err)
+	}
+	conn.Close()
+	wp.Put(wb)
+	// must ensure all channel message discard, for reader won't blocking Signal
+	for !finish {
+		p, f := ch.Ready()
+		message{p: p, f: f}.release()
+		finish = (p == protocol.ProtoFinish)
+	}
+	for _, m := range ch.drainOverflow() {
+		m.release()
+	}
+	if conf.Conf.Debug {
+		log.Infof("key: %s dispatch goroutine exit", ch.Key)
+	}
+}
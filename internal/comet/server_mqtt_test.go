@@ -0,0 +1,201 @@
+package comet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/logic"
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/comet/conf"
+	"github.com/Terry-Mao/goim/pkg/bufio"
+	"github.com/Terry-Mao/goim/pkg/bytes"
+	"github.com/Terry-Mao/goim/pkg/mqtt"
+	"google.golang.org/grpc"
+)
+
+// testLogic is a logic client accepting the token "token".
+type testLogic struct {
+	logic.LogicClient
+	received     chan *protocol.Proto
+	disconnected chan string
+}
+
+func (l *testLogic) Connect(ctx context.Context, in *logic.ConnectReq, opts ...grpc.CallOption) (*logic.ConnectReply, error) {
+	if string(in.Token) != "token" {
+		return nil, errors.New("bad token")
+	}
+	return &logic.ConnectReply{Mid: 1, Key: "k", RoomID: "live://1", Accepts: []int32{1000}, Heartbeat: int64(time.Minute)}, nil
+}
+
+func (l *testLogic) Disconnect(ctx context.Context, in *logic.DisconnectReq, opts ...grpc.CallOption) (*logic.DisconnectReply, error) {
+	l.disconnected <- in.Key
+	return &logic.DisconnectReply{}, nil
+}
+
+func (l *testLogic) Heartbeat(ctx context.Context, in *logic.HeartbeatReq, opts ...grpc.CallOption) (*logic.HeartbeatReply, error) {
+	return &logic.HeartbeatReply{}, nil
+}
+
+func (l *testLogic) Receive(ctx context.Context, in *logic.ReceiveReq, opts ...grpc.CallOption) (*logic.ReceiveReply, error) {
+	// the proto is reused once replied
+	l.received <- &protocol.Proto{Op: in.Proto.Op, Body: append([]byte(nil), in.Proto.Body...)}
+	return &logic.ReceiveReply{}, nil
+}
+
+// mqttClient is an in-process mqtt client.
+type mqttClient struct {
+	t    *testing.T
+	conn net.Conn
+	rr   *bufio.Reader
+	wr   *bufio.Writer
+}
+
+func (c *mqttClient) send(pk mqtt.Packet) {
+	if err := mqtt.WritePacket(c.wr, pk); err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.wr.Flush(); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *mqttClient) recv() mqtt.Packet {
+	_ = c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	pk, err := mqtt.ReadPacket(c.rr)
+	if err != nil {
+		c.t.Fatalf("ReadPacket() error(%v)", err)
+	}
+	return pk
+}
+
+// newTestServer returns a server connecting to the test logic.
+func newTestServer() (*Server, *testLogic) {
+	if conf.Conf == nil {
+		conf.Conf = conf.Default()
+	}
+	if whitelist == nil {
+		whitelist = &Whitelist{list: map[int64]struct{}{}}
+	}
+	c := conf.Default()
+	l := &testLogic{received: make(chan *protocol.Proto, 1), disconnected: make(chan string, 1)}
+	return &Server{
+		c:         c,
+		round:     NewRound(c),
+		buckets:   []*Bucket{NewBucket(&conf.Bucket{Channel: 10, Room: 10, RoutineAmount: 1, RoutineSize: 1})},
+		bucketIdx: 1,
+		rpcClient: l,
+		overflow:  newOverflowConf(c.Protocol),
+		sessions:  newSessionStore(c.Protocol),
+	}, l
+}
+
+func newMQTTServer(t *testing.T) (*Server, *testLogic, string) {
+	s, l := newTestServer()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	tr := s.round.Timer(0)
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go s.ServeMQTT(conn, bytes.NewPool(1, 1024), bytes.NewPool(1, 1024), tr)
+		}
+	}()
+	return s, l, lis.Addr().String()
+}
+
+func dialMQTT(t *testing.T, addr string) *mqttClient {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &mqttClient{t: t, conn: conn, rr: bufio.NewReader(conn), wr: bufio.NewWriter(conn)}
+}
+
+func TestMQTTConnectRefused(t *testing.T) {
+	_, _, addr := newMQTTServer(t)
+	// bad token
+	c := dialMQTT(t, addr)
+	c.send(&mqtt.Connect{Level: 4, ClientID: "d", Username: "device", Password: []byte("bad")})
+	if pk, ok := c.recv().(*mqtt.Connack); !ok || pk.Code != mqtt.RefusedBadUsernamePassword {
+		t.Fatalf("connack %#v want bad username or password", pk)
+	}
+	// mqtt 3.1
+	c = dialMQTT(t, addr)
+	b := mqtt.AppendPacket(nil, &mqtt.Connect{ClientID: "d", Username: "token"})
+	b[8] = 3
+	if _, err := c.conn.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if pk, ok := c.recv().(*mqtt.Connack); !ok || pk.Code != mqtt.RefusedProtocolVersion {
+		t.Fatalf("connack %#v want unacceptable protocol version", pk)
+	}
+}
+
+func TestMQTT(t *testing.T) {
+	s, l, addr := newMQTTServer(t)
+	c := dialMQTT(t, addr)
+	// the username carries the token without a password
+	c.send(&mqtt.Connect{Level: 4, CleanSession: true, KeepAlive: 30, ClientID: "d", Username: "token"})
+	if pk, ok := c.recv().(*mqtt.Connack); !ok || pk.Code != mqtt.Accepted {
+		t.Fatalf("connack %#v want accepted", pk)
+	}
+	c.send(&mqtt.Pingreq{})
+	if _, ok := c.recv().(*mqtt.Pingresp); !ok {
+		t.Fatal("pingreq must be replied")
+	}
+	c.send(&mqtt.Subscribe{ID: 1, Topics: []string{"live://2", "goim/1001", "goim/+"}, QoS: []byte{0, 1, 0}})
+	if pk, ok := c.recv().(*mqtt.Suback); !ok || pk.ID != 1 || string(pk.Codes) != string([]byte{0, 0, mqtt.SubackFailure}) {
+		t.Fatalf("suback %#v", pk)
+	}
+	ch := s.Bucket("k").Channel("k")
+	if ch == nil || !ch.HasRoom("live://1") || !ch.HasRoom("live://2") || !ch.NeedPush(1001) {
+		t.Fatal("topics must be subscribed")
+	}
+	// upstream
+	c.send(&mqtt.Publish{QoS: 1, ID: 2, Topic: "goim/1002", Payload: []byte("up")})
+	if pk, ok := c.recv().(*mqtt.Puback); !ok || pk.ID != 2 {
+		t.Fatalf("puback %#v", pk)
+	}
+	if p := <-l.received; p.Op != 1002 || string(p.Body) != "up" {
+		t.Fatalf("received %v", p)
+	}
+	// downstream to the key and to the room
+	if err := s.PushKey("k", 1000, &protocol.Proto{Ver: 1, Op: protocol.OpRaw, Body: []byte(`{"msg":1}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if pk, ok := c.recv().(*mqtt.Publish); !ok || pk.Topic != "goim/9" || string(pk.Payload) != `{"msg":1}` {
+		t.Fatalf("publish %#v", pk)
+	}
+	w := bytes.NewWriterSize(64)
+	(&protocol.Proto{Ver: 1, Op: 1001, Body: []byte("room")}).WriteTo(w)
+	s.Bucket("k").Room("live://2").Push(&protocol.Proto{Ver: 1, Op: protocol.OpRaw, Body: w.Buffer()})
+	if pk, ok := c.recv().(*mqtt.Publish); !ok || pk.Topic != "goim/1001" || string(pk.Payload) != "room" {
+		t.Fatalf("publish %#v", pk)
+	}
+	c.send(&mqtt.Unsubscribe{ID: 3, Topics: []string{"live://2"}})
+	if pk, ok := c.recv().(*mqtt.Unsuback); !ok || pk.ID != 3 {
+		t.Fatalf("unsuback %#v", pk)
+	}
+	if ch.HasRoom("live://2") {
+		t.Fatal("room must be left")
+	}
+	c.send(&mqtt.Disconnect{})
+	select {
+	case key := <-l.disconnected:
+		if key != "k" {
+			t.Fatalf("disconnected %s", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("logic must be told the disconnect")
+	}
+}
@@ -2,11 +2,13 @@ package comet
 
 import (
 	"context"
+	"crypto/tls"
 	"io"
 	"net"
 	"strings"
 	"time"
 
+	"github.com/Terry-Mao/goim/api/logic"
 	"github.com/Terry-Mao/goim/api/protocol"
 	"github.com/Terry-Mao/goim/internal/comet/conf"
 	"github.com/Terry-Mao/goim/pkg/bufio"
@@ -21,11 +23,28 @@ const (
 
 // InitTCP listen all tcp.bind and start accept connections.
 func InitTCP(server *Server, addrs []string, accept int) (err error) {
+	return initTCP(server, addrs, nil, accept)
+}
+
+// InitTCPWithTLS init tcp with tls.
+func InitTCPWithTLS(server *Server, addrs []string, certFile, privateFile string, accept int) (err error) {
+	var tlsCfg *tls.Config
+	if tlsCfg, err = server.loadTLSConfig(certFile, privateFile, time.Duration(server.c.TCP.CertReload)); err != nil {
+		return
+	}
+	return initTCP(server, addrs, tlsCfg, accept)
+}
+
+func initTCP(server *Server, addrs []string, tlsCfg *tls.Config, accept int) (err error) {
 	var (
 		bind     string
 		listener *net.TCPListener
 		addr     *net.TCPAddr
+		lc       *listenConf
 	)
+	if lc, err = newListenConf(server.c.TCP.ProxyProtocol, server.c.TCP.ProxyTrusted, tlsCfg); err != nil {
+		return
+	}
 	for _, bind = range addrs {
 		if addr, err = net.ResolveTCPAddr("tcp", bind); err != nil {
 			log.Errorf("net.ResolveTCPAddr(tcp, %s) error(%v)", bind, err)
@@ -35,10 +54,10 @@ func InitTCP(server *Server, addrs []string, accept int) (err error) {
 			log.Errorf("net.ListenTCP(tcp, %s) error(%v)", bind, err)
 			return
 		}
-		log.Infof("start tcp listen: %s", bind)
+		log.Infof("start tcp listen: %s tls: %t proxy: %t", bind, tlsCfg != nil, lc.proxy)
 		// split N core accept
 		for i := 0; i < accept; i++ {
-			go acceptTCP(server, listener)
+			go acceptTCP(server, listener, lc)
 		}
 	}
 	return
@@ -47,7 +66,7 @@ func InitTCP(server *Server, addrs []string, accept int) (err error) {
 // Accept accepts connections on the listener and serves requests
 // for each incoming connection.  Accept blocks; the caller typically
 // invokes it in a go statement.
-func acceptTCP(server *Server, lis *net.TCPListener) {
+func acceptTCP(server *Server, lis *net.TCPListener, lc *listenConf) {
 	var (
 		conn *net.TCPConn
 		err  error
@@ -59,59 +78,58 @@ func acceptTCP(server *Server, lis *net.TCPListener) {
 			log.Errorf("listener.Accept(\"%s\") error(%v)", lis.Addr().String(), err)
 			return
 		}
-		if err = conn.SetKeepAlive(server.c.TCP.KeepAlive); err != nil {
-			log.Errorf("conn.SetKeepAlive() error(%v)", err)
+		if err = setSockopt(server, conn); err != nil {
 			return
 		}
-		if err = conn.SetReadBuffer(server.c.TCP.Rcvbuf); err != nil {
-			log.Errorf("conn.SetReadBuffer() error(%v)", err)
-			return
-		}
-		if err = conn.SetWriteBuffer(server.c.TCP.Sndbuf); err != nil {
-			log.Errorf("conn.SetWriteBuffer() error(%v)", err)
-			return
-		}
-		go serveTCP(server, conn, r)
+		go serveTCP(server, conn, lc, r)
 		if r++; r == maxInt {
 			r = 0
 		}
 	}
 }
 
-func serveTCP(s *Server, conn *net.TCPConn, r int) {
+func serveTCP(s *Server, tcpConn *net.TCPConn, lc *listenConf, r int) {
 	var (
 		// timer
 		tr = s.round.Timer(r)
 		rp = s.round.Reader(r)
 		wp = s.round.Writer(r)
-		// ip addr
-		lAddr = conn.LocalAddr().String()
-		rAddr = conn.RemoteAddr().String()
 	)
+	conn, err := lc.upgrade(tcpConn, time.Duration(s.c.Protocol.HandshakeTimeout))
+	if err != nil {
+		tcpConn.Close()
+		log.Errorf("remoteIP: %s tcp proxy protocol error(%v)", tcpConn.RemoteAddr().String(), err)
+		return
+	}
 	if conf.Conf.Debug {
+		// ip addr
+		lAddr := conn.LocalAddr().String()
+		rAddr := conn.RemoteAddr().String()
 		log.Infof("start tcp serve \"%s\" with \"%s\"", lAddr, rAddr)
 	}
 	s.ServeTCP(conn, rp, wp, tr)
 }
 
 // ServeTCP serve a tcp connection.
-func (s *Server) ServeTCP(conn *net.TCPConn, rp, wp *bytes.Pool, tr *xtime.Timer) {
+func (s *Server) ServeTCP(conn net.Conn, rp, wp *bytes.Pool, tr xtime.Scheduler) {
 	var (
-		err     error
-		rid     string
-		accepts []int32
-		hb      time.Duration
-		white   bool
-		p       *protocol.Proto
-		b       *Bucket
-		trd     *xtime.TimerData
-		lastHb  = time.Now()
-		rb      = rp.Get()
-		wb      = wp.Get()
-		ch      = NewChannel(s.c.Protocol.CliProto, s.c.Protocol.SvrProto)
-		rr      = &ch.Reader
-		wr      = &ch.Writer
+		err    error
+		rid    string
+		reason string
+		reply  *logic.ConnectReply
+		hb     time.Duration
+		white  bool
+		p      *protocol.Proto
+		b      *Bucket
+		trd    *xtime.TimerData
+		lastHb = time.Now()
+		rb     = rp.Get()
+		wb     = wp.Get()
+		ch     = NewChannel(s.c.Protocol.CliProto, s.c.Protocol.SvrProto)
+		rr     = &ch.Reader
+		wr     = &ch.Writer
 	)
+	ch.SetOverflow(s.overflow, conn)
 	ch.Reader.ResetBuffer(conn, rb.Bytes())
 	ch.Writer.ResetBuffer(conn, wb.Bytes())
 	ctx, cancel := context.WithCancel(context.Background())
@@ -119,24 +137,44 @@ func (s *Server) ServeTCP(conn *net.TCPConn, rp, wp *bytes.Pool, tr *xtime.Timer
 	// handshake
 	step := 0
 	trd = tr.Add(time.Duration(s.c.Protocol.HandshakeTimeout), func() {
+		// the heartbeat timeout may be extended by config update
+		if d := ch.heartbeatLeft(time.Now()); d > 0 {
+			tr.Set(trd, d)
+			return
+		}
 		conn.Close()
 		log.Errorf("key: %s remoteIP: %s step: %d tcp handshake timeout", ch.Key, conn.RemoteAddr().String(), step)
 	})
 	ch.IP, _, _ = net.SplitHostPort(conn.RemoteAddr().String())
 	// must not setadv, only used in auth
 	step = 1
-	if p, err = ch.CliProto.Set(); err == nil {
-		if ch.Mid, ch.Key, rid, accepts, hb, err = s.authTCP(ctx, rr, wr, p); err == nil {
-			ch.Watch(accepts...)
-			b = s.Bucket(ch.Key)
-			err = b.Put(rid, ch)
-			if conf.Conf.Debug {
-				log.Infof("tcp connnected key:%s mid:%d proto:%+v", ch.Key, ch.Mid, p)
+	if reason, err = s.limiter.CheckIP(ch.IP); err != nil {
+		// tell the client why before the auth
+		_ = disconnectProto(reason).WriteTCP(wr)
+		_ = wr.Flush()
+	} else if p, err = ch.CliProto.Set(); err == nil {
+		if reply, err = s.authTCP(ctx, rr, wr, p, ch.IP); err == nil {
+			ch.Mid, ch.Key, ch.Platform, ch.App = reply.Mid, reply.Key, reply.Platform, reply.App
+			rid, hb = reply.RoomID, time.Duration(reply.Heartbeat)
+			if reason, err = s.limiter.Acquire(ch); err != nil {
+				// tell the client why, then drop the session just connected
+				_ = disconnectProto(reason).WriteTCP(wr)
+				_ = wr.Flush()
+				_ = s.Disconnect(ctx, ch.Mid, ch.Key)
+			} else {
+				ch.Watch(reply.Accepts...)
+				b = s.Bucket(ch.Key)
+				s.resumeChannel(b, ch, reply)
+				err = b.Put(rid, ch)
+				if conf.Conf.Debug {
+					log.Infof("tcp connnected key:%s mid:%d proto:%+v", ch.Key, ch.Mid, p)
+				}
 			}
 		}
 	}
 	step = 2
 	if err != nil {
+		s.limiter.Release(ch)
 		conn.Close()
 		rp.Put(rb)
 		wp.Put(wb)
@@ -145,7 +183,11 @@ func (s *Server) ServeTCP(conn *net.TCPConn, rp, wp *bytes.Pool, tr *xtime.Timer
 		return
 	}
 	trd.Key = ch.Key
+	ch.SetHeartbeat(hb)
+	ch.beat(time.Now())
 	tr.Set(trd, hb)
+	ae := newAuthExpiry(tr, ch, time.Duration(s.c.Protocol.AuthExpiring))
+	ae.Refresh(reply.Expire)
 	white = whitelist.Contains(ch.Mid)
 	if white {
 		whitelist.Printf("key: %s[%s] auth\n", ch.Key, rid)
@@ -154,6 +196,7 @@ func (s *Server) ServeTCP(conn *net.TCPConn, rp, wp *bytes.Pool, tr *xtime.Timer
 	// hanshake ok start dispatch goroutine
 	go s.dispatchTCP(conn, wr, wp, wb, ch)
 	serverHeartbeat := s.RandServerHearbeat()
+	ol := newOpLimiter(s.c.Protocol)
 	for {
 		if p, err = ch.CliProto.Set(); err != nil {
 			break
@@ -167,8 +210,15 @@ func (s *Server) ServeTCP(conn *net.TCPConn, rp, wp *bytes.Pool, tr *xtime.Timer
 		if white {
 			whitelist.Printf("key: %s read proto:%v\n", ch.Key, p)
 		}
-		if p.Op == protocol.OpHeartbeat {
-			tr.Set(trd, hb)
+		if pass, closing := ol.check(ch, p); closing {
+			continue
+		} else if !pass {
+			if conf.Conf.Debug {
+				log.Infof("tcp rate limited key:%s, mid:%d, seq:%d", ch.Key, ch.Mid, p.Seq)
+			}
+		} else if p.Op == protocol.OpHeartbeat {
+			ch.beat(time.Now())
+			tr.Set(trd, ch.Heartbeat())
 			p.Op = protocol.OpHeartbeatReply
 			p.Body = nil
 			// NOTE: send server heartbeat for a long time
@@ -181,6 +231,8 @@ func (s *Server) ServeTCP(conn *net.TCPConn, rp, wp *bytes.Pool, tr *xtime.Timer
 				log.Infof("tcp heartbeat receive key:%s, mid:%d", ch.Key, ch.Mid)
 			}
 			step++
+		} else if p.Op == protocol.OpAuth {
+			s.reauth(ctx, p, ch, ae)
 		} else {
 			if err = s.Operate(ctx, p, ch, b); err != nil {
 				break
@@ -201,12 +253,17 @@ func (s *Server) ServeTCP(conn *net.TCPConn, rp, wp *bytes.Pool, tr *xtime.Timer
 	if err != nil && err != io.EOF && !strings.Contains(err.Error(), "closed") {
 		log.Errorf("key: %s server tcp failed error(%v)", ch.Key, err)
 	}
+	rooms := channelRooms(ch)
 	b.Del(ch)
+	s.limiter.Release(ch)
 	tr.Del(trd)
+	ae.Close()
 	rp.Put(rb)
 	conn.Close()
 	ch.Close()
-	if err = s.Disconnect(ctx, ch.Mid, ch.Key); err != nil {
+	if s.park(ch, rooms) {
+		err = nil
+	} else if err = s.Disconnect(ctx, ch.Mid, ch.Key); err != nil {
 		log.Errorf("key: %s mid: %d operator do disconnect error(%v)", ch.Key, ch.Mid, err)
 	}
 	if white {
@@ -220,12 +277,13 @@ func (s *Server) ServeTCP(conn *net.TCPConn, rp, wp *bytes.Pool, tr *xtime.Timer
 // dispatch accepts connections on the listener and serves requests
 // for each incoming connection.  dispatch blocks; the caller typically
 // invokes it in a go statement.
-func (s *Server) dispatchTCP(conn *net.TCPConn, wr *bufio.Writer, wp *bytes.Pool, wb *bytes.Buffer, ch *Channel) {
+func (s *Server) dispatchTCP(conn net.Conn, wr *bufio.Writer, wp *bytes.Pool, wb *bytes.Buffer, ch *Channel) {
 	var (
 		err    error
 		finish bool
 		online int32
 		white  = whitelist.Contains(ch.Mid)
+		frames []*protocol.Frame // written frames released after flushed
 	)
 	if conf.Conf.Debug {
 		log.Infof("key: %s start dispatch tcp goroutine", ch.Key)
@@ -234,7 +292,7 @@ func (s *Server) dispatchTCP(conn *net.TCPConn, wr *bufio.Writer, wp *bytes.Pool
 		if white {
 			whitelist.Printf("key: %s wait proto ready\n", ch.Key)
 		}
-		var p = ch.Ready()
+		var p, f = ch.Ready()
 		if white {
 			whitelist.Printf("key: %s proto ready\n", ch.Key)
 		}
@@ -283,7 +341,7 @@ func (s *Server) dispatchTCP(conn *net.TCPConn, wr *bufio.Writer, wp *bytes.Pool
 				whitelist.Printf("key: %s start write server proto%v\n", ch.Key, p)
 			}
 			// server send
-			if err = p.WriteTCP(wr); err != nil {
+			if frames, err = (message{p: p, f: f}).writeTCP(wr, frames); err != nil {
 				goto failed
 			}
 			if white {
@@ -292,12 +350,28 @@ func (s *Server) dispatchTCP(conn *net.TCPConn, wr *bufio.Writer, wp *bytes.Pool
 			if conf.Conf.Debug {
 				log.Infof("tcp sent a message key:%s mid:%d proto:%+v", ch.Key, ch.Mid, p)
 			}
+			// the conn is closed once the disconnect reason is sent, the
+			// messages are discarded till the reader finishes
+			if p.Op == protocol.OpDisconnectReason {
+				err = wr.Flush()
+				goto failed
+			}
+		}
+		// drain the spilled messages once the signal channel is empty
+		if len(ch.signal) == 0 {
+			for _, m := range ch.drainOverflow() {
+				if frames, err = m.writeTCP(wr, frames); err != nil {
+					goto failed
+				}
+			}
 		}
 		if white {
 			whitelist.Printf("key: %s start flush \n", ch.Key)
 		}
 		// only hungry flush response
-		if err = wr.Flush(); err != nil {
+		err = wr.Flush()
+		frames = releaseFrames(frames)
+		if err != nil {
 			break
 		}
 		if white {
@@ -312,10 +386,16 @@ failed:
 		log.Errorf("key: %s dispatch tcp error(%v)", ch.Key, err)
 	}
 	conn.Close()
+	releaseFrames(frames)
 	wp.Put(wb)
 	// must ensure all channel message discard, for reader won't blocking Signal
 	for !finish {
-		finish = (ch.Ready() == protocol.ProtoFinish)
+		p, f := ch.Ready()
+		message{p: p, f: f}.release()
+		finish = (p == protocol.ProtoFinish)
+	}
+	for _, m := range ch.drainOverflow() {
+		m.release()
 	}
 	if conf.Conf.Debug {
 		log.Infof("key: %s dispatch goroutine exit", ch.Key)
@@ -323,7 +403,7 @@ failed:
 }
 
 // auth for goim handshake with client, use rsa & aes.
-func (s *Server) authTCP(ctx context.Context, rr *bufio.Reader, wr *bufio.Writer, p *protocol.Proto) (mid int64, key, rid string, accepts []int32, hb time.Duration, err error) {
+func (s *Server) authTCP(ctx context.Context, rr *bufio.Reader, wr *bufio.Writer, p *protocol.Proto, ip string) (reply *logic.ConnectReply, err error) {
 	for {
 		if err = p.ReadTCP(rr); err != nil {
 			return
@@ -334,14 +414,14 @@ func (s *Server) authTCP(ctx context.Context, rr *bufio.Reader, wr *bufio.Writer
 			log.Errorf("tcp request operation(%d) not auth", p.Op)
 		}
 	}
-	if mid, key, rid, accepts, hb, err = s.Connect(ctx, p, ""); err != nil {
-		log.Errorf("authTCP.Connect(key:%v).err(%v)", key, err)
+	if reply, err = s.Connect(ctx, p, "", ip); err != nil {
+		log.Errorf("authTCP.Connect(ip:%v).err(%v)", ip, err)
 		return
 	}
 	p.Op = protocol.OpAuthReply
-	p.Body = nil
+	p.Body = s.authReplyBody(reply)
 	if err = p.WriteTCP(wr); err != nil {
-		log.Errorf("authTCP.WriteTCP(key:%v).err(%v)", key, err)
+		log.Errorf("authTCP.WriteTCP(key:%v).err(%v)", reply.Key, err)
 		return
 	}
 	err = wr.Flush()
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Terry-Mao/goim/api/logic"
 	"github.com/Terry-Mao/goim/api/protocol"
 	"github.com/Terry-Mao/goim/internal/comet/conf"
 	"github.com/Terry-Mao/goim/pkg/bytes"
@@ -20,11 +21,28 @@ import (
 
 // InitWebsocket listen all tcp.bind and start accept connections.
 func InitWebsocket(server *Server, addrs []string, accept int) (err error) {
+	return initWebsocket(server, addrs, nil, accept)
+}
+
+// InitWebsocketWithTLS init websocket with tls.
+func InitWebsocketWithTLS(server *Server, addrs []string, certFile, privateFile string, accept int) (err error) {
+	var tlsCfg *tls.Config
+	if tlsCfg, err = server.loadTLSConfig(certFile, privateFile, time.Duration(server.c.Websocket.CertReload)); err != nil {
+		return
+	}
+	return initWebsocket(server, addrs, tlsCfg, accept)
+}
+
+func initWebsocket(server *Server, addrs []string, tlsCfg *tls.Config, accept int) (err error) {
 	var (
 		bind     string
 		listener *net.TCPListener
 		addr     *net.TCPAddr
+		lc       *listenConf
 	)
+	if lc, err = newListenConf(server.c.Websocket.ProxyProtocol, server.c.Websocket.ProxyTrusted, tlsCfg); err != nil {
+		return
+	}
 	for _, bind = range addrs {
 		if addr, err = net.ResolveTCPAddr("tcp", bind); err != nil {
 			log.Errorf("net.ResolveTCPAddr(tcp, %s) error(%v)", bind, err)
@@ -34,44 +52,14 @@ func InitWebsocket(server *Server, addrs []string, accept int) (err error) {
 			log.Errorf("net.ListenTCP(tcp, %s) error(%v)", bind, err)
 			return
 		}
-		log.Infof("start ws listen: %s", bind)
-		// split N core accept
-		for i := 0; i < accept; i++ {
-			go acceptWebsocket(server, listener)
-		}
-	}
-	return
-}
-
-// InitWebsocketWithTLS init websocket with tls.
-func InitWebsocketWithTLS(server *Server, addrs []string, certFile, privateFile string, accept int) (err error) {
-	var (
-		bind     string
-		listener net.Listener
-		cert     tls.Certificate
-		certs    []tls.Certificate
-	)
-	certFiles := strings.Split(certFile, ",")
-	privateFiles := strings.Split(privateFile, ",")
-	for i := range certFiles {
-		cert, err = tls.LoadX509KeyPair(certFiles[i], privateFiles[i])
-		if err != nil {
-			log.Errorf("Error loading certificate. error(%v)", err)
-			return
-		}
-		certs = append(certs, cert)
-	}
-	tlsCfg := &tls.Config{Certificates: certs}
-	tlsCfg.BuildNameToCertificate()
-	for _, bind = range addrs {
-		if listener, err = tls.Listen("tcp", bind, tlsCfg); err != nil {
-			log.Errorf("net.ListenTCP(tcp, %s) error(%v)", bind, err)
-			return
+		if tlsCfg != nil {
+			log.Infof("start wss listen: %s proxy: %t", bind, lc.proxy)
+		} else {
+			log.Infof("start ws listen: %s proxy: %t", bind, lc.proxy)
 		}
-		log.Infof("start wss listen: %s", bind)
 		// split N core accept
 		for i := 0; i < accept; i++ {
-			go acceptWebsocketWithTLS(server, listener)
+			go acceptWebsocket(server, listener, lc)
 		}
 	}
 	return
@@ -80,7 +68,7 @@ func InitWebsocketWithTLS(server *Server, addrs []string, certFile, privateFile
 // Accept accepts connections on the listener and serves requests
 // for each incoming connection.  Accept blocks; the caller typically
 // invokes it in a go statement.
-func acceptWebsocket(server *Server, lis *net.TCPListener) {
+func acceptWebsocket(server *Server, lis *net.TCPListener, lc *listenConf) {
 	var (
 		conn *net.TCPConn
 		err  error
@@ -92,54 +80,29 @@ func acceptWebsocket(server *Server, lis *net.TCPListener) {
 			log.Errorf("listener.Accept(%s) error(%v)", lis.Addr().String(), err)
 			return
 		}
-		if err = conn.SetKeepAlive(server.c.TCP.KeepAlive); err != nil {
-			log.Errorf("conn.SetKeepAlive() error(%v)", err)
-			return
-		}
-		if err = conn.SetReadBuffer(server.c.TCP.Rcvbuf); err != nil {
-			log.Errorf("conn.SetReadBuffer() error(%v)", err)
-			return
-		}
-		if err = conn.SetWriteBuffer(server.c.TCP.Sndbuf); err != nil {
-			log.Errorf("conn.SetWriteBuffer() error(%v)", err)
+		if err = setSockopt(server, conn); err != nil {
 			return
 		}
-		go serveWebsocket(server, conn, r)
+		go serveWebsocket(server, conn, lc, r)
 		if r++; r == maxInt {
 			r = 0
 		}
 	}
 }
 
-// Accept accepts connections on the listener and serves requests
-// for each incoming connection.  Accept blocks; the caller typically
-// invokes it in a go statement.
-func acceptWebsocketWithTLS(server *Server, lis net.Listener) {
-	var (
-		conn net.Conn
-		err  error
-		r    int
-	)
-	for {
-		if conn, err = lis.Accept(); err != nil {
-			// if listener close then return
-			log.Errorf("listener.Accept(\"%s\") error(%v)", lis.Addr().String(), err)
-			return
-		}
-		go serveWebsocket(server, conn, r)
-		if r++; r == maxInt {
-			r = 0
-		}
-	}
-}
-
-func serveWebsocket(s *Server, conn net.Conn, r int) {
+func serveWebsocket(s *Server, tcpConn *net.TCPConn, lc *listenConf, r int) {
 	var (
 		// timer
 		tr = s.round.Timer(r)
 		rp = s.round.Reader(r)
 		wp = s.round.Writer(r)
 	)
+	conn, err := lc.upgrade(tcpConn, time.Duration(s.c.Protocol.HandshakeTimeout))
+	if err != nil {
+		tcpConn.Close()
+		log.Errorf("remoteIP: %s ws proxy protocol error(%v)", tcpConn.RemoteAddr().String(), err)
+		return
+	}
 	if conf.Conf.Debug {
 		// ip addr
 		lAddr := conn.LocalAddr().String()
@@ -150,24 +113,26 @@ func serveWebsocket(s *Server, conn net.Conn, r int) {
 }
 
 // ServeWebsocket serve a websocket connection.
-func (s *Server) ServeWebsocket(conn net.Conn, rp, wp *bytes.Pool, tr *xtime.Timer) {
+func (s *Server) ServeWebsocket(conn net.Conn, rp, wp *bytes.Pool, tr xtime.Scheduler) {
 	var (
-		err     error
-		rid     string
-		accepts []int32
-		hb      time.Duration
-		white   bool
-		p       *protocol.Proto
-		b       *Bucket
-		trd     *xtime.TimerData
-		lastHB  = time.Now()
-		rb      = rp.Get()
-		ch      = NewChannel(s.c.Protocol.CliProto, s.c.Protocol.SvrProto)
-		rr      = &ch.Reader
-		wr      = &ch.Writer
-		ws      *websocket.Conn // websocket
-		req     *websocket.Request
+		err    error
+		rid    string
+		reason string
+		reply  *logic.ConnectReply
+		hb     time.Duration
+		white  bool
+		p      *protocol.Proto
+		b      *Bucket
+		trd    *xtime.TimerData
+		lastHB = time.Now()
+		rb     = rp.Get()
+		ch     = NewChannel(s.c.Protocol.CliProto, s.c.Protocol.SvrProto)
+		rr     = &ch.Reader
+		wr     = &ch.Writer
+		ws     *websocket.Conn // websocket
+		req    *websocket.Request
 	)
+	ch.SetOverflow(s.overflow, conn)
 	// reader
 	ch.Reader.ResetBuffer(conn, rb.Bytes())
 	ctx, cancel := context.WithCancel(context.Background())
@@ -175,6 +140,11 @@ func (s *Server) ServeWebsocket(conn net.Conn, rp, wp *bytes.Pool, tr *xtime.Tim
 	// handshake
 	step := 0
 	trd = tr.Add(time.Duration(s.c.Protocol.HandshakeTimeout), func() {
+		// the heartbeat timeout may be extended by config update
+		if d := ch.heartbeatLeft(time.Now()); d > 0 {
+			tr.Set(trd, d)
+			return
+		}
 		// NOTE: fix close block for tls
 		_ = conn.SetDeadline(time.Now().Add(time.Millisecond * 100))
 		_ = conn.Close()
@@ -208,18 +178,33 @@ func (s *Server) ServeWebsocket(conn net.Conn, rp, wp *bytes.Pool, tr *xtime.Tim
 	}
 	// must not setadv, only used in auth
 	step = 3
-	if p, err = ch.CliProto.Set(); err == nil {
-		if ch.Mid, ch.Key, rid, accepts, hb, err = s.authWebsocket(ctx, ws, p, req.Header.Get("Cookie")); err == nil {
-			ch.Watch(accepts...)
-			b = s.Bucket(ch.Key)
-			err = b.Put(rid, ch)
-			if conf.Conf.Debug {
-				log.Infof("websocket connected key:%s mid:%d proto:%+v", ch.Key, ch.Mid, p)
+	if reason, err = s.limiter.CheckIP(ch.IP); err != nil {
+		// tell the client why before the auth
+		_ = disconnectProto(reason).WriteWebsocket(ws)
+		_ = ws.Flush()
+	} else if p, err = ch.CliProto.Set(); err == nil {
+		if reply, err = s.authWebsocket(ctx, ws, p, req.Header.Get("Cookie"), ch.IP); err == nil {
+			ch.Mid, ch.Key, ch.Platform, ch.App = reply.Mid, reply.Key, reply.Platform, reply.App
+			rid, hb = reply.RoomID, time.Duration(reply.Heartbeat)
+			if reason, err = s.limiter.Acquire(ch); err != nil {
+				// tell the client why, then drop the session just connected
+				_ = disconnectProto(reason).WriteWebsocket(ws)
+				_ = ws.Flush()
+				_ = s.Disconnect(ctx, ch.Mid, ch.Key)
+			} else {
+				ch.Watch(reply.Accepts...)
+				b = s.Bucket(ch.Key)
+				s.resumeChannel(b, ch, reply)
+				err = b.Put(rid, ch)
+				if conf.Conf.Debug {
+					log.Infof("websocket connected key:%s mid:%d proto:%+v", ch.Key, ch.Mid, p)
+				}
 			}
 		}
 	}
 	step = 4
 	if err != nil {
+		s.limiter.Release(ch)
 		ws.Close()
 		rp.Put(rb)
 		wp.Put(wb)
@@ -230,7 +215,11 @@ func (s *Server) ServeWebsocket(conn net.Conn, rp, wp *bytes.Pool, tr *xtime.Tim
 		return
 	}
 	trd.Key = ch.Key
+	ch.SetHeartbeat(hb)
+	ch.beat(time.Now())
 	tr.Set(trd, hb)
+	ae := newAuthExpiry(tr, ch, time.Duration(s.c.Protocol.AuthExpiring))
+	ae.Refresh(reply.Expire)
 	white = whitelist.Contains(ch.Mid)
 	if white {
 		whitelist.Printf("key: %s[%s] auth\n", ch.Key, rid)
@@ -239,6 +228,7 @@ func (s *Server) ServeWebsocket(conn net.Conn, rp, wp *bytes.Pool, tr *xtime.Tim
 	step = 5
 	go s.dispatchWebsocket(ws, wp, wb, ch)
 	serverHeartbeat := s.RandServerHearbeat()
+	ol := newOpLimiter(s.c.Protocol)
 	for {
 		if p, err = ch.CliProto.Set(); err != nil {
 			break
@@ -252,8 +242,15 @@ func (s *Server) ServeWebsocket(conn net.Conn, rp, wp *bytes.Pool, tr *xtime.Tim
 		if white {
 			whitelist.Printf("key: %s read proto:%v\n", ch.Key, p)
 		}
-		if p.Op == protocol.OpHeartbeat {
-			tr.Set(trd, hb)
+		if pass, closing := ol.check(ch, p); closing {
+			continue
+		} else if !pass {
+			if conf.Conf.Debug {
+				log.Infof("websocket rate limited key:%s, mid:%d, seq:%d", ch.Key, ch.Mid, p.Seq)
+			}
+		} else if p.Op == protocol.OpHeartbeat {
+			ch.beat(time.Now())
+			tr.Set(trd, ch.Heartbeat())
 			p.Op = protocol.OpHeartbeatReply
 			p.Body = nil
 			// NOTE: send server heartbeat for a long time
@@ -266,6 +263,8 @@ func (s *Server) ServeWebsocket(conn net.Conn, rp, wp *bytes.Pool, tr *xtime.Tim
 				log.Infof("websocket heartbeat receive key:%s, mid:%d", ch.Key, ch.Mid)
 			}
 			step++
+		} else if p.Op == protocol.OpAuth {
+			s.reauth(ctx, p, ch, ae)
 		} else {
 			if err = s.Operate(ctx, p, ch, b); err != nil {
 				break
@@ -286,12 +285,17 @@ func (s *Server) ServeWebsocket(conn net.Conn, rp, wp *bytes.Pool, tr *xtime.Tim
 	if err != nil && err != io.EOF && err != websocket.ErrMessageClose && !strings.Contains(err.Error(), "closed") {
 		log.Errorf("key: %s server ws failed error(%v)", ch.Key, err)
 	}
+	rooms := channelRooms(ch)
 	b.Del(ch)
+	s.limiter.Release(ch)
 	tr.Del(trd)
+	ae.Close()
 	ws.Close()
 	ch.Close()
 	rp.Put(rb)
-	if err = s.Disconnect(ctx, ch.Mid, ch.Key); err != nil {
+	if s.park(ch, rooms) {
+		err = nil
+	} else if err = s.Disconnect(ctx, ch.Mid, ch.Key); err != nil {
 		log.Errorf("key: %s operator do disconnect error(%v)", ch.Key, err)
 	}
 	if white {
@@ -311,6 +315,7 @@ func (s *Server) dispatchWebsocket(ws *websocket.Conn, wp *bytes.Pool, wb *bytes
 		finish bool
 		online int32
 		white  = whitelist.Contains(ch.Mid)
+		frames []*protocol.Frame // written frames released after flushed
 	)
 	if conf.Conf.Debug {
 		log.Infof("key: %s start dispatch tcp goroutine", ch.Key)
@@ -319,7 +324,7 @@ func (s *Server) dispatchWebsocket(ws *websocket.Conn, wp *bytes.Pool, wb *bytes
 		if white {
 			whitelist.Printf("key: %s wait proto ready\n", ch.Key)
 		}
-		var p = ch.Ready()
+		var p, f = ch.Ready()
 		fmt.Fprintf(os.Stderr, "=== dispatch: key=%s got proto op=%d bodyLen=%d ===\n", ch.Key, p.Op, len(p.Body))
 		if white {
 			whitelist.Printf("key: %s proto ready\n", ch.Key)
@@ -373,7 +378,7 @@ func (s *Server) dispatchWebsocket(ws *websocket.Conn, wp *bytes.Pool, wb *bytes
 			if white {
 				whitelist.Printf("key: %s start write server proto%v\n", ch.Key, p)
 			}
-			if err = p.WriteWebsocket(ws); err != nil {
+			if frames, err = (message{p: p, f: f}).writeWebsocket(ws, frames); err != nil {
 				fmt.Fprintf(os.Stderr, "=== dispatch: WriteWebsocket ERROR: %v ===\n", err)
 				goto failed
 			}
@@ -384,12 +389,28 @@ func (s *Server) dispatchWebsocket(ws *websocket.Conn, wp *bytes.Pool, wb *bytes
 			if conf.Conf.Debug {
 				log.Infof("websocket sent a message key:%s mid:%d proto:%+v", ch.Key, ch.Mid, p)
 			}
+			// the conn is closed once the disconnect reason is sent, the
+			// messages are discarded till the reader finishes
+			if p.Op == protocol.OpDisconnectReason {
+				err = ws.Flush()
+				goto failed
+			}
+		}
+		// drain the spilled messages once the signal channel is empty
+		if len(ch.signal) == 0 {
+			for _, m := range ch.drainOverflow() {
+				if frames, err = m.writeWebsocket(ws, frames); err != nil {
+					goto failed
+				}
+			}
 		}
 		if white {
 			whitelist.Printf("key: %s start flush \n", ch.Key)
 		}
 		// only hungry flush response
-		if err = ws.Flush(); err != nil {
+		err = ws.Flush()
+		frames = releaseFrames(frames)
+		if err != nil {
 			break
 		}
 		if white {
@@ -404,10 +425,16 @@ failed:
 		log.Errorf("key: %s dispatch ws error(%v)", ch.Key, err)
 	}
 	ws.Close()
+	releaseFrames(frames)
 	wp.Put(wb)
 	// must ensure all channel message discard, for reader won't blocking Signal
 	for !finish {
-		finish = (ch.Ready() == protocol.ProtoFinish)
+		p, f := ch.Ready()
+		message{p: p, f: f}.release()
+		finish = (p == protocol.ProtoFinish)
+	}
+	for _, m := range ch.drainOverflow() {
+		m.release()
 	}
 	if conf.Conf.Debug {
 		log.Infof("key: %s dispatch goroutine exit", ch.Key)
@@ -415,7 +442,7 @@ failed:
 }
 
 // auth for goim handshake with client, use rsa & aes.
-func (s *Server) authWebsocket(ctx context.Context, ws *websocket.Conn, p *protocol.Proto, cookie string) (mid int64, key, rid string, accepts []int32, hb time.Duration, err error) {
+func (s *Server) authWebsocket(ctx context.Context, ws *websocket.Conn, p *protocol.Proto, cookie, ip string) (reply *logic.ConnectReply, err error) {
 	fmt.Fprintf(os.Stderr, "=== authWebsocket START ===\n")
 	for {
 		if err = p.ReadWebsocket(ws); err != nil {
@@ -430,13 +457,13 @@ func (s *Server) authWebsocket(ctx context.Context, ws *websocket.Conn, p *proto
 		}
 	}
 	fmt.Fprintf(os.Stderr, "=== calling s.Connect ===\n")
-	if mid, key, rid, accepts, hb, err = s.Connect(ctx, p, cookie); err != nil {
+	if reply, err = s.Connect(ctx, p, cookie, ip); err != nil {
 		fmt.Fprintf(os.Stderr, "=== s.Connect error: %v ===\n", err)
 		return
 	}
-	fmt.Fprintf(os.Stderr, "=== auth success mid=%d key=%s rid=%s accepts=%v ===\n", mid, key, rid, accepts)
+	fmt.Fprintf(os.Stderr, "=== auth success mid=%d key=%s rid=%s accepts=%v ===\n", reply.Mid, reply.Key, reply.RoomID, reply.Accepts)
 	p.Op = protocol.OpAuthReply
-	p.Body = nil
+	p.Body = s.authReplyBody(reply)
 	if err = p.WriteWebsocket(ws); err != nil {
 		fmt.Fprintf(os.Stderr, "=== WriteWebsocket error: %v ===\n", err)
 		return
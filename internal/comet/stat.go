@@ -0,0 +1,147 @@
+package comet
+
+import (
+	"expvar"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/pkg/bytes"
+	log "github.com/golang/glog"
+)
+
+// stats are the comet counters published by expvar.
+var stats = expvar.NewMap("comet")
+
+// channelDrops is the messages dropped for all the channels, it's counted
+// where a message is dropped so that a scrape doesn't walk the channels.
+var channelDrops uint64
+
+// _topDrops is the keys kept by topDrops.
+const _topDrops = 100
+
+// topDrops is the keys of the most messages dropped, the closed ones too,
+// published as comet_channel_top_drops.
+var topDrops = newDropTop(_topDrops)
+
+func init() {
+	expvar.Publish("comet_channel_top_drops", expvar.Func(topDrops.drops))
+}
+
+// dropTop keeps the drops of the keys of the most drops, at most size keys.
+type dropTop struct {
+	mu   sync.Mutex
+	size int
+	keys map[string]uint64
+	// min is at most the least drops kept as the drops only grow, so that
+	// a key of fewer drops is not kept without a scan
+	min uint64
+}
+
+func newDropTop(size int) *dropTop {
+	return &dropTop{size: size, keys: make(map[string]uint64, size)}
+}
+
+// add sets the drops of the key, the key of the least drops is replaced by
+// a key of more drops if it's full.
+func (t *dropTop) add(key string, drops uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.keys[key]; ok || len(t.keys) < t.size {
+		t.keys[key] = drops
+		return
+	}
+	if drops <= t.min {
+		return
+	}
+	// the least and the next least drops
+	var (
+		least     string
+		min, next uint64 = 0, math.MaxUint64
+	)
+	for k, n := range t.keys {
+		switch {
+		case least == "":
+			least, min = k, n
+		case n < min:
+			least, min, next = k, n, min
+		case n < next:
+			next = n
+		}
+	}
+	if drops <= min {
+		t.min = min
+		return
+	}
+	delete(t.keys, least)
+	t.keys[key] = drops
+	if t.min = next; drops < next {
+		t.min = drops
+	}
+}
+
+// drops returns the drops per key.
+func (t *dropTop) drops() interface{} {
+	t.mu.Lock()
+	drops := make(map[string]uint64, len(t.keys))
+	for k, n := range t.keys {
+		drops[k] = n
+	}
+	t.mu.Unlock()
+	return drops
+}
+
+// stat counter names.
+const (
+	statConnIPLimit     = "conn_ip_limit"
+	statConnDeviceLimit = "conn_device_limit"
+	statConnEvicted     = "conn_evicted"
+	statRateHeartbeat   = "rate_limited_heartbeat"
+	statRateRoom        = "rate_limited_room"
+	statRateOp          = "rate_limited_op"
+	statRateDisconnect  = "rate_limit_disconnect"
+	statPushSpilled     = "push_spilled"
+	statPushDropped     = "push_dropped"
+	statSlowConsumer    = "slow_consumer_disconnect"
+	statPresenceDropped = "presence_dropped"
+)
+
+// InitMetrics serves the expvar counters on addr at /metrics and /debug/vars.
+func InitMetrics(s *Server, addr string) {
+	expvar.Publish("comet_channel_drops", expvar.Func(func() interface{} { return atomic.LoadUint64(&channelDrops) }))
+	expvar.Publish("comet_pools", expvar.Func(s.poolStats))
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", expvar.Handler())
+	mux.Handle("/debug/vars", expvar.Handler())
+	log.Infof("start metrics listen: %s", addr)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Errorf("metrics http.ListenAndServe(%s) error(%v)", addr, err)
+		}
+	}()
+}
+
+// poolStats returns the utilization of the round buffer pools and the frame
+// pool.
+func (s *Server) poolStats() interface{} {
+	var (
+		readers = make([]bytes.PoolStat, 0, len(s.round.readers))
+		writers = make([]bytes.PoolStat, 0, len(s.round.writers))
+	)
+	for i := range s.round.readers {
+		readers = append(readers, s.round.readers[i].Stats())
+	}
+	for i := range s.round.writers {
+		writers = append(writers, s.round.writers[i].Stats())
+	}
+	return map[string]interface{}{
+		"reader": readers,
+		"writer": writers,
+		"frame":  protocol.FramePoolStats(),
+	}
+}
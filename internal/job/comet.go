@@ -9,6 +9,7 @@ import (
 
 	"github.com/Terry-Mao/goim/api/comet"
 	"github.com/Terry-Mao/goim/internal/job/conf"
+	"github.com/Terry-Mao/goim/pkg/bytes"
 	"github.com/bilibili/discovery/naming"
 
 	log "github.com/golang/glog"
@@ -60,7 +61,7 @@ type Comet struct {
 	serverID      string
 	client        comet.CometClient
 	pushChan      []chan *comet.PushMsgReq
-	roomChan      []chan *comet.BroadcastRoomReq
+	roomChan      []chan *roomReq
 	broadcastChan chan *comet.BroadcastReq
 	pushChanNum   uint64
 	roomChanNum   uint64
@@ -75,7 +76,7 @@ func NewComet(in *naming.Instance, c *conf.Comet) (*Comet, error) {
 	cmt := &Comet{
 		serverID:      in.Hostname,
 		pushChan:      make([]chan *comet.PushMsgReq, c.RoutineSize),
-		roomChan:      make([]chan *comet.BroadcastRoomReq, c.RoutineSize),
+		roomChan:      make([]chan *roomReq, c.RoutineSize),
 		broadcastChan: make(chan *comet.BroadcastReq, c.RoutineSize),
 		routineSize:   uint64(c.RoutineSize),
 	}
@@ -97,7 +98,7 @@ func NewComet(in *naming.Instance, c *conf.Comet) (*Comet, error) {
 
 	for i := 0; i < c.RoutineSize; i++ {
 		cmt.pushChan[i] = make(chan *comet.PushMsgReq, c.RoutineChan)
-		cmt.roomChan[i] = make(chan *comet.BroadcastRoomReq, c.RoutineChan)
+		cmt.roomChan[i] = make(chan *roomReq, c.RoutineChan)
 		go cmt.process(cmt.pushChan[i], cmt.roomChan[i], cmt.broadcastChan)
 	}
 	return cmt, nil
@@ -110,10 +111,17 @@ func (c *Comet) Push(arg *comet.PushMsgReq) (err error) {
 	return
 }
 
-// BroadcastRoom broadcast a room message.
-func (c *Comet) BroadcastRoom(arg *comet.BroadcastRoomReq) (err error) {
+// roomReq is a room message with the buffer of its body if pooled.
+type roomReq struct {
+	arg *comet.BroadcastRoomReq
+	buf *bytes.RefBuffer
+}
+
+// BroadcastRoom broadcast a room message, buf is the buffer of the body
+// released after the message is sent, it can be nil.
+func (c *Comet) BroadcastRoom(arg *comet.BroadcastRoomReq, buf *bytes.RefBuffer) (err error) {
 	idx := atomic.AddUint64(&c.roomChanNum, 1) % c.routineSize
-	c.roomChan[idx] <- arg
+	c.roomChan[idx] <- &roomReq{arg: arg, buf: buf}
 	return
 }
 
@@ -123,19 +131,22 @@ func (c *Comet) Broadcast(arg *comet.BroadcastReq) (err error) {
 	return
 }
 
-func (c *Comet) process(pushChan chan *comet.PushMsgReq, roomChan chan *comet.BroadcastRoomReq, broadcastChan chan *comet.BroadcastReq) {
+func (c *Comet) process(pushChan chan *comet.PushMsgReq, roomChan chan *roomReq, broadcastChan chan *comet.BroadcastReq) {
 	for {
 		select {
 		case broadcastArg := <-broadcastChan:
 			_, err := c.client.Broadcast(context.Background(), &comet.BroadcastReq{
-				Proto:   broadcastArg.Proto,
-				ProtoOp: broadcastArg.ProtoOp,
-				Speed:   broadcastArg.Speed,
+				Proto:    broadcastArg.Proto,
+				ProtoOp:  broadcastArg.ProtoOp,
+				Speed:    broadcastArg.Speed,
+				Platform: broadcastArg.Platform,
+				App:      broadcastArg.App,
 			})
 			if err != nil {
 				log.Errorf("c.client.Broadcast(%s, reply) serverId:%s error(%v)", broadcastArg, c.serverID, err)
 			}
-		case roomArg := <-roomChan:
+		case req := <-roomChan:
+			roomArg := req.arg
 			_, err := c.client.BroadcastRoom(context.Background(), &comet.BroadcastRoomReq{
 				RoomID: roomArg.RoomID,
 				Proto:  roomArg.Proto,
@@ -143,6 +154,9 @@ func (c *Comet) process(pushChan chan *comet.PushMsgReq, roomChan chan *comet.Br
 			if err != nil {
 				log.Errorf("c.client.BroadcastRoom(%s, reply) serverId:%s error(%v)", roomArg, c.serverID, err)
 			}
+			if req.buf != nil {
+				req.buf.Release()
+			}
 		case pushArg := <-pushChan:
 			_, err := c.client.PushMsg(context.Background(), &comet.PushMsgReq{
 				Keys:    pushArg.Keys,
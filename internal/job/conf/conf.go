@@ -2,6 +2,7 @@ package conf
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"time"
 
@@ -34,8 +35,10 @@ func init() {
 // Init init config.
 func Init() (err error) {
 	Conf = Default()
-	_, err = toml.DecodeFile(confPath, &Conf)
-	return
+	if _, err = toml.DecodeFile(confPath, &Conf); err != nil {
+		return
+	}
+	return Conf.Room.validate()
 }
 
 // Default new a config with specified defualt value.
@@ -49,6 +52,7 @@ func Default() *Config {
 			Signal: xtime.Duration(time.Second),
 			Idle:   xtime.Duration(time.Minute * 15),
 		},
+		Metrics: &Metrics{},
 	}
 }
 
@@ -59,13 +63,44 @@ type Config struct {
 	Discovery *naming.Config
 	Comet     *Comet
 	Room      *Room
+	Metrics   *Metrics
+}
+
+// Metrics is metrics config.
+type Metrics struct {
+	Addr string
 }
 
 // Room is room config.
 type Room struct {
-	Batch  int
-	Signal xtime.Duration
-	Idle   xtime.Duration
+	Batch   int
+	Signal  xtime.Duration
+	Idle    xtime.Duration
+	Budgets []*RoomBudget
+}
+
+// RoomBudget is the push budget of the rooms by the id prefix, the longest
+// prefix matched is used and the rooms matched none are unlimited. The
+// messages of Priority or higher are never throttled if Priority > 0.
+type RoomBudget struct {
+	Prefix   string
+	QPS      float64
+	Burst    int
+	Strategy string // merge, sample or drop_low, default merge
+	Sample   int    // sample keeps one of every Sample messages over the budget
+	Priority int32
+}
+
+// validate fails on an unknown budget strategy.
+func (r *Room) validate() error {
+	for _, b := range r.Budgets {
+		switch b.Strategy {
+		case "", "merge", "sample", "drop_low":
+		default:
+			return fmt.Errorf("room budget %s unknown strategy: %s", b.Prefix, b.Strategy)
+		}
+	}
+	return nil
 }
 
 // Comet is comet config.
@@ -8,6 +8,7 @@ import (
 	"github.com/Terry-Mao/goim/api/comet"
 	pb "github.com/Terry-Mao/goim/api/logic"
 	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/pkg/bytes"
 	log "github.com/golang/glog"
 )
 
@@ -16,9 +17,14 @@ func (j *Job) push(ctx context.Context, pushMsg *pb.PushMsg) (err error) {
 	case pb.PushMsg_PUSH:
 		err = j.pushKeys(pushMsg.Operation, pushMsg.Server, pushMsg.Keys, pushMsg.Msg)
 	case pb.PushMsg_ROOM:
-		err = j.getRoom(pushMsg.Room).Push(pushMsg.Operation, pushMsg.Msg)
+		if pushMsg.Operation == protocol.OpPresence {
+			// batched by logic, and comet pushes it to the subscribers only
+			err = j.broadcastRoom(pushMsg.Room, &protocol.Proto{Ver: 1, Op: protocol.OpPresence, Body: pushMsg.Msg})
+			break
+		}
+		err = j.getRoom(pushMsg.Room).Push(pushMsg.Operation, pushMsg.Priority, pushMsg.Msg)
 	case pb.PushMsg_BROADCAST:
-		err = j.broadcast(pushMsg.Operation, pushMsg.Msg, pushMsg.Speed)
+		err = j.broadcast(pushMsg.App, pushMsg.Operation, pushMsg.Msg, pushMsg.Speed, pushMsg.Platform)
 	default:
 		err = fmt.Errorf("no match push type: %s", pushMsg.Type)
 	}
@@ -61,20 +67,27 @@ func (j *Job) pushKeys(operation int32, serverID string, subKeys []string, body
 	return
 }
 
-// broadcast broadcast a message to all.
-func (j *Job) broadcast(operation int32, body []byte, speed int32) (err error) {
+// broadcast broadcast a message to all of the app, or the platform if it's
+// not empty.
+func (j *Job) broadcast(app string, operation int32, body []byte, speed int32, platform string) (err error) {
 	// Use OpRaw to indicate the body is already encoded
 	p := &protocol.Proto{
 		Ver:  1,
 		Op:   protocol.OpRaw,
 		Body: body,  // body is already the JSON string, don't encode it again
 	}
+	if operation == protocol.OpConfigUpdate {
+		// handled by comet and the client sdk as is
+		p.Op = protocol.OpConfigUpdate
+	}
 	comets := j.cometServers
 	speed /= int32(len(comets))
 	var args = comet.BroadcastReq{
-		ProtoOp: operation,
-		Proto:   p,
-		Speed:   speed,
+		ProtoOp:  operation,
+		Proto:    p,
+		Speed:    speed,
+		Platform: platform,
+		App:      app,
 	}
 	for serverID, c := range comets {
 		maxRetries := 3 // 最大重试次数
@@ -100,14 +113,30 @@ func (j *Job) broadcast(operation int32, body []byte, speed int32) (err error) {
 	return
 }
 
+// broadcastRoom broadcast a message to room as is.
+func (j *Job) broadcastRoom(roomID string, p *protocol.Proto) (err error) {
+	args := comet.BroadcastRoomReq{
+		RoomID: roomID,
+		Proto:  p,
+	}
+	for serverID, c := range j.cometServers {
+		if err = c.BroadcastRoom(&args, nil); err != nil {
+			log.Errorf("c.BroadcastRoom(%v) roomID:%s serverID:%s error(%v)", args, roomID, serverID, err)
+		}
+	}
+	return
+}
+
 // broadcastRoomRawBytes broadcast aggregation messages to room.
-func (j *Job) broadcastRoomRawBytes(roomID string, body []byte) (err error) {
+// The body is released after it's sent to all the comets.
+func (j *Job) broadcastRoomRawBytes(roomID string, body *bytes.RefBuffer) (err error) {
+	defer body.Release()
 	args := comet.BroadcastRoomReq{
 		RoomID: roomID,
 		Proto: &protocol.Proto{
 			Ver:  1,
 			Op:   protocol.OpRaw,
-			Body: body,
+			Body: body.Bytes(),
 		},
 	}
 	comets := j.cometServers
@@ -115,7 +144,9 @@ func (j *Job) broadcastRoomRawBytes(roomID string, body []byte) (err error) {
 	for serverID, c := range comets {
 		retryCount := 0
 		for retryCount < maxRetries {
-			if err = c.BroadcastRoom(&args); err != nil {
+			body.Retain()
+			if err = c.BroadcastRoom(&args, body); err != nil {
+				body.Release()
 				retryCount++
 				if retryCount >= maxRetries {
 					log.Errorf("c.BroadcastRoom(%v) roomID:%s serverID:%s error(%v) after %d retries, giving up", args, roomID, serverID, err, maxRetries)
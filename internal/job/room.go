@@ -2,14 +2,24 @@ package job
 
 import (
 	"errors"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/Terry-Mao/goim/api/protocol"
 	"github.com/Terry-Mao/goim/internal/job/conf"
 	"github.com/Terry-Mao/goim/pkg/bytes"
+	"github.com/Terry-Mao/goim/pkg/ratelimit"
 	log "github.com/golang/glog"
 )
 
+// room budget strategies.
+const (
+	strategyMerge   = "merge"
+	strategySample  = "sample"
+	strategyDropLow = "drop_low"
+)
+
 var (
 	// ErrComet commet error.
 	ErrComet = errors.New("comet rpc is not available")
@@ -19,30 +29,77 @@ var (
 	ErrRoomFull = errors.New("room proto chan full")
 
 	roomReadyProto = new(protocol.Proto)
+	// roomPool is the pool of the room batch buffers.
+	roomPool = bytes.NewClassPool(1024, int(protocol.MaxBodySize)<<6)
 )
 
 // Room room.
 type Room struct {
-	c     *conf.Room
-	job   *Job
-	id    string
-	proto chan *protocol.Proto
+	c      *conf.Room
+	job    *Job
+	id     string
+	proto  chan *protocol.Proto
+	budget *conf.RoomBudget
+	limit  *ratelimit.Bucket
+	over   uint64 // messages over the budget for sampling
+	stat   RoomStats
+}
+
+// RoomStats is the counters of a room, the throttled ones are also summed up
+// in the job stats.
+type RoomStats struct {
+	Pushed  int64 `json:"pushed"`
+	Batches int64 `json:"batches"`
+	Merged  int64 `json:"merged"`
+	Sampled int64 `json:"sampled"`
+	Dropped int64 `json:"dropped"`
+	Full    int64 `json:"full"`
 }
 
 // NewRoom new a room struct, store channel room info.
 func NewRoom(job *Job, id string, c *conf.Room) (r *Room) {
 	r = &Room{
-		c:     c,
-		id:    id,
-		job:   job,
-		proto: make(chan *protocol.Proto, c.Batch*2),
+		c:      c,
+		id:     id,
+		job:    job,
+		proto:  make(chan *protocol.Proto, c.Batch*2),
+		budget: roomBudget(c, id),
+	}
+	if r.budget != nil {
+		r.limit = ratelimit.New(r.budget.QPS, r.budget.Burst)
 	}
 	go r.pushproc(c.Batch, time.Duration(c.Signal))
 	return
 }
 
-// Push push msg to the room, if chan full discard it.
-func (r *Room) Push(op int32, msg []byte) (err error) {
+// matchRoom reports whether the room id has the prefix of a budget, a prefix
+// in a namespace as @app/live:// matches the rooms of the app only, others
+// match the room named by the client in any namespace.
+func matchRoom(id, prefix string) bool {
+	if !strings.HasPrefix(prefix, "@") && strings.HasPrefix(id, "@") {
+		if i := strings.IndexByte(id, '/'); i > 0 {
+			id = id[i+1:]
+		}
+	}
+	return strings.HasPrefix(id, prefix)
+}
+
+// roomBudget returns the budget of the longest prefix matched, nil if none.
+func roomBudget(c *conf.Room, id string) (b *conf.RoomBudget) {
+	for _, rb := range c.Budgets {
+		if rb.QPS > 0 && matchRoom(id, rb.Prefix) && (b == nil || len(rb.Prefix) > len(b.Prefix)) {
+			b = rb
+		}
+	}
+	return
+}
+
+// Push push msg to the room, if chan full discard it. The message is
+// throttled silently by the room budget.
+func (r *Room) Push(op, priority int32, msg []byte) (err error) {
+	if !r.admit(priority, time.Now()) {
+		return
+	}
 	var p = &protocol.Proto{
 		Ver:  1,
 		Op:   op,
@@ -50,19 +107,75 @@ func (r *Room) Push(op int32, msg []byte) (err error) {
 	}
 	select {
 	case r.proto <- p:
+		atomic.AddInt64(&r.stat.Pushed, 1)
 	default:
+		atomic.AddInt64(&r.stat.Full, 1)
+		stats.Add(statRoomFull, 1)
 		err = ErrRoomFull
 	}
 	return
 }
 
+// admit reports whether the message is pushed by the sample and drop_low
+// budget, the merge one is applied by the batches.
+func (r *Room) admit(priority int32, now time.Time) bool {
+	if r.budget == nil || r.budget.Strategy == strategyMerge || r.budget.Strategy == "" {
+		return true
+	}
+	if r.budget.Priority > 0 && priority >= r.budget.Priority {
+		return true
+	}
+	if r.limit.AllowN(now, 1) {
+		return true
+	}
+	if r.budget.Strategy == strategySample {
+		if r.budget.Sample > 0 && atomic.AddUint64(&r.over, 1)%uint64(r.budget.Sample) == 0 {
+			return true
+		}
+		atomic.AddInt64(&r.stat.Sampled, 1)
+		stats.Add(statRoomSampled, 1)
+		return false
+	}
+	atomic.AddInt64(&r.stat.Dropped, 1)
+	stats.Add(statRoomDropped, 1)
+	return false
+}
+
+// wait returns the duration until the merge budget allows the next batch,
+// zero if it's allowed now.
+func (r *Room) wait(now time.Time) time.Duration {
+	if r.budget == nil || (r.budget.Strategy != strategyMerge && r.budget.Strategy != "") {
+		return 0
+	}
+	if r.limit.AllowN(now, 1) {
+		return 0
+	}
+	d := time.Duration((1 - r.limit.Tokens(now)) / r.budget.QPS * float64(time.Second))
+	if d < time.Millisecond {
+		d = time.Millisecond
+	}
+	return d
+}
+
+// Stats returns the counters of the room.
+func (r *Room) Stats() RoomStats {
+	return RoomStats{
+		Pushed:  atomic.LoadInt64(&r.stat.Pushed),
+		Batches: atomic.LoadInt64(&r.stat.Batches),
+		Merged:  atomic.LoadInt64(&r.stat.Merged),
+		Sampled: atomic.LoadInt64(&r.stat.Sampled),
+		Dropped: atomic.LoadInt64(&r.stat.Dropped),
+		Full:    atomic.LoadInt64(&r.stat.Full),
+	}
+}
+
 // pushproc merge proto and push msgs in batch.
 func (r *Room) pushproc(batch int, sigTime time.Duration) {
 	var (
 		n    int
 		last time.Time
 		p    *protocol.Proto
-		buf  = bytes.NewWriterSize(int(protocol.MaxBodySize))
+		buf  = bytes.NewPoolWriter(roomPool, int(protocol.MaxBodySize))
 	)
 	log.Infof("start room:%s goroutine", r.id)
 	td := time.AfterFunc(sigTime, func() {
@@ -72,10 +185,18 @@ func (r *Room) pushproc(batch int, sigTime time.Duration) {
 		}
 	})
 	defer td.Stop()
+	defer buf.Release()
 	for {
 		if p = <-r.proto; p == nil {
 			break // exit
 		} else if p != roomReadyProto {
+			if n >= batch {
+				// the batch is full and throttled, drop the message to keep
+				// the buffer in the batch
+				atomic.AddInt64(&r.stat.Dropped, 1)
+				stats.Add(statRoomDropped, 1)
+				continue
+			}
 			// merge buffer: only write the body, not the full proto header
 			// The proto headers will be added by broadcastRoomRawBytes
 			if p.Body != nil {
@@ -95,10 +216,19 @@ func (r *Room) pushproc(batch int, sigTime time.Duration) {
 				break
 			}
 		}
-		_ = r.job.broadcastRoomRawBytes(r.id, buf.Buffer())
-		// TODO use reset buffer
-		// after push to room channel, renew a buffer, let old buffer gc
-		buf = bytes.NewWriterSize(buf.Size())
+		if d := r.wait(time.Now()); d > 0 {
+			// over the budget, merge the messages into the next batch
+			if p != roomReadyProto {
+				atomic.AddInt64(&r.stat.Merged, 1)
+				stats.Add(statRoomMerged, 1)
+			}
+			td.Reset(d)
+			continue
+		}
+		// the buffer is released after pushed to all the comets, the writer
+		// gets a new one from the pool
+		_ = r.job.broadcastRoomRawBytes(r.id, buf.Detach())
+		atomic.AddInt64(&r.stat.Batches, 1)
 		n = 0
 		if r.c.Idle != 0 {
 			td.Reset(time.Duration(r.c.Idle))
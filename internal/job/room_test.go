@@ -0,0 +1,101 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Terry-Mao/goim/internal/job/conf"
+	xtime "github.com/Terry-Mao/goim/pkg/time"
+)
+
+func newTestRoom(id string, budgets ...*conf.RoomBudget) *Room {
+	c := &conf.Room{Batch: 2, Signal: xtime.Duration(5 * time.Millisecond), Idle: xtime.Duration(time.Minute), Budgets: budgets}
+	j := &Job{c: &conf.Config{Room: c}, cometServers: map[string]*Comet{}, rooms: map[string]*Room{}}
+	return j.getRoom(id)
+}
+
+func TestRoomBudget(t *testing.T) {
+	c := &conf.Room{Budgets: []*conf.RoomBudget{
+		{Prefix: "live://", QPS: 10},
+		{Prefix: "live://big/", QPS: 100},
+		{Prefix: "group://", QPS: 0},
+		{Prefix: "@shop/live://", QPS: 20},
+	}}
+	for id, want := range map[string]float64{
+		"live://1":           10,
+		"live://big/1":       100,
+		"group://1":          0,
+		"chat://1":           0,
+		"@game/live://1":     10,
+		"@game/live://big/1": 100,
+		"@shop/live://1":     20,
+	} {
+		b := roomBudget(c, id)
+		if (b == nil && want != 0) || (b != nil && b.QPS != want) {
+			t.Fatalf("room: %s budget %+v want qps %v", id, b, want)
+		}
+	}
+}
+
+func TestRoomSample(t *testing.T) {
+	r := newTestRoom("live://1", &conf.RoomBudget{Prefix: "live://", QPS: 1, Burst: 2, Strategy: strategySample, Sample: 3, Priority: 5})
+	now := time.Now()
+	var admitted int
+	for i := 0; i < 11; i++ {
+		if r.admit(0, now) {
+			admitted++
+		}
+	}
+	// 2 in the burst, 3 of the 9 over it
+	if s := r.Stats(); admitted != 5 || s.Sampled != 6 {
+		t.Fatalf("admitted %d stats %+v", admitted, s)
+	}
+	if !r.admit(5, now) {
+		t.Fatal("high priority must not be sampled")
+	}
+}
+
+func TestRoomDropLow(t *testing.T) {
+	r := newTestRoom("live://1", &conf.RoomBudget{Prefix: "live://", QPS: 1, Burst: 1, Strategy: strategyDropLow, Priority: 1})
+	now := time.Now()
+	if !r.admit(0, now) || r.admit(0, now) || !r.admit(1, now) {
+		t.Fatal("only low priority must be dropped over the budget")
+	}
+	if !r.admit(0, now.Add(time.Second)) {
+		t.Fatal("budget must be refilled")
+	}
+	if s := r.Stats(); s.Dropped != 1 {
+		t.Fatalf("stats %+v", s)
+	}
+}
+
+func TestRoomMerge(t *testing.T) {
+	r := newTestRoom("live://1", &conf.RoomBudget{Prefix: "live://", QPS: 10, Burst: 1})
+	for i := 0; i < 10; i++ {
+		for k := 0; k < 2; k++ {
+			if err := r.Push(1000, 0, []byte("msg")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(150 * time.Millisecond)
+	// 10 batches without the budget
+	if s := r.Stats(); s.Pushed != 20 || s.Batches < 1 || s.Batches > 3 || s.Merged == 0 {
+		t.Fatalf("stats %+v", s)
+	}
+}
+
+func TestRoomMergeCap(t *testing.T) {
+	r := newTestRoom("live://1", &conf.RoomBudget{Prefix: "live://", QPS: 1, Burst: 1})
+	for i := 0; i < 10; i++ {
+		if err := r.Push(1000, 0, []byte("msg")); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	// one batch in the budget, the next one is full and throttled
+	if s := r.Stats(); s.Pushed != 10 || s.Batches != 1 || s.Dropped == 0 {
+		t.Fatalf("stats %+v", s)
+	}
+}
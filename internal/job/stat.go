@@ -0,0 +1,52 @@
+package job
+
+import (
+	"expvar"
+	"net/http"
+
+	log "github.com/golang/glog"
+)
+
+// stats are the job counters published by expvar.
+var stats = expvar.NewMap("job")
+
+// stat counter names.
+const (
+	statRoomMerged  = "room_merged"
+	statRoomSampled = "room_sampled"
+	statRoomDropped = "room_dropped"
+	statRoomFull    = "room_full"
+)
+
+// InitMetrics serves the expvar counters on addr at /metrics and /debug/vars.
+func InitMetrics(j *Job, addr string) {
+	expvar.Publish("job_room_pool", expvar.Func(func() interface{} {
+		return roomPool.Stats()
+	}))
+	expvar.Publish("job_rooms", expvar.Func(j.roomStats))
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", expvar.Handler())
+	mux.Handle("/debug/vars", expvar.Handler())
+	log.Infof("start metrics listen: %s", addr)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Errorf("metrics http.ListenAndServe(%s) error(%v)", addr, err)
+		}
+	}()
+}
+
+// roomStats returns the counters of the rooms throttled.
+func (j *Job) roomStats() interface{} {
+	rooms := make(map[string]RoomStats)
+	j.roomsMutex.RLock()
+	for id, r := range j.rooms {
+		if s := r.Stats(); s.Merged > 0 || s.Sampled > 0 || s.Dropped > 0 || s.Full > 0 {
+			rooms[id] = s
+		}
+	}
+	j.roomsMutex.RUnlock()
+	return rooms
+}
@@ -0,0 +1,62 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Terry-Mao/goim/internal/logic/conf"
+	"github.com/Terry-Mao/goim/internal/logic/model"
+)
+
+var (
+	errAppUnknown = errors.New("app unknown")
+	errAppConns   = errors.New("app connections over the quota")
+	errAppKey     = errors.New("key or room in an app namespace")
+)
+
+func (l *Logic) initApps() {
+	l.apps = make(map[string]*conf.App, len(l.c.Apps))
+	l.appIDs = []string{model.DefaultApp}
+	for _, app := range l.c.Apps {
+		if app.ID == model.DefaultApp {
+			continue
+		}
+		l.apps[app.ID] = app
+		l.appIDs = append(l.appIDs, app.ID)
+	}
+}
+
+// appKey is the key in the namespace of the app, empty if the key is.
+func appKey(app, key string) string {
+	if key == "" {
+		return ""
+	}
+	return model.AppKey(app, key)
+}
+
+// checkApp rejects the connection of an unknown app, or of an app over its
+// connections, which are renewed by the comets every 10 seconds.
+func (l *Logic) checkApp(app string) error {
+	if app == model.DefaultApp {
+		return nil
+	}
+	a, ok := l.apps[app]
+	if !ok {
+		return errAppUnknown
+	}
+	if a.MaxConns > 0 && l.appConns[app] >= a.MaxConns {
+		return errAppConns
+	}
+	return nil
+}
+
+// OnlineApp get the connections and the rooms of the app.
+func (l *Logic) OnlineApp(c context.Context, app string) *model.AppOnline {
+	res := &model.AppOnline{App: app, Conns: l.appConns[app]}
+	for roomID := range l.roomCount {
+		if a, _ := model.SplitAppKey(roomID); a == app {
+			res.Rooms++
+		}
+	}
+	return res
+}
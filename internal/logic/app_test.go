@@ -0,0 +1,59 @@
+package logic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Terry-Mao/goim/internal/logic/conf"
+	"github.com/Terry-Mao/goim/internal/logic/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppKey(t *testing.T) {
+	assert.Equal(t, "key", appKey("", "key"))
+	assert.Equal(t, "@shop/key", appKey("shop", "key"))
+	assert.Equal(t, "", appKey("shop", ""))
+	app, key := model.SplitAppKey("@shop/live://1")
+	assert.Equal(t, "shop", app)
+	assert.Equal(t, "live://1", key)
+	app, key = model.SplitAppKey("live://1")
+	assert.Equal(t, "", app)
+	assert.Equal(t, "live://1", key)
+}
+
+func TestCheckApp(t *testing.T) {
+	apps, appConns := lg.apps, lg.appConns
+	defer func() {
+		lg.apps, lg.appConns = apps, appConns
+	}()
+	lg.apps = map[string]*conf.App{"shop": {ID: "shop", MaxConns: 2}}
+	lg.appConns = map[string]int32{"shop": 1}
+	assert.Nil(t, lg.checkApp(""))
+	assert.Nil(t, lg.checkApp("shop"))
+	assert.Equal(t, errAppUnknown, lg.checkApp("game"))
+	lg.appConns["shop"] = 2
+	assert.Equal(t, errAppConns, lg.checkApp("shop"))
+}
+
+func TestOnlineApp(t *testing.T) {
+	c := context.TODO()
+	lg.roomCount = map[string]int32{
+		"live://1":       100,
+		"@shop/live://1": 10,
+		"@shop/live://2": 20,
+	}
+	lg.roomUsers = map[string]int32{}
+	lg.appConns = map[string]int32{"shop": 30}
+	assert.Equal(t, &model.AppOnline{App: "shop", Conns: 30, Rooms: 2}, lg.OnlineApp(c, "shop"))
+	tops, err := lg.OnlineTop(c, "shop", "live", 10)
+	assert.Nil(t, err)
+	assert.Len(t, tops, 2)
+	assert.Equal(t, "2", tops[0].RoomID)
+	tops, err = lg.OnlineTop(c, "", "live", 10)
+	assert.Nil(t, err)
+	assert.Len(t, tops, 1)
+	assert.Equal(t, int32(100), tops[0].Count)
+	onlines, err := lg.OnlineRoom(c, "shop", "live", []string{"1"})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(10), onlines["1"])
+}
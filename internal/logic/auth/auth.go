@@ -0,0 +1,249 @@
+// Package auth authenticates the apps calling the push api by the api keys
+// or the signed requests, and limits them by the permissions, the rate and
+// the daily quota.
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Terry-Mao/goim/internal/logic/conf"
+	"github.com/Terry-Mao/goim/pkg/ratelimit"
+	log "github.com/golang/glog"
+)
+
+// push targets.
+const (
+	TargetKeys   = "keys"
+	TargetMids   = "mids"
+	TargetRoom   = "room"
+	TargetAll    = "all"
+	TargetConfig = "config"
+)
+
+// request headers.
+const (
+	HeaderKey       = "X-Goim-Key"
+	HeaderApp       = "X-Goim-App"
+	HeaderTimestamp = "X-Goim-Timestamp"
+	HeaderNonce     = "X-Goim-Nonce"
+	HeaderSignature = "X-Goim-Signature"
+)
+
+// maxNonce is the max length of a nonce.
+const maxNonce = 64
+
+var (
+	// ErrUnauthorized the app or the signature is unknown.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrExpired the signed request is out of the skew.
+	ErrExpired = errors.New("signature expired")
+	// ErrReplayed the nonce of the signed request is used in the skew.
+	ErrReplayed = errors.New("request replayed")
+	// ErrForbidden the target or the operation is not allowed.
+	ErrForbidden = errors.New("forbidden")
+	// ErrRateLimited the app is over the qps.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrQuotaExceeded the app is over the quota of the day.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+// Store counts the pushes and keeps the nonces of the apps, it's shared by
+// the logics.
+type Store interface {
+	// IncrPushQuota counts n pushes of the app in the day, returns the
+	// pushes of the day.
+	IncrPushQuota(c context.Context, app, day string, n int64) (int64, error)
+	// AddPushNonce keeps the nonce of the app in ttl, false if it's kept.
+	AddPushNonce(c context.Context, app, nonce string, ttl time.Duration) (bool, error)
+}
+
+// App is an app calling the push api.
+type App struct {
+	c       *conf.PushApp
+	ops     map[int32]struct{}
+	targets map[string]struct{}
+	apps    map[string]struct{}
+	limit   *ratelimit.Bucket
+}
+
+func newApp(c *conf.PushApp) *App {
+	app := &App{c: c, limit: ratelimit.New(c.QPS, c.Burst)}
+	if len(c.Ops) > 0 {
+		app.ops = make(map[int32]struct{}, len(c.Ops))
+		for _, op := range c.Ops {
+			app.ops[op] = struct{}{}
+		}
+	}
+	if len(c.Targets) > 0 {
+		app.targets = make(map[string]struct{}, len(c.Targets))
+		for _, target := range c.Targets {
+			app.targets[target] = struct{}{}
+		}
+	}
+	if len(c.Apps) > 0 {
+		app.apps = make(map[string]struct{}, len(c.Apps))
+		for _, ns := range c.Apps {
+			app.apps[ns] = struct{}{}
+		}
+	}
+	return app
+}
+
+// ID returns the app id.
+func (app *App) ID() string {
+	return app.c.ID
+}
+
+// AllowTarget reports whether the app may push to the target.
+func (app *App) AllowTarget(target string) bool {
+	if app.targets == nil {
+		return true
+	}
+	_, ok := app.targets[target]
+	return ok
+}
+
+// AllowApp reports whether the app may push to the namespace.
+func (app *App) AllowApp(ns string) bool {
+	if app.apps == nil {
+		return true
+	}
+	_, ok := app.apps[ns]
+	return ok
+}
+
+// AllowOp reports whether the app may push the operation.
+func (app *App) AllowOp(op int32) bool {
+	if app.ops == nil {
+		return true
+	}
+	_, ok := app.ops[op]
+	return ok
+}
+
+// Auth is the apps calling the push api.
+type Auth struct {
+	skew  time.Duration
+	apps  map[string]*App
+	keys  map[string]*App
+	store Store
+}
+
+// New returns nil if the push api is open to all by c.Open, otherwise it's
+// closed to all but the apps.
+func New(c *conf.PushAuth, store Store) *Auth {
+	if c == nil {
+		c = new(conf.PushAuth)
+	}
+	if c.Open {
+		log.Warning("push api is open to all without authentication")
+		return nil
+	}
+	a := &Auth{
+		skew:  time.Duration(c.Skew),
+		apps:  make(map[string]*App, len(c.Apps)),
+		keys:  make(map[string]*App, len(c.Apps)),
+		store: store,
+	}
+	if a.skew <= 0 {
+		a.skew = 5 * time.Minute
+	}
+	for _, ac := range c.Apps {
+		app := newApp(ac)
+		a.apps[ac.ID] = app
+		if ac.Key != "" {
+			a.keys[ac.Key] = app
+		}
+	}
+	return a
+}
+
+// Sign returns the hmac-sha256 of the request signed by the secret.
+func Sign(secret, method, path, query string, ts int64, nonce string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d\n%s\n", method, path, query, ts, nonce)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// Credential is the credential headers of a call, as the headers of a http
+// request or the metadata of a grpc call.
+type Credential interface {
+	Get(key string) string
+}
+
+// Authenticate returns the app of the request by the api key, or by the
+// signature of the method, the path, the query, the timestamp, the nonce and
+// the body. A nonce is used once in the skew.
+func (a *Auth) Authenticate(r *http.Request, body []byte, now time.Time) (app *App, err error) {
+	return a.AuthenticateCall(r.Context(), r.Header, r.Method, r.URL.Path, r.URL.RawQuery, body, now)
+}
+
+// AuthenticateCall returns the app of a call by the credential as
+// Authenticate, a grpc call is signed as a POST to the full method without a
+// query and with the marshaled request as the body.
+func (a *Auth) AuthenticateCall(c context.Context, cred Credential, method, path, query string, body []byte, now time.Time) (app *App, err error) {
+	if key := cred.Get(HeaderKey); key != "" {
+		if app = a.keys[key]; app == nil {
+			return nil, ErrUnauthorized
+		}
+		return
+	}
+	if app = a.apps[cred.Get(HeaderApp)]; app == nil || app.c.Secret == "" {
+		return nil, ErrUnauthorized
+	}
+	ts, err := strconv.ParseInt(cred.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > a.skew || d < -a.skew {
+		return nil, ErrExpired
+	}
+	nonce := cred.Get(HeaderNonce)
+	if nonce == "" || len(nonce) > maxNonce {
+		return nil, ErrUnauthorized
+	}
+	sig, err := hex.DecodeString(cred.Get(HeaderSignature))
+	if err != nil || !hmac.Equal(sig, Sign(app.c.Secret, method, path, query, ts, nonce, body)) {
+		return nil, ErrUnauthorized
+	}
+	// the request is in the skew of now till now+2*skew at most
+	ok, err := a.store.AddPushNonce(c, app.c.ID, nonce, 2*a.skew)
+	if err != nil {
+		log.Errorf("AddPushNonce(%s,%s) error(%v)", app.c.ID, nonce, err)
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrReplayed
+	}
+	return app, nil
+}
+
+// Limit takes a request of n pushes of the app at now, the request from the
+// rate and the pushes from the quota of the day in UTC. The request is let
+// go if the quota can't be counted.
+func (a *Auth) Limit(c context.Context, app *App, now time.Time, pushes int64) error {
+	if !app.limit.AllowN(now, 1) {
+		return ErrRateLimited
+	}
+	if app.c.Quota <= 0 {
+		return nil
+	}
+	n, err := a.store.IncrPushQuota(c, app.c.ID, now.UTC().Format("20060102"), pushes)
+	if err != nil {
+		log.Errorf("IncrPushQuota(%s) error(%v)", app.c.ID, err)
+		return nil
+	}
+	if n > app.c.Quota {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Terry-Mao/goim/internal/logic/conf"
+	xtime "github.com/Terry-Mao/goim/pkg/time"
+)
+
+// testStore counts the pushes and keeps the nonces in memory.
+type testStore struct {
+	quota  map[string]int64
+	nonces map[string]bool
+}
+
+func (s *testStore) IncrPushQuota(c context.Context, app, day string, n int64) (int64, error) {
+	s.quota[app+"_"+day] += n
+	return s.quota[app+"_"+day], nil
+}
+
+func (s *testStore) AddPushNonce(c context.Context, app, nonce string, ttl time.Duration) (bool, error) {
+	if s.nonces[app+"_"+nonce] {
+		return false, nil
+	}
+	s.nonces[app+"_"+nonce] = true
+	return true, nil
+}
+
+func newTestAuth(apps ...*conf.PushApp) (*Auth, *testStore) {
+	s := &testStore{quota: map[string]int64{}, nonces: map[string]bool{}}
+	return New(&conf.PushAuth{Skew: xtime.Duration(time.Minute), Apps: apps}, s), s
+}
+
+func TestAuthClosed(t *testing.T) {
+	a := New(conf.Default().HTTPServer.Auth, nil)
+	if a == nil {
+		t.Fatal("push api must be closed by default")
+	}
+	r := httptest.NewRequest("POST", "/goim/push/all?operation=1000", nil)
+	r.Header.Set(HeaderKey, "key")
+	if _, err := a.Authenticate(r, nil, time.Now()); err != ErrUnauthorized {
+		t.Fatalf("no app error(%v)", err)
+	}
+	if a := New(&conf.PushAuth{Open: true}, nil); a != nil {
+		t.Fatal("push api must be open by the config")
+	}
+}
+
+func TestAuthenticateKey(t *testing.T) {
+	a, _ := newTestAuth(&conf.PushApp{ID: "a", Key: "key_a"}, &conf.PushApp{ID: "b", Secret: "secret_b"})
+	now := time.Now()
+	r := httptest.NewRequest("POST", "/goim/push/keys?operation=1000&keys=k", nil)
+	if _, err := a.Authenticate(r, nil, now); err != ErrUnauthorized {
+		t.Fatalf("no credential error(%v)", err)
+	}
+	r.Header.Set(HeaderKey, "key_b")
+	if _, err := a.Authenticate(r, nil, now); err != ErrUnauthorized {
+		t.Fatalf("unknown key error(%v)", err)
+	}
+	r.Header.Set(HeaderKey, "key_a")
+	if app, err := a.Authenticate(r, nil, now); err != nil || app.ID() != "a" {
+		t.Fatalf("app %v error(%v)", app, err)
+	}
+}
+
+func TestAuthenticateSign(t *testing.T) {
+	a, _ := newTestAuth(&conf.PushApp{ID: "a", Key: "key_a"}, &conf.PushApp{ID: "b", Secret: "secret_b"})
+	var (
+		now  = time.Now()
+		body = []byte("hello")
+	)
+	// the body signed by the app, the one received is hello
+	var nonce int
+	newReq := func(app, secret string, ts int64, signed []byte) (*App, error) {
+		nonce++
+		r := httptest.NewRequest("POST", "/goim/push/room?operation=1000&type=live&room=1", nil)
+		r.Header.Set(HeaderApp, app)
+		r.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+		r.Header.Set(HeaderNonce, strconv.Itoa(nonce))
+		r.Header.Set(HeaderSignature, hex.EncodeToString(Sign(secret, r.Method, r.URL.Path, r.URL.RawQuery, ts, strconv.Itoa(nonce), signed)))
+		return a.Authenticate(r, body, now)
+	}
+	if app, err := newReq("b", "secret_b", now.Unix(), body); err != nil || app.ID() != "b" {
+		t.Fatalf("app %v error(%v)", app, err)
+	}
+	if _, err := newReq("b", "bad", now.Unix(), body); err != ErrUnauthorized {
+		t.Fatalf("bad secret error(%v)", err)
+	}
+	if _, err := newReq("b", "secret_b", now.Unix(), []byte("changed")); err != ErrUnauthorized {
+		t.Fatalf("body changed error(%v)", err)
+	}
+	if _, err := newReq("b", "secret_b", now.Add(-2*time.Minute).Unix(), body); err != ErrExpired {
+		t.Fatalf("expired error(%v)", err)
+	}
+	// the app without a secret can't sign
+	if _, err := newReq("a", "", now.Unix(), body); err != ErrUnauthorized {
+		t.Fatalf("no secret error(%v)", err)
+	}
+}
+
+func TestAuthenticateReplay(t *testing.T) {
+	a, _ := newTestAuth(&conf.PushApp{ID: "b", Secret: "secret_b"})
+	var (
+		now  = time.Now()
+		ts   = now.Unix()
+		body = []byte("hello")
+	)
+	r := httptest.NewRequest("POST", "/goim/push/all?operation=1000", nil)
+	r.Header.Set(HeaderApp, "b")
+	r.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	r.Header.Set(HeaderSignature, hex.EncodeToString(Sign("secret_b", r.Method, r.URL.Path, r.URL.RawQuery, ts, "", body)))
+	if _, err := a.Authenticate(r, body, now); err != ErrUnauthorized {
+		t.Fatalf("no nonce error(%v)", err)
+	}
+	r.Header.Set(HeaderNonce, "n1")
+	r.Header.Set(HeaderSignature, hex.EncodeToString(Sign("secret_b", r.Method, r.URL.Path, r.URL.RawQuery, ts, "n1", body)))
+	if _, err := a.Authenticate(r, body, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Authenticate(r, body, now); err != ErrReplayed {
+		t.Fatalf("replayed error(%v)", err)
+	}
+}
+
+func TestAppAllow(t *testing.T) {
+	a, _ := newTestAuth(&conf.PushApp{ID: "a", Key: "key_a", Ops: []int32{1000}, Targets: []string{TargetKeys, TargetMids}, Apps: []string{"shop"}}, &conf.PushApp{ID: "b", Key: "key_b"})
+	app := a.apps["a"]
+	if !app.AllowTarget(TargetKeys) || app.AllowTarget(TargetAll) || !app.AllowOp(1000) || app.AllowOp(1001) {
+		t.Fatal("app a must be allowed to push 1000 to the keys and the mids only")
+	}
+	if !app.AllowApp("shop") || app.AllowApp("") {
+		t.Fatal("app a must be allowed to push to the shop namespace only")
+	}
+	app = a.apps["b"]
+	if !app.AllowTarget(TargetAll) || !app.AllowOp(1001) || !app.AllowApp("") || !app.AllowApp("shop") {
+		t.Fatal("app b must be allowed all")
+	}
+}
+
+// testCredential is the metadata of a grpc call.
+type testCredential map[string]string
+
+func (c testCredential) Get(key string) string {
+	return c[strings.ToLower(key)]
+}
+
+func TestAuthenticateCall(t *testing.T) {
+	a, _ := newTestAuth(&conf.PushApp{ID: "b", Secret: "secret_b"})
+	var (
+		now    = time.Now()
+		body   = []byte("request")
+		method = "/goim.logic.Push/PushKeys"
+		ts     = now.Unix()
+		cred   = testCredential{
+			"x-goim-app":       "b",
+			"x-goim-timestamp": strconv.FormatInt(ts, 10),
+			"x-goim-nonce":     "n1",
+			"x-goim-signature": hex.EncodeToString(Sign("secret_b", "POST", method, "", ts, "n1", body)),
+		}
+	)
+	if app, err := a.AuthenticateCall(context.Background(), cred, "POST", method, "", body, now); err != nil || app.ID() != "b" {
+		t.Fatalf("app %v error(%v)", app, err)
+	}
+	if _, err := a.AuthenticateCall(context.Background(), cred, "POST", "/goim.logic.Push/PushAll", "", body, now); err != ErrUnauthorized {
+		t.Fatalf("method changed error(%v)", err)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	a, s := newTestAuth(&conf.PushApp{ID: "a", Key: "key_a", QPS: 1, Burst: 2, Quota: 3})
+	var (
+		c   = context.Background()
+		app = a.apps["a"]
+		now = time.Date(2026, 1, 1, 23, 59, 0, 0, time.UTC)
+	)
+	for i := 0; i < 2; i++ {
+		if err := a.Limit(c, app, now, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Limit(c, app, now, 1); err != ErrRateLimited {
+		t.Fatalf("over the burst error(%v)", err)
+	}
+	now = now.Add(time.Second)
+	if err := a.Limit(c, app, now, 1); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Second)
+	if err := a.Limit(c, app, now, 1); err != ErrQuotaExceeded {
+		t.Fatalf("over the quota error(%v)", err)
+	}
+	// a new day
+	now = now.Add(time.Minute)
+	if err := a.Limit(c, app, now, 1); err != nil {
+		t.Fatal(err)
+	}
+	// a batch takes the quota by the pushes
+	now = now.Add(time.Second)
+	if err := a.Limit(c, app, now, 3); err != ErrQuotaExceeded {
+		t.Fatalf("batch over the quota error(%v)", err)
+	}
+	if s.quota["a_20260101"] != 4 || s.quota["a_20260102"] != 4 {
+		t.Fatalf("quota %v", s.quota)
+	}
+}
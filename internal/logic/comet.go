@@ -0,0 +1,69 @@
+package logic
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Terry-Mao/goim/api/comet"
+	"github.com/bilibili/discovery/naming"
+	log "github.com/golang/glog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// cometConn is a grpc connection to a comet.
+type cometConn struct {
+	conn   *grpc.ClientConn
+	client comet.CometClient
+}
+
+// cometClient returns the client of the comet instance, it's dialed once.
+func (l *Logic) cometClient(in *naming.Instance) (comet.CometClient, error) {
+	l.cometsMutex.Lock()
+	defer l.cometsMutex.Unlock()
+	if c, ok := l.comets[in.Hostname]; ok {
+		return c.client, nil
+	}
+	var addr string
+	for _, addrs := range in.Addrs {
+		u, err := url.Parse(addrs)
+		if err == nil && u.Scheme == "grpc" {
+			addr = u.Host
+		}
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("invalid grpc address:%v", in.Addrs)
+	}
+	conn, err := grpc.Dial(addr,
+		grpc.WithInsecure(),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                time.Second * 10,
+			Timeout:             time.Second * 3,
+			PermitWithoutStream: true,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	c := &cometConn{conn: conn, client: comet.NewCometClient(conn)}
+	l.comets[in.Hostname] = c
+	return c.client, nil
+}
+
+// closeComets closes the clients of the comets not in the instances.
+func (l *Logic) closeComets(ins []*naming.Instance) {
+	hosts := make(map[string]struct{}, len(ins))
+	for _, in := range ins {
+		hosts[in.Hostname] = struct{}{}
+	}
+	l.cometsMutex.Lock()
+	for host, c := range l.comets {
+		if _, ok := hosts[host]; !ok {
+			delete(l.comets, host)
+			_ = c.conn.Close()
+			log.Infof("close comet client:%s", host)
+		}
+	}
+	l.cometsMutex.Unlock()
+}
@@ -58,6 +58,8 @@ func Default() *Config {
 			Addr:         "3111",
 			ReadTimeout:  xtime.Duration(time.Second),
 			WriteTimeout: xtime.Duration(time.Second),
+			MaxBody:      1 << 20,
+			Auth:         &PushAuth{Skew: xtime.Duration(time.Minute * 5)},
 		},
 		RPCClient: &RPCClient{Dial: xtime.Duration(time.Second), Timeout: xtime.Duration(time.Second)},
 		RPCServer: &RPCServer{
@@ -71,6 +73,18 @@ func Default() *Config {
 			KeepAliveTimeout:  xtime.Duration(time.Second * 20),
 		},
 		Backoff: &Backoff{MaxDelay: 300, BaseDelay: 3, Factor: 1.8, Jitter: 1.3},
+		Presence: &Presence{
+			Flush:    xtime.Duration(time.Second),
+			MaxMids:  50,
+			BigRoom:  1000,
+			BigFlush: xtime.Duration(time.Second * 10),
+		},
+		Schedule: &Schedule{
+			Interval: xtime.Duration(time.Second),
+			LockTTL:  xtime.Duration(time.Second * 10),
+			Batch:    100,
+			MaxDelay: xtime.Duration(time.Hour * 24 * 30),
+		},
 	}
 }
 
@@ -85,6 +99,9 @@ type Config struct {
 	Redis      *Redis
 	Node       *Node
 	Backoff    *Backoff
+	Presence   *Presence
+	Schedule   *Schedule
+	Apps       []*App
 	Regions    map[string][]string
 }
 
@@ -108,6 +125,36 @@ type Node struct {
 	HeartbeatMax  int
 	Heartbeat     xtime.Duration
 	RegionWeight  float64
+	// Resume issues resume tokens restoring the session on reconnect
+	Resume bool
+}
+
+// Presence is the room join/leave events config, the events of a room are
+// pushed every Flush, or BigFlush if the room has BigRoom users or more. A
+// push lists MaxMids mids at most, the others are only counted.
+type Presence struct {
+	Flush    xtime.Duration
+	MaxMids  int
+	BigRoom  int32
+	BigFlush xtime.Duration
+}
+
+// Schedule is the scheduled push config, the leader runs the schedules due
+// in Batch every Interval, and it keeps the lock for LockTTL. A schedule is
+// delivered in MaxDelay at most.
+type Schedule struct {
+	Interval xtime.Duration
+	LockTTL  xtime.Duration
+	Batch    int
+	MaxDelay xtime.Duration
+}
+
+// App is a namespace sharing the cluster, the connections of the app are
+// limited to MaxConns in the cluster if it's positive. The clients without an
+// app are in the default namespace.
+type App struct {
+	ID       string
+	MaxConns int32
 }
 
 // Backoff backoff.
@@ -162,4 +209,32 @@ type HTTPServer struct {
 	Addr         string
 	ReadTimeout  xtime.Duration
 	WriteTimeout xtime.Duration
+	MaxBody      int64 // the max bytes of a push request body
+	Auth         *PushAuth
+}
+
+// PushAuth is the apps calling the push api of the http and the grpc servers,
+// it's closed to all but the apps unless Open. A signed request expires after
+// Skew.
+type PushAuth struct {
+	Open bool
+	Skew xtime.Duration
+	Apps []*PushApp
+}
+
+// PushApp is an app calling the push api with Key, or signing the requests
+// with Secret. Ops, Targets (keys, mids, room, all or config) and the
+// namespaces of Apps are the ones allowed, empty for all. The requests are
+// limited to QPS with Burst, and to Quota a day shared by the logics if it's
+// positive.
+type PushApp struct {
+	ID      string
+	Key     string
+	Secret  string
+	Ops     []int32
+	Targets []string
+	Apps    []string
+	QPS     float64
+	Burst   int
+	Quota   int64
 }
@@ -3,41 +3,90 @@ package logic
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
+	pb "github.com/Terry-Mao/goim/api/logic"
 	"github.com/Terry-Mao/goim/api/protocol"
 	"github.com/Terry-Mao/goim/internal/logic/model"
 	log "github.com/golang/glog"
 	"github.com/google/uuid"
 )
 
+var (
+	errTokenExpired  = errors.New("token expired")
+	errTokenMismatch = errors.New("token mismatch")
+)
+
+// connToken is the token of a connection.
+type connToken struct {
+	Mid      int64   `json:"mid"`
+	Key      string  `json:"key"`
+	RoomID   string  `json:"room_id"`
+	Platform string  `json:"platform"`
+	Accepts  []int32 `json:"accepts"`
+	Resume   string  `json:"resume"`
+	// App namespace, empty for the default
+	App string `json:"app"`
+	// Expire unix seconds, zero never expires
+	Expire int64 `json:"expire"`
+}
+
+// parseToken parses the token and rejects it if expired.
+func parseToken(token []byte) (t *connToken, err error) {
+	t = new(connToken)
+	if err = json.Unmarshal(token, t); err != nil {
+		log.Errorf("json.Unmarshal(%s) error(%v)", token, err)
+		return
+	}
+	if t.Expire > 0 && t.Expire <= time.Now().Unix() {
+		log.Warningf("token mid:%d key:%s expired at %d", t.Mid, t.Key, t.Expire)
+		err = errTokenExpired
+	}
+	return
+}
+
 // Connect connected a conn.
-func (l *Logic) Connect(c context.Context, server, cookie string, token []byte) (mid int64, key, roomID string, accepts []int32, hb int64, err error) {
+func (l *Logic) Connect(c context.Context, server, cookie, ip string, token []byte) (reply *pb.ConnectReply, err error) {
 	fmt.Fprintf(os.Stderr, "=== LOGIC Connect START: server=%s token=%s ===\n", server, string(token))
 	log.Infof("Connect called: server=%s cookie=%s token=%s", server, cookie, string(token))
-	var params struct {
-		Mid      int64   `json:"mid"`
-		Key      string  `json:"key"`
-		RoomID   string  `json:"room_id"`
-		Platform string  `json:"platform"`
-		Accepts  []int32 `json:"accepts"`
-	}
-	if err = json.Unmarshal(token, &params); err != nil {
-		log.Errorf("json.Unmarshal(%s) error(%v)", token, err)
-		fmt.Fprintf(os.Stderr, "=== LOGIC JSON UNMARSHAL ERROR: %v ===\n", err)
+	var params *connToken
+	if params, err = parseToken(token); err != nil {
+		log.Errorf("parseToken(%s) server:%s error(%v)", token, server, err)
 		return
 	}
 	fmt.Fprintf(os.Stderr, "=== LOGIC Connect parsed: mid=%d key=%s roomID=%s ===\n", params.Mid, params.Key, params.RoomID)
 	log.Infof("Connect parsed: mid=%d key=%s roomID=%s", params.Mid, params.Key, params.RoomID)
-	mid = params.Mid
-	roomID = params.RoomID
-	accepts = params.Accepts
-	hb = int64(l.c.Node.Heartbeat) * int64(l.c.Node.HeartbeatMax)
-	if key = params.Key; key == "" {
-		key = uuid.New().String()
+	if model.IsAppKey(params.Key) || model.IsAppKey(params.RoomID) {
+		err = errAppKey
+		return
+	}
+	if err = l.checkApp(params.App); err != nil {
+		log.Warningf("conn app:%s mid:%d key:%s error(%v)", params.App, params.Mid, params.Key, err)
+		return
+	}
+	reply = &pb.ConnectReply{
+		Mid:       params.Mid,
+		Key:       appKey(params.App, params.Key),
+		RoomID:    appKey(params.App, params.RoomID),
+		App:       params.App,
+		Platform:  params.Platform,
+		Accepts:   params.Accepts,
+		Heartbeat: int64(l.c.Node.Heartbeat) * int64(l.c.Node.HeartbeatMax),
+		Expire:    params.Expire,
+	}
+	if l.c.Node.Resume {
+		if err = l.resume(c, reply, params.Resume); err != nil {
+			return
+		}
+	}
+	if reply.Key == "" {
+		reply.Key = model.AppKey(reply.App, uuid.New().String())
 	}
+	mid, key := reply.Mid, reply.Key
 	fmt.Fprintf(os.Stderr, "=== LOGIC before AddMapping: mid=%d key=%s server=%s ===\n", mid, key, server)
 	log.Infof("Connect before AddMapping: mid=%d key=%s server=%s", mid, key, server)
 	if err = l.dao.AddMapping(c, mid, key, server); err != nil {
@@ -45,13 +94,68 @@ func (l *Logic) Connect(c context.Context, server, cookie string, token []byte)
 		fmt.Fprintf(os.Stderr, "=== LOGIC AddMapping ERROR: %v ===\n", err)
 		return
 	}
+	if l.c.Node.Resume && !reply.Resumed {
+		secret := uuid.New().String()
+		if err = l.dao.AddResume(c, &model.Resume{
+			Secret:   secret,
+			Mid:      mid,
+			Key:      key,
+			RoomID:   reply.RoomID,
+			Platform: reply.Platform,
+			Accepts:  reply.Accepts,
+		}); err != nil {
+			return
+		}
+		reply.Resume = resumeToken(key, secret)
+	}
 	fmt.Fprintf(os.Stderr, "=== LOGIC Connect SUCCESS: mid=%d key=%s ===\n", mid, key)
-	log.Infof("conn connected key:%s server:%s mid:%d token:%s", key, server, mid, token)
+	log.Infof("conn connected key:%s server:%s mid:%d ip:%s token:%s resumed:%t", key, server, mid, ip, token, reply.Resumed)
+	return
+}
+
+// resumeToken returns the token of the key, the secret is random.
+func resumeToken(key, secret string) string {
+	return key + "." + secret
+}
+
+// resume restores the session of the token into the reply, an invalid or
+// expired token is ignored and a new session is connected.
+func (l *Logic) resume(c context.Context, reply *pb.ConnectReply, token string) (err error) {
+	i := strings.LastIndexByte(token, '.')
+	if i <= 0 {
+		return
+	}
+	var r *model.Resume
+	if r, err = l.dao.Resume(c, token[:i]); err != nil || r == nil {
+		return
+	}
+	if app, _ := model.SplitAppKey(r.Key); r.Secret != token[i+1:] || app != reply.App || (reply.Mid != 0 && reply.Mid != r.Mid) {
+		log.Warningf("resume key:%s mid:%d token mismatch", r.Key, reply.Mid)
+		return
+	}
+	reply.Mid = r.Mid
+	reply.Key = r.Key
+	reply.RoomID = r.RoomID
+	reply.Platform = r.Platform
+	reply.Accepts = r.Accepts
+	reply.Resume = token
+	reply.Resumed = true
 	return
 }
 
 // Disconnect disconnect a conn.
 func (l *Logic) Disconnect(c context.Context, mid int64, key, server string) (has bool, err error) {
+	if l.c.Node.Resume {
+		// the session may be resumed on another server
+		var servers []string
+		if servers, err = l.dao.ServersByKeys(c, []string{key}); err == nil && len(servers) > 0 && servers[0] != "" && servers[0] != server {
+			log.Infof("conn disconnected key:%s server:%s mid:%d, resumed on server:%s", key, server, mid, servers[0])
+			return
+		}
+		if err = l.dao.DelResume(c, key); err != nil {
+			return
+		}
+	}
 	if has, err = l.dao.DelMapping(c, mid, key, server); err != nil {
 		log.Errorf("l.dao.DelMapping(%d,%s) error(%v)", mid, key, server)
 		return
@@ -73,15 +177,38 @@ func (l *Logic) Heartbeat(c context.Context, mid int64, key, server string) (err
 			return
 		}
 	}
+	if l.c.Node.Resume {
+		if err = l.dao.ExpireResume(c, key); err != nil {
+			return
+		}
+	}
 	log.Infof("conn heartbeat key:%s server:%s mid:%d", key, server, mid)
 	return
 }
 
+// Reauth refreshes the token of a live conn, the token must be of the same
+// mid and key.
+func (l *Logic) Reauth(c context.Context, mid int64, key, server string, token []byte) (expire int64, err error) {
+	var t *connToken
+	if t, err = parseToken(token); err != nil {
+		return
+	}
+	if t.Mid != mid || (t.Key != "" && appKey(t.App, t.Key) != key) {
+		log.Warningf("reauth key:%s mid:%d token of key:%s mid:%d", key, mid, t.Key, t.Mid)
+		err = errTokenMismatch
+		return
+	}
+	log.Infof("conn reauth key:%s server:%s mid:%d expire:%d", key, server, mid, t.Expire)
+	return t.Expire, nil
+}
+
 // RenewOnline renew a server online.
-func (l *Logic) RenewOnline(c context.Context, server string, roomCount map[string]int32) (map[string]int32, error) {
+func (l *Logic) RenewOnline(c context.Context, server string, roomCount map[string]int32, roomUsers map[string]*model.RoomUsers, appConns map[string]int32) (map[string]int32, error) {
 	online := &model.Online{
 		Server:    server,
 		RoomCount: roomCount,
+		RoomUsers: roomUsers,
+		AppConns:  appConns,
 		Updated:   time.Now().Unix(),
 	}
 	if err := l.dao.AddServerOnline(context.Background(), server, online); err != nil {
@@ -2,7 +2,9 @@ package logic
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/Terry-Mao/goim/api/protocol"
 	"github.com/stretchr/testify/assert"
@@ -13,18 +15,21 @@ func TestConnect(t *testing.T) {
 		server    = "test_server"
 		serverKey = "test_server_key"
 		cookie    = ""
+		ip        = "127.0.0.1"
 		token     = []byte(`{"mid":1, "key":"test_server_key", "room_id":"test://test_room", "platform":"web", "accepts":[1000,1001,1002]}`)
 		ol        = map[string]int32{"test://test_room": 100}
 		c         = context.Background()
 	)
 	// connect
-	mid, key, roomID, accepts, hb, err := lg.Connect(c, server, cookie, token)
+	reply, err := lg.Connect(c, server, cookie, ip, token)
 	assert.Nil(t, err)
+	mid, key := reply.Mid, reply.Key
 	assert.Equal(t, serverKey, key)
-	assert.Equal(t, roomID, "test://test_room")
-	assert.Equal(t, len(accepts), 3)
-	assert.NotZero(t, hb)
-	t.Log(mid, key, roomID, accepts, err)
+	assert.Equal(t, reply.RoomID, "test://test_room")
+	assert.Equal(t, reply.Platform, "web")
+	assert.Equal(t, len(reply.Accepts), 3)
+	assert.NotZero(t, reply.Heartbeat)
+	t.Log(mid, key, reply.RoomID, reply.Accepts, err)
 	// heartbeat
 	err = lg.Heartbeat(c, mid, key, server)
 	assert.Nil(t, err)
@@ -33,10 +38,65 @@ func TestConnect(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, true, has)
 	// renew
-	online, err := lg.RenewOnline(c, server, ol)
+	online, err := lg.RenewOnline(c, server, ol, nil, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, online)
 	// message
 	err = lg.Receive(c, mid, &protocol.Proto{})
 	assert.Nil(t, err)
 }
+
+func TestConnectResume(t *testing.T) {
+	var (
+		server = "test_server"
+		token  = []byte(`{"mid":1, "room_id":"test://test_room", "platform":"web", "accepts":[1000]}`)
+		c      = context.Background()
+	)
+	resume := lg.c.Node.Resume
+	lg.c.Node.Resume = true
+	defer func() { lg.c.Node.Resume = resume }()
+	reply, err := lg.Connect(c, server, "", "127.0.0.1", token)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, reply.Resume)
+	assert.False(t, reply.Resumed)
+	// reconnect without key and room
+	resumed, err := lg.Connect(c, server, "", "127.0.0.1", []byte(`{"mid":1, "resume":"`+reply.Resume+`"}`))
+	assert.Nil(t, err)
+	assert.True(t, resumed.Resumed)
+	assert.Equal(t, reply.Key, resumed.Key)
+	assert.Equal(t, reply.RoomID, resumed.RoomID)
+	assert.Equal(t, reply.Accepts, resumed.Accepts)
+	// wrong secret
+	other, err := lg.Connect(c, server, "", "127.0.0.1", []byte(`{"mid":1, "resume":"`+reply.Key+`.bad"}`))
+	assert.Nil(t, err)
+	assert.False(t, other.Resumed)
+	assert.NotEqual(t, reply.Key, other.Key)
+	_, err = lg.Disconnect(c, 1, reply.Key, server)
+	assert.Nil(t, err)
+	_, err = lg.Disconnect(c, 1, other.Key, server)
+	assert.Nil(t, err)
+}
+
+func TestReauth(t *testing.T) {
+	var (
+		server = "test_server"
+		expire = time.Now().Add(time.Hour).Unix()
+		c      = context.Background()
+	)
+	// expired token is rejected
+	_, err := lg.Connect(c, server, "", "", []byte(`{"mid":1, "expire":1}`))
+	assert.Equal(t, errTokenExpired, err)
+	reply, err := lg.Connect(c, server, "", "", []byte(fmt.Sprintf(`{"mid":1, "expire":%d}`, expire)))
+	assert.Nil(t, err)
+	assert.Equal(t, expire, reply.Expire)
+	// refresh
+	exp, err := lg.Reauth(c, 1, reply.Key, server, []byte(fmt.Sprintf(`{"mid":1, "expire":%d}`, expire+60)))
+	assert.Nil(t, err)
+	assert.Equal(t, expire+60, exp)
+	_, err = lg.Reauth(c, 2, reply.Key, server, []byte(`{"mid":1}`))
+	assert.Equal(t, errTokenMismatch, err)
+	_, err = lg.Reauth(c, 1, reply.Key, server, []byte(`{"mid":1, "key":"other"}`))
+	assert.Equal(t, errTokenMismatch, err)
+	_, err = lg.Disconnect(c, 1, reply.Key, server)
+	assert.Nil(t, err)
+}
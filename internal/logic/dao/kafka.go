@@ -45,12 +45,13 @@ func (d *Dao) PushMsg(c context.Context, op int32, server string, keys []string,
 }
 
 // BroadcastRoomMsg push a message to databus.
-func (d *Dao) BroadcastRoomMsg(c context.Context, op int32, room string, msg []byte) (err error) {
+func (d *Dao) BroadcastRoomMsg(c context.Context, op int32, room string, priority int32, msg []byte) (err error) {
 	pushMsg := &pb.PushMsg{
 		Type:      pb.PushMsg_ROOM,
 		Operation: op,
 		Room:      room,
 		Msg:       msg,
+		Priority:  priority,
 	}
 	b, err := proto.Marshal(pushMsg)
 	if err != nil {
@@ -67,13 +68,21 @@ func (d *Dao) BroadcastRoomMsg(c context.Context, op int32, room string, msg []b
 	return
 }
 
-// BroadcastMsg push a message to databus.
-func (d *Dao) BroadcastMsg(c context.Context, op, speed int32, msg []byte) (err error) {
+// BroadcastMsg push a message to all of the app to databus.
+func (d *Dao) BroadcastMsg(c context.Context, app string, op, speed int32, msg []byte) (err error) {
+	return d.BroadcastPlatformMsg(c, app, op, speed, "", msg)
+}
+
+// BroadcastPlatformMsg broadcast a message to the platform of the app, empty
+// for all the platforms.
+func (d *Dao) BroadcastPlatformMsg(c context.Context, app string, op, speed int32, platform string, msg []byte) (err error) {
 	pushMsg := &pb.PushMsg{
 		Type:      pb.PushMsg_BROADCAST,
 		Operation: op,
 		Speed:     speed,
 		Msg:       msg,
+		Platform:  platform,
+		App:       app,
 	}
 	b, err := proto.Marshal(pushMsg)
 	if err != nil {
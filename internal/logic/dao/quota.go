@@ -0,0 +1,62 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/gomodule/redigo/redis"
+)
+
+// _pushQuotaExpire keeps the counter of a day until the next day is over.
+const _pushQuotaExpire = 2 * 24 * 3600
+
+func keyPushQuota(app, day string) string {
+	return fmt.Sprintf("pq_%s_%s", app, day)
+}
+
+func keyPushNonce(app, nonce string) string {
+	return fmt.Sprintf("pn_%s_%s", app, nonce)
+}
+
+// IncrPushQuota counts n pushes of the app in the day, returns the pushes of
+// the day.
+func (d *Dao) IncrPushQuota(c context.Context, app, day string, n int64) (total int64, err error) {
+	conn := d.redis.Get()
+	defer conn.Close()
+	key := keyPushQuota(app, day)
+	if err = conn.Send("INCRBY", key, n); err != nil {
+		log.Errorf("conn.Send(INCRBY %s,%d) error(%v)", key, n, err)
+		return
+	}
+	if err = conn.Send("EXPIRE", key, _pushQuotaExpire); err != nil {
+		log.Errorf("conn.Send(EXPIRE %s) error(%v)", key, err)
+		return
+	}
+	if err = conn.Flush(); err != nil {
+		log.Errorf("conn.Flush() error(%v)", err)
+		return
+	}
+	if total, err = redis.Int64(conn.Receive()); err != nil {
+		log.Errorf("conn.Receive() error(%v)", err)
+		return
+	}
+	if _, err = conn.Receive(); err != nil {
+		log.Errorf("conn.Receive() error(%v)", err)
+	}
+	return
+}
+
+// AddPushNonce keeps the nonce of the app in ttl, ok is false if it's kept.
+func (d *Dao) AddPushNonce(c context.Context, app, nonce string, ttl time.Duration) (ok bool, err error) {
+	conn := d.redis.Get()
+	defer conn.Close()
+	key := keyPushNonce(app, nonce)
+	reply, err := conn.Do("SET", key, 1, "PX", int64(ttl/time.Millisecond), "NX")
+	if err != nil {
+		log.Errorf("conn.Do(SET %s NX) error(%v)", key, err)
+		return
+	}
+	return reply != nil, nil
+}
@@ -0,0 +1,32 @@
+package dao
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDaoIncrPushQuota(t *testing.T) {
+	c := context.Background()
+	n, err := d.IncrPushQuota(c, "test_app", "19700101", 1)
+	assert.Nil(t, err)
+	m, err := d.IncrPushQuota(c, "test_app", "19700101", 3)
+	assert.Nil(t, err)
+	assert.Equal(t, n+3, m)
+}
+
+func TestDaoAddPushNonce(t *testing.T) {
+	var (
+		c     = context.Background()
+		nonce = strconv.FormatInt(time.Now().UnixNano(), 10)
+	)
+	ok, err := d.AddPushNonce(c, "test_app", nonce, time.Second)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = d.AddPushNonce(c, "test_app", nonce, time.Second)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
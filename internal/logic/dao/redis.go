@@ -14,22 +14,35 @@ import (
 	"github.com/zhenjl/cityhash"
 )
 
+// the keys in an app namespace are @app/key, the mids and the servers are
+// namespaced the same.
 const (
-	_prefixMidServer    = "mid_%d" // mid -> key:server
+	_prefixMidServer    = "mid_%s" // mid -> key:server
 	_prefixKeyServer    = "key_%s" // key -> server
 	_prefixServerOnline = "ol_%s"  // server -> online
+	_prefixResume       = "rs_%s"  // key -> resume session
 )
 
-func keyMidServer(mid int64) string {
-	return fmt.Sprintf(_prefixMidServer, mid)
+func keyMidServer(app string, mid int64) string {
+	return fmt.Sprintf(_prefixMidServer, model.AppKey(app, strconv.FormatInt(mid, 10)))
+}
+
+// keyKeyMidServer is the mid in the namespace of the key.
+func keyKeyMidServer(key string, mid int64) string {
+	app, _ := model.SplitAppKey(key)
+	return keyMidServer(app, mid)
 }
 
 func keyKeyServer(key string) string {
 	return fmt.Sprintf(_prefixKeyServer, key)
 }
 
-func keyServerOnline(key string) string {
-	return fmt.Sprintf(_prefixServerOnline, key)
+func keyServerOnline(app, server string) string {
+	return fmt.Sprintf(_prefixServerOnline, model.AppKey(app, server))
+}
+
+func keyResume(key string) string {
+	return fmt.Sprintf(_prefixResume, key)
 }
 
 // pingRedis check redis connection.
@@ -50,13 +63,13 @@ func (d *Dao) AddMapping(c context.Context, mid int64, key, server string) (err
 	defer conn.Close()
 	var n = 2
 	if mid > 0 {
-		fmt.Fprintf(os.Stderr, "=== REDIS HSET %s %s %s ===\n", keyMidServer(mid), key, server)
-		if err = conn.Send("HSET", keyMidServer(mid), key, server); err != nil {
+		fmt.Fprintf(os.Stderr, "=== REDIS HSET %s %s %s ===\n", keyKeyMidServer(key, mid), key, server)
+		if err = conn.Send("HSET", keyKeyMidServer(key, mid), key, server); err != nil {
 			log.Errorf("conn.Send(HSET %d,%s,%s) error(%v)", mid, server, key, err)
 			fmt.Fprintf(os.Stderr, "=== REDIS HSET ERROR: %v ===\n", err)
 			return
 		}
-		if err = conn.Send("EXPIRE", keyMidServer(mid), d.redisExpire); err != nil {
+		if err = conn.Send("EXPIRE", keyKeyMidServer(key, mid), d.redisExpire); err != nil {
 			log.Errorf("conn.Send(EXPIRE %d,%s,%s) error(%v)", mid, key, server, err)
 			fmt.Fprintf(os.Stderr, "=== REDIS EXPIRE(mid) ERROR: %v ===\n", err)
 			return
@@ -102,7 +115,7 @@ func (d *Dao) ExpireMapping(c context.Context, mid int64, key string) (has bool,
 	defer conn.Close()
 	var n = 1
 	if mid > 0 {
-		if err = conn.Send("EXPIRE", keyMidServer(mid), d.redisExpire); err != nil {
+		if err = conn.Send("EXPIRE", keyKeyMidServer(key, mid), d.redisExpire); err != nil {
 			log.Errorf("conn.Send(EXPIRE %d,%s) error(%v)", mid, key, err)
 			return
 		}
@@ -131,7 +144,7 @@ func (d *Dao) DelMapping(c context.Context, mid int64, key, server string) (has
 	defer conn.Close()
 	n := 1
 	if mid > 0 {
-		if err = conn.Send("HDEL", keyMidServer(mid), key); err != nil {
+		if err = conn.Send("HDEL", keyKeyMidServer(key, mid), key); err != nil {
 			log.Errorf("conn.Send(HDEL %d,%s,%s) error(%v)", mid, key, server, err)
 			return
 		}
@@ -175,12 +188,30 @@ func (d *Dao) ServersByKeys(c context.Context, keys []string) (res []string, err
 }
 
 // KeysByMids get a key server by mid.
-func (d *Dao) KeysByMids(c context.Context, mids []int64) (ress map[string]string, olMids []int64, err error) {
+func (d *Dao) KeysByMids(c context.Context, app string, mids []int64) (ress map[string]string, olMids []int64, err error) {
+	servers, err := d.ServersByMids(c, app, mids)
+	if err != nil {
+		return
+	}
+	ress = make(map[string]string)
+	for idx, res := range servers {
+		if len(res) > 0 {
+			olMids = append(olMids, mids[idx])
+		}
+		for k, v := range res {
+			ress[k] = v
+		}
+	}
+	return
+}
+
+// ServersByMids get the key servers of each mid of the app, in the order of
+// the mids.
+func (d *Dao) ServersByMids(c context.Context, app string, mids []int64) (res []map[string]string, err error) {
 	conn := d.redis.Get()
 	defer conn.Close()
-	ress = make(map[string]string)
 	for _, mid := range mids {
-		if err = conn.Send("HGETALL", keyMidServer(mid)); err != nil {
+		if err = conn.Send("HGETALL", keyMidServer(app, mid)); err != nil {
 			log.Errorf("conn.Do(HGETALL %d) error(%v)", mid, err)
 			return
 		}
@@ -189,40 +220,53 @@ func (d *Dao) KeysByMids(c context.Context, mids []int64) (ress map[string]strin
 		log.Errorf("conn.Flush() error(%v)", err)
 		return
 	}
+	res = make([]map[string]string, len(mids))
 	for idx := 0; idx < len(mids); idx++ {
-		var (
-			res map[string]string
-		)
-		if res, err = redis.StringMap(conn.Receive()); err != nil {
+		if res[idx], err = redis.StringMap(conn.Receive()); err != nil {
 			log.Errorf("conn.Receive() error(%v)", err)
 			return
 		}
-		if len(res) > 0 {
-			olMids = append(olMids, mids[idx])
-		}
-		for k, v := range res {
-			ress[k] = v
-		}
 	}
 	return
 }
 
-// AddServerOnline add a server online.
+// AddServerOnline add a server online, the rooms and the connections of an
+// app are kept in its namespace.
 func (d *Dao) AddServerOnline(c context.Context, server string, online *model.Online) (err error) {
-	roomsMap := map[uint32]map[string]int32{}
+	apps := map[string]map[uint32]*model.Online{}
+	shard := func(app string, hashKey uint32) *model.Online {
+		roomsMap := apps[app]
+		if roomsMap == nil {
+			roomsMap = map[uint32]*model.Online{}
+			apps[app] = roomsMap
+		}
+		ol := roomsMap[hashKey]
+		if ol == nil {
+			ol = &model.Online{RoomCount: make(map[string]int32), RoomUsers: make(map[string]*model.RoomUsers), Server: online.Server, Updated: online.Updated}
+			roomsMap[hashKey] = ol
+		}
+		return ol
+	}
 	for room, count := range online.RoomCount {
-		rMap := roomsMap[cityhash.CityHash32([]byte(room), uint32(len(room)))%64]
-		if rMap == nil {
-			rMap = make(map[string]int32)
-			roomsMap[cityhash.CityHash32([]byte(room), uint32(len(room)))%64] = rMap
+		app, _ := model.SplitAppKey(room)
+		hashKey := cityhash.CityHash32([]byte(room), uint32(len(room))) % 64
+		ol := shard(app, hashKey)
+		ol.RoomCount[room] = count
+		if users, ok := online.RoomUsers[room]; ok {
+			ol.RoomUsers[room] = users
 		}
-		rMap[room] = count
 	}
-	key := keyServerOnline(server)
-	for hashKey, value := range roomsMap {
-		err = d.addServerOnline(c, key, strconv.FormatInt(int64(hashKey), 10), &model.Online{RoomCount: value, Server: online.Server, Updated: online.Updated})
-		if err != nil {
-			return
+	// the connections are kept in the first shard
+	for app, conns := range online.AppConns {
+		shard(app, 0).AppConns = map[string]int32{app: conns}
+	}
+	for app, roomsMap := range apps {
+		key := keyServerOnline(app, server)
+		for hashKey, value := range roomsMap {
+			err = d.addServerOnline(c, key, strconv.FormatInt(int64(hashKey), 10), value)
+			if err != nil {
+				return
+			}
 		}
 	}
 	return
@@ -253,19 +297,27 @@ func (d *Dao) addServerOnline(c context.Context, key string, hashKey string, onl
 	return
 }
 
-// ServerOnline get a server online.
-func (d *Dao) ServerOnline(c context.Context, server string) (online *model.Online, err error) {
-	online = &model.Online{RoomCount: map[string]int32{}}
-	key := keyServerOnline(server)
-	for i := 0; i < 64; i++ {
-		ol, err := d.serverOnline(c, key, strconv.FormatInt(int64(i), 10))
-		if err == nil && ol != nil {
-			online.Server = ol.Server
-			if ol.Updated > online.Updated {
-				online.Updated = ol.Updated
-			}
-			for room, count := range ol.RoomCount {
-				online.RoomCount[room] = count
+// ServerOnline get a server online of the apps.
+func (d *Dao) ServerOnline(c context.Context, apps []string, server string) (online *model.Online, err error) {
+	online = &model.Online{RoomCount: map[string]int32{}, RoomUsers: map[string]*model.RoomUsers{}, AppConns: map[string]int32{}}
+	for _, app := range apps {
+		key := keyServerOnline(app, server)
+		for i := 0; i < 64; i++ {
+			ol, err := d.serverOnline(c, key, strconv.FormatInt(int64(i), 10))
+			if err == nil && ol != nil {
+				online.Server = ol.Server
+				if ol.Updated > online.Updated {
+					online.Updated = ol.Updated
+				}
+				for room, count := range ol.RoomCount {
+					online.RoomCount[room] = count
+				}
+				for room, users := range ol.RoomUsers {
+					online.RoomUsers[room] = users
+				}
+				for app, conns := range ol.AppConns {
+					online.AppConns[app] = conns
+				}
 			}
 		}
 	}
@@ -290,13 +342,71 @@ func (d *Dao) serverOnline(c context.Context, key string, hashKey string) (onlin
 	return
 }
 
-// DelServerOnline del a server online.
-func (d *Dao) DelServerOnline(c context.Context, server string) (err error) {
+// DelServerOnline del a server online of the apps.
+func (d *Dao) DelServerOnline(c context.Context, apps []string, server string) (err error) {
+	conn := d.redis.Get()
+	defer conn.Close()
+	args := redis.Args{}
+	for _, app := range apps {
+		args = args.Add(keyServerOnline(app, server))
+	}
+	if _, err = conn.Do("DEL", args...); err != nil {
+		log.Errorf("conn.Do(DEL %v) error(%v)", args, err)
+	}
+	return
+}
+
+// AddResume add a resume session of the key.
+func (d *Dao) AddResume(c context.Context, r *model.Resume) (err error) {
+	var b []byte
+	if b, err = json.Marshal(r); err != nil {
+		return
+	}
+	conn := d.redis.Get()
+	defer conn.Close()
+	if _, err = conn.Do("SETEX", keyResume(r.Key), d.redisExpire, b); err != nil {
+		log.Errorf("conn.Do(SETEX %s) error(%v)", keyResume(r.Key), err)
+	}
+	return
+}
+
+// Resume get the resume session of the key.
+func (d *Dao) Resume(c context.Context, key string) (r *model.Resume, err error) {
+	conn := d.redis.Get()
+	defer conn.Close()
+	b, err := redis.Bytes(conn.Do("GET", keyResume(key)))
+	if err != nil {
+		if err == redis.ErrNil {
+			err = nil
+		} else {
+			log.Errorf("conn.Do(GET %s) error(%v)", keyResume(key), err)
+		}
+		return
+	}
+	r = new(model.Resume)
+	if err = json.Unmarshal(b, r); err != nil {
+		log.Errorf("json.Unmarshal(%s) error(%v)", b, err)
+		return nil, err
+	}
+	return
+}
+
+// ExpireResume expire the resume session of the key.
+func (d *Dao) ExpireResume(c context.Context, key string) (err error) {
+	conn := d.redis.Get()
+	defer conn.Close()
+	if _, err = conn.Do("EXPIRE", keyResume(key), d.redisExpire); err != nil {
+		log.Errorf("conn.Do(EXPIRE %s) error(%v)", keyResume(key), err)
+	}
+	return
+}
+
+// DelResume del the resume session of the key.
+func (d *Dao) DelResume(c context.Context, key string) (err error) {
 	conn := d.redis.Get()
 	defer conn.Close()
-	key := keyServerOnline(server)
-	if _, err = conn.Do("DEL", key); err != nil {
-		log.Errorf("conn.Do(DEL %s) error(%v)", key, err)
+	if _, err = conn.Do("DEL", keyResume(key)); err != nil {
+		log.Errorf("conn.Do(DEL %s) error(%v)", keyResume(key), err)
 	}
 	return
 }
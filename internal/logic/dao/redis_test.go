@@ -36,11 +36,27 @@ func TestDaoAddMapping(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, server, res[0])
 
-	ress, mids, err := d.KeysByMids(c, []int64{mid})
+	ress, mids, err := d.KeysByMids(c, "", []int64{mid})
 	assert.Nil(t, err)
 	assert.Equal(t, server, ress[key])
 	assert.Equal(t, mid, mids[0])
 
+	servers, err := d.ServersByMids(c, "", []int64{mid, 0})
+	assert.Nil(t, err)
+	assert.Equal(t, server, servers[0][key])
+	assert.Len(t, servers[1], 0)
+
+	// the same mid in an app is another user
+	appKey := model.AppKey("shop", key)
+	err = d.AddMapping(c, mid, appKey, server)
+	assert.Nil(t, err)
+	servers, err = d.ServersByMids(c, "shop", []int64{mid})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{appKey: server}, servers[0])
+	has, err = d.DelMapping(c, mid, appKey, server)
+	assert.Nil(t, err)
+	assert.NotEqual(t, false, has)
+
 	has, err = d.DelMapping(c, 0, "test", server)
 	assert.Nil(t, err)
 	assert.NotEqual(t, false, has)
@@ -53,17 +69,41 @@ func TestDaoAddServerOnline(t *testing.T) {
 	var (
 		c      = context.Background()
 		server = "test_server"
+		apps   = []string{"", "shop"}
 		online = &model.Online{
-			RoomCount: map[string]int32{"room": 10},
+			RoomCount: map[string]int32{"room": 10, "@shop/room": 3},
+			RoomUsers: map[string]*model.RoomUsers{"room": {Mids: []byte{0}, Guests: 8}, "@shop/room": {Mids: []byte{0}, Guests: 2}},
+			AppConns:  map[string]int32{"shop": 3},
 		}
 	)
 	err := d.AddServerOnline(c, server, online)
 	assert.Nil(t, err)
 
-	r, err := d.ServerOnline(c, server)
+	r, err := d.ServerOnline(c, apps, server)
+	assert.Nil(t, err)
+	assert.Equal(t, online.RoomCount, r.RoomCount)
+	assert.Equal(t, online.RoomUsers, r.RoomUsers)
+	assert.Equal(t, online.AppConns, r.AppConns)
+
+	err = d.DelServerOnline(c, apps, server)
 	assert.Nil(t, err)
-	assert.Equal(t, online.RoomCount["room"], r.RoomCount["room"])
+}
 
-	err = d.DelServerOnline(c, server)
+func TestDaoResume(t *testing.T) {
+	var (
+		c = context.Background()
+		r = &model.Resume{Secret: "secret", Mid: 1, Key: "test_resume_key", RoomID: "test://1", Platform: "web", Accepts: []int32{1000}}
+	)
+	err := d.AddResume(c, r)
+	assert.Nil(t, err)
+	res, err := d.Resume(c, r.Key)
+	assert.Nil(t, err)
+	assert.Equal(t, r, res)
+	err = d.ExpireResume(c, r.Key)
+	assert.Nil(t, err)
+	err = d.DelResume(c, r.Key)
+	assert.Nil(t, err)
+	res, err = d.Resume(c, r.Key)
 	assert.Nil(t, err)
+	assert.Nil(t, res)
 }
@@ -0,0 +1,119 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Terry-Mao/goim/internal/logic/model"
+	log "github.com/golang/glog"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	_keySchedule     = "sch"      // zset: id -> deliver time
+	_keyScheduleMsg  = "sch_msg"  // hash: id -> schedule
+	_keyScheduleLock = "sch_lock" // the scheduler leader
+)
+
+// _scheduleLock takes or renews the lock of the owner.
+var _scheduleLock = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0`)
+
+// _scheduleClaim removes the schedule and returns whether it's there with
+// its message.
+var _scheduleClaim = redis.NewScript(2, `
+local n = redis.call("ZREM", KEYS[1], ARGV[1])
+local msg = redis.call("HGET", KEYS[2], ARGV[1])
+redis.call("HDEL", KEYS[2], ARGV[1])
+return {n, msg or ""}`)
+
+// AddSchedule add a schedule.
+func (d *Dao) AddSchedule(c context.Context, s *model.Schedule) (err error) {
+	var b []byte
+	if b, err = json.Marshal(s); err != nil {
+		return
+	}
+	conn := d.redis.Get()
+	defer conn.Close()
+	if err = conn.Send("HSET", _keyScheduleMsg, s.ID, b); err != nil {
+		log.Errorf("conn.Send(HSET %s,%s) error(%v)", _keyScheduleMsg, s.ID, err)
+		return
+	}
+	if err = conn.Send("ZADD", _keySchedule, s.At, s.ID); err != nil {
+		log.Errorf("conn.Send(ZADD %s,%s) error(%v)", _keySchedule, s.ID, err)
+		return
+	}
+	if err = conn.Flush(); err != nil {
+		log.Errorf("conn.Flush() error(%v)", err)
+		return
+	}
+	for i := 0; i < 2; i++ {
+		if _, err = conn.Receive(); err != nil {
+			log.Errorf("conn.Receive() error(%v)", err)
+			return
+		}
+	}
+	return
+}
+
+// ClaimSchedule removes the schedule with its message atomically, s is the
+// schedule claimed by the call to deliver, nil if it's claimed already or
+// canceled. A schedule is delivered at most once, it's lost if the claimer
+// fails before the push.
+func (d *Dao) ClaimSchedule(c context.Context, id string) (s *model.Schedule, err error) {
+	conn := d.redis.Get()
+	defer conn.Close()
+	var (
+		n   int
+		b   []byte
+		res []interface{}
+	)
+	if res, err = redis.Values(_scheduleClaim.Do(conn, _keySchedule, _keyScheduleMsg, id)); err != nil {
+		log.Errorf("schedule claim id:%s error(%v)", id, err)
+		return
+	}
+	if _, err = redis.Scan(res, &n, &b); err != nil {
+		log.Errorf("redis.Scan(%v) error(%v)", res, err)
+		return
+	}
+	if n == 0 {
+		return
+	}
+	s = new(model.Schedule)
+	if len(b) == 0 || json.Unmarshal(b, s) != nil {
+		// the message is lost, the id is claimed to drop it
+		log.Errorf("schedule:%s message is invalid", id)
+		s = &model.Schedule{ID: id}
+	}
+	return
+}
+
+// DueSchedules get the ids of the schedules due at now, limit at most.
+func (d *Dao) DueSchedules(c context.Context, now time.Time, limit int) (ids []string, err error) {
+	conn := d.redis.Get()
+	defer conn.Close()
+	if ids, err = redis.Strings(conn.Do("ZRANGEBYSCORE", _keySchedule, "-inf", now.Unix(), "LIMIT", 0, limit)); err != nil {
+		log.Errorf("conn.Do(ZRANGEBYSCORE %s) error(%v)", _keySchedule, err)
+	}
+	return
+}
+
+// LockSchedule takes or renews the scheduler lock for the owner in ttl.
+func (d *Dao) LockSchedule(c context.Context, owner string, ttl time.Duration) (ok bool, err error) {
+	conn := d.redis.Get()
+	defer conn.Close()
+	var n int
+	if n, err = redis.Int(_scheduleLock.Do(conn, _keyScheduleLock, owner, int64(ttl/time.Millisecond))); err != nil {
+		log.Errorf("schedule lock owner:%s error(%v)", owner, err)
+		return
+	}
+	return n == 1, nil
+}
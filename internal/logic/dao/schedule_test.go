@@ -0,0 +1,50 @@
+package dao
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Terry-Mao/goim/internal/logic/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDaoSchedule(t *testing.T) {
+	var (
+		c   = context.Background()
+		now = time.Now()
+		s   = &model.Schedule{ID: "test_schedule", Target: model.ScheduleAll, Op: 1000, Msg: []byte("msg"), At: now.Unix()}
+	)
+	err := d.AddSchedule(c, s)
+	assert.Nil(t, err)
+	ids, err := d.DueSchedules(c, now.Add(-time.Minute), 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(ids))
+	ids, err = d.DueSchedules(c, now, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{s.ID}, ids)
+	claimed, err := d.ClaimSchedule(c, s.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, s, claimed)
+	claimed, err = d.ClaimSchedule(c, s.ID)
+	assert.Nil(t, err)
+	assert.Nil(t, claimed)
+}
+
+func TestDaoLockSchedule(t *testing.T) {
+	c := context.Background()
+	ok, err := d.LockSchedule(c, "test_a", time.Second)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = d.LockSchedule(c, "test_b", time.Second)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	// renewed
+	ok, err = d.LockSchedule(c, "test_a", time.Millisecond)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	time.Sleep(10 * time.Millisecond)
+	ok, err = d.LockSchedule(c, "test_b", time.Millisecond)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,87 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Terry-Mao/goim/internal/logic/auth"
+	log "github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const _pushService = "/goim.logic.Push/"
+
+// pushTargets is the push targets of the methods of the push service.
+var pushTargets = map[string]string{
+	_pushService + "PushKeys": auth.TargetKeys,
+	_pushService + "PushMids": auth.TargetMids,
+	_pushService + "PushRoom": auth.TargetRoom,
+	_pushService + "PushAll":  auth.TargetAll,
+}
+
+// mdCredential is the credential in the metadata, the keys are the headers
+// in lower case.
+type mdCredential metadata.MD
+
+func (md mdCredential) Get(key string) string {
+	if vs := metadata.MD(md).Get(key); len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+// pushAuth authenticates the app calling the push service by the metadata as
+// the http push api, and checks the target, the operation and the app of the
+// request. The broadcasts are audited, the rejected ones too.
+func pushAuth(a *auth.Auth) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		if a == nil || !strings.HasPrefix(info.FullMethod, _pushService) {
+			return handler(ctx, req)
+		}
+		target := pushTargets[info.FullMethod]
+		appID := "-"
+		switch target {
+		case auth.TargetRoom, auth.TargetAll:
+			defer func() {
+				log.Infof("push audit: APP:%s | METHOD:%s | CODE:%s", appID, info.FullMethod, status.Code(err))
+			}()
+		}
+		body, err := proto.Marshal(req.(proto.Message))
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		now := time.Now()
+		app, err := a.AuthenticateCall(ctx, mdCredential(md), "POST", info.FullMethod, "", body, now)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		appID = app.ID()
+		if !allowPush(app, target, req) {
+			return nil, status.Error(codes.PermissionDenied, auth.ErrForbidden.Error())
+		}
+		if err = a.Limit(ctx, app, now, 1); err != nil {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return handler(ctx, req)
+	}
+}
+
+// allowPush checks the target, the operation and the app of the request.
+func allowPush(app *auth.App, target string, req interface{}) bool {
+	if target != "" && !app.AllowTarget(target) {
+		return false
+	}
+	if r, ok := req.(interface{ GetOp() int32 }); ok && !app.AllowOp(r.GetOp()) {
+		return false
+	}
+	if r, ok := req.(interface{ GetApp() string }); ok && !app.AllowApp(r.GetApp()) {
+		return false
+	}
+	return true
+}
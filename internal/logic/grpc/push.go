@@ -0,0 +1,124 @@
+package grpc
+
+import (
+	"context"
+
+	pb "github.com/Terry-Mao/goim/api/logic"
+	"github.com/Terry-Mao/goim/internal/logic"
+	"github.com/Terry-Mao/goim/internal/logic/model"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/status"
+)
+
+// pushServer is the push api of the business services.
+type pushServer struct {
+	srv *logic.Logic
+}
+
+var _ pb.PushServer = &pushServer{}
+
+// PushKeys push a message by keys.
+func (s *pushServer) PushKeys(ctx context.Context, req *pb.PushKeysReq) (*pb.PushKeysReply, error) {
+	rs, err := s.srv.PushKeysResult(ctx, req.App, req.Op, req.Keys, req.Msg)
+	if rs == nil {
+		return &pb.PushKeysReply{}, err
+	}
+	reply := &pb.PushKeysReply{Results: keyResults(rs)}
+	if err != nil {
+		return reply, withResults(err, reply)
+	}
+	return reply, nil
+}
+
+// PushMids push a message by mids.
+func (s *pushServer) PushMids(ctx context.Context, req *pb.PushMidsReq) (*pb.PushMidsReply, error) {
+	rs, err := s.srv.PushMidsResult(ctx, req.App, req.Op, req.Mids, req.Msg)
+	if rs == nil {
+		return &pb.PushMidsReply{}, err
+	}
+	reply := &pb.PushMidsReply{Results: make([]*pb.MidResult, 0, len(rs))}
+	for _, r := range rs {
+		reply.Results = append(reply.Results, &pb.MidResult{Mid: r.Mid, Status: r.Status, Keys: keyResults(r.Keys)})
+	}
+	if err != nil {
+		return reply, withResults(err, reply)
+	}
+	return reply, nil
+}
+
+// PushRoom push a message by room.
+func (s *pushServer) PushRoom(ctx context.Context, req *pb.PushRoomReq) (*pb.PushRoomReply, error) {
+	if err := s.srv.PushRoom(ctx, req.App, req.Op, req.Type, req.Room, req.Priority, req.Msg); err != nil {
+		return &pb.PushRoomReply{}, err
+	}
+	return &pb.PushRoomReply{}, nil
+}
+
+// PushAll push a message to all.
+func (s *pushServer) PushAll(ctx context.Context, req *pb.PushAllReq) (*pb.PushAllReply, error) {
+	if err := s.srv.PushAll(ctx, req.App, req.Op, req.Speed, req.Msg); err != nil {
+		return &pb.PushAllReply{}, err
+	}
+	return &pb.PushAllReply{}, nil
+}
+
+// OnlineMids get the mids online.
+func (s *pushServer) OnlineMids(ctx context.Context, req *pb.OnlineMidsReq) (*pb.OnlineMidsReply, error) {
+	mids, err := s.srv.OnlineMids(ctx, req.App, req.Mids)
+	if err != nil {
+		return &pb.OnlineMidsReply{}, err
+	}
+	return &pb.OnlineMidsReply{Mids: mids}, nil
+}
+
+// OnlineRoom get the connections and the users of rooms.
+func (s *pushServer) OnlineRoom(ctx context.Context, req *pb.OnlineRoomReq) (*pb.OnlineRoomReply, error) {
+	res, err := s.srv.OnlineRoomUsers(ctx, req.App, req.Type, req.Rooms)
+	if err != nil {
+		return &pb.OnlineRoomReply{}, err
+	}
+	reply := &pb.OnlineRoomReply{Rooms: make(map[string]*pb.RoomOnline, len(res))}
+	for room, ol := range res {
+		reply.Rooms[room] = &pb.RoomOnline{Count: ol.Count, Users: ol.Users}
+	}
+	return reply, nil
+}
+
+// RoomMembers get a page of the mids in the room.
+func (s *pushServer) RoomMembers(ctx context.Context, req *pb.RoomMembersReq) (*pb.RoomMembersReply, error) {
+	res, err := s.srv.RoomMembers(ctx, req.App, req.Type, req.Room, req.Cursor, int(req.Size))
+	if err != nil {
+		return &pb.RoomMembersReply{}, err
+	}
+	return &pb.RoomMembersReply{Mids: res.Mids, Next: res.Next}, nil
+}
+
+// OnlineApp get the connections and the rooms of the app.
+func (s *pushServer) OnlineApp(ctx context.Context, req *pb.OnlineAppReq) (*pb.OnlineAppReply, error) {
+	res := s.srv.OnlineApp(ctx, req.App)
+	return &pb.OnlineAppReply{Conns: res.Conns, Rooms: res.Rooms}, nil
+}
+
+// OnlineTotal get all online.
+func (s *pushServer) OnlineTotal(ctx context.Context, req *pb.OnlineTotalReq) (*pb.OnlineTotalReply, error) {
+	ipCount, connCount := s.srv.OnlineTotal(ctx)
+	return &pb.OnlineTotalReply{IpCount: ipCount, ConnCount: connCount}, nil
+}
+
+// withResults returns the error of a push failed partly with the reply in the
+// status details, a reply is not sent with an error by grpc.
+func withResults(err error, reply proto.Message) error {
+	st, _ := status.FromError(err)
+	if ds, e := st.WithDetails(reply); e == nil {
+		return ds.Err()
+	}
+	return err
+}
+
+func keyResults(rs []*model.KeyResult) []*pb.KeyResult {
+	res := make([]*pb.KeyResult, 0, len(rs))
+	for _, r := range rs {
+		res = append(res, &pb.KeyResult{Key: r.Key, Status: r.Status, Server: r.Server, Error: r.Error})
+	}
+	return res
+}
@@ -7,7 +7,9 @@ import (
 
 	pb "github.com/Terry-Mao/goim/api/logic"
 	"github.com/Terry-Mao/goim/internal/logic"
+	"github.com/Terry-Mao/goim/internal/logic/auth"
 	"github.com/Terry-Mao/goim/internal/logic/conf"
+	"github.com/Terry-Mao/goim/internal/logic/model"
 	log "github.com/golang/glog"
 
 	"google.golang.org/grpc"
@@ -17,8 +19,8 @@ import (
 	_ "google.golang.org/grpc/encoding/gzip"
 )
 
-// New logic grpc server
-func New(c *conf.RPCServer, l *logic.Logic) *grpc.Server {
+// New logic grpc server, the push service is authenticated by the apps of a.
+func New(c *conf.RPCServer, l *logic.Logic, a *auth.Auth) *grpc.Server {
 	keepParams := grpc.KeepaliveParams(keepalive.ServerParameters{
 		MaxConnectionIdle:     time.Duration(c.IdleTimeout),
 		MaxConnectionAgeGrace: time.Duration(c.ForceCloseWait),
@@ -26,8 +28,9 @@ func New(c *conf.RPCServer, l *logic.Logic) *grpc.Server {
 		Timeout:               time.Duration(c.KeepAliveTimeout),
 		MaxConnectionAge:      time.Duration(c.MaxLifeTime),
 	})
-	srv := grpc.NewServer(keepParams)
+	srv := grpc.NewServer(keepParams, grpc.UnaryInterceptor(pushAuth(a)))
 	pb.RegisterLogicServer(srv, &server{l})
+	pb.RegisterPushServer(srv, &pushServer{l})
 	lis, err := net.Listen(c.Network, c.Addr)
 	if err != nil {
 		panic(err)
@@ -49,13 +52,13 @@ var _ pb.LogicServer = &server{}
 // Connect connect a conn.
 func (s *server) Connect(ctx context.Context, req *pb.ConnectReq) (*pb.ConnectReply, error) {
 	log.Infof("gRPC Connect called: server=%s cookie=%s token=%s", req.Server, req.Cookie, string(req.Token))
-	mid, key, room, accepts, hb, err := s.srv.Connect(ctx, req.Server, req.Cookie, req.Token)
+	reply, err := s.srv.Connect(ctx, req.Server, req.Cookie, req.Ip, req.Token)
 	if err != nil {
 		log.Errorf("gRPC Connect error: %v", err)
 		return &pb.ConnectReply{}, err
 	}
-	log.Infof("gRPC Connect success: mid=%d key=%s", mid, key)
-	return &pb.ConnectReply{Mid: mid, Key: key, RoomID: room, Accepts: accepts, Heartbeat: hb}, nil
+	log.Infof("gRPC Connect success: mid=%d key=%s", reply.Mid, reply.Key)
+	return reply, nil
 }
 
 // Disconnect disconnect a conn.
@@ -75,9 +78,22 @@ func (s *server) Heartbeat(ctx context.Context, req *pb.HeartbeatReq) (*pb.Heart
 	return &pb.HeartbeatReply{}, nil
 }
 
+// Reauth refreshes the token of a conn.
+func (s *server) Reauth(ctx context.Context, req *pb.ReauthReq) (*pb.ReauthReply, error) {
+	expire, err := s.srv.Reauth(ctx, req.Mid, req.Key, req.Server, req.Token)
+	if err != nil {
+		return &pb.ReauthReply{}, err
+	}
+	return &pb.ReauthReply{Expire: expire}, nil
+}
+
 // RenewOnline renew server online.
 func (s *server) RenewOnline(ctx context.Context, req *pb.OnlineReq) (*pb.OnlineReply, error) {
-	allRoomCount, err := s.srv.RenewOnline(ctx, req.Server, req.RoomCount)
+	roomUsers := make(map[string]*model.RoomUsers, len(req.RoomUsers))
+	for roomID, users := range req.RoomUsers {
+		roomUsers[roomID] = &model.RoomUsers{Mids: users.Mids, Guests: users.Guests}
+	}
+	allRoomCount, err := s.srv.RenewOnline(ctx, req.Server, req.RoomCount, roomUsers, req.AppConns)
 	if err != nil {
 		return &pb.OnlineReply{}, err
 	}
@@ -92,6 +108,12 @@ func (s *server) Receive(ctx context.Context, req *pb.ReceiveReq) (*pb.ReceiveRe
 	return &pb.ReceiveReply{}, nil
 }
 
+// Presence receive the room join/leave events.
+func (s *server) Presence(ctx context.Context, req *pb.PresenceReq) (*pb.PresenceReply, error) {
+	s.srv.Presence(ctx, req.Server, req.Events)
+	return &pb.PresenceReply{}, nil
+}
+
 // nodes return nodes.
 func (s *server) Nodes(ctx context.Context, req *pb.NodesReq) (*pb.NodesReply, error) {
 	return s.srv.NodesWeighted(ctx, req.Platform, req.ClientIP), nil
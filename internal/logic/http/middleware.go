@@ -1,11 +1,16 @@
 package http
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"net/http/httputil"
 	"runtime"
+	"strconv"
 	"time"
 
+	"github.com/Terry-Mao/goim/internal/logic/auth"
 	"github.com/gin-gonic/gin"
 	log "github.com/golang/glog"
 )
@@ -47,3 +52,74 @@ func recoverHandler(c *gin.Context) {
 	}()
 	c.Next()
 }
+
+const (
+	// targetSchedule takes the push target of a schedule from the query.
+	targetSchedule = "schedule"
+	// targetBatch is checked and limited by the items in the handler.
+	targetBatch = "batch"
+)
+
+// pushAuth authenticates the app calling the push api, and checks the target
+// the operation and the app in the query, the batch items are checked and
+// limited by the handler. The body is read up to the max bytes.
+// The broadcasts are audited, the rejected ones too.
+func (s *Server) pushAuth(target string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.maxBody)
+		tg := target
+		switch tg {
+		case targetSchedule:
+			tg = c.Query("target")
+		case targetBatch:
+			tg = ""
+		}
+		appID := "-"
+		switch tg {
+		case auth.TargetRoom, auth.TargetAll, auth.TargetConfig:
+			defer func() {
+				log.Infof("push audit: APP:%s | IP:%s | METHOD:%s | PATH:%s | QUERY:%s | BODY:%d | ECODE:%d",
+					appID, c.ClientIP(), c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery, c.Request.ContentLength, c.GetInt(contextErrCode))
+			}()
+		}
+		if s.auth != nil {
+			body, err := ioutil.ReadAll(c.Request.Body)
+			if err != nil {
+				errors(c, RequestErr, err.Error())
+				c.Abort()
+				return
+			}
+			c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+			now := time.Now()
+			app, err := s.auth.Authenticate(c.Request, body, now)
+			if err != nil {
+				errors(c, AuthErr, err.Error())
+				c.Abort()
+				return
+			}
+			appID = app.ID()
+			if (tg != "" && !app.AllowTarget(tg)) || !app.AllowApp(c.Query("app")) {
+				errors(c, ForbiddenErr, auth.ErrForbidden.Error())
+				c.Abort()
+				return
+			}
+			// the config is pushed by its own operation
+			if op := c.Query("operation"); op != "" && tg != auth.TargetConfig {
+				if n, err := strconv.ParseInt(op, 10, 32); err == nil && !app.AllowOp(int32(n)) {
+					errors(c, ForbiddenErr, auth.ErrForbidden.Error())
+					c.Abort()
+					return
+				}
+			}
+			if target != targetBatch {
+				if err = s.auth.Limit(c, app, now, 1); err != nil {
+					errors(c, LimitErr, err.Error())
+					c.Abort()
+					return
+				}
+			}
+			c.Set(contextApp, app)
+		}
+		c.Next()
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 func (s *Server) onlineTop(c *gin.Context) {
 	var arg struct {
+		App   string `form:"app"`
 		Type  string `form:"type" binding:"required"`
 		Limit int    `form:"limit" binding:"required"`
 	}
@@ -15,7 +16,7 @@ func (s *Server) onlineTop(c *gin.Context) {
 		errors(c, RequestErr, err.Error())
 		return
 	}
-	res, err := s.logic.OnlineTop(c, arg.Type, arg.Limit)
+	res, err := s.logic.OnlineTop(c, arg.App, arg.Type, arg.Limit)
 	if err != nil {
 		result(c, nil, RequestErr)
 		return
@@ -25,14 +26,25 @@ func (s *Server) onlineTop(c *gin.Context) {
 
 func (s *Server) onlineRoom(c *gin.Context) {
 	var arg struct {
-		Type  string   `form:"type" binding:"required"`
-		Rooms []string `form:"rooms" binding:"required"`
+		App    string   `form:"app"`
+		Type   string   `form:"type" binding:"required"`
+		Rooms  []string `form:"rooms" binding:"required"`
+		Detail bool     `form:"detail"`
 	}
 	if err := c.BindQuery(&arg); err != nil {
 		errors(c, RequestErr, err.Error())
 		return
 	}
-	res, err := s.logic.OnlineRoom(c, arg.Type, arg.Rooms)
+	if arg.Detail {
+		res, err := s.logic.OnlineRoomUsers(c, arg.App, arg.Type, arg.Rooms)
+		if err != nil {
+			result(c, nil, RequestErr)
+			return
+		}
+		result(c, res, OK)
+		return
+	}
+	res, err := s.logic.OnlineRoom(c, arg.App, arg.Type, arg.Rooms)
 	if err != nil {
 		result(c, nil, RequestErr)
 		return
@@ -40,6 +52,37 @@ func (s *Server) onlineRoom(c *gin.Context) {
 	result(c, res, OK)
 }
 
+func (s *Server) onlineMembers(c *gin.Context) {
+	var arg struct {
+		App    string `form:"app"`
+		Type   string `form:"type" binding:"required"`
+		Room   string `form:"room" binding:"required"`
+		Cursor int64  `form:"cursor"`
+		Size   int    `form:"size"`
+	}
+	if err := c.BindQuery(&arg); err != nil {
+		errors(c, RequestErr, err.Error())
+		return
+	}
+	res, err := s.logic.RoomMembers(c, arg.App, arg.Type, arg.Room, arg.Cursor, arg.Size)
+	if err != nil {
+		errors(c, ServerErr, err.Error())
+		return
+	}
+	result(c, res, OK)
+}
+
+func (s *Server) onlineApp(c *gin.Context) {
+	var arg struct {
+		App string `form:"app"`
+	}
+	if err := c.BindQuery(&arg); err != nil {
+		errors(c, RequestErr, err.Error())
+		return
+	}
+	result(c, s.logic.OnlineApp(c, arg.App), OK)
+}
+
 func (s *Server) onlineTotal(c *gin.Context) {
 	ipCount, connCount := s.logic.OnlineTotal(context.TODO())
 	res := map[string]interface{}{
@@ -3,12 +3,16 @@ package http
 import (
 	"context"
 	"io/ioutil"
+	"time"
 
+	"github.com/Terry-Mao/goim/internal/logic/auth"
+	"github.com/Terry-Mao/goim/internal/logic/model"
 	"github.com/gin-gonic/gin"
 )
 
 func (s *Server) pushKeys(c *gin.Context) {
 	var arg struct {
+		App  string   `form:"app"`
 		Op   int32    `form:"operation"`
 		Keys []string `form:"keys"`
 	}
@@ -22,15 +26,22 @@ func (s *Server) pushKeys(c *gin.Context) {
 		errors(c, RequestErr, err.Error())
 		return
 	}
-	if err = s.logic.PushKeys(context.TODO(), arg.Op, arg.Keys, msg); err != nil {
-		result(c, nil, RequestErr)
+	rs, err := s.logic.PushKeysResult(context.TODO(), arg.App, arg.Op, arg.Keys, msg)
+	if err != nil {
+		if rs == nil {
+			errors(c, ServerErr, err.Error())
+			return
+		}
+		// failed partly, the results tell the ones pushed
+		errorsResult(c, ServerErr, err.Error(), rs)
 		return
 	}
-	result(c, nil, OK)
+	result(c, rs, OK)
 }
 
 func (s *Server) pushMids(c *gin.Context) {
 	var arg struct {
+		App  string  `form:"app"`
 		Op   int32   `form:"operation"`
 		Mids []int64 `form:"mids"`
 	}
@@ -44,18 +55,63 @@ func (s *Server) pushMids(c *gin.Context) {
 		errors(c, RequestErr, err.Error())
 		return
 	}
-	if err = s.logic.PushMids(context.TODO(), arg.Op, arg.Mids, msg); err != nil {
-		errors(c, ServerErr, err.Error())
+	rs, err := s.logic.PushMidsResult(context.TODO(), arg.App, arg.Op, arg.Mids, msg)
+	if err != nil {
+		if rs == nil {
+			errors(c, ServerErr, err.Error())
+			return
+		}
+		// failed partly, the results tell the ones pushed
+		errorsResult(c, ServerErr, err.Error(), rs)
 		return
 	}
-	result(c, nil, OK)
+	result(c, rs, OK)
+}
+
+func (s *Server) pushBatch(c *gin.Context) {
+	var arg struct {
+		Items []*model.PushItem `json:"items"`
+	}
+	app := c.Query("app")
+	if err := c.ShouldBindJSON(&arg); err != nil {
+		errors(c, RequestErr, err.Error())
+		return
+	}
+	if v, ok := c.Get(contextApp); ok {
+		pa := v.(*auth.App)
+		if !pa.AllowApp(app) {
+			errors(c, ForbiddenErr, auth.ErrForbidden.Error())
+			return
+		}
+		for _, item := range arg.Items {
+			if !pa.AllowOp(item.Op) ||
+				(len(item.Keys) > 0 && !pa.AllowTarget(auth.TargetKeys)) ||
+				(len(item.Mids) > 0 && !pa.AllowTarget(auth.TargetMids)) {
+				errors(c, ForbiddenErr, auth.ErrForbidden.Error())
+				return
+			}
+		}
+		// the quota is taken by the items
+		if err := s.auth.Limit(c, pa, time.Now(), int64(len(arg.Items))); err != nil {
+			errors(c, LimitErr, err.Error())
+			return
+		}
+	}
+	rs, err := s.logic.PushBatch(c, app, arg.Items)
+	if err != nil {
+		errors(c, RequestErr, err.Error())
+		return
+	}
+	result(c, rs, OK)
 }
 
 func (s *Server) pushRoom(c *gin.Context) {
 	var arg struct {
-		Op   int32  `form:"operation" binding:"required"`
-		Type string `form:"type" binding:"required"`
-		Room string `form:"room" binding:"required"`
+		App      string `form:"app"`
+		Op       int32  `form:"operation" binding:"required"`
+		Type     string `form:"type" binding:"required"`
+		Room     string `form:"room" binding:"required"`
+		Priority int32  `form:"priority"`
 	}
 	if err := c.BindQuery(&arg); err != nil {
 		errors(c, RequestErr, err.Error())
@@ -67,7 +123,7 @@ func (s *Server) pushRoom(c *gin.Context) {
 		errors(c, RequestErr, err.Error())
 		return
 	}
-	if err = s.logic.PushRoom(c, arg.Op, arg.Type, arg.Room, msg); err != nil {
+	if err = s.logic.PushRoom(c, arg.App, arg.Op, arg.Type, arg.Room, arg.Priority, msg); err != nil {
 		errors(c, ServerErr, err.Error())
 		return
 	}
@@ -76,8 +132,38 @@ func (s *Server) pushRoom(c *gin.Context) {
 
 func (s *Server) pushAll(c *gin.Context) {
 	var arg struct {
-		Op    int32 `form:"operation" binding:"required"`
-		Speed int32 `form:"speed"`
+		App   string `form:"app"`
+		Op    int32  `form:"operation" binding:"required"`
+		Speed int32  `form:"speed"`
+	}
+	if err := c.BindQuery(&arg); err != nil {
+		errors(c, RequestErr, err.Error())
+		return
+	}
+	msg, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errors(c, RequestErr, err.Error())
+		return
+	}
+	if err = s.logic.PushAll(c, arg.App, arg.Op, arg.Speed, msg); err != nil {
+		errors(c, ServerErr, err.Error())
+		return
+	}
+	result(c, nil, OK)
+}
+
+func (s *Server) pushSchedule(c *gin.Context) {
+	var arg struct {
+		App    string   `form:"app"`
+		Target string   `form:"target" binding:"required"`
+		Op     int32    `form:"operation" binding:"required"`
+		Keys   []string `form:"keys"`
+		Mids   []int64  `form:"mids"`
+		Type   string   `form:"type"`
+		Room   string   `form:"room"`
+		Speed  int32    `form:"speed"`
+		At     int64    `form:"at"`
+		Delay  int64    `form:"delay"`
 	}
 	if err := c.BindQuery(&arg); err != nil {
 		errors(c, RequestErr, err.Error())
@@ -88,7 +174,59 @@ func (s *Server) pushAll(c *gin.Context) {
 		errors(c, RequestErr, err.Error())
 		return
 	}
-	if err = s.logic.PushAll(c, arg.Op, arg.Speed, msg); err != nil {
+	if arg.At == 0 && arg.Delay > 0 {
+		arg.At = time.Now().Unix() + arg.Delay
+	}
+	id, err := s.logic.Schedule(c, &model.Schedule{
+		App:      arg.App,
+		Target:   arg.Target,
+		Op:       arg.Op,
+		Keys:     arg.Keys,
+		Mids:     arg.Mids,
+		RoomType: arg.Type,
+		Room:     arg.Room,
+		Speed:    arg.Speed,
+		Msg:      msg,
+		At:       arg.At,
+	})
+	if err != nil {
+		errors(c, RequestErr, err.Error())
+		return
+	}
+	result(c, map[string]string{"id": id}, OK)
+}
+
+func (s *Server) cancelSchedule(c *gin.Context) {
+	var arg struct {
+		ID string `form:"id" binding:"required"`
+	}
+	if err := c.BindQuery(&arg); err != nil {
+		errors(c, RequestErr, err.Error())
+		return
+	}
+	has, err := s.logic.CancelSchedule(c, arg.ID)
+	if err != nil {
+		errors(c, ServerErr, err.Error())
+		return
+	}
+	result(c, map[string]bool{"canceled": has}, OK)
+}
+
+func (s *Server) pushConfig(c *gin.Context) {
+	var arg struct {
+		Platform string `form:"platform"`
+		Speed    int32  `form:"speed"`
+	}
+	if err := c.BindQuery(&arg); err != nil {
+		errors(c, RequestErr, err.Error())
+		return
+	}
+	cfg := new(model.ClientConfig)
+	if err := c.ShouldBindJSON(cfg); err != nil {
+		errors(c, RequestErr, err.Error())
+		return
+	}
+	if err := s.logic.PushConfig(c, arg.Platform, arg.Speed, cfg); err != nil {
 		errors(c, ServerErr, err.Error())
 		return
 	}
@@ -11,8 +11,15 @@ const (
 	RequestErr = -400
 	// ServerErr server error
 	ServerErr = -500
+	// AuthErr the app is not authenticated
+	AuthErr = -401
+	// ForbiddenErr the push is not allowed to the app
+	ForbiddenErr = -403
+	// LimitErr the app is over the rate or the quota
+	LimitErr = -429
 
 	contextErrCode = "context/err/code"
+	contextApp     = "context/app"
 )
 
 type resp struct {
@@ -29,6 +36,17 @@ func errors(c *gin.Context, code int, msg string) {
 	})
 }
 
+// errorsResult responds the error with the data, as the results of a push
+// failed partly.
+func errorsResult(c *gin.Context, code int, msg string, data interface{}) {
+	c.Set(contextErrCode, code)
+	c.JSON(200, resp{
+		Code:    code,
+		Message: msg,
+		Data:    data,
+	})
+}
+
 func result(c *gin.Context, data interface{}, code int) {
 	c.Set(contextErrCode, code)
 	c.JSON(200, resp{
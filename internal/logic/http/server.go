@@ -2,19 +2,25 @@ package http
 
 import (
 	"github.com/Terry-Mao/goim/internal/logic"
+	"github.com/Terry-Mao/goim/internal/logic/auth"
 	"github.com/Terry-Mao/goim/internal/logic/conf"
 
 	"github.com/gin-gonic/gin"
 )
 
+// _maxBody is the max bytes of a push request body by default.
+const _maxBody = 1 << 20
+
 // Server is http server.
 type Server struct {
-	engine *gin.Engine
-	logic  *logic.Logic
+	engine  *gin.Engine
+	logic   *logic.Logic
+	auth    *auth.Auth
+	maxBody int64
 }
 
-// New new a http server.
-func New(c *conf.HTTPServer, l *logic.Logic) *Server {
+// New new a http server, the push api is authenticated by the apps of a.
+func New(c *conf.HTTPServer, l *logic.Logic, a *auth.Auth) *Server {
 	engine := gin.New()
 	engine.Use(loggerHandler, recoverHandler)
 	go func() {
@@ -23,8 +29,13 @@ func New(c *conf.HTTPServer, l *logic.Logic) *Server {
 		}
 	}()
 	s := &Server{
-		engine: engine,
-		logic:  l,
+		engine:  engine,
+		logic:   l,
+		auth:    a,
+		maxBody: c.MaxBody,
+	}
+	if s.maxBody <= 0 {
+		s.maxBody = _maxBody
 	}
 	s.initRouter()
 	return s
@@ -32,12 +43,18 @@ func New(c *conf.HTTPServer, l *logic.Logic) *Server {
 
 func (s *Server) initRouter() {
 	group := s.engine.Group("/goim")
-	group.POST("/push/keys", s.pushKeys)
-	group.POST("/push/mids", s.pushMids)
-	group.POST("/push/room", s.pushRoom)
-	group.POST("/push/all", s.pushAll)
+	group.POST("/push/keys", s.pushAuth(auth.TargetKeys), s.pushKeys)
+	group.POST("/push/mids", s.pushAuth(auth.TargetMids), s.pushMids)
+	group.POST("/push/batch", s.pushAuth(targetBatch), s.pushBatch)
+	group.POST("/push/room", s.pushAuth(auth.TargetRoom), s.pushRoom)
+	group.POST("/push/all", s.pushAuth(auth.TargetAll), s.pushAll)
+	group.POST("/push/config", s.pushAuth(auth.TargetConfig), s.pushConfig)
+	group.POST("/push/schedule", s.pushAuth(targetSchedule), s.pushSchedule)
+	group.DELETE("/push/schedule", s.pushAuth(""), s.cancelSchedule)
 	group.GET("/online/top", s.onlineTop)
 	group.GET("/online/room", s.onlineRoom)
+	group.GET("/online/members", s.onlineMembers)
+	group.GET("/online/app", s.onlineApp)
 	group.GET("/online/total", s.onlineTotal)
 	group.GET("/nodes/weighted", s.nodesWeighted)
 	group.GET("/nodes/instances", s.nodesInstances)
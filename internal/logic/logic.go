@@ -2,12 +2,16 @@ package logic
 
 import (
 	"context"
+	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/Terry-Mao/goim/internal/logic/conf"
 	"github.com/Terry-Mao/goim/internal/logic/dao"
 	"github.com/Terry-Mao/goim/internal/logic/model"
+	"github.com/Terry-Mao/goim/internal/logic/scheduler"
+	"github.com/Terry-Mao/goim/pkg/hll"
 	"github.com/bilibili/discovery/naming"
 	log "github.com/golang/glog"
 )
@@ -26,6 +30,20 @@ type Logic struct {
 	totalIPs   int64
 	totalConns int64
 	roomCount  map[string]int32
+	roomUsers  map[string]int32
+	appConns   map[string]int32
+	// app namespaces, the default first
+	apps   map[string]*conf.App
+	appIDs []string
+	// comet clients by hostname for the room members
+	comets      map[string]*cometConn
+	cometsMutex sync.Mutex
+	// room presence events not pushed yet, the comets of the mids in the
+	// rooms
+	presence      map[string]*roomPresence
+	members       map[string]map[int64]map[string]struct{}
+	presenceMutex sync.Mutex
+	scheduler     *scheduler.Scheduler
 	// load balancer
 	nodes        []*naming.Instance
 	loadBalancer *LoadBalancer
@@ -40,11 +58,20 @@ func New(c *conf.Config) (l *Logic) {
 		dis:          naming.New(c.Discovery),
 		loadBalancer: NewLoadBalancer(),
 		regions:      make(map[string]string),
+		comets:       make(map[string]*cometConn),
+		presence:     make(map[string]*roomPresence),
+		members:      make(map[string]map[int64]map[string]struct{}),
 	}
 	l.initRegions()
+	l.initApps()
 	l.initNodes()
 	_ = l.loadOnline()
 	go l.onlineproc()
+	go l.presenceproc()
+	// the owner is unique with the logics on the same host
+	owner := c.Env.Host + ":" + strconv.Itoa(os.Getpid())
+	l.scheduler = scheduler.New(c.Schedule, owner, l.dao, scheduler.RealClock, l.pushSchedule)
+	l.scheduler.Start()
 	return l
 }
 
@@ -55,6 +82,7 @@ func (l *Logic) Ping(c context.Context) (err error) {
 
 // Close close resources.
 func (l *Logic) Close() {
+	l.scheduler.Close()
 	l.dao.Close()
 }
 
@@ -126,6 +154,7 @@ func (l *Logic) newNodes(res naming.Resolver) {
 		l.totalIPs = totalIPs
 		l.nodes = allIns
 		l.loadBalancer.Update(allIns)
+		l.closeComets(allIns)
 	}
 }
 
@@ -139,23 +168,55 @@ func (l *Logic) onlineproc() {
 }
 
 func (l *Logic) loadOnline() (err error) {
-	var (
-		roomCount = make(map[string]int32)
-	)
+	var onlines []*model.Online
 	for _, server := range l.nodes {
 		var online *model.Online
-		online, err = l.dao.ServerOnline(context.Background(), server.Hostname)
+		online, err = l.dao.ServerOnline(context.Background(), l.appIDs, server.Hostname)
 		if err != nil {
 			return
 		}
 		if time.Since(time.Unix(online.Updated, 0)) > _onlineDeadline {
-			_ = l.dao.DelServerOnline(context.Background(), server.Hostname)
+			_ = l.dao.DelServerOnline(context.Background(), l.appIDs, server.Hostname)
 			continue
 		}
+		onlines = append(onlines, online)
+	}
+	l.mergeOnline(onlines)
+	return
+}
+
+// mergeOnline sums up the onlines of the comets, the mids of a room are
+// merged so that a user on the different comets counts once.
+func (l *Logic) mergeOnline(onlines []*model.Online) {
+	var (
+		roomCount = make(map[string]int32)
+		roomUsers = make(map[string]int32)
+		roomMids  = make(map[string]*hll.Sketch)
+		appConns  = make(map[string]int32)
+	)
+	for _, online := range onlines {
 		for roomID, count := range online.RoomCount {
 			roomCount[roomID] += count
 		}
+		for roomID, users := range online.RoomUsers {
+			mids, ok := roomMids[roomID]
+			if !ok {
+				mids = new(hll.Sketch)
+				roomMids[roomID] = mids
+			}
+			if err := mids.Merge(users.Mids); err != nil {
+				log.Errorf("server:%s room:%s merge mids error(%v)", online.Server, roomID, err)
+			}
+			roomUsers[roomID] += users.Guests
+		}
+		for app, conns := range online.AppConns {
+			appConns[app] += conns
+		}
+	}
+	for roomID, mids := range roomMids {
+		roomUsers[roomID] += int32(mids.Count())
 	}
 	l.roomCount = roomCount
-	return
+	l.roomUsers = roomUsers
+	l.appConns = appConns
 }
@@ -0,0 +1,40 @@
+package model
+
+import "strings"
+
+// DefaultApp is the namespace of the clients without an app.
+const DefaultApp = ""
+
+// AppKey returns the key, mid, room or server in the namespace of the app as
+// @app/key, the ones of the default app are not changed.
+func AppKey(app, key string) string {
+	if app == DefaultApp {
+		return key
+	}
+	return "@" + app + "/" + key
+}
+
+// SplitAppKey returns the app and the key of a key in a namespace.
+func SplitAppKey(s string) (app, key string) {
+	if !IsAppKey(s) {
+		return DefaultApp, s
+	}
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return DefaultApp, s
+	}
+	return s[1:i], s[i+1:]
+}
+
+// IsAppKey reports whether the key is in a namespace, a client can't name
+// one.
+func IsAppKey(s string) bool {
+	return strings.HasPrefix(s, "@")
+}
+
+// AppOnline is the connections and the rooms of an app.
+type AppOnline struct {
+	App   string `json:"app"`
+	Conns int32  `json:"conns"`
+	Rooms int32  `json:"rooms"`
+}
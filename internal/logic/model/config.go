@@ -0,0 +1,20 @@
+package model
+
+// ClientConfig is the runtime config pushed to the live clients, the zero
+// fields are not changed.
+type ClientConfig struct {
+	// Heartbeat interval in seconds
+	Heartbeat int32 `json:"heartbeat,omitempty"`
+	// HeartbeatMax heartbeats missed before the server closes the connection
+	HeartbeatMax int32           `json:"heartbeat_max,omitempty"`
+	Backoff      *Backoff        `json:"backoff,omitempty"`
+	Features     map[string]bool `json:"features,omitempty"`
+}
+
+// Backoff is the reconnect backoff of the clients.
+type Backoff struct {
+	MaxDelay  int32   `json:"max_delay"`
+	BaseDelay int32   `json:"base_delay"`
+	Factor    float32 `json:"factor"`
+	Jitter    float32 `json:"jitter"`
+}
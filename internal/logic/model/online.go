@@ -2,13 +2,46 @@ package model
 
 // Online ip and room online.
 type Online struct {
-	Server    string           `json:"server"`
-	RoomCount map[string]int32 `json:"room_count"`
-	Updated   int64            `json:"updated"`
+	Server    string                `json:"server"`
+	RoomCount map[string]int32      `json:"room_count"`
+	RoomUsers map[string]*RoomUsers `json:"room_users"`
+	AppConns  map[string]int32      `json:"app_conns,omitempty"`
+	Updated   int64                 `json:"updated"`
+}
+
+// RoomUsers is the users of a room on a server, Mids is the sketch of the
+// mids by pkg/hll which is merged with the other servers.
+type RoomUsers struct {
+	Mids   []byte `json:"mids"`
+	Guests int32  `json:"guests"`
 }
 
 // Top top sorted.
 type Top struct {
 	RoomID string `json:"room_id"`
 	Count  int32  `json:"count"`
+	Users  int32  `json:"users"`
+}
+
+// RoomOnline is the connections and the distinct users of a room.
+type RoomOnline struct {
+	Count int32 `json:"count"`
+	Users int32 `json:"users"`
+}
+
+// Presence is the join/leave events of a room in a while, Joined and Left
+// count the mids including the ones not listed.
+type Presence struct {
+	Room   string  `json:"room"`
+	Joins  []int64 `json:"joins"`
+	Leaves []int64 `json:"leaves"`
+	Joined int     `json:"joined"`
+	Left   int     `json:"left"`
+}
+
+// Members is a page of the mids in a room, Next is the cursor of the next
+// page, zero if there is no more.
+type Members struct {
+	Mids []int64 `json:"mids"`
+	Next int64   `json:"next"`
 }
@@ -0,0 +1,40 @@
+package model
+
+// push result status of a target.
+const (
+	PushEnqueued = "enqueued"
+	PushOffline  = "offline"
+	PushFailed   = "failed"
+)
+
+// KeyResult is the push result of a key, Server is the comet the message is
+// enqueued to.
+type KeyResult struct {
+	Key    string `json:"key"`
+	Status string `json:"status"`
+	Server string `json:"server,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// MidResult is the push result of a mid, it's enqueued if any of its keys is.
+type MidResult struct {
+	Mid    int64        `json:"mid"`
+	Status string       `json:"status"`
+	Keys   []*KeyResult `json:"keys,omitempty"`
+}
+
+// PushItem is a message of a batch push to the keys and the mids.
+type PushItem struct {
+	Op   int32    `json:"operation"`
+	Keys []string `json:"keys,omitempty"`
+	Mids []int64  `json:"mids,omitempty"`
+	Msg  string   `json:"msg"`
+}
+
+// PushItemResult is the push result of a batch item, Error is set if the
+// item is not pushed at all.
+type PushItemResult struct {
+	Keys  []*KeyResult `json:"keys,omitempty"`
+	Mids  []*MidResult `json:"mids,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
@@ -0,0 +1,11 @@
+package model
+
+// Resume is the session restored by a resume token.
+type Resume struct {
+	Secret   string  `json:"secret"`
+	Mid      int64   `json:"mid"`
+	Key      string  `json:"key"`
+	RoomID   string  `json:"room_id"`
+	Platform string  `json:"platform"`
+	Accepts  []int32 `json:"accepts"`
+}
@@ -0,0 +1,24 @@
+package model
+
+// schedule push targets.
+const (
+	ScheduleKeys = "keys"
+	ScheduleMids = "mids"
+	ScheduleRoom = "room"
+	ScheduleAll  = "all"
+)
+
+// Schedule is a push delivered at the time in unix seconds.
+type Schedule struct {
+	ID       string   `json:"id"`
+	App      string   `json:"app,omitempty"`
+	Target   string   `json:"target"`
+	Op       int32    `json:"op"`
+	Keys     []string `json:"keys,omitempty"`
+	Mids     []int64  `json:"mids,omitempty"`
+	RoomType string   `json:"room_type,omitempty"`
+	Room     string   `json:"room,omitempty"`
+	Speed    int32    `json:"speed,omitempty"`
+	Msg      []byte   `json:"msg"`
+	At       int64    `json:"at"`
+}
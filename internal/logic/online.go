@@ -5,24 +5,28 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/Terry-Mao/goim/api/comet"
 	"github.com/Terry-Mao/goim/internal/logic/model"
+	log "github.com/golang/glog"
 )
 
 var (
 	_emptyTops = make([]*model.Top, 0)
 )
 
-// OnlineTop get the top online.
-func (l *Logic) OnlineTop(c context.Context, typ string, n int) (tops []*model.Top, err error) {
+// OnlineTop get the top online of the app.
+func (l *Logic) OnlineTop(c context.Context, app, typ string, n int) (tops []*model.Top, err error) {
 	for key, cnt := range l.roomCount {
-		if strings.HasPrefix(key, typ) {
-			_, roomID, err := model.DecodeRoomKey(key)
+		a, roomKey := model.SplitAppKey(key)
+		if a == app && strings.HasPrefix(roomKey, typ) {
+			_, roomID, err := model.DecodeRoomKey(roomKey)
 			if err != nil {
 				continue
 			}
 			top := &model.Top{
 				RoomID: roomID,
 				Count:  cnt,
+				Users:  l.roomUsers[key],
 			}
 			tops = append(tops, top)
 		}
@@ -39,15 +43,87 @@ func (l *Logic) OnlineTop(c context.Context, typ string, n int) (tops []*model.T
 	return
 }
 
-// OnlineRoom get rooms online.
-func (l *Logic) OnlineRoom(c context.Context, typ string, rooms []string) (res map[string]int32, err error) {
+// OnlineRoom get rooms online of the app.
+func (l *Logic) OnlineRoom(c context.Context, app, typ string, rooms []string) (res map[string]int32, err error) {
 	res = make(map[string]int32, len(rooms))
 	for _, room := range rooms {
-		res[room] = l.roomCount[model.EncodeRoomKey(typ, room)]
+		res[room] = l.roomCount[model.AppKey(app, model.EncodeRoomKey(typ, room))]
 	}
 	return
 }
 
+// OnlineRoomUsers get the connections and the distinct users of rooms of
+// the app.
+func (l *Logic) OnlineRoomUsers(c context.Context, app, typ string, rooms []string) (res map[string]*model.RoomOnline, err error) {
+	res = make(map[string]*model.RoomOnline, len(rooms))
+	for _, room := range rooms {
+		key := model.AppKey(app, model.EncodeRoomKey(typ, room))
+		res[room] = &model.RoomOnline{Count: l.roomCount[key], Users: l.roomUsers[key]}
+	}
+	return
+}
+
+const (
+	defaultMembersSize = 100
+	maxMembersSize     = 1000
+)
+
+// RoomMembers get a page of the mids in the room of the app from all the
+// comets, the mids greater than the cursor in ascending order.
+func (l *Logic) RoomMembers(c context.Context, app, typ, room string, cursor int64, size int) (res *model.Members, err error) {
+	if size <= 0 {
+		size = defaultMembersSize
+	} else if size > maxMembersSize {
+		size = maxMembersSize
+	}
+	var (
+		roomID = model.AppKey(app, model.EncodeRoomKey(typ, room))
+		pages  = make([][]int64, 0, len(l.nodes))
+	)
+	for _, in := range l.nodes {
+		client, err := l.cometClient(in)
+		if err != nil {
+			return nil, err
+		}
+		reply, err := client.RoomMembers(c, &comet.RoomMembersReq{RoomID: roomID, Cursor: cursor, Size: int32(size)})
+		if err != nil {
+			log.Errorf("comet:%s RoomMembers(%s) error(%v)", in.Hostname, roomID, err)
+			return nil, err
+		}
+		pages = append(pages, reply.Mids)
+	}
+	return mergeMembers(pages, size), nil
+}
+
+// mergeMembers merges the pages of the comets into one, a mid on the
+// different comets is kept once.
+func mergeMembers(pages [][]int64, size int) *model.Members {
+	set := make(map[int64]struct{})
+	for _, mids := range pages {
+		for _, mid := range mids {
+			set[mid] = struct{}{}
+		}
+	}
+	mids := make([]int64, 0, len(set))
+	for mid := range set {
+		mids = append(mids, mid)
+	}
+	sort.Slice(mids, func(i, j int) bool { return mids[i] < mids[j] })
+	res := &model.Members{Mids: mids}
+	if len(mids) >= size {
+		// every comet returns size at most, the ones after are not complete
+		res.Mids = mids[:size]
+		res.Next = mids[size-1]
+	}
+	return res
+}
+
+// OnlineMids get the mids of the app online in the order of the mids.
+func (l *Logic) OnlineMids(c context.Context, app string, mids []int64) (olMids []int64, err error) {
+	_, olMids, err = l.dao.KeysByMids(c, app, mids)
+	return
+}
+
 // OnlineTotal get all online.
 func (l *Logic) OnlineTotal(c context.Context) (int64, int64) {
 	return l.totalIPs, l.totalConns
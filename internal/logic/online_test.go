@@ -4,6 +4,8 @@ import (
 	"context"
 	"testing"
 
+	"github.com/Terry-Mao/goim/internal/logic/model"
+	"github.com/Terry-Mao/goim/pkg/hll"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,15 +23,48 @@ func TestOnline(t *testing.T) {
 		"test://room_02": 200,
 		"test://room_03": 300,
 	}
-	tops, err := lg.OnlineTop(c, typ, n)
+	lg.roomUsers = map[string]int32{
+		"test://room_01": 80,
+		"test://room_03": 250,
+	}
+	tops, err := lg.OnlineTop(c, "", typ, n)
 	assert.Nil(t, err)
 	assert.Equal(t, len(tops), 2)
-	onlines, err := lg.OnlineRoom(c, typ, rooms)
+	assert.Equal(t, tops[0].Users, int32(250))
+	onlines, err := lg.OnlineRoom(c, "", typ, rooms)
 	assert.Nil(t, err)
 	assert.Equal(t, onlines["room_01"], int32(100))
 	assert.Equal(t, onlines["room_02"], int32(200))
 	assert.Equal(t, onlines["room_03"], int32(300))
+	users, err := lg.OnlineRoomUsers(c, "", typ, rooms)
+	assert.Nil(t, err)
+	assert.Equal(t, &model.RoomOnline{Count: 100, Users: 80}, users["room_01"])
+	assert.Equal(t, &model.RoomOnline{Count: 200}, users["room_02"])
 	ips, conns := lg.OnlineTotal(c)
 	assert.Equal(t, ips, int64(100))
 	assert.Equal(t, conns, int64(200))
 }
+
+func TestMergeOnline(t *testing.T) {
+	var a, b hll.Sketch
+	a.Add(1)
+	a.Add(2)
+	b.Add(2)
+	b.Add(3)
+	lg.mergeOnline([]*model.Online{
+		{RoomCount: map[string]int32{"live://1": 4}, RoomUsers: map[string]*model.RoomUsers{"live://1": {Mids: a.Bytes(), Guests: 1}}},
+		{RoomCount: map[string]int32{"live://1": 3}, RoomUsers: map[string]*model.RoomUsers{"live://1": {Mids: b.Bytes(), Guests: 1}}},
+	})
+	// mid 2 on both comets counts once, the guests on each
+	assert.Equal(t, int32(7), lg.roomCount["live://1"])
+	assert.Equal(t, int32(5), lg.roomUsers["live://1"])
+}
+
+func TestMergeMembers(t *testing.T) {
+	res := mergeMembers([][]int64{{1, 3, 5}, {1, 2, 6}}, 3)
+	assert.Equal(t, []int64{1, 2, 3}, res.Mids)
+	assert.Equal(t, int64(3), res.Next)
+	res = mergeMembers([][]int64{{7}, {7, 8}}, 3)
+	assert.Equal(t, []int64{7, 8}, res.Mids)
+	assert.Equal(t, int64(0), res.Next)
+}
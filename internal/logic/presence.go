@@ -0,0 +1,150 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	pb "github.com/Terry-Mao/goim/api/logic"
+	"github.com/Terry-Mao/goim/api/protocol"
+	"github.com/Terry-Mao/goim/internal/logic/model"
+	log "github.com/golang/glog"
+)
+
+// roomPresence is the join/leave events of a room not pushed yet.
+type roomPresence struct {
+	joins  map[int64]struct{}
+	leaves map[int64]struct{}
+	since  time.Time
+}
+
+// add adds an event, a mid joined and left in a while is cancelled out.
+func (r *roomPresence) add(mid int64, join bool) {
+	in, out := r.joins, r.leaves
+	if !join {
+		in, out = out, in
+	}
+	if _, ok := out[mid]; ok {
+		delete(out, mid)
+		return
+	}
+	in[mid] = struct{}{}
+}
+
+// presence returns the events of the room, max mids listed at most.
+func (r *roomPresence) presence(roomID string, max int) *model.Presence {
+	return &model.Presence{
+		Room:   roomID,
+		Joins:  sortedMids(r.joins, max),
+		Leaves: sortedMids(r.leaves, max),
+		Joined: len(r.joins),
+		Left:   len(r.leaves),
+	}
+}
+
+func sortedMids(set map[int64]struct{}, max int) []int64 {
+	mids := make([]int64, 0, len(set))
+	for mid := range set {
+		mids = append(mids, mid)
+	}
+	sort.Slice(mids, func(i, j int) bool { return mids[i] < mids[j] })
+	if max >= 0 && len(mids) > max {
+		mids = mids[:max]
+	}
+	return mids
+}
+
+// member counts the comets of the mid in the room, it reports whether the
+// mid joins its first comet or leaves its last one.
+func (l *Logic) member(roomID, server string, mid int64, join bool) bool {
+	mids := l.members[roomID]
+	if mids == nil {
+		mids = make(map[int64]map[string]struct{})
+		l.members[roomID] = mids
+	}
+	servers := mids[mid]
+	if join {
+		if servers == nil {
+			servers = make(map[string]struct{})
+			mids[mid] = servers
+		}
+		_, ok := servers[server]
+		servers[server] = struct{}{}
+		return !ok && len(servers) == 1
+	}
+	// a leave of a comet not known (as a logic restarted) is reported if no
+	// other comet is known
+	delete(servers, server)
+	if len(servers) > 0 {
+		return false
+	}
+	delete(mids, mid)
+	if len(mids) == 0 {
+		delete(l.members, roomID)
+	}
+	return true
+}
+
+// Presence adds the room join/leave events reported by the comet server.
+func (l *Logic) Presence(c context.Context, server string, events []*pb.PresenceEvent) {
+	now := time.Now()
+	l.presenceMutex.Lock()
+	for _, e := range events {
+		if e.RoomID == "" || e.Mid == 0 || !l.member(e.RoomID, server, e.Mid, e.Join) {
+			continue
+		}
+		r, ok := l.presence[e.RoomID]
+		if !ok {
+			r = &roomPresence{joins: make(map[int64]struct{}), leaves: make(map[int64]struct{}), since: now}
+			l.presence[e.RoomID] = r
+		}
+		r.add(e.Mid, e.Join)
+	}
+	l.presenceMutex.Unlock()
+}
+
+// flushPresence returns the events of the rooms due at now, a room is due
+// in flush since the first event, or big flush if it's a big room.
+func (l *Logic) flushPresence(now time.Time) (ps []*model.Presence) {
+	c := l.c.Presence
+	l.presenceMutex.Lock()
+	for roomID, r := range l.presence {
+		flush := time.Duration(c.Flush)
+		if c.BigRoom > 0 && l.roomUsers[roomID] >= c.BigRoom {
+			flush = time.Duration(c.BigFlush)
+		}
+		if now.Sub(r.since) < flush {
+			continue
+		}
+		delete(l.presence, roomID)
+		if len(r.joins) == 0 && len(r.leaves) == 0 {
+			continue
+		}
+		ps = append(ps, r.presence(roomID, c.MaxMids))
+	}
+	l.presenceMutex.Unlock()
+	return
+}
+
+func (l *Logic) presenceproc() {
+	flush := time.Duration(l.c.Presence.Flush)
+	if flush <= 0 {
+		return
+	}
+	for {
+		time.Sleep(flush)
+		for _, p := range l.flushPresence(time.Now()) {
+			roomID := p.Room
+			// the clients name the room without the app
+			_, p.Room = model.SplitAppKey(roomID)
+			msg, err := json.Marshal(p)
+			if err != nil {
+				continue
+			}
+			if err = l.dao.BroadcastRoomMsg(context.Background(), protocol.OpPresence, roomID, 0, msg); err != nil {
+				log.Errorf("presence room:%s error(%v)", roomID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package logic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/Terry-Mao/goim/api/logic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoomPresence(t *testing.T) {
+	r := &roomPresence{joins: map[int64]struct{}{}, leaves: map[int64]struct{}{}}
+	r.add(3, true)
+	r.add(1, true)
+	r.add(2, true)
+	// a quick visit
+	r.add(2, false)
+	r.add(4, false)
+	p := r.presence("live://1", 1)
+	assert.Equal(t, []int64{1}, p.Joins)
+	assert.Equal(t, []int64{4}, p.Leaves)
+	assert.Equal(t, 2, p.Joined)
+	assert.Equal(t, 1, p.Left)
+}
+
+func TestFlushPresence(t *testing.T) {
+	c := context.TODO()
+	lg.roomUsers = map[string]int32{"live://big": lg.c.Presence.BigRoom}
+	lg.Presence(c, "comet1", []*pb.PresenceEvent{
+		{RoomID: "live://1", Mid: 1, Join: true},
+		{RoomID: "live://big", Mid: 2, Join: true},
+		{RoomID: "live://2", Mid: 3, Join: true},
+		{RoomID: "live://2", Mid: 3},
+	})
+	now := time.Now()
+	ps := lg.flushPresence(now.Add(time.Duration(lg.c.Presence.Flush)))
+	assert.Equal(t, 1, len(ps))
+	assert.Equal(t, "live://1", ps[0].Room)
+	ps = lg.flushPresence(now.Add(time.Duration(lg.c.Presence.BigFlush)))
+	assert.Equal(t, 1, len(ps))
+	assert.Equal(t, "live://big", ps[0].Room)
+}
+
+func TestPresenceComets(t *testing.T) {
+	c := context.TODO()
+	// the devices of a mid on two comets
+	lg.Presence(c, "comet1", []*pb.PresenceEvent{{RoomID: "live://3", Mid: 5, Join: true}})
+	lg.Presence(c, "comet2", []*pb.PresenceEvent{{RoomID: "live://3", Mid: 5, Join: true}})
+	lg.Presence(c, "comet1", []*pb.PresenceEvent{{RoomID: "live://3", Mid: 5}})
+	ps := lg.flushPresence(time.Now().Add(time.Duration(lg.c.Presence.Flush)))
+	assert.Equal(t, 1, len(ps))
+	assert.Equal(t, []int64{5}, ps[0].Joins)
+	assert.Equal(t, 0, ps[0].Left)
+	lg.Presence(c, "comet2", []*pb.PresenceEvent{{RoomID: "live://3", Mid: 5}})
+	ps = lg.flushPresence(time.Now().Add(time.Duration(lg.c.Presence.Flush)))
+	assert.Equal(t, 1, len(ps))
+	assert.Equal(t, []int64{5}, ps[0].Leaves)
+	assert.Equal(t, 0, len(lg.members["live://3"]))
+}
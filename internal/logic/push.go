@@ -2,71 +2,250 @@ package logic
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
+	"time"
 
+	"github.com/Terry-Mao/goim/api/protocol"
 	"github.com/Terry-Mao/goim/internal/logic/model"
 
 	log "github.com/golang/glog"
 )
 
-// PushKeys push a message by keys.
-func (l *Logic) PushKeys(c context.Context, op int32, keys []string, msg []byte) (err error) {
-	fmt.Fprintf(os.Stderr, "=== LOGIC PushKeys START: op=%d keys=%v msg_len=%d ===\n", op, keys, len(msg))
-	servers, err := l.dao.ServersByKeys(c, keys)
+// PushKeys push a message by keys of the app, a server failed doesn't stop
+// the others and the first error is returned.
+func (l *Logic) PushKeys(c context.Context, app string, op int32, keys []string, msg []byte) (err error) {
+	_, err = l.PushKeysResult(c, app, op, keys, msg)
+	return
+}
+
+// PushKeysResult push a message by keys of the app and returns the result of
+// each key, a key in a namespace is always offline.
+func (l *Logic) PushKeysResult(c context.Context, app string, op int32, keys []string, msg []byte) (rs []*model.KeyResult, err error) {
+	fmt.Fprintf(os.Stderr, "=== LOGIC PushKeys START: app=%s op=%d keys=%v msg_len=%d ===\n", app, op, keys, len(msg))
+	appKeys := make([]string, len(keys))
+	for i, key := range keys {
+		if !model.IsAppKey(key) {
+			appKeys[i] = appKey(app, key)
+		}
+	}
+	servers, err := l.dao.ServersByKeys(c, appKeys)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "=== LOGIC PushKeys ServersByKeys ERROR: %v ===\n", err)
 		return
 	}
 	fmt.Fprintf(os.Stderr, "=== LOGIC PushKeys servers: %v ===\n", servers)
-	pushKeys := make(map[string][]string)
+	rs = make([]*model.KeyResult, 0, len(keys))
 	for i, key := range keys {
-		server := servers[i]
-		fmt.Fprintf(os.Stderr, "=== LOGIC PushKeys key[%d]=%s server=%s ===\n", i, key, server)
-		if server != "" && key != "" {
-			pushKeys[server] = append(pushKeys[server], key)
+		r := &model.KeyResult{Key: key, Status: model.PushOffline}
+		if appKeys[i] != "" {
+			r.Server = servers[i]
 		}
+		rs = append(rs, r)
 	}
-	fmt.Fprintf(os.Stderr, "=== LOGIC PushKeys pushKeys map: %+v ===\n", pushKeys)
-	for server, serverKeys := range pushKeys {
-		fmt.Fprintf(os.Stderr, "=== LOGIC PushKeys calling PushMsg: server=%s keys=%v ===\n", server, serverKeys)
-		if err = l.dao.PushMsg(c, op, server, serverKeys, msg); err != nil {
-			fmt.Fprintf(os.Stderr, "=== LOGIC PushKeys PushMsg ERROR: %v ===\n", err)
-			return
-		}
+	if err = l.pushResults(c, app, op, rs, msg); err != nil {
+		fmt.Fprintf(os.Stderr, "=== LOGIC PushKeys PushMsg ERROR: %v ===\n", err)
+		return
 	}
 	fmt.Fprintf(os.Stderr, "=== LOGIC PushKeys SUCCESS ===\n")
 	return
 }
 
-// PushMids push a message by mid.
-func (l *Logic) PushMids(c context.Context, op int32, mids []int64, msg []byte) (err error) {
-	keyServers, _, err := l.dao.KeysByMids(c, mids)
+// PushMids push a message by mid of the app, a server failed doesn't stop
+// the others and the first error is returned.
+func (l *Logic) PushMids(c context.Context, app string, op int32, mids []int64, msg []byte) (err error) {
+	_, err = l.PushMidsResult(c, app, op, mids, msg)
+	return
+}
+
+// PushMidsResult push a message by mid of the app and returns the result of
+// each mid with its keys.
+func (l *Logic) PushMidsResult(c context.Context, app string, op int32, mids []int64, msg []byte) (rs []*model.MidResult, err error) {
+	servers, err := l.dao.ServersByMids(c, app, mids)
 	if err != nil {
 		return
 	}
-	keys := make(map[string][]string)
-	for key, server := range keyServers {
-		if key == "" || server == "" {
-			log.Warningf("push key:%s server:%s is empty", key, server)
+	var keys []*model.KeyResult
+	rs = make([]*model.MidResult, 0, len(mids))
+	for i, mid := range mids {
+		r := &model.MidResult{Mid: mid}
+		for key, server := range servers[i] {
+			if key == "" || server == "" {
+				log.Warningf("push key:%s server:%s is empty", key, server)
+				continue
+			}
+			_, key = model.SplitAppKey(key)
+			r.Keys = append(r.Keys, &model.KeyResult{Key: key, Status: model.PushOffline, Server: server})
+		}
+		sort.Slice(r.Keys, func(i, j int) bool { return r.Keys[i].Key < r.Keys[j].Key })
+		keys = append(keys, r.Keys...)
+		rs = append(rs, r)
+	}
+	err = l.pushResults(c, app, op, keys, msg)
+	for _, r := range rs {
+		r.Status = midStatus(r.Keys)
+	}
+	return
+}
+
+// serverPush is the keys of a server to push.
+type serverPush struct {
+	server string
+	keys   []string
+	rs     []*model.KeyResult
+}
+
+// groupByServer groups the results online by the servers in order, a key
+// repeated is pushed once.
+func groupByServer(rs []*model.KeyResult) (ps []*serverPush) {
+	var (
+		servers = make(map[string]*serverPush)
+		seen    = make(map[string]struct{})
+	)
+	for _, r := range rs {
+		if r.Server == "" {
 			continue
 		}
-		keys[server] = append(keys[server], key)
+		p, ok := servers[r.Server]
+		if !ok {
+			p = &serverPush{server: r.Server}
+			servers[r.Server] = p
+			ps = append(ps, p)
+		}
+		p.rs = append(p.rs, r)
+		if _, ok = seen[r.Key]; !ok {
+			seen[r.Key] = struct{}{}
+			p.keys = append(p.keys, r.Key)
+		}
+	}
+	return
+}
+
+// pushResults pushes the message to the servers of the results of the app
+// and sets their status, the first error is returned.
+func (l *Logic) pushResults(c context.Context, app string, op int32, rs []*model.KeyResult, msg []byte) (err error) {
+	for _, p := range groupByServer(rs) {
+		status, reason := model.PushEnqueued, ""
+		keys := make([]string, 0, len(p.keys))
+		for _, key := range p.keys {
+			keys = append(keys, model.AppKey(app, key))
+		}
+		if e := l.dao.PushMsg(c, op, p.server, keys, msg); e != nil {
+			log.Errorf("l.dao.PushMsg(%s,%v) error(%v)", p.server, p.keys, e)
+			status, reason = model.PushFailed, e.Error()
+			if err == nil {
+				err = e
+			}
+		}
+		for _, r := range p.rs {
+			r.Status = status
+			r.Error = reason
+		}
+	}
+	return
+}
+
+// midStatus is enqueued if any of the keys is, offline if there is no key.
+func midStatus(keys []*model.KeyResult) string {
+	if len(keys) == 0 {
+		return model.PushOffline
+	}
+	for _, k := range keys {
+		if k.Status == model.PushEnqueued {
+			return model.PushEnqueued
+		}
+	}
+	return model.PushFailed
+}
+
+const maxPushItems = 100
+
+var (
+	errPushItems  = fmt.Errorf("push items must be 1 to %d", maxPushItems)
+	errPushTarget = errors.New("push item has no keys or mids")
+)
+
+// PushBatch pushes the items of the app one by one, an item failed doesn't
+// stop the others and its error is in the result.
+func (l *Logic) PushBatch(c context.Context, app string, items []*model.PushItem) (rs []*model.PushItemResult, err error) {
+	if len(items) == 0 || len(items) > maxPushItems {
+		return nil, errPushItems
+	}
+	rs = make([]*model.PushItemResult, 0, len(items))
+	for _, item := range items {
+		var (
+			r   = new(model.PushItemResult)
+			msg = []byte(item.Msg)
+			e   error
+		)
+		rs = append(rs, r)
+		if len(item.Keys) == 0 && len(item.Mids) == 0 {
+			r.Error = errPushTarget.Error()
+			continue
+		}
+		if len(item.Keys) > 0 {
+			// the failed keys are in the results
+			if r.Keys, e = l.PushKeysResult(c, app, item.Op, item.Keys, msg); e != nil && r.Keys == nil {
+				r.Error = e.Error()
+			}
+		}
+		if len(item.Mids) > 0 {
+			if r.Mids, e = l.PushMidsResult(c, app, item.Op, item.Mids, msg); e != nil && r.Mids == nil {
+				r.Error = e.Error()
+			}
+		}
+	}
+	return
+}
+
+// PushRoom push a message by room of the app, the low priority ones are
+// throttled first by job if the room is over the budget.
+func (l *Logic) PushRoom(c context.Context, app string, op int32, typ, room string, priority int32, msg []byte) (err error) {
+	if model.IsAppKey(typ) {
+		return errAppKey
+	}
+	return l.dao.BroadcastRoomMsg(c, op, model.AppKey(app, model.EncodeRoomKey(typ, room)), priority, msg)
+}
+
+// PushAll push a message to all of the app.
+func (l *Logic) PushAll(c context.Context, app string, op, speed int32, msg []byte) (err error) {
+	return l.dao.BroadcastMsg(c, app, op, speed, msg)
+}
+
+var errConfigEmpty = errors.New("client config empty")
+
+// PushConfig pushes the runtime config to the live clients of the platform
+// in all the apps, empty for all.
+func (l *Logic) PushConfig(c context.Context, platform string, speed int32, cfg *model.ClientConfig) (err error) {
+	if cfg.Heartbeat <= 0 && cfg.HeartbeatMax <= 0 && cfg.Backoff == nil && len(cfg.Features) == 0 {
+		return errConfigEmpty
+	}
+	if cfg.Heartbeat > 0 && cfg.HeartbeatMax <= 0 {
+		// comet times out the heartbeat by the both
+		cfg.HeartbeatMax = int32(l.c.Node.HeartbeatMax)
+	}
+	msg, err := json.Marshal(cfg)
+	if err != nil {
+		return
 	}
-	for server, keys := range keys {
-		if err = l.dao.PushMsg(c, op, server, keys, msg); err != nil {
-			return
+	for _, app := range l.appIDs {
+		if e := l.dao.BroadcastPlatformMsg(c, app, protocol.OpConfigUpdate, speed, platform, msg); e != nil {
+			log.Errorf("l.dao.BroadcastPlatformMsg(%s,%s) error(%v)", app, platform, e)
+			err = e
 		}
 	}
 	return
 }
 
-// PushRoom push a message by room.
-func (l *Logic) PushRoom(c context.Context, op int32, typ, room string, msg []byte) (err error) {
-	return l.dao.BroadcastRoomMsg(c, op, model.EncodeRoomKey(typ, room), msg)
+// IncrPushQuota counts n pushes of the app in the day.
+func (l *Logic) IncrPushQuota(c context.Context, app, day string, n int64) (int64, error) {
+	return l.dao.IncrPushQuota(c, app, day, n)
 }
 
-// PushAll push a message to all.
-func (l *Logic) PushAll(c context.Context, op, speed int32, msg []byte) (err error) {
-	return l.dao.BroadcastMsg(c, op, speed, msg)
+// AddPushNonce keeps the nonce of a signed push request of the app in ttl.
+func (l *Logic) AddPushNonce(c context.Context, app, nonce string, ttl time.Duration) (bool, error) {
+	return l.dao.AddPushNonce(c, app, nonce, ttl)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/Terry-Mao/goim/internal/logic/model"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,7 +15,7 @@ func TestPushKeys(t *testing.T) {
 		keys = []string{"test_key"}
 		msg  = []byte("hello")
 	)
-	err := lg.PushKeys(c, op, keys, msg)
+	err := lg.PushKeys(c, "", op, keys, msg)
 	assert.Nil(t, err)
 }
 
@@ -25,7 +26,7 @@ func TestPushMids(t *testing.T) {
 		mids = []int64{1, 2, 3}
 		msg  = []byte("hello")
 	)
-	err := lg.PushMids(c, op, mids, msg)
+	err := lg.PushMids(c, "", op, mids, msg)
 	assert.Nil(t, err)
 }
 
@@ -37,7 +38,7 @@ func TestPushRoom(t *testing.T) {
 		room = "test_room"
 		msg  = []byte("hello")
 	)
-	err := lg.PushRoom(c, op, typ, room, msg)
+	err := lg.PushRoom(c, "", op, typ, room, 0, msg)
 	assert.Nil(t, err)
 }
 
@@ -48,6 +49,62 @@ func TestPushAll(t *testing.T) {
 		speed = int32(100)
 		msg   = []byte("hello")
 	)
-	err := lg.PushAll(c, op, speed, msg)
+	err := lg.PushAll(c, "", op, speed, msg)
 	assert.Nil(t, err)
 }
+
+func TestPushConfig(t *testing.T) {
+	c := context.TODO()
+	err := lg.PushConfig(c, "", 0, &model.ClientConfig{})
+	assert.Equal(t, errConfigEmpty, err)
+	cfg := &model.ClientConfig{Heartbeat: 60, Features: map[string]bool{"typing": true}}
+	err = lg.PushConfig(c, "android", 100, cfg)
+	assert.Nil(t, err)
+	assert.NotZero(t, cfg.HeartbeatMax)
+}
+
+func TestPushKeysResult(t *testing.T) {
+	rs, err := lg.PushKeysResult(context.TODO(), "", 100, []string{"test_key", ""}, []byte("hello"))
+	assert.Nil(t, err)
+	assert.Len(t, rs, 2)
+	assert.Equal(t, model.PushOffline, rs[1].Status)
+}
+
+func TestPushBatch(t *testing.T) {
+	c := context.TODO()
+	_, err := lg.PushBatch(c, "", nil)
+	assert.Equal(t, errPushItems, err)
+	rs, err := lg.PushBatch(c, "", []*model.PushItem{
+		{Op: 100, Keys: []string{"test_key"}, Mids: []int64{1}, Msg: "hello"},
+		{Op: 100, Msg: "hello"},
+	})
+	assert.Nil(t, err)
+	assert.Len(t, rs, 2)
+	assert.Len(t, rs[0].Keys, 1)
+	assert.Len(t, rs[0].Mids, 1)
+	assert.Equal(t, errPushTarget.Error(), rs[1].Error)
+}
+
+func TestGroupByServer(t *testing.T) {
+	rs := []*model.KeyResult{
+		{Key: "a", Server: "s1"},
+		{Key: "b", Server: "s2"},
+		{Key: "c"},
+		{Key: "d", Server: "s1"},
+		{Key: "a", Server: "s1"},
+	}
+	ps := groupByServer(rs)
+	assert.Len(t, ps, 2)
+	assert.Equal(t, "s1", ps[0].server)
+	assert.Equal(t, []string{"a", "d"}, ps[0].keys)
+	assert.Len(t, ps[0].rs, 3)
+	assert.Equal(t, []string{"b"}, ps[1].keys)
+}
+
+func TestMidStatus(t *testing.T) {
+	assert.Equal(t, model.PushOffline, midStatus(nil))
+	failed := &model.KeyResult{Key: "a", Status: model.PushFailed}
+	assert.Equal(t, model.PushFailed, midStatus([]*model.KeyResult{failed}))
+	enqueued := &model.KeyResult{Key: "b", Status: model.PushEnqueued}
+	assert.Equal(t, model.PushEnqueued, midStatus([]*model.KeyResult{failed, enqueued}))
+}
@@ -0,0 +1,71 @@
+package logic
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/Terry-Mao/goim/internal/logic/model"
+)
+
+var (
+	errScheduleTarget = errors.New("schedule target invalid")
+	errScheduleTime   = errors.New("schedule time invalid")
+)
+
+// Schedule adds a push delivered at its time, the ones due are delivered in
+// the next round.
+func (l *Logic) Schedule(c context.Context, s *model.Schedule) (id string, err error) {
+	switch s.Target {
+	case model.ScheduleKeys:
+		if len(s.Keys) == 0 {
+			return "", errScheduleTarget
+		}
+	case model.ScheduleMids:
+		if len(s.Mids) == 0 {
+			return "", errScheduleTarget
+		}
+	case model.ScheduleRoom:
+		if s.RoomType == "" || s.Room == "" {
+			return "", errScheduleTarget
+		}
+	case model.ScheduleAll:
+	default:
+		return "", errScheduleTarget
+	}
+	if s.At <= 0 || time.Until(time.Unix(s.At, 0)) > time.Duration(l.c.Schedule.MaxDelay) {
+		return "", errScheduleTime
+	}
+	b := make([]byte, 16)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	s.ID = hex.EncodeToString(b)
+	if err = l.dao.AddSchedule(c, s); err != nil {
+		return
+	}
+	return s.ID, nil
+}
+
+// CancelSchedule cancels a schedule, has reports whether it's not delivered.
+func (l *Logic) CancelSchedule(c context.Context, id string) (has bool, err error) {
+	s, err := l.dao.ClaimSchedule(c, id)
+	return s != nil, err
+}
+
+// pushSchedule delivers a schedule.
+func (l *Logic) pushSchedule(c context.Context, s *model.Schedule) error {
+	switch s.Target {
+	case model.ScheduleKeys:
+		return l.PushKeys(c, s.App, s.Op, s.Keys, s.Msg)
+	case model.ScheduleMids:
+		return l.PushMids(c, s.App, s.Op, s.Mids, s.Msg)
+	case model.ScheduleRoom:
+		return l.PushRoom(c, s.App, s.Op, s.RoomType, s.Room, 0, s.Msg)
+	case model.ScheduleAll:
+		return l.PushAll(c, s.App, s.Op, s.Speed, s.Msg)
+	}
+	return errScheduleTarget
+}
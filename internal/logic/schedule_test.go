@@ -0,0 +1,29 @@
+package logic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Terry-Mao/goim/internal/logic/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedule(t *testing.T) {
+	var (
+		c   = context.TODO()
+		now = time.Now().Unix()
+	)
+	_, err := lg.Schedule(c, &model.Schedule{Target: model.ScheduleKeys, Op: 1000, At: now})
+	assert.Equal(t, errScheduleTarget, err)
+	_, err = lg.Schedule(c, &model.Schedule{Target: model.ScheduleAll, Op: 1000})
+	assert.Equal(t, errScheduleTime, err)
+	_, err = lg.Schedule(c, &model.Schedule{Target: model.ScheduleAll, Op: 1000, At: now + int64(time.Duration(lg.c.Schedule.MaxDelay)/time.Second) + 60})
+	assert.Equal(t, errScheduleTime, err)
+	id, err := lg.Schedule(c, &model.Schedule{Target: model.ScheduleRoom, Op: 1000, RoomType: "test", Room: "1", Msg: []byte("msg"), At: now + 3600})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, id)
+	has, err := lg.CancelSchedule(c, id)
+	assert.Nil(t, err)
+	assert.True(t, has)
+}
@@ -0,0 +1,125 @@
+// Package scheduler delivers the scheduled pushes, only the leader holding
+// the lock in the store runs them.
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/Terry-Mao/goim/internal/logic/conf"
+	"github.com/Terry-Mao/goim/internal/logic/model"
+	log "github.com/golang/glog"
+)
+
+// Clock is the time source, it's faked in the tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// RealClock is the wall clock.
+var RealClock Clock = realClock{}
+
+// Store persists the schedules.
+type Store interface {
+	// LockSchedule takes or renews the leader lock of the owner in ttl.
+	LockSchedule(c context.Context, owner string, ttl time.Duration) (bool, error)
+	// DueSchedules get the ids of the schedules due at now, limit at most.
+	DueSchedules(c context.Context, now time.Time, limit int) ([]string, error)
+	// ClaimSchedule removes the schedule atomically, it returns the one
+	// removed by the call, nil if none.
+	ClaimSchedule(c context.Context, id string) (*model.Schedule, error)
+}
+
+// PushFunc delivers a schedule.
+type PushFunc func(c context.Context, s *model.Schedule) error
+
+// Scheduler runs the schedules due every interval if it's the leader, a
+// schedule is delivered once at most: it's claimed before the push and not
+// retried if the push fails.
+type Scheduler struct {
+	c      *conf.Schedule
+	owner  string
+	store  Store
+	clock  Clock
+	push   PushFunc
+	leader bool
+	closed chan struct{}
+}
+
+// New new a scheduler of the owner.
+func New(c *conf.Schedule, owner string, store Store, clock Clock, push PushFunc) *Scheduler {
+	return &Scheduler{
+		c:      c,
+		owner:  owner,
+		store:  store,
+		clock:  clock,
+		push:   push,
+		closed: make(chan struct{}),
+	}
+}
+
+// Start starts the scheduler.
+func (s *Scheduler) Start() {
+	go s.run()
+}
+
+// Close stops the scheduler.
+func (s *Scheduler) Close() {
+	close(s.closed)
+}
+
+func (s *Scheduler) run() {
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-s.clock.After(time.Duration(s.c.Interval)):
+			s.tick(context.Background())
+		}
+	}
+}
+
+// tick delivers the schedules due if it's the leader, returns the count.
+func (s *Scheduler) tick(c context.Context) (n int) {
+	ok, err := s.store.LockSchedule(c, s.owner, time.Duration(s.c.LockTTL))
+	if err != nil {
+		return
+	}
+	if ok != s.leader {
+		log.Infof("scheduler owner:%s leader:%t", s.owner, ok)
+		s.leader = ok
+	}
+	if !ok {
+		return
+	}
+	for {
+		ids, err := s.store.DueSchedules(c, s.clock.Now(), s.c.Batch)
+		if err != nil || len(ids) == 0 {
+			return
+		}
+		for _, id := range ids {
+			var sch *model.Schedule
+			if sch, err = s.store.ClaimSchedule(c, id); err != nil {
+				return
+			}
+			if sch == nil {
+				// claimed by the former leader or canceled
+				continue
+			}
+			if err = s.push(c, sch); err != nil {
+				log.Errorf("schedule:%s push error(%v)", sch.ID, err)
+				continue
+			}
+			n++
+		}
+		if len(ids) < s.c.Batch {
+			return
+		}
+	}
+}
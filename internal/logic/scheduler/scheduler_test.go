@@ -0,0 +1,187 @@
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Terry-Mao/goim/internal/logic/conf"
+	"github.com/Terry-Mao/goim/internal/logic/model"
+	xtime "github.com/Terry-Mao/goim/pkg/time"
+)
+
+// fakeClock is a clock advanced by the test.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After fires on the next Advance.
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, ch)
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	waiters := c.waiters
+	c.waiters = nil
+	c.mu.Unlock()
+	for _, ch := range waiters {
+		ch <- c.now
+	}
+}
+
+// fakeStore is a store in memory with a lock expiring by the clock.
+type fakeStore struct {
+	mu        sync.Mutex
+	clock     *fakeClock
+	schedules map[string]*model.Schedule
+	owner     string
+	expire    time.Time
+}
+
+func newFakeStore(clock *fakeClock) *fakeStore {
+	return &fakeStore{clock: clock, schedules: make(map[string]*model.Schedule)}
+}
+
+func (s *fakeStore) add(id string, at time.Time) {
+	s.mu.Lock()
+	s.schedules[id] = &model.Schedule{ID: id, At: at.Unix()}
+	s.mu.Unlock()
+}
+
+func (s *fakeStore) LockSchedule(c context.Context, owner string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	if s.owner != owner && now.Before(s.expire) {
+		return false, nil
+	}
+	s.owner, s.expire = owner, now.Add(ttl)
+	return true, nil
+}
+
+func (s *fakeStore) DueSchedules(c context.Context, now time.Time, limit int) (ids []string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ss []*model.Schedule
+	for _, sch := range s.schedules {
+		if sch.At <= now.Unix() {
+			ss = append(ss, sch)
+		}
+	}
+	sort.Slice(ss, func(i, j int) bool { return ss[i].At < ss[j].At })
+	if len(ss) > limit {
+		ss = ss[:limit]
+	}
+	for _, sch := range ss {
+		ids = append(ids, sch.ID)
+	}
+	return
+}
+
+func (s *fakeStore) ClaimSchedule(c context.Context, id string) (*model.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sch := s.schedules[id]
+	delete(s.schedules, id)
+	return sch, nil
+}
+
+func newTestScheduler(owner string, store Store, clock Clock, pushed chan string) *Scheduler {
+	c := &conf.Schedule{Interval: xtime.Duration(time.Second), LockTTL: xtime.Duration(10 * time.Second), Batch: 2}
+	return New(c, owner, store, clock, func(c context.Context, s *model.Schedule) error {
+		pushed <- s.ID
+		return nil
+	})
+}
+
+func TestSchedulerTick(t *testing.T) {
+	var (
+		clock  = &fakeClock{now: time.Unix(1000, 0)}
+		store  = newFakeStore(clock)
+		pushed = make(chan string, 10)
+		s      = newTestScheduler("a", store, clock, pushed)
+	)
+	store.add("1", clock.Now())
+	store.add("2", clock.Now().Add(-time.Minute))
+	store.add("3", clock.Now())
+	store.add("later", clock.Now().Add(time.Minute))
+	// all the due ones in batches, the oldest first
+	if n := s.tick(context.Background()); n != 3 || <-pushed != "2" {
+		t.Fatalf("pushed %d want 3", n)
+	}
+	<-pushed
+	<-pushed
+	if n := s.tick(context.Background()); n != 0 {
+		t.Fatalf("pushed %d twice", n)
+	}
+	clock.Advance(time.Minute)
+	if n := s.tick(context.Background()); n != 1 || <-pushed != "later" {
+		t.Fatalf("pushed %d want the later one", n)
+	}
+}
+
+func TestSchedulerLeader(t *testing.T) {
+	var (
+		clock  = &fakeClock{now: time.Unix(1000, 0)}
+		store  = newFakeStore(clock)
+		pushed = make(chan string, 10)
+		a      = newTestScheduler("a", store, clock, pushed)
+		b      = newTestScheduler("b", store, clock, pushed)
+	)
+	store.add("1", clock.Now())
+	if n := b.tick(context.Background()); n != 1 {
+		t.Fatalf("leader pushed %d", n)
+	}
+	store.add("2", clock.Now())
+	if n := a.tick(context.Background()); n != 0 {
+		t.Fatalf("follower pushed %d", n)
+	}
+	// the leader is gone
+	clock.Advance(11 * time.Second)
+	if n := a.tick(context.Background()); n != 1 || !a.leader {
+		t.Fatalf("new leader pushed %d", n)
+	}
+	if n := b.tick(context.Background()); n != 0 || b.leader {
+		t.Fatalf("former leader pushed %d", n)
+	}
+}
+
+func TestSchedulerRun(t *testing.T) {
+	var (
+		clock  = &fakeClock{now: time.Unix(1000, 0)}
+		store  = newFakeStore(clock)
+		pushed = make(chan string, 10)
+		s      = newTestScheduler("a", store, clock, pushed)
+	)
+	store.add("1", clock.Now().Add(time.Second))
+	s.Start()
+	defer s.Close()
+	for deadline := time.Now().Add(time.Second); len(pushed) == 0 && time.Now().Before(deadline); {
+		clock.Advance(time.Second)
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case id := <-pushed:
+		if id != "1" {
+			t.Fatalf("pushed %s", id)
+		}
+	default:
+		t.Fatal("schedule must be pushed on time")
+	}
+}
@@ -11,10 +11,14 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"net"
 )
 
 const (
 	defaultBufSize = 4096
+	// minNoCopySize is the smallest slice queued by WriteNoCopy, the smaller
+	// ones are cheaper to copy.
+	minNoCopySize = 512
 )
 
 var (
@@ -353,6 +357,11 @@ type Writer struct {
 	buf []byte
 	n   int
 	wr  io.Writer
+	// queued slices written by writev on flush, buf[off:n] is not queued yet
+	bufs   net.Buffers
+	iov    net.Buffers // consumed by writev
+	off    int
+	queued int
 }
 
 // NewWriterSize returns a new Writer whose buffer has at least the specified
@@ -384,6 +393,7 @@ func (b *Writer) Reset(w io.Writer) {
 	b.err = nil
 	b.n = 0
 	b.wr = w
+	b.resetQueue()
 }
 
 // ResetBuffer discards any unflushed buffered data, clears any error, and
@@ -393,6 +403,16 @@ func (b *Writer) ResetBuffer(w io.Writer, buf []byte) {
 	b.err = nil
 	b.n = 0
 	b.wr = w
+	b.resetQueue()
+}
+
+func (b *Writer) resetQueue() {
+	for i := range b.bufs {
+		b.bufs[i] = nil
+	}
+	b.bufs = b.bufs[:0]
+	b.off = 0
+	b.queued = 0
 }
 
 // Flush writes any buffered data to the underlying io.Writer.
@@ -405,6 +425,9 @@ func (b *Writer) flush() error {
 	if b.err != nil {
 		return b.err
 	}
+	if len(b.bufs) > 0 {
+		return b.flushQueue()
+	}
 	if b.n == 0 {
 		return nil
 	}
@@ -424,11 +447,50 @@ func (b *Writer) flush() error {
 	return nil
 }
 
+// flushQueue writes the queued slices and the rest of the buffer in one
+// writev if the underlying io.Writer is a net.Conn. The unwritten data is
+// discarded on error.
+func (b *Writer) flushQueue() error {
+	if b.n > b.off {
+		b.bufs = append(b.bufs, b.buf[b.off:b.n])
+	}
+	size := int64(b.queued + b.n)
+	b.iov = b.bufs
+	n, err := b.iov.WriteTo(b.wr)
+	if n < size && err == nil {
+		err = io.ErrShortWrite
+	}
+	b.n = 0
+	b.resetQueue()
+	b.err = err
+	return err
+}
+
 // Available returns how many bytes are unused in the buffer.
 func (b *Writer) Available() int { return len(b.buf) - b.n }
 
-// Buffered returns the number of bytes that have been written into the current buffer.
-func (b *Writer) Buffered() int { return b.n }
+// Buffered returns the number of bytes that have been written into the
+// current buffer or queued.
+func (b *Writer) Buffered() int { return b.n + b.queued }
+
+// WriteNoCopy queues p to be written on flush without copying it into the
+// buffer, p must not be modified until the writer is flushed. The small
+// slices are copied as Write does.
+func (b *Writer) WriteNoCopy(p []byte) (nn int, err error) {
+	if len(p) < minNoCopySize {
+		return b.Write(p)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.n > b.off {
+		b.bufs = append(b.bufs, b.buf[b.off:b.n])
+		b.off = b.n
+	}
+	b.bufs = append(b.bufs, p)
+	b.queued += len(p)
+	return len(p), nil
+}
 
 // Write writes the contents of p into the buffer.
 // It returns the number of bytes written.
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"strings"
 	"testing"
 	"testing/iotest"
@@ -1008,3 +1009,84 @@ func BenchmarkWriterFlush(b *testing.B) {
 		_ = bw.Flush()
 	}
 }
+
+func TestWriteNoCopy(t *testing.T) {
+	var (
+		buf   bytes.Buffer
+		small = []byte("header")
+		large = bytes.Repeat([]byte("x"), 1024)
+	)
+	bw := NewWriterSize(&buf, 16)
+	bw.Write(small)
+	if n, err := bw.WriteNoCopy(large); n != len(large) || err != nil {
+		t.Fatalf("WriteNoCopy() n(%d) error(%v)", n, err)
+	}
+	bw.WriteNoCopy(small)
+	if n := bw.Buffered(); n != 2*len(small)+len(large) {
+		t.Fatalf("Buffered() %d", n)
+	}
+	// the buffer is full, flushed with the queued slice
+	bw.Write(bytes.Repeat([]byte("y"), 8))
+	if err := bw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := string(small) + string(large) + string(small) + strings.Repeat("y", 8)
+	if buf.String() != want {
+		t.Fatalf("got %d bytes want %d", buf.Len(), len(want))
+	}
+	if bw.Buffered() != 0 {
+		t.Fatalf("Buffered() %d after flush", bw.Buffered())
+	}
+	bw.Reset(errorWriterTest{0, 1, io.ErrClosedPipe, nil})
+	bw.WriteNoCopy(large)
+	if err := bw.Flush(); err != io.ErrClosedPipe {
+		t.Fatalf("Flush() error(%v)", err)
+	}
+}
+
+// benchmarkWritev writes batches of header and body to a loopback tcp conn.
+func benchmarkWritev(b *testing.B, size int, noCopy bool) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer lis.Close()
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		io.Copy(ioutil.Discard, conn)
+	}()
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+	var (
+		bw     = NewWriterSize(conn, 8192)
+		header = make([]byte, 16)
+		body   = bytes.Repeat([]byte("x"), size)
+	)
+	b.ReportAllocs()
+	b.SetBytes(int64(10 * (len(header) + size)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 10; j++ {
+			bw.Write(header)
+			if noCopy {
+				bw.WriteNoCopy(body)
+			} else {
+				bw.Write(body)
+			}
+		}
+		if err = bw.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriterCopy1K(b *testing.B)    { benchmarkWritev(b, 1<<10, false) }
+func BenchmarkWriterNoCopy1K(b *testing.B)  { benchmarkWritev(b, 1<<10, true) }
+func BenchmarkWriterCopy16K(b *testing.B)   { benchmarkWritev(b, 16<<10, false) }
+func BenchmarkWriterNoCopy16K(b *testing.B) { benchmarkWritev(b, 16<<10, true) }
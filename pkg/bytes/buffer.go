@@ -22,6 +22,11 @@ type Pool struct {
 	max  int
 	num  int
 	size int
+	// stats
+	hits   int64
+	misses int64
+	grows  int64
+	inuse  int64
 }
 
 // NewPool new a memory buffer pool struct.
@@ -52,6 +57,7 @@ func (p *Pool) grow() {
 		bs  []Buffer
 		buf []byte
 	)
+	p.grows++
 	buf = make([]byte, p.max)
 	bs = make([]Buffer, p.num)
 	p.free = &bs[0]
@@ -71,8 +77,12 @@ func (p *Pool) Get() (b *Buffer) {
 	if b = p.free; b == nil {
 		p.grow()
 		b = p.free
+		p.misses++
+	} else {
+		p.hits++
 	}
 	p.free = b.next
+	p.inuse++
 	p.lock.Unlock()
 	return
 }
@@ -82,5 +92,14 @@ func (p *Pool) Put(b *Buffer) {
 	p.lock.Lock()
 	b.next = p.free
 	p.free = b
+	p.inuse--
 	p.lock.Unlock()
 }
+
+// Stats returns the utilization of the pool.
+func (p *Pool) Stats() (s PoolStat) {
+	p.lock.Lock()
+	s = PoolStat{Size: p.size, Hits: p.hits, Misses: p.misses, Grows: p.grows, InUse: p.inuse}
+	p.lock.Unlock()
+	return
+}
@@ -0,0 +1,129 @@
+package bytes
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+// PoolStat is the utilization of a pool or a size class of a pool.
+type PoolStat struct {
+	Size   int   `json:"size"`
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	Grows  int64 `json:"grows"`
+	InUse  int64 `json:"in_use"`
+}
+
+// ClassStats is the utilization of a ClassPool, Oversize counts the buffers
+// larger than the largest class which are not pooled.
+type ClassStats struct {
+	Classes  []PoolStat `json:"classes"`
+	Oversize int64      `json:"oversize"`
+}
+
+type sizeClass struct {
+	pool   sync.Pool
+	size   int
+	hits   int64
+	misses int64
+	grows  int64
+	inuse  int64
+}
+
+// ClassPool is a pool of reference counted buffers in power of two size
+// classes from min to max.
+type ClassPool struct {
+	shift    uint
+	classes  []*sizeClass
+	oversize int64
+}
+
+// NewClassPool new a size classed buffer pool, min and max are rounded up
+// to powers of two.
+func NewClassPool(min, max int) (p *ClassPool) {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	p = &ClassPool{shift: uint(bits.Len(uint(min - 1)))}
+	for size := 1 << p.shift; ; size <<= 1 {
+		p.classes = append(p.classes, &sizeClass{size: size})
+		if size >= max {
+			break
+		}
+	}
+	return
+}
+
+// class returns the smallest class of n bytes, nil if n is too large.
+func (p *ClassPool) class(n int) *sizeClass {
+	i := 0
+	if n > 1 {
+		if i = bits.Len(uint(n-1)) - int(p.shift); i < 0 {
+			i = 0
+		}
+	}
+	if i >= len(p.classes) {
+		return nil
+	}
+	return p.classes[i]
+}
+
+// Get get a buffer of n bytes with one reference.
+func (p *ClassPool) Get(n int) (b *RefBuffer) {
+	c := p.class(n)
+	if c == nil {
+		atomic.AddInt64(&p.oversize, 1)
+		return &RefBuffer{buf: make([]byte, n), refs: 1, pool: p}
+	}
+	if v := c.pool.Get(); v != nil {
+		b = v.(*RefBuffer)
+		atomic.AddInt64(&c.hits, 1)
+	} else {
+		b = &RefBuffer{buf: make([]byte, 0, c.size), pool: p}
+		atomic.AddInt64(&c.misses, 1)
+	}
+	atomic.AddInt64(&c.inuse, 1)
+	b.buf = b.buf[:n]
+	b.refs = 1
+	return
+}
+
+// grow gets a buffer of n bytes for the content of b which is released.
+func (p *ClassPool) grow(b *RefBuffer, n int) (nb *RefBuffer) {
+	if c := p.class(cap(b.buf)); c != nil && c.size == cap(b.buf) {
+		atomic.AddInt64(&c.grows, 1)
+	}
+	nb = p.Get(n)
+	copy(nb.buf, b.buf)
+	b.Release()
+	return
+}
+
+func (p *ClassPool) put(b *RefBuffer) {
+	c := p.class(cap(b.buf))
+	if c == nil || c.size != cap(b.buf) {
+		return
+	}
+	atomic.AddInt64(&c.inuse, -1)
+	c.pool.Put(b)
+}
+
+// Stats returns the utilization of the pool.
+func (p *ClassPool) Stats() (s ClassStats) {
+	s.Classes = make([]PoolStat, 0, len(p.classes))
+	for _, c := range p.classes {
+		s.Classes = append(s.Classes, PoolStat{
+			Size:   c.size,
+			Hits:   atomic.LoadInt64(&c.hits),
+			Misses: atomic.LoadInt64(&c.misses),
+			Grows:  atomic.LoadInt64(&c.grows),
+			InUse:  atomic.LoadInt64(&c.inuse),
+		})
+	}
+	s.Oversize = atomic.LoadInt64(&p.oversize)
+	return
+}
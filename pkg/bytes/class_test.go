@@ -0,0 +1,99 @@
+package bytes
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestClassPool(t *testing.T) {
+	p := NewClassPool(100, 1000)
+	if n := len(p.classes); n != 4 {
+		t.Fatalf("classes %d want 4", n)
+	}
+	for _, c := range []struct{ n, cap int }{{0, 128}, {1, 128}, {128, 128}, {129, 256}, {1024, 1024}, {1025, 1025}} {
+		b := p.Get(c.n)
+		if len(b.Bytes()) != c.n || cap(b.Bytes()) != c.cap {
+			t.Fatalf("Get(%d) len %d cap %d want cap %d", c.n, len(b.Bytes()), cap(b.Bytes()), c.cap)
+		}
+		b.Release()
+	}
+	b := p.Get(200)
+	s := p.Stats()
+	if c := s.Classes[1]; c.Size != 256 || c.InUse != 1 || c.Hits+c.Misses != 2 {
+		t.Fatalf("class stat %+v", c)
+	}
+	if s.Oversize != 1 {
+		t.Fatalf("oversize %d want 1", s.Oversize)
+	}
+	copy(b.Bytes(), "hello")
+	nb := p.grow(b, 300)
+	if !bytes.HasPrefix(nb.Bytes(), []byte("hello")) || cap(nb.Bytes()) != 512 {
+		t.Fatalf("grow %q cap %d", nb.Bytes()[:5], cap(nb.Bytes()))
+	}
+	nb.Release()
+	s = p.Stats()
+	if c := s.Classes[1]; c.Grows != 1 || c.InUse != 0 {
+		t.Fatalf("class stat %+v", c)
+	}
+}
+
+func TestPoolWriter(t *testing.T) {
+	p := NewClassPool(16, 1024)
+	w := NewPoolWriter(p, 16)
+	for i := 0; i < 10; i++ {
+		w.Write([]byte("hello"))
+	}
+	b := w.Detach()
+	if string(b.Bytes()) != string(bytes.Repeat([]byte("hello"), 10)) {
+		t.Fatalf("detach %q", b.Bytes())
+	}
+	// reused after detach
+	w.Write([]byte("world"))
+	if string(w.Buffer()) != "world" {
+		t.Fatalf("buffer %q", w.Buffer())
+	}
+	b.Release()
+	w.Release()
+	var inuse int64
+	for _, c := range p.Stats().Classes {
+		inuse += c.InUse
+	}
+	if inuse != 0 {
+		t.Fatalf("in use %d want 0", inuse)
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	p := NewPool(1, 10)
+	b := p.Get()
+	p.Get()
+	p.Put(b)
+	if s := p.Stats(); s.Size != 10 || s.Hits != 1 || s.Misses != 1 || s.Grows != 2 || s.InUse != 1 {
+		t.Fatalf("pool stat %+v", s)
+	}
+}
+
+var batch = bytes.Repeat([]byte("x"), 200)
+
+func BenchmarkWriterRenew(b *testing.B) {
+	b.ReportAllocs()
+	w := NewWriterSize(4096)
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 20; j++ {
+			w.Write(batch)
+		}
+		_ = w.Buffer()
+		w = NewWriterSize(w.Size())
+	}
+}
+
+func BenchmarkPoolWriter(b *testing.B) {
+	b.ReportAllocs()
+	w := NewPoolWriter(NewClassPool(1024, 1<<20), 4096)
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 20; j++ {
+			w.Write(batch)
+		}
+		w.Detach().Release()
+	}
+}
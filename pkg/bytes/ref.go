@@ -0,0 +1,37 @@
+package bytes
+
+import "sync/atomic"
+
+// RefBuffer is a reference counted buffer, it's put back to the pool when
+// the last reference is released.
+type RefBuffer struct {
+	buf  []byte
+	refs int32
+	pool refPool
+}
+
+// refPool puts back the buffers of which the last reference is released.
+type refPool interface {
+	put(b *RefBuffer)
+}
+
+// Bytes bytes.
+func (b *RefBuffer) Bytes() []byte {
+	return b.buf
+}
+
+// Retain adds a reference.
+func (b *RefBuffer) Retain() {
+	atomic.AddInt32(&b.refs, 1)
+}
+
+// Release drops a reference, the buffer must not be used after the last
+// reference is released.
+func (b *RefBuffer) Release() {
+	refs := atomic.AddInt32(&b.refs, -1)
+	if refs == 0 {
+		b.pool.put(b)
+	} else if refs < 0 {
+		panic("bytes: RefBuffer released too many times")
+	}
+}
@@ -0,0 +1,20 @@
+package bytes
+
+import "testing"
+
+func TestRefBuffer(t *testing.T) {
+	p := NewClassPool(16, 64)
+	b := p.Get(8)
+	if len(b.Bytes()) != 8 {
+		t.Fatalf("len %d want 8", len(b.Bytes()))
+	}
+	b.Retain()
+	b.Release()
+	b.Release()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("over release must panic")
+		}
+	}()
+	b.Release()
+}
@@ -4,6 +4,10 @@ package bytes
 type Writer struct {
 	n   int
 	buf []byte
+	// pooled
+	size int
+	ref  *RefBuffer
+	pool *ClassPool
 }
 
 // NewWriterSize new a writer with size.
@@ -11,6 +15,39 @@ func NewWriterSize(n int) *Writer {
 	return &Writer{buf: make([]byte, n)}
 }
 
+// NewPoolWriter new a writer of which the buffers are got from the pool,
+// the writer can be reused after Detach.
+func NewPoolWriter(p *ClassPool, n int) (w *Writer) {
+	w = &Writer{size: n, pool: p}
+	w.get(n)
+	return
+}
+
+func (w *Writer) get(n int) {
+	w.ref = w.pool.Get(n)
+	w.ref.buf = w.ref.buf[:cap(w.ref.buf)]
+	w.buf = w.ref.buf
+}
+
+// Detach returns the written buffer which the caller must release, the
+// writer gets a new buffer from the pool on the next write.
+func (w *Writer) Detach() (b *RefBuffer) {
+	if b = w.ref; b == nil {
+		b = w.pool.Get(0)
+	}
+	b.buf = b.buf[:w.n]
+	w.ref, w.buf, w.n = nil, nil, 0
+	return
+}
+
+// Release puts back the buffer of a pooled writer.
+func (w *Writer) Release() {
+	if w.ref != nil {
+		w.ref.Release()
+		w.ref, w.buf, w.n = nil, nil, 0
+	}
+}
+
 // Len buff len.
 func (w *Writer) Len() int {
 	return w.n
@@ -51,6 +88,20 @@ func (w *Writer) grow(n int) {
 	if w.n+n < len(w.buf) {
 		return
 	}
+	if w.pool != nil {
+		if w.ref == nil {
+			if n < w.size {
+				n = w.size
+			}
+			w.get(n)
+			return
+		}
+		w.ref.buf = w.ref.buf[:w.n]
+		w.ref = w.pool.grow(w.ref, 2*len(w.buf)+n)
+		w.ref.buf = w.ref.buf[:cap(w.ref.buf)]
+		w.buf = w.ref.buf
+		return
+	}
 	buf = make([]byte, 2*len(w.buf)+n)
 	copy(buf, w.buf[:w.n])
 	w.buf = buf
@@ -30,3 +30,20 @@ func (bigEndian) PutInt32(b []byte, v int32) {
 	b[2] = byte(v >> 8)
 	b[3] = byte(v)
 }
+
+func (bigEndian) Int64(b []byte) int64 {
+	return int64(b[7]) | int64(b[6])<<8 | int64(b[5])<<16 | int64(b[4])<<24 |
+		int64(b[3])<<32 | int64(b[2])<<40 | int64(b[1])<<48 | int64(b[0])<<56
+}
+
+func (bigEndian) PutInt64(b []byte, v int64) {
+	_ = b[7]
+	b[0] = byte(v >> 56)
+	b[1] = byte(v >> 48)
+	b[2] = byte(v >> 40)
+	b[3] = byte(v >> 32)
+	b[4] = byte(v >> 24)
+	b[5] = byte(v >> 16)
+	b[6] = byte(v >> 8)
+	b[7] = byte(v)
+}
@@ -28,3 +28,12 @@ func TestInt32(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestInt64(t *testing.T) {
+	b := make([]byte, 8)
+	BigEndian.PutInt64(b, 1<<40+100)
+	i := BigEndian.Int64(b)
+	if i != 1<<40+100 {
+		t.FailNow()
+	}
+}
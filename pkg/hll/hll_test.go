@@ -0,0 +1,48 @@
+package hll
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSketchSparse(t *testing.T) {
+	var a, b Sketch
+	for i := int64(0); i < 100; i++ {
+		a.Add(i)
+		a.Add(i)
+		b.Add(i + 50)
+	}
+	if err := a.Merge(b.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if a.Count() != 150 {
+		t.Fatalf("count %d want 150", a.Count())
+	}
+}
+
+func TestSketchDense(t *testing.T) {
+	const n = 100000
+	var a, b, c Sketch
+	for i := int64(0); i < n; i++ {
+		a.Add(i)
+		b.Add(i + n/2)
+	}
+	for _, s := range []*Sketch{&a, &b} {
+		if err := c.Merge(s.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 3 standard errors
+	if got := float64(c.Count()); math.Abs(got-n*1.5)/(n*1.5) > 0.1 {
+		t.Fatalf("count %v want about %d", got, n*3/2)
+	}
+	// a sparse one into a dense one
+	var d Sketch
+	d.Add(-1)
+	if err := c.Merge(d.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Merge([]byte{tagDense, 1}); err != ErrSketch {
+		t.Fatalf("merge error(%v) want %v", err, ErrSketch)
+	}
+}
@@ -0,0 +1,478 @@
+package mqtt
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/Terry-Mao/goim/pkg/bufio"
+)
+
+// Control packet types defined in MQTT 3.1.1, section 2.2.1.
+const (
+	CONNECT     = 1
+	CONNACK     = 2
+	PUBLISH     = 3
+	PUBACK      = 4
+	SUBSCRIBE   = 8
+	SUBACK      = 9
+	UNSUBSCRIBE = 10
+	UNSUBACK    = 11
+	PINGREQ     = 12
+	PINGRESP    = 13
+	DISCONNECT  = 14
+)
+
+// Connect return codes defined in MQTT 3.1.1, section 3.2.2.3.
+const (
+	Accepted                   = 0
+	RefusedProtocolVersion     = 1
+	RefusedIdentifierRejected  = 2
+	RefusedServerUnavailable   = 3
+	RefusedBadUsernamePassword = 4
+	RefusedNotAuthorized       = 5
+)
+
+// SubackFailure is the return code of a failed subscription.
+const SubackFailure = 0x80
+
+const (
+	protocolName  = "MQTT"
+	protocolLevel = 4
+	maxQoS        = 1
+	// remaining length is at most 4 bytes
+	maxLengthBytes = 4
+	// offset of the flags in the CONNECT variable header
+	connectFlagsOffset = 7
+	// connect flags
+	connectUsername     = 1 << 7
+	connectPassword     = 1 << 6
+	connectWillRetain   = 1 << 5
+	connectWillQoSShift = 3
+	connectWill         = 1 << 2
+	connectCleanSession = 1 << 1
+	connectReserved     = 1 << 0
+	// publish flags
+	publishDup      = 1 << 3
+	publishQoSShift = 1
+	publishQoSMask  = 0x06
+	publishRetain   = 1 << 0
+	// SUBSCRIBE and UNSUBSCRIBE fixed header flags
+	subscribeFlags = 0x02
+)
+
+var (
+	// ErrMalformed the packet is malformed
+	ErrMalformed = errors.New("mqtt: malformed packet")
+	// ErrPacketType the packet type is not supported
+	ErrPacketType = errors.New("mqtt: unsupported packet type")
+	// ErrProtocolLevel the protocol level is not 3.1.1
+	ErrProtocolLevel = errors.New("mqtt: unsupported protocol level")
+	// ErrQoS the qos is not supported
+	ErrQoS = errors.New("mqtt: unsupported qos")
+)
+
+// Packet is a MQTT control packet.
+type Packet interface {
+	// Type returns the control packet type.
+	Type() byte
+}
+
+// Connect is the CONNECT packet, Password aliases the read buffer.
+type Connect struct {
+	Level        byte
+	CleanSession bool
+	KeepAlive    uint16
+	ClientID     string
+	WillTopic    string
+	WillMessage  []byte
+	WillQoS      byte
+	WillRetain   bool
+	Username     string
+	Password     []byte
+}
+
+// Connack is the CONNACK packet.
+type Connack struct {
+	SessionPresent bool
+	Code           byte
+}
+
+// Publish is the PUBLISH packet, Payload aliases the read buffer.
+type Publish struct {
+	Dup     bool
+	QoS     byte
+	Retain  bool
+	Topic   string
+	ID      uint16
+	Payload []byte
+}
+
+// Puback is the PUBACK packet.
+type Puback struct {
+	ID uint16
+}
+
+// Subscribe is the SUBSCRIBE packet.
+type Subscribe struct {
+	ID     uint16
+	Topics []string
+	QoS    []byte
+}
+
+// Suback is the SUBACK packet.
+type Suback struct {
+	ID    uint16
+	Codes []byte
+}
+
+// Unsubscribe is the UNSUBSCRIBE packet.
+type Unsubscribe struct {
+	ID     uint16
+	Topics []string
+}
+
+// Unsuback is the UNSUBACK packet.
+type Unsuback struct {
+	ID uint16
+}
+
+// Pingreq is the PINGREQ packet.
+type Pingreq struct{}
+
+// Pingresp is the PINGRESP packet.
+type Pingresp struct{}
+
+// Disconnect is the DISCONNECT packet.
+type Disconnect struct{}
+
+// Type returns the packet type.
+func (*Connect) Type() byte { return CONNECT }
+
+// Type returns the packet type.
+func (*Connack) Type() byte { return CONNACK }
+
+// Type returns the packet type.
+func (*Publish) Type() byte { return PUBLISH }
+
+// Type returns the packet type.
+func (*Puback) Type() byte { return PUBACK }
+
+// Type returns the packet type.
+func (*Subscribe) Type() byte { return SUBSCRIBE }
+
+// Type returns the packet type.
+func (*Suback) Type() byte { return SUBACK }
+
+// Type returns the packet type.
+func (*Unsubscribe) Type() byte { return UNSUBSCRIBE }
+
+// Type returns the packet type.
+func (*Unsuback) Type() byte { return UNSUBACK }
+
+// Type returns the packet type.
+func (*Pingreq) Type() byte { return PINGREQ }
+
+// Type returns the packet type.
+func (*Pingresp) Type() byte { return PINGRESP }
+
+// Type returns the packet type.
+func (*Disconnect) Type() byte { return DISCONNECT }
+
+// ReadPacket reads a control packet, the packet must fit in the buffer of
+// the reader, the bytes of it stop being valid at the next read call.
+func ReadPacket(rr *bufio.Reader) (pk Packet, err error) {
+	var (
+		b      byte
+		n      int
+		length int
+		buf    []byte
+	)
+	if b, err = rr.ReadByte(); err != nil {
+		return
+	}
+	for shift := uint(0); ; shift += 7 {
+		var c byte
+		if c, err = rr.ReadByte(); err != nil {
+			return
+		}
+		length |= int(c&0x7f) << shift
+		if n++; c&0x80 == 0 {
+			break
+		}
+		if n == maxLengthBytes {
+			return nil, ErrMalformed
+		}
+	}
+	if buf, err = rr.Pop(length); err != nil {
+		return
+	}
+	return decode(b>>4, b&0x0f, buf)
+}
+
+// decode decodes the variable header and payload of the packet.
+func decode(typ, flags byte, buf []byte) (pk Packet, err error) {
+	d := decoder{buf: buf}
+	switch typ {
+	case CONNECT:
+		pk, err = d.connect()
+	case CONNACK:
+		if len(buf) != 2 {
+			return nil, ErrMalformed
+		}
+		pk = &Connack{SessionPresent: buf[0]&1 == 1, Code: buf[1]}
+	case PUBLISH:
+		p := &Publish{
+			Dup:    flags&publishDup != 0,
+			QoS:    (flags & publishQoSMask) >> publishQoSShift,
+			Retain: flags&publishRetain != 0,
+		}
+		if p.QoS > maxQoS {
+			return nil, ErrQoS
+		}
+		p.Topic = d.string()
+		if p.QoS > 0 {
+			p.ID = d.uint16()
+		}
+		p.Payload = d.rest()
+		pk = p
+	case PUBACK:
+		pk = &Puback{ID: d.uint16()}
+	case SUBSCRIBE:
+		if flags != subscribeFlags {
+			return nil, ErrMalformed
+		}
+		p := &Subscribe{ID: d.uint16()}
+		for d.err == nil && len(d.buf) > 0 {
+			p.Topics = append(p.Topics, d.string())
+			p.QoS = append(p.QoS, d.byte())
+		}
+		if len(p.Topics) == 0 {
+			return nil, ErrMalformed
+		}
+		pk = p
+	case SUBACK:
+		pk = &Suback{ID: d.uint16(), Codes: append([]byte(nil), d.rest()...)}
+	case UNSUBSCRIBE:
+		if flags != subscribeFlags {
+			return nil, ErrMalformed
+		}
+		p := &Unsubscribe{ID: d.uint16()}
+		for d.err == nil && len(d.buf) > 0 {
+			p.Topics = append(p.Topics, d.string())
+		}
+		if len(p.Topics) == 0 {
+			return nil, ErrMalformed
+		}
+		pk = p
+	case UNSUBACK:
+		pk = &Unsuback{ID: d.uint16()}
+	case PINGREQ:
+		pk = &Pingreq{}
+	case PINGRESP:
+		pk = &Pingresp{}
+	case DISCONNECT:
+		pk = &Disconnect{}
+	default:
+		return nil, ErrPacketType
+	}
+	if err == nil {
+		err = d.err
+	}
+	if err != nil && err != ErrProtocolLevel {
+		pk = nil
+	}
+	return
+}
+
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) byte() (b byte) {
+	if len(d.buf) < 1 {
+		d.err = ErrMalformed
+		return
+	}
+	b, d.buf = d.buf[0], d.buf[1:]
+	return
+}
+
+func (d *decoder) uint16() (v uint16) {
+	if len(d.buf) < 2 {
+		d.err = ErrMalformed
+		return
+	}
+	v, d.buf = binary.BigEndian.Uint16(d.buf), d.buf[2:]
+	return
+}
+
+func (d *decoder) bytes() (b []byte) {
+	n := int(d.uint16())
+	if d.err != nil {
+		return
+	}
+	if len(d.buf) < n {
+		d.err = ErrMalformed
+		return
+	}
+	b, d.buf = d.buf[:n], d.buf[n:]
+	return
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) rest() (b []byte) {
+	b, d.buf = d.buf, nil
+	return
+}
+
+func (d *decoder) connect() (p *Connect, err error) {
+	if name := d.string(); d.err == nil && name != protocolName {
+		return nil, ErrMalformed
+	}
+	p = &Connect{Level: d.byte()}
+	flags := d.byte()
+	p.KeepAlive = d.uint16()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if p.Level != protocolLevel {
+		// the server replies the refused code
+		return p, ErrProtocolLevel
+	}
+	if flags&connectReserved != 0 {
+		return nil, ErrMalformed
+	}
+	p.CleanSession = flags&connectCleanSession != 0
+	p.ClientID = d.string()
+	if flags&connectWill != 0 {
+		p.WillQoS = (flags >> connectWillQoSShift) & 0x03
+		p.WillRetain = flags&connectWillRetain != 0
+		p.WillTopic = d.string()
+		p.WillMessage = d.bytes()
+	}
+	if flags&connectUsername != 0 {
+		p.Username = d.string()
+	}
+	if flags&connectPassword != 0 {
+		p.Password = d.bytes()
+	}
+	return
+}
+
+// WritePacket writes a control packet.
+func WritePacket(wr *bufio.Writer, pk Packet) (err error) {
+	_, err = wr.Write(AppendPacket(nil, pk))
+	return
+}
+
+// AppendPacket appends the encoded control packet to b.
+func AppendPacket(b []byte, pk Packet) []byte {
+	var (
+		flags byte
+		body  []byte
+	)
+	switch p := pk.(type) {
+	case *Connect:
+		var cf byte
+		body = appendString(body, protocolName)
+		body = append(body, protocolLevel, 0)
+		body = binary.BigEndian.AppendUint16(body, p.KeepAlive)
+		body = appendString(body, p.ClientID)
+		if p.CleanSession {
+			cf |= connectCleanSession
+		}
+		if p.WillTopic != "" {
+			cf |= connectWill | (p.WillQoS&0x03)<<connectWillQoSShift
+			if p.WillRetain {
+				cf |= connectWillRetain
+			}
+			body = appendString(body, p.WillTopic)
+			body = appendBytes(body, p.WillMessage)
+		}
+		if p.Username != "" {
+			cf |= connectUsername
+			body = appendString(body, p.Username)
+		}
+		if p.Password != nil {
+			cf |= connectPassword
+			body = appendBytes(body, p.Password)
+		}
+		body[connectFlagsOffset] = cf
+	case *Connack:
+		var sp byte
+		if p.SessionPresent {
+			sp = 1
+		}
+		body = append(body, sp, p.Code)
+	case *Publish:
+		flags = (p.QoS << publishQoSShift) & publishQoSMask
+		if p.Dup {
+			flags |= publishDup
+		}
+		if p.Retain {
+			flags |= publishRetain
+		}
+		b = appendFixedHeader(b, PUBLISH, flags, 2+len(p.Topic)+publishIDSize(p.QoS)+len(p.Payload))
+		b = appendString(b, p.Topic)
+		if p.QoS > 0 {
+			b = binary.BigEndian.AppendUint16(b, p.ID)
+		}
+		return append(b, p.Payload...)
+	case *Puback:
+		body = binary.BigEndian.AppendUint16(body, p.ID)
+	case *Subscribe:
+		flags = subscribeFlags
+		body = binary.BigEndian.AppendUint16(body, p.ID)
+		for i, topic := range p.Topics {
+			body = appendString(body, topic)
+			body = append(body, p.QoS[i])
+		}
+	case *Suback:
+		body = binary.BigEndian.AppendUint16(body, p.ID)
+		body = append(body, p.Codes...)
+	case *Unsubscribe:
+		flags = subscribeFlags
+		body = binary.BigEndian.AppendUint16(body, p.ID)
+		for _, topic := range p.Topics {
+			body = appendString(body, topic)
+		}
+	case *Unsuback:
+		body = binary.BigEndian.AppendUint16(body, p.ID)
+	}
+	b = appendFixedHeader(b, pk.Type(), flags, len(body))
+	return append(b, body...)
+}
+
+func publishIDSize(qos byte) int {
+	if qos > 0 {
+		return 2
+	}
+	return 0
+}
+
+func appendFixedHeader(b []byte, typ, flags byte, length int) []byte {
+	b = append(b, typ<<4|flags)
+	for {
+		c := byte(length & 0x7f)
+		if length >>= 7; length > 0 {
+			c |= 0x80
+		}
+		if b = append(b, c); length == 0 {
+			return b
+		}
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func appendBytes(b []byte, p []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(p)))
+	return append(b, p...)
+}
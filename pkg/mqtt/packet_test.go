@@ -0,0 +1,103 @@
+package mqtt
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/Terry-Mao/goim/pkg/bufio"
+)
+
+func TestPacket(t *testing.T) {
+	packets := []Packet{
+		&Connect{Level: protocolLevel, CleanSession: true, KeepAlive: 60, ClientID: "device-1", Username: "user", Password: []byte(`{"mid":1}`)},
+		&Connect{Level: protocolLevel, KeepAlive: 30, WillTopic: "goim/will", WillMessage: []byte("bye"), WillQoS: 1, WillRetain: true},
+		&Connack{SessionPresent: true, Code: Accepted},
+		&Publish{Topic: "goim/1000", Payload: []byte("hello")},
+		&Publish{Dup: true, QoS: 1, Retain: true, Topic: "goim/1000", ID: 7, Payload: bytes.Repeat([]byte("goim"), 64)},
+		&Puback{ID: 7},
+		&Subscribe{ID: 8, Topics: []string{"live://1", "goim/1000"}, QoS: []byte{0, 1}},
+		&Suback{ID: 8, Codes: []byte{0, 1, SubackFailure}},
+		&Unsubscribe{ID: 9, Topics: []string{"live://1"}},
+		&Unsuback{ID: 9},
+		&Pingreq{},
+		&Pingresp{},
+		&Disconnect{},
+	}
+	var buf bytes.Buffer
+	wr := bufio.NewWriter(&buf)
+	for _, pk := range packets {
+		if err := WritePacket(wr, pk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := wr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	rr := bufio.NewReader(&buf)
+	for _, pk := range packets {
+		got, err := ReadPacket(rr)
+		if err != nil {
+			t.Fatalf("ReadPacket(%#v) error(%v)", pk, err)
+		}
+		if !reflect.DeepEqual(got, pk) {
+			t.Fatalf("ReadPacket() got %#v want %#v", got, pk)
+		}
+	}
+}
+
+func TestRemainingLength(t *testing.T) {
+	for _, n := range []int{0, 127, 128, 16383, 16384} {
+		pk := &Publish{Topic: "t", Payload: make([]byte, n)}
+		b := AppendPacket(nil, pk)
+		rr := bufio.NewReaderSize(bytes.NewReader(b), len(b))
+		got, err := ReadPacket(rr)
+		if err != nil {
+			t.Fatalf("ReadPacket(%d) error(%v)", n, err)
+		}
+		if len(got.(*Publish).Payload) != n {
+			t.Fatalf("ReadPacket() payload %d want %d", len(got.(*Publish).Payload), n)
+		}
+	}
+	// five length bytes
+	rr := bufio.NewReader(bytes.NewReader([]byte{PUBLISH << 4, 0xff, 0xff, 0xff, 0xff, 0x01}))
+	if _, err := ReadPacket(rr); err != ErrMalformed {
+		t.Fatalf("ReadPacket() error(%v) want %v", err, ErrMalformed)
+	}
+}
+
+func TestPacketError(t *testing.T) {
+	cases := []struct {
+		b   []byte
+		err error
+	}{
+		// qos 2
+		{[]byte{PUBLISH<<4 | 2<<publishQoSShift, 5, 0, 1, 'a', 0, 1}, ErrQoS},
+		// subscribe without topics
+		{[]byte{SUBSCRIBE<<4 | subscribeFlags, 2, 0, 1}, ErrMalformed},
+		// subscribe with bad flags
+		{[]byte{SUBSCRIBE << 4, 6, 0, 1, 0, 1, 'a', 0}, ErrMalformed},
+		// topic longer than the packet
+		{[]byte{PUBLISH << 4, 3, 0, 5, 'a'}, ErrMalformed},
+		// reserved type
+		{[]byte{15 << 4, 0}, ErrPacketType},
+	}
+	for _, c := range cases {
+		if _, err := ReadPacket(bufio.NewReader(bytes.NewReader(c.b))); err != c.err {
+			t.Fatalf("ReadPacket(%v) error(%v) want %v", c.b, err, c.err)
+		}
+	}
+}
+
+func TestConnectLevel(t *testing.T) {
+	// mqtt 3.1
+	b := AppendPacket(nil, &Connect{ClientID: "c"})
+	b[2+2+len(protocolName)] = 3
+	pk, err := ReadPacket(bufio.NewReader(bytes.NewReader(b)))
+	if err != ErrProtocolLevel {
+		t.Fatalf("ReadPacket() error(%v) want %v", err, ErrProtocolLevel)
+	}
+	if p, ok := pk.(*Connect); !ok || p.Level != 3 {
+		t.Fatalf("ReadPacket() got %#v want the level", pk)
+	}
+}
@@ -0,0 +1,177 @@
+// Package proxyproto implements the HAProxy PROXY protocol v1 and v2, it
+// recovers the real client address of a connection accepted behind a L4
+// load balancer.
+package proxyproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	v1Prefix    = "PROXY "
+	v1MaxLength = 107
+	v2HeaderLen = 16
+	// v2 command
+	v2CmdLocal = 0x0
+	v2CmdProxy = 0x1
+	// v2 address family
+	v2FamilyInet  = 0x1
+	v2FamilyInet6 = 0x2
+)
+
+var (
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	// ErrNoProxyHeader connection does not start with a PROXY header.
+	ErrNoProxyHeader = errors.New("proxyproto: no proxy protocol header")
+	// ErrInvalidHeader PROXY header is malformed.
+	ErrInvalidHeader = errors.New("proxyproto: invalid proxy protocol header")
+)
+
+// ReadHeader reads a PROXY v1 or v2 header from r and returns the source
+// address it carries. It never reads past the end of the header, so r can
+// be used unbuffered. A nil addr with a nil error means the header is
+// valid but carries no address (v1 UNKNOWN or v2 LOCAL).
+func ReadHeader(r io.Reader) (addr net.Addr, err error) {
+	var buf [v1MaxLength]byte
+	// the shortest v1 header "PROXY UNKNOWN\r\n" is longer than the v2 signature
+	if _, err = io.ReadFull(r, buf[:len(v2Signature)]); err != nil {
+		return
+	}
+	if bytes.Equal(buf[:len(v2Signature)], v2Signature) {
+		return readV2(r, buf[:])
+	}
+	if string(buf[:len(v1Prefix)]) != v1Prefix {
+		return nil, ErrNoProxyHeader
+	}
+	return readV1(r, buf[:])
+}
+
+func readV1(r io.Reader, buf []byte) (addr net.Addr, err error) {
+	n := len(v2Signature)
+	for {
+		if n >= 2 && buf[n-2] == '\r' && buf[n-1] == '\n' {
+			break
+		}
+		if n == len(buf) {
+			return nil, ErrInvalidHeader
+		}
+		if _, err = io.ReadFull(r, buf[n:n+1]); err != nil {
+			return
+		}
+		n++
+	}
+	fields := strings.Fields(string(buf[len(v1Prefix) : n-2]))
+	if len(fields) == 0 {
+		return nil, ErrInvalidHeader
+	}
+	switch fields[0] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+		if len(fields) != 5 {
+			return nil, ErrInvalidHeader
+		}
+	default:
+		return nil, ErrInvalidHeader
+	}
+	ip := net.ParseIP(fields[1])
+	port, perr := strconv.Atoi(fields[3])
+	if ip == nil || perr != nil || port < 0 || port > 65535 {
+		return nil, ErrInvalidHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+func readV2(r io.Reader, buf []byte) (addr net.Addr, err error) {
+	if _, err = io.ReadFull(r, buf[len(v2Signature):v2HeaderLen]); err != nil {
+		return
+	}
+	var (
+		verCmd = buf[12]
+		family = buf[13] >> 4
+		length = int(binary.BigEndian.Uint16(buf[14:16]))
+		body   = make([]byte, length)
+	)
+	if verCmd>>4 != 0x2 {
+		return nil, ErrInvalidHeader
+	}
+	if _, err = io.ReadFull(r, body); err != nil {
+		return
+	}
+	switch verCmd & 0xf {
+	case v2CmdLocal:
+		return nil, nil
+	case v2CmdProxy:
+	default:
+		return nil, ErrInvalidHeader
+	}
+	switch family {
+	case v2FamilyInet:
+		if length < 12 {
+			return nil, ErrInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}, nil
+	case v2FamilyInet6:
+		if length < 36 {
+			return nil, ErrInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}, nil
+	}
+	// unix sockets and unspec carry no usable client address
+	return nil, nil
+}
+
+// Conn is a net.Conn which reads the PROXY header lazily, on the first
+// Read or RemoteAddr, so the accept loop is never blocked by a client.
+type Conn struct {
+	net.Conn
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+// NewConn new a PROXY protocol conn.
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{Conn: conn}
+}
+
+func (c *Conn) readHeader() {
+	c.once.Do(func() {
+		var addr net.Addr
+		if addr, c.err = ReadHeader(c.Conn); c.err == nil && addr != nil {
+			c.remote = addr
+		}
+	})
+}
+
+// Handshake reads the PROXY header if it has not been read yet, callers can
+// bound it with a read deadline instead of relying on the lazy read.
+func (c *Conn) Handshake() error {
+	c.readHeader()
+	return c.err
+}
+
+// Read reads data from the connection after the PROXY header.
+func (c *Conn) Read(b []byte) (int, error) {
+	if c.readHeader(); c.err != nil {
+		return 0, c.err
+	}
+	return c.Conn.Read(b)
+}
+
+// RemoteAddr returns the client address carried by the PROXY header, or the
+// peer address if the header carries none.
+func (c *Conn) RemoteAddr() net.Addr {
+	if c.readHeader(); c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
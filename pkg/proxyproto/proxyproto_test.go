@@ -0,0 +1,67 @@
+package proxyproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestReadHeaderV1(t *testing.T) {
+	r := bytes.NewBufferString("PROXY TCP4 192.168.1.10 10.0.0.1 56324 3101\r\nbody")
+	addr, err := ReadHeader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.String() != "192.168.1.10:56324" {
+		t.Fatalf("addr: %s", addr)
+	}
+	if r.String() != "body" {
+		t.Fatalf("read past the header: %q", r.String())
+	}
+	if addr, err = ReadHeader(bytes.NewBufferString("PROXY UNKNOWN\r\n")); err != nil || addr != nil {
+		t.Fatalf("unknown: %v %v", addr, err)
+	}
+	if _, err = ReadHeader(bytes.NewBufferString("GET / HTTP/1.1\r\n")); err != ErrNoProxyHeader {
+		t.Fatalf("error(%v) want %v", err, ErrNoProxyHeader)
+	}
+}
+
+func TestReadHeaderV2(t *testing.T) {
+	var b bytes.Buffer
+	b.Write(v2Signature)
+	b.Write([]byte{0x21, 0x11, 0, 12})
+	b.Write(net.ParseIP("203.0.113.7").To4())
+	b.Write(net.ParseIP("10.0.0.1").To4())
+	binary.Write(&b, binary.BigEndian, uint16(40000))
+	binary.Write(&b, binary.BigEndian, uint16(3101))
+	b.WriteString("body")
+	addr, err := ReadHeader(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.String() != "203.0.113.7:40000" {
+		t.Fatalf("addr: %s", addr)
+	}
+	if b.String() != "body" {
+		t.Fatalf("read past the header: %q", b.String())
+	}
+}
+
+func TestConn(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	go func() {
+		c2.Write([]byte("PROXY TCP6 2001:db8::1 2001:db8::2 1000 3101\r\nhello"))
+		c2.Close()
+	}()
+	c := NewConn(c1)
+	if host, _, _ := net.SplitHostPort(c.RemoteAddr().String()); host != "2001:db8::1" {
+		t.Fatalf("remote: %s", c.RemoteAddr())
+	}
+	b, err := io.ReadAll(c)
+	if err != nil || string(b) != "hello" {
+		t.Fatalf("read: %q %v", b, err)
+	}
+}
@@ -0,0 +1,89 @@
+// Package ratelimit implements a token bucket rate limiter.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket, it's refilled with rate tokens per second up to
+// burst tokens.
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// New new a token bucket which is full, a rate <= 0 means unlimited.
+func New(rate float64, burst int) *Bucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Bucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// Allow reports whether one token can be taken now.
+func (b *Bucket) Allow() bool {
+	return b.AllowN(time.Now(), 1)
+}
+
+// AllowN reports whether n tokens can be taken at now.
+func (b *Bucket) AllowN(now time.Time, n int) bool {
+	if b == nil || b.rate <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	b.refill(now)
+	if b.tokens < float64(n) {
+		b.mu.Unlock()
+		return false
+	}
+	b.tokens -= float64(n)
+	b.mu.Unlock()
+	return true
+}
+
+// Wait returns the duration to wait at now until n tokens are available,
+// the tokens are reserved so the caller must wait before going on.
+func (b *Bucket) Wait(now time.Time, n int) time.Duration {
+	if b == nil || b.rate <= 0 {
+		return 0
+	}
+	b.mu.Lock()
+	b.refill(now)
+	b.tokens -= float64(n)
+	var d time.Duration
+	if b.tokens < 0 {
+		d = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+	return d
+}
+
+// Tokens returns the available tokens at now.
+func (b *Bucket) Tokens(now time.Time) float64 {
+	b.mu.Lock()
+	b.refill(now)
+	tokens := b.tokens
+	b.mu.Unlock()
+	return tokens
+}
+
+func (b *Bucket) refill(now time.Time) {
+	if b.last.IsZero() {
+		b.last = now
+		return
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		if b.tokens += elapsed.Seconds() * b.rate; b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
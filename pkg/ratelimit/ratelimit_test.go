@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	var (
+		now = time.Unix(1000, 0)
+		b   = New(10, 5)
+	)
+	for i := 0; i < 5; i++ {
+		if !b.AllowN(now, 1) {
+			t.Fatalf("burst %d must be allowed", i)
+		}
+	}
+	if b.AllowN(now, 1) {
+		t.Fatal("empty bucket must not be allowed")
+	}
+	// 10 tokens per second
+	now = now.Add(time.Millisecond * 100)
+	if !b.AllowN(now, 1) || b.AllowN(now, 1) {
+		t.Fatal("one token must be refilled after 100ms")
+	}
+	// never over burst
+	now = now.Add(time.Hour)
+	if tokens := b.Tokens(now); tokens != 5 {
+		t.Fatalf("tokens %v want 5", tokens)
+	}
+	if New(0, 0).AllowN(now, 100) != true {
+		t.Fatal("zero rate must be unlimited")
+	}
+}
+
+func TestBucketWait(t *testing.T) {
+	var (
+		now = time.Unix(1000, 0)
+		b   = New(100, 1)
+	)
+	if d := b.Wait(now, 1); d != 0 {
+		t.Fatalf("wait %v want 0", d)
+	}
+	if d := b.Wait(now, 1); d != time.Millisecond*10 {
+		t.Fatalf("wait %v want 10ms", d)
+	}
+	if d := b.Wait(now, 2); d != time.Millisecond*30 {
+		t.Fatalf("wait %v want 30ms", d)
+	}
+}
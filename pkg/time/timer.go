@@ -19,6 +19,16 @@ type TimerData struct {
 	fn     func()
 	index  int
 	next   *TimerData
+	// used by the wheel
+	prev *TimerData
+	when int64
+}
+
+// Scheduler is the timer api shared by the heap Timer and the timing Wheel.
+type Scheduler interface {
+	Add(expire itime.Duration, fn func()) *TimerData
+	Del(td *TimerData)
+	Set(td *TimerData, expire itime.Duration)
 }
 
 // Delay delay duration.
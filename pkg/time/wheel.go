@@ -0,0 +1,258 @@
+package time
+
+import (
+	"sync"
+	itime "time"
+
+	log "github.com/golang/glog"
+)
+
+// the wheel levels, the first one has 256 slots of one tick and the others
+// have 64 slots of the whole lower level, up to 2^26 ticks.
+const (
+	wheelBits0   = 8
+	wheelBitsN   = 6
+	wheelLevels  = 4
+	wheelSize0   = 1 << wheelBits0
+	wheelSizeN   = 1 << wheelBitsN
+	wheelMask0   = wheelSize0 - 1
+	wheelMaskN   = wheelSizeN - 1
+	wheelMaxTick = 1 << (wheelBits0 + (wheelLevels-1)*wheelBitsN)
+)
+
+// Wheel is a hashed hierarchical timing wheel, Add, Del and Set are O(1)
+// instead of O(log(n)) of the heap Timer, the expire precision is one tick.
+type Wheel struct {
+	lock   sync.Mutex
+	free   *TimerData
+	num    int
+	tick   itime.Duration
+	epoch  itime.Time
+	now    int64 // the last expired tick
+	slots  [wheelLevels][]TimerData
+	ticker *itime.Ticker
+	done   chan struct{}
+}
+
+// NewWheel new a timing wheel.
+func NewWheel(num int, tick itime.Duration) (w *Wheel) {
+	w = new(Wheel)
+	w.init(num, tick)
+	return
+}
+
+func (w *Wheel) init(num int, tick itime.Duration) {
+	if tick <= 0 {
+		tick = itime.Millisecond * 100
+	}
+	w.num = num
+	w.tick = tick
+	w.epoch = itime.Now()
+	for l := 0; l < wheelLevels; l++ {
+		size := wheelSizeN
+		if l == 0 {
+			size = wheelSize0
+		}
+		w.slots[l] = make([]TimerData, size)
+		for i := range w.slots[l] {
+			// the slot is the sentinel of a circular list
+			head := &w.slots[l][i]
+			head.next, head.prev = head, head
+		}
+	}
+	w.grow()
+	w.ticker = itime.NewTicker(tick)
+	w.done = make(chan struct{})
+	go w.start()
+}
+
+// Stop stops the wheel, the timers are not expired any more.
+func (w *Wheel) Stop() {
+	w.ticker.Stop()
+	close(w.done)
+}
+
+func (w *Wheel) grow() {
+	var (
+		i   int
+		td  *TimerData
+		tds = make([]TimerData, w.num)
+	)
+	w.free = &(tds[0])
+	td = w.free
+	for i = 1; i < w.num; i++ {
+		td.next = &(tds[i])
+		td = td.next
+	}
+	td.next = nil
+}
+
+// get get a free timer data.
+func (w *Wheel) get() (td *TimerData) {
+	if td = w.free; td == nil {
+		w.grow()
+		td = w.free
+	}
+	w.free = td.next
+	td.next = nil
+	return
+}
+
+// put put back a timer data.
+func (w *Wheel) put(td *TimerData) {
+	td.fn = nil
+	td.prev = nil
+	td.next = w.free
+	w.free = td
+}
+
+// ticks returns the ticks since the wheel started, rounded up, so a timer
+// data never expires early.
+func (w *Wheel) ticks(t itime.Time) int64 {
+	d := t.Sub(w.epoch)
+	return int64((d + w.tick - 1) / w.tick)
+}
+
+// Add add a timer data expired after expire.
+func (w *Wheel) Add(expire itime.Duration, fn func()) (td *TimerData) {
+	w.lock.Lock()
+	td = w.get()
+	td.fn = fn
+	w.add(td, expire)
+	w.lock.Unlock()
+	return
+}
+
+// Del removes the timer data.
+func (w *Wheel) Del(td *TimerData) {
+	w.lock.Lock()
+	w.del(td)
+	w.put(td)
+	w.lock.Unlock()
+}
+
+// Set update timer data.
+func (w *Wheel) Set(td *TimerData, expire itime.Duration) {
+	w.lock.Lock()
+	w.del(td)
+	w.add(td, expire)
+	w.lock.Unlock()
+}
+
+func (w *Wheel) add(td *TimerData, expire itime.Duration) {
+	td.expire = itime.Now().Add(expire)
+	if td.when = w.ticks(td.expire); td.when <= w.now {
+		td.when = w.now + 1
+	}
+	w.place(td)
+	if Debug {
+		log.Infof("wheel: add item key: %s, expire: %s, tick: %d", td.Key, td.ExpireString(), td.when)
+	}
+}
+
+// place links the timer data to the slot of its tick.
+func (w *Wheel) place(td *TimerData) {
+	var (
+		head  *TimerData
+		when  = td.when
+		delta = when - w.now
+	)
+	if delta < 0 {
+		delta, when = 0, w.now
+	} else if delta >= wheelMaxTick {
+		// too far, placed at the end and placed again when reached
+		delta, when = wheelMaxTick-1, w.now+wheelMaxTick-1
+	}
+	if delta < wheelSize0 {
+		head = &w.slots[0][when&wheelMask0]
+	} else {
+		for l := 1; l < wheelLevels; l++ {
+			shift := uint(wheelBits0 + (l-1)*wheelBitsN)
+			if delta < 1<<(shift+wheelBitsN) {
+				head = &w.slots[l][(when>>shift)&wheelMaskN]
+				break
+			}
+		}
+	}
+	td.prev = head.prev
+	td.next = head
+	head.prev.next = td
+	head.prev = td
+}
+
+func (w *Wheel) del(td *TimerData) {
+	if td.prev == nil {
+		// already remove, usually by expire
+		return
+	}
+	td.prev.next = td.next
+	td.next.prev = td.prev
+	td.prev, td.next = nil, nil
+	if Debug {
+		log.Infof("wheel: remove item key: %s, expire: %s, tick: %d", td.Key, td.ExpireString(), td.when)
+	}
+}
+
+// start start the wheel.
+func (w *Wheel) start() {
+	for {
+		select {
+		case now := <-w.ticker.C:
+			w.expire(int64(now.Sub(w.epoch) / w.tick))
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// cascade places the timer data of a higher level slot again.
+func (w *Wheel) cascade(l int, i int64) {
+	head := &w.slots[l][i]
+	for td := head.next; td != head; td = head.next {
+		w.del(td)
+		w.place(td)
+	}
+}
+
+// expire runs the expired timer data up to the tick.
+func (w *Wheel) expire(tick int64) {
+	var (
+		fn   func()
+		td   *TimerData
+		head *TimerData
+	)
+	w.lock.Lock()
+	for w.now < tick {
+		w.now++
+		if w.now&wheelMask0 == 0 {
+			for l := 1; l < wheelLevels; l++ {
+				i := (w.now >> uint(wheelBits0+(l-1)*wheelBitsN)) & wheelMaskN
+				w.cascade(l, i)
+				if i != 0 {
+					break
+				}
+			}
+		}
+		head = &w.slots[0][w.now&wheelMask0]
+		for td = head.next; td != head; td = head.next {
+			w.del(td)
+			if td.when > w.now {
+				w.place(td)
+				continue
+			}
+			fn = td.fn
+			// let caller put back
+			w.lock.Unlock()
+			if fn == nil {
+				log.Warning("expire timer no fn")
+			} else {
+				if Debug {
+					log.Infof("wheel key: %s, expire: %s, tick: %d expired, call fn", td.Key, td.ExpireString(), td.when)
+				}
+				fn()
+			}
+			w.lock.Lock()
+		}
+	}
+	w.lock.Unlock()
+}
@@ -0,0 +1,138 @@
+package time
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func wheelLen(w *Wheel) (n int) {
+	w.lock.Lock()
+	for l := range w.slots {
+		for i := range w.slots[l] {
+			head := &w.slots[l][i]
+			for td := head.next; td != head; td = td.next {
+				n++
+			}
+		}
+	}
+	w.lock.Unlock()
+	return
+}
+
+func TestWheel(t *testing.T) {
+	wheel := NewWheel(100, 10*time.Millisecond)
+	tds := make([]*TimerData, 100)
+	for i := 0; i < 100; i++ {
+		tds[i] = wheel.Add(time.Duration(i)*time.Second+5*time.Minute, nil)
+	}
+	if n := wheelLen(wheel); n != 100 {
+		t.Fatalf("wheel len %d want 100", n)
+	}
+	for i := 0; i < 100; i++ {
+		wheel.Del(tds[i])
+	}
+	if n := wheelLen(wheel); n != 0 {
+		t.Fatalf("wheel len %d want 0", n)
+	}
+	var fired int32
+	start := time.Now()
+	wheel.Add(50*time.Millisecond, func() {
+		if time.Since(start) < 50*time.Millisecond {
+			t.Error("timer expired early")
+		}
+		atomic.AddInt32(&fired, 1)
+	})
+	td := wheel.Add(50*time.Millisecond, func() { atomic.AddInt32(&fired, 10) })
+	// heartbeat pushes it back
+	wheel.Set(td, time.Hour)
+	time.Sleep(200 * time.Millisecond)
+	if n := atomic.LoadInt32(&fired); n != 1 {
+		t.Fatalf("fired %d want 1", n)
+	}
+	if n := wheelLen(wheel); n != 1 {
+		t.Fatalf("wheel len %d want 1", n)
+	}
+}
+
+func TestWheelCascade(t *testing.T) {
+	wheel := NewWheel(10, time.Hour)
+	wheel.ticker.Stop()
+	var (
+		fired []int64
+		ticks = []int64{1, 255, 256, 300, 1 << 14, 1<<14 + 7, 1 << 20, wheelMaxTick + 3}
+	)
+	for _, d := range ticks {
+		d := d
+		// placed at the tick exactly without the clock
+		td := wheel.get()
+		td.when, td.fn = d, func() { fired = append(fired, d) }
+		wheel.place(td)
+	}
+	for _, d := range ticks {
+		wheel.expire(d - 1)
+		if len(fired) != 0 {
+			t.Fatalf("tick %d fired %v early", d-1, fired)
+		}
+		wheel.expire(d)
+		if len(fired) != 1 || fired[0] != d {
+			t.Fatalf("tick %d fired %v", d, fired)
+		}
+		fired = fired[:0]
+	}
+}
+
+const benchTimers = 100000
+
+func benchmarkHeartbeat(b *testing.B, s Scheduler) {
+	tds := make([]*TimerData, benchTimers)
+	for i := range tds {
+		tds[i] = s.Add(time.Duration(i%600)*time.Second+5*time.Minute, nil)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			s.Set(tds[i%benchTimers], 5*time.Minute)
+			i += 7919
+		}
+	})
+}
+
+func BenchmarkTimerHeartbeat(b *testing.B) {
+	benchmarkHeartbeat(b, NewTimer(benchTimers))
+}
+
+func BenchmarkWheelHeartbeat(b *testing.B) {
+	benchmarkHeartbeat(b, NewWheel(benchTimers, time.Second))
+}
+
+func benchmarkAddDel(b *testing.B, s Scheduler) {
+	tds := make([]*TimerData, benchTimers)
+	for i := range tds {
+		tds[i] = s.Add(time.Duration(i%600)*time.Second+5*time.Minute, nil)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Del(s.Add(5*time.Minute, nil))
+	}
+}
+
+func BenchmarkTimerAddDel(b *testing.B) {
+	benchmarkAddDel(b, NewTimer(benchTimers))
+}
+
+func BenchmarkWheelAddDel(b *testing.B) {
+	benchmarkAddDel(b, NewWheel(benchTimers, time.Second))
+}
+
+func TestWheelStop(t *testing.T) {
+	wheel := NewWheel(10, time.Millisecond)
+	var fired int32
+	wheel.Add(20*time.Millisecond, func() { atomic.AddInt32(&fired, 1) })
+	wheel.Stop()
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&fired); n != 0 {
+		t.Fatalf("fired %d after stop", n)
+	}
+}
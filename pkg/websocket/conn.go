@@ -110,6 +110,44 @@ func (c *Conn) WriteHeader(msgType int, length int) (err error) {
 	return
 }
 
+// MaxHeaderLen is the max length of a server frame header.
+const MaxHeaderLen = 10
+
+// HeaderLen returns the length of a server frame header for the payload.
+func HeaderLen(length int) int {
+	switch {
+	case length <= 125:
+		return 2
+	case length < 65536:
+		return 4
+	}
+	return 10
+}
+
+// PutHeader encodes a server frame header into buf which must hold
+// HeaderLen(length) bytes, it's used to pre-encode a frame once for many
+// connections.
+func PutHeader(buf []byte, msgType int, length int) {
+	buf[0] = finBit | byte(msgType)
+	switch {
+	case length <= 125:
+		buf[1] = byte(length)
+	case length < 65536:
+		buf[1] = 126
+		binary.BigEndian.PutUint16(buf[2:], uint16(length))
+	default:
+		buf[1] = 127
+		binary.BigEndian.PutUint64(buf[2:], uint64(length))
+	}
+}
+
+// WriteNoCopy queues the bytes without copy, b must not be modified until
+// the connection is flushed.
+func (c *Conn) WriteNoCopy(b []byte) (err error) {
+	_, err = c.w.WriteNoCopy(b)
+	return
+}
+
 // WriteBody write a message body.
 func (c *Conn) WriteBody(b []byte) (err error) {
 	if len(b) > 0 {